@@ -0,0 +1,1164 @@
+// antha-tools/antha/liquid/eval.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package liquid
+
+// This file defines the symbolic evaluator, a minimal interpreter in
+// the style of package ssa/interp whose values may be unknown.
+//
+// Values are boxed in the empty interface, value.  Their dynamic
+// types are:
+//
+// - exact.Value --- booleans, strings and numbers
+// - unknown --- a value not known statically
+// - nil --- nil pointers, slices, maps, functions and interfaces
+// - *value --- pointers
+// - structure --- structs.  Fields are accessed by numeric indices.
+// - array --- arrays
+// - []value --- slices
+// - *hashmap --- maps
+// - iface --- non-nil interfaces
+// - *ssa.Function, *ssa.Builtin, *closure --- functions
+// - tuple --- results of calls, "value,ok" modes and Next
+// - *iter --- iterators of range loops over maps and strings
+//
+// Operations whose operands are unknown have unknown results, so
+// only the operations that need a known value fail: branches, API
+// calls and stores.
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"unicode/utf8"
+
+	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// maxDepth limits the depth of calls, to catch unbounded recursion.
+const maxDepth = 200
+
+type value interface{}
+
+type unknown struct{}
+
+type structure []value
+
+type array []value
+
+type tuple []value
+
+type iface struct {
+	t types.Type // dynamic type
+	v value
+}
+
+type closure struct {
+	fn  *ssa.Function
+	env []value
+}
+
+// A hashmap is a map whose keys are booleans, strings or numbers.
+type hashmap struct {
+	keys    []value          // keys, in order of insertion
+	entries map[string]value // values, by key string
+}
+
+type iter struct {
+	m    *hashmap
+	keys []value // keys of m when the loop began
+	s    string
+	i    int
+}
+
+type frame struct {
+	fn     *ssa.Function
+	env    map[ssa.Value]value
+	defers []func()
+}
+
+type evaluator struct {
+	config   *Config
+	prog     *ssa.Program
+	pkg      *ssa.Package // package of the Steps function
+	instrs   []*Instruction
+	globals  map[*ssa.Global]*value
+	steps    int
+	maxSteps int
+	depth    int
+}
+
+// errorf aborts evaluation with an error at the position of instr.
+func (e *evaluator) errorf(instr ssa.Instruction, format string, args ...interface{}) {
+	err := &Error{Msg: fmt.Sprintf(format, args...)}
+	pos := token.NoPos
+	if instr != nil {
+		if pos = instr.Pos(); pos == token.NoPos {
+			pos = instr.Parent().Pos()
+		}
+	}
+	if pos.IsValid() {
+		err.Pos = e.prog.Fset.Position(pos).String()
+	}
+	panic(err)
+}
+
+// inputs returns the arguments of steps given by the configuration.
+func (e *evaluator) inputs(steps *ssa.Function) ([]value, error) {
+	e.pkg = steps.Pkg
+	e.globals = make(map[*ssa.Global]*value)
+	used := make(map[string]bool)
+	var args []value
+	for _, p := range steps.Params {
+		if in, ok := e.config.Inputs[p.Name()]; ok {
+			v, err := input(in, p.Type())
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %s", p.Name(), err)
+			}
+			used[p.Name()] = true
+			args = append(args, v)
+			continue
+		}
+
+		st, ok := deref(p.Type()).Underlying().(*types.Struct)
+		if !ok {
+			args = append(args, unknown{})
+			continue
+		}
+		s := make(structure, st.NumFields())
+		for i := range s {
+			f := st.Field(i)
+			s[i] = unknown{}
+			if in, ok := e.config.Inputs[f.Name()]; ok {
+				v, err := input(in, f.Type())
+				if err != nil {
+					return nil, fmt.Errorf("input %s: %s", f.Name(), err)
+				}
+				used[f.Name()] = true
+				s[i] = v
+			}
+		}
+		if _, ok := p.Type().Underlying().(*types.Pointer); ok {
+			var cell value = s
+			args = append(args, &cell)
+		} else {
+			args = append(args, s)
+		}
+	}
+	for name := range e.config.Inputs {
+		if !used[name] {
+			return nil, fmt.Errorf("%s has no parameter or field %s", steps, name)
+		}
+	}
+
+	return args, nil
+}
+
+// input converts the Go value in, a boolean, string or number, to a
+// value of type t.
+func input(in interface{}, t types.Type) (value, error) {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return nil, fmt.Errorf("cannot give a value of type %s", t)
+	}
+	var x exact.Value
+	switch in := in.(type) {
+	case bool:
+		x = exact.MakeBool(in)
+	case string:
+		x = exact.MakeString(in)
+	case int:
+		x = exact.MakeInt64(int64(in))
+	case int64:
+		x = exact.MakeInt64(in)
+	case float64:
+		x = exact.MakeFloat64(in)
+	default:
+		return nil, fmt.Errorf("unexpected %T value", in)
+	}
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0 && x.Kind() == exact.Bool,
+		info&types.IsString != 0 && x.Kind() == exact.String,
+		info&types.IsInteger != 0 && x.Kind() == exact.Int,
+		info&(types.IsFloat|types.IsComplex) != 0 && (x.Kind() == exact.Int || x.Kind() == exact.Float):
+		return x, nil
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", in, t)
+}
+
+// global returns the address of g.  The variables of the element's
+// package start with their zero values, to be set by its initializer,
+// and those of other packages are unknown.
+func (e *evaluator) global(g *ssa.Global) *value {
+	cell, ok := e.globals[g]
+	if !ok {
+		var v value = unknown{}
+		if g.Pkg == e.pkg {
+			v = zero(deref(g.Type()))
+		}
+		cell = &v
+		e.globals[g] = cell
+	}
+	return cell
+}
+
+// call calls the function value fn with arguments args, on behalf of
+// the call instruction site, or nil for the initial call.
+func (e *evaluator) call(fn value, args []value, site ssa.CallInstruction) value {
+	switch fn := fn.(type) {
+	case *ssa.Function:
+		return e.callFunction(fn, args, nil, site)
+	case *closure:
+		return e.callFunction(fn.fn, args, fn.env, site)
+	case *ssa.Builtin:
+		return e.builtin(fn, args, site)
+	case nil:
+		e.errorf(site, "call of nil function")
+	}
+	e.errorf(site, "call of unknown function")
+	return nil
+}
+
+func (e *evaluator) callFunction(fn *ssa.Function, args, env []value, site ssa.CallInstruction) value {
+	if op, ok := e.config.API[fn.Name()]; ok && site != nil {
+		e.emit(op, fn, args, site)
+		return zero(results(fn.Signature))
+	}
+	if fn.Blocks == nil {
+		return unknownResults(fn.Signature)
+	}
+	if fn.Pkg != nil && fn.Pkg != e.pkg && fn == fn.Pkg.Func("init") {
+		return nil // the variables of other packages are unknown
+	}
+
+	if e.depth++; e.depth > maxDepth {
+		e.errorf(site, "calls nested more than %d deep", maxDepth)
+	}
+	defer func() { e.depth-- }()
+
+	fr := &frame{fn: fn, env: make(map[ssa.Value]value)}
+	for i, p := range fn.Params {
+		fr.env[p] = args[i]
+	}
+	for i, fv := range fn.FreeVars {
+		fr.env[fv] = env[i]
+	}
+	return e.run(fr)
+}
+
+// results returns the type of the value of a call to a function of
+// signature sig.
+func results(sig *types.Signature) types.Type {
+	if sig.Results().Len() == 1 {
+		return sig.Results().At(0).Type()
+	}
+	return sig.Results()
+}
+
+// unknownResults returns the value of a call to a function of
+// signature sig that has no code.
+func unknownResults(sig *types.Signature) value {
+	switch n := sig.Results().Len(); n {
+	case 0:
+		return nil
+	case 1:
+		return unknown{}
+	default:
+		t := make(tuple, n)
+		for i := range t {
+			t[i] = unknown{}
+		}
+		return t
+	}
+}
+
+// emit records the instruction performed by a call of API function
+// fn, of operation op, with arguments args.
+func (e *evaluator) emit(op Op, fn *ssa.Function, args []value, site ssa.CallInstruction) {
+	instr := &Instruction{Op: op}
+	if pos := site.Pos(); pos.IsValid() {
+		instr.Source = e.prog.Fset.Position(pos).String()
+	}
+	params := fn.Signature.Params()
+	args = args[len(args)-params.Len():] // skip any receiver
+	for i, arg := range args {
+		p := params.At(i)
+		if _, ok := p.Type().Underlying().(*types.Basic); !ok {
+			continue
+		}
+		x, ok := arg.(exact.Value)
+		if !ok {
+			e.errorf(site, "cannot resolve %s argument of %s", p.Name(), fn.Name())
+		}
+		switch field(p.Name()) {
+		case "Volume":
+			f, _ := exact.Float64Val(x)
+			if x.Kind() != exact.Int && x.Kind() != exact.Float {
+				e.errorf(site, "%s argument of %s is not a number", p.Name(), fn.Name())
+			}
+			instr.Volume = f
+		case "Location":
+			if x.Kind() == exact.String {
+				instr.Location = exact.StringVal(x)
+			} else {
+				instr.Location = x.String()
+			}
+		default:
+			if instr.Settings == nil {
+				instr.Settings = make(map[string]interface{})
+			}
+			instr.Settings[p.Name()] = goValue(x)
+		}
+	}
+	e.instrs = append(e.instrs, instr)
+}
+
+// goValue returns x as a bool, string or float64.
+func goValue(x exact.Value) interface{} {
+	switch x.Kind() {
+	case exact.Bool:
+		return exact.BoolVal(x)
+	case exact.String:
+		return exact.StringVal(x)
+	case exact.Int, exact.Float:
+		f, _ := exact.Float64Val(x)
+		return f
+	}
+	return x.String()
+}
+
+// run evaluates the body of the function of fr, and returns its
+// results.
+func (e *evaluator) run(fr *frame) value {
+	var pred *ssa.BasicBlock
+	b := fr.fn.Blocks[0]
+	for {
+		// φ-nodes take their values simultaneously.
+		var phis []*ssa.Phi
+		var edges []value
+		for _, instr := range b.Instrs {
+			phi, ok := instr.(*ssa.Phi)
+			if !ok {
+				break
+			}
+			for i, p := range b.Preds {
+				if p == pred {
+					phis = append(phis, phi)
+					edges = append(edges, e.get(fr, phi.Edges[i]))
+				}
+			}
+		}
+		for i, phi := range phis {
+			fr.env[phi] = edges[i]
+		}
+
+		for _, instr := range b.Instrs[len(phis):] {
+			if e.steps++; e.steps > e.maxSteps {
+				e.errorf(instr, "evaluation exceeded %d steps", e.maxSteps)
+			}
+			switch instr := instr.(type) {
+			case *ssa.Jump:
+				pred, b = b, b.Succs[0]
+
+			case *ssa.If:
+				cond, ok := e.get(fr, instr.Cond).(exact.Value)
+				if !ok {
+					e.errorf(instr, "branch depends on an unknown value")
+				}
+				succ := b.Succs[1]
+				if exact.BoolVal(cond) {
+					succ = b.Succs[0]
+				}
+				pred, b = b, succ
+
+			case *ssa.Return:
+				switch len(instr.Results) {
+				case 0:
+					return nil
+				case 1:
+					return e.get(fr, instr.Results[0])
+				}
+				t := make(tuple, len(instr.Results))
+				for i, r := range instr.Results {
+					t[i] = e.get(fr, r)
+				}
+				return t
+
+			case *ssa.Panic:
+				e.errorf(instr, "panic: %s", describe(e.get(fr, instr.X)))
+
+			default:
+				e.exec(fr, instr)
+				continue
+			}
+			break
+		}
+	}
+}
+
+// describe returns a description of a panic value v.
+func describe(v value) string {
+	if i, ok := v.(iface); ok {
+		v = i.v
+	}
+	if x, ok := v.(exact.Value); ok {
+		return x.String()
+	}
+	return "unknown value"
+}
+
+// get returns the value of v in frame fr.
+func (e *evaluator) get(fr *frame, v ssa.Value) value {
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.Value == nil {
+			return zero(v.Type())
+		}
+		return v.Value
+	case *ssa.Global:
+		return e.global(v)
+	case *ssa.Function, *ssa.Builtin:
+		return v
+	}
+	return fr.env[v]
+}
+
+// exec evaluates instr, which does not transfer control.
+func (e *evaluator) exec(fr *frame, instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.DebugRef:
+		// no-op
+
+	case *ssa.Store:
+		ptr, ok := e.get(fr, instr.Addr).(*value)
+		if !ok {
+			e.errorf(instr, "store through an unknown or nil pointer")
+		}
+		*ptr = copyVal(e.get(fr, instr.Val))
+
+	case *ssa.MapUpdate:
+		m, ok := e.get(fr, instr.Map).(*hashmap)
+		if !ok {
+			e.errorf(instr, "update of an unknown or nil map")
+		}
+		m.set(e.key(instr, e.get(fr, instr.Key)), copyVal(e.get(fr, instr.Value)))
+
+	case *ssa.Go:
+		fn, args := e.prepareCall(fr, &instr.Call, instr)
+		e.call(fn, args, instr)
+
+	case *ssa.Defer:
+		fn, args := e.prepareCall(fr, &instr.Call, instr)
+		fr.defers = append(fr.defers, func() { e.call(fn, args, instr) })
+
+	case *ssa.RunDefers:
+		for i := len(fr.defers) - 1; i >= 0; i-- {
+			fr.defers[i]()
+		}
+		fr.defers = nil
+
+	case *ssa.Send:
+		e.errorf(instr, "channel operations are not supported")
+
+	case ssa.Value:
+		fr.env[instr] = e.eval(fr, instr)
+
+	default:
+		e.errorf(instr, "unexpected instruction: %s", instr)
+	}
+}
+
+// eval returns the value of the register instr.
+func (e *evaluator) eval(fr *frame, instr ssa.Value) value {
+	switch instr := instr.(type) {
+	case *ssa.Alloc:
+		v := zero(deref(instr.Type()))
+		return &v
+
+	case *ssa.Call:
+		fn, args := e.prepareCall(fr, &instr.Call, instr)
+		return e.call(fn, args, instr)
+
+	case *ssa.BinOp:
+		return e.binop(instr, e.get(fr, instr.X), e.get(fr, instr.Y))
+
+	case *ssa.UnOp:
+		return e.unop(instr, e.get(fr, instr.X))
+
+	case *ssa.ChangeType:
+		return e.get(fr, instr.X)
+
+	case *ssa.ChangeInterface:
+		return e.get(fr, instr.X)
+
+	case *ssa.Convert:
+		return convert(e.get(fr, instr.X), instr.X.Type(), instr.Type())
+
+	case *ssa.MakeInterface:
+		return iface{instr.X.Type(), e.get(fr, instr.X)}
+
+	case *ssa.MakeClosure:
+		c := &closure{fn: instr.Fn.(*ssa.Function)}
+		for _, b := range instr.Bindings {
+			c.env = append(c.env, e.get(fr, b))
+		}
+		return c
+
+	case *ssa.MakeSlice:
+		n := e.int(instr, e.get(fr, instr.Len), "length of slice")
+		s := make([]value, n)
+		for i := range s {
+			s[i] = zero(instr.Type().Underlying().(*types.Slice).Elem())
+		}
+		return s
+
+	case *ssa.MakeMap:
+		return &hashmap{entries: make(map[string]value)}
+
+	case *ssa.MakeChan, *ssa.Select:
+		e.errorf(instr.(ssa.Instruction), "channel operations are not supported")
+
+	case *ssa.Slice:
+		return e.slice(fr, instr)
+
+	case *ssa.FieldAddr:
+		switch x := e.get(fr, instr.X).(type) {
+		case *value:
+			return &(*x).(structure)[instr.Field]
+		case nil:
+			e.errorf(instr, "nil pointer dereference")
+		}
+		return unknown{}
+
+	case *ssa.Field:
+		if s, ok := e.get(fr, instr.X).(structure); ok {
+			return s[instr.Field]
+		}
+		return unknown{}
+
+	case *ssa.IndexAddr:
+		var elems []value
+		switch x := e.get(fr, instr.X).(type) {
+		case []value:
+			elems = x
+		case *value:
+			elems = (*x).(array)
+		case nil:
+			e.errorf(instr, "index of nil slice")
+		default:
+			return unknown{}
+		}
+		i := e.int(instr, e.get(fr, instr.Index), "index")
+		if i < 0 || i >= len(elems) {
+			e.errorf(instr, "index out of range: %d", i)
+		}
+		return &elems[i]
+
+	case *ssa.Index:
+		a, ok := e.get(fr, instr.X).(array)
+		i, known := e.get(fr, instr.Index).(exact.Value)
+		if !ok || !known {
+			return unknown{}
+		}
+		return a[e.int(instr, i, "index")]
+
+	case *ssa.Lookup:
+		return e.lookup(fr, instr)
+
+	case *ssa.Range:
+		switch x := e.get(fr, instr.X).(type) {
+		case exact.Value:
+			return &iter{s: exact.StringVal(x)}
+		case *hashmap:
+			return &iter{m: x, keys: append([]value(nil), x.keys...)}
+		case nil:
+			return &iter{}
+		}
+		return unknown{}
+
+	case *ssa.Next:
+		it, ok := e.get(fr, instr.Iter).(*iter)
+		if !ok {
+			return tuple{unknown{}, unknown{}, unknown{}}
+		}
+		return it.next(instr.IsString)
+
+	case *ssa.TypeAssert:
+		return e.typeAssert(fr, instr)
+
+	case *ssa.Extract:
+		if t, ok := e.get(fr, instr.Tuple).(tuple); ok {
+			return t[instr.Index]
+		}
+		return unknown{}
+	}
+	e.errorf(instr.(ssa.Instruction), "unexpected instruction: %s", instr)
+	return nil
+}
+
+// prepareCall returns the function and arguments of the call c.
+func (e *evaluator) prepareCall(fr *frame, c *ssa.CallCommon, site ssa.CallInstruction) (value, []value) {
+	var args []value
+	var fn value
+	if c.IsInvoke() {
+		var recv iface
+		switch x := e.get(fr, c.Value).(type) {
+		case iface:
+			recv = x
+		case nil:
+			e.errorf(site, "call of method %s on nil interface value", c.Method.Name())
+		default:
+			e.errorf(site, "call of method %s on unknown interface value", c.Method.Name())
+		}
+		fn = e.prog.LookupMethod(recv.t, c.Method.Pkg(), c.Method.Name())
+		args = append(args, recv.v)
+	} else {
+		fn = e.get(fr, c.Value)
+	}
+	for _, arg := range c.Args {
+		args = append(args, e.get(fr, arg))
+	}
+	return fn, args
+}
+
+// int returns the value of x, an integer used as the what of instr.
+func (e *evaluator) int(instr ssa.Instruction, x value, what string) int {
+	if x, ok := x.(exact.Value); ok {
+		if i, ok := exact.Int64Val(x); ok {
+			return int(i)
+		}
+	}
+	e.errorf(instr, "cannot resolve %s", what)
+	return 0
+}
+
+func (e *evaluator) binop(instr *ssa.BinOp, x, y value) value {
+	if instr.Op == token.EQL || instr.Op == token.NEQ {
+		eq := equals(x, y)
+		if eq == nil {
+			return unknown{}
+		}
+		return exact.MakeBool(*eq == (instr.Op == token.EQL))
+	}
+	a, ok1 := x.(exact.Value)
+	b, ok2 := y.(exact.Value)
+	if !ok1 || !ok2 {
+		return unknown{}
+	}
+	op := instr.Op
+	switch op {
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return exact.MakeBool(exact.Compare(a, op, b))
+
+	case token.SHL, token.SHR:
+		s, ok := exact.Uint64Val(b)
+		if !ok {
+			e.errorf(instr, "bad shift count %s", b)
+		}
+		return exact.Shift(a, op, uint(s))
+
+	case token.QUO, token.REM:
+		if b.Kind() != exact.String && exact.Sign(b) == 0 {
+			e.errorf(instr, "division by zero")
+		}
+		if isInteger(instr.X.Type()) && op == token.QUO {
+			op = token.QUO_ASSIGN // integer division
+		}
+	}
+	return round(exact.BinaryOp(a, op, b), instr.Type())
+}
+
+func (e *evaluator) unop(instr *ssa.UnOp, x value) value {
+	switch instr.Op {
+	case token.MUL:
+		switch x := x.(type) {
+		case *value:
+			return copyVal(*x)
+		case nil:
+			e.errorf(instr, "nil pointer dereference")
+		}
+		return unknown{}
+	case token.ARROW:
+		e.errorf(instr, "channel operations are not supported")
+	}
+	a, ok := x.(exact.Value)
+	if !ok {
+		return unknown{}
+	}
+	size := 0
+	if b, ok := instr.Type().Underlying().(*types.Basic); ok && b.Info()&types.IsUnsigned != 0 {
+		size = int(sizes.Sizeof(b))
+	}
+	return round(exact.UnaryOp(instr.Op, a, size), instr.Type())
+}
+
+var sizes = &types.StdSizes{WordSize: 8, MaxAlign: 8}
+
+func (e *evaluator) slice(fr *frame, instr *ssa.Slice) value {
+	x := e.get(fr, instr.X)
+	bound := func(v ssa.Value, def int) (int, bool) {
+		if v == nil {
+			return def, true
+		}
+		if i, ok := e.get(fr, v).(exact.Value); ok {
+			return e.int(instr, i, "slice bound"), true
+		}
+		return 0, false
+	}
+	var n, c int
+	switch x := x.(type) {
+	case exact.Value:
+		n = len(exact.StringVal(x))
+		c = n
+	case []value:
+		n, c = len(x), cap(x)
+	case *value:
+		n = len((*x).(array))
+		c = n
+	case nil:
+	default:
+		return unknown{}
+	}
+	lo, ok1 := bound(instr.Low, 0)
+	hi, ok2 := bound(instr.High, n)
+	max, ok3 := bound(instr.Max, c)
+	if !ok1 || !ok2 || !ok3 {
+		return unknown{}
+	}
+	if lo < 0 || hi < lo || max < hi || max > c {
+		e.errorf(instr, "slice bounds out of range")
+	}
+	switch x := x.(type) {
+	case exact.Value:
+		return exact.MakeString(exact.StringVal(x)[lo:hi])
+	case []value:
+		return x[lo:hi:max]
+	case *value:
+		return []value((*x).(array)[lo:hi:max])
+	}
+	return []value(nil)
+}
+
+func (e *evaluator) lookup(fr *frame, instr *ssa.Lookup) value {
+	var v value = unknown{}
+	found := false
+	k := e.get(fr, instr.Index)
+	switch x := e.get(fr, instr.X).(type) {
+	case exact.Value:
+		s := exact.StringVal(x)
+		if _, ok := k.(exact.Value); ok {
+			i := e.int(instr, k, "index")
+			if i < 0 || i >= len(s) {
+				e.errorf(instr, "index out of range: %d", i)
+			}
+			v = exact.MakeInt64(int64(s[i]))
+		}
+		found = true
+	case *hashmap, nil:
+		m, _ := x.(*hashmap)
+		if _, ok := k.(exact.Value); ok {
+			if m != nil {
+				v, found = m.entries[e.key(instr, k)]
+			}
+			if !found {
+				v = zero(instr.X.Type().Underlying().(*types.Map).Elem())
+			}
+		}
+	}
+	if instr.CommaOk {
+		var ok value = unknown{}
+		if _, known := v.(unknown); !known {
+			ok = exact.MakeBool(found)
+		}
+		return tuple{v, ok}
+	}
+	return v
+}
+
+func (e *evaluator) typeAssert(fr *frame, instr *ssa.TypeAssert) value {
+	x := e.get(fr, instr.X)
+	var v value
+	var ok bool
+	switch x := x.(type) {
+	case iface:
+		if it, isIface := instr.AssertedType.Underlying().(*types.Interface); isIface {
+			v, ok = x, types.Implements(x.t, it)
+		} else {
+			v, ok = x.v, types.Identical(x.t, instr.AssertedType)
+		}
+	case nil:
+	default:
+		if instr.CommaOk {
+			return tuple{unknown{}, unknown{}}
+		}
+		return unknown{}
+	}
+	if !ok {
+		if !instr.CommaOk {
+			e.errorf(instr, "type assertion to %s fails", instr.AssertedType)
+		}
+		v = zero(instr.AssertedType)
+	}
+	if instr.CommaOk {
+		return tuple{v, exact.MakeBool(ok)}
+	}
+	return v
+}
+
+// builtin calls the built-in function fn.
+func (e *evaluator) builtin(fn *ssa.Builtin, args []value, site ssa.CallInstruction) value {
+	switch fn.Name() {
+	case "len", "cap":
+		switch x := args[0].(type) {
+		case exact.Value:
+			return exact.MakeInt64(int64(len(exact.StringVal(x))))
+		case []value:
+			if fn.Name() == "cap" {
+				return exact.MakeInt64(int64(cap(x)))
+			}
+			return exact.MakeInt64(int64(len(x)))
+		case *value:
+			return exact.MakeInt64(int64(len((*x).(array))))
+		case *hashmap:
+			return exact.MakeInt64(int64(len(x.keys)))
+		case nil:
+			return exact.MakeInt64(0)
+		}
+		return unknown{}
+
+	case "append":
+		s, ok1 := args[0].([]value)
+		var t []value
+		ok2 := true
+		switch y := args[1].(type) {
+		case []value:
+			t = y
+		case exact.Value: // append([]byte, string...)
+			for _, c := range []byte(exact.StringVal(y)) {
+				t = append(t, exact.MakeInt64(int64(c)))
+			}
+		case nil:
+		default:
+			ok2 = false
+		}
+		if !ok1 && args[0] != nil || !ok2 {
+			return unknown{}
+		}
+		r := make([]value, 0, len(s)+len(t))
+		return append(append(r, s...), t...)
+
+	case "copy":
+		dst, ok1 := args[0].([]value)
+		src, ok2 := args[1].([]value)
+		if !ok1 || !ok2 {
+			return unknown{}
+		}
+		return exact.MakeInt64(int64(copy(dst, src)))
+
+	case "delete":
+		if m, ok := args[0].(*hashmap); ok {
+			m.delete(e.key(site, args[1]))
+		}
+		return nil
+
+	case "print", "println", "close":
+		return nil
+
+	case "panic":
+		e.errorf(site, "panic: %s", describe(args[0]))
+
+	case "recover":
+		return nil
+	}
+	return unknown{}
+}
+
+// key returns the map key string of k.
+func (e *evaluator) key(instr ssa.Instruction, k value) string {
+	x, ok := k.(exact.Value)
+	if !ok {
+		e.errorf(instr, "cannot resolve map key")
+	}
+	return fmt.Sprintf("%d:%s", x.Kind(), x)
+}
+
+func (m *hashmap) set(k string, v value) {
+	if _, ok := m.entries[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.entries[k] = v
+}
+
+func (m *hashmap) delete(k string) {
+	if _, ok := m.entries[k]; !ok {
+		return
+	}
+	delete(m.entries, k)
+	for i, key := range m.keys {
+		if key == k {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// next returns the next (ok, key, value) tuple of the iteration.
+func (it *iter) next(isString bool) value {
+	if isString {
+		if it.i >= len(it.s) {
+			return tuple{exact.MakeBool(false), unknown{}, unknown{}}
+		}
+		r, size := utf8.DecodeRuneInString(it.s[it.i:])
+		t := tuple{exact.MakeBool(true), exact.MakeInt64(int64(it.i)), exact.MakeInt64(int64(r))}
+		it.i += size
+		return t
+	}
+	for it.i < len(it.keys) {
+		k := it.keys[it.i].(string)
+		it.i++
+		if v, ok := it.m.entries[k]; ok {
+			return tuple{exact.MakeBool(true), keyValue(k), v}
+		}
+	}
+	return tuple{exact.MakeBool(false), unknown{}, unknown{}}
+}
+
+// keyValue returns the key whose key string is k.
+func keyValue(k string) value {
+	var kind exact.Kind
+	var lit string
+	fmt.Sscanf(k, "%d:", &kind)
+	lit = k[len(fmt.Sprint(int(kind)))+1:]
+	switch kind {
+	case exact.Bool:
+		return exact.MakeBool(lit == "true")
+	case exact.String:
+		return exact.MakeFromLiteral(lit, token.STRING)
+	case exact.Int:
+		return exact.MakeFromLiteral(lit, token.INT)
+	}
+	return unknown{}
+}
+
+// equals reports whether x and y are equal, or returns nil if that
+// is unknown.
+func equals(x, y value) *bool {
+	if _, ok := x.(unknown); ok {
+		return nil
+	}
+	if _, ok := y.(unknown); ok {
+		return nil
+	}
+	r := false
+	switch x := x.(type) {
+	case exact.Value:
+		if y, ok := y.(exact.Value); ok {
+			r = exact.Compare(x, token.EQL, y)
+		}
+	case iface:
+		if y, ok := y.(iface); ok && types.Identical(x.t, y.t) {
+			return equals(x.v, y.v)
+		}
+	case structure:
+		y, ok := y.(structure)
+		if !ok {
+			return nil
+		}
+		return equalElems(x, y)
+	case array:
+		y, ok := y.(array)
+		if !ok {
+			return nil
+		}
+		return equalElems(x, y)
+	default:
+		r = x == y // pointers, nil
+	}
+	return &r
+}
+
+func equalElems(x, y []value) *bool {
+	r := true
+	for i := range x {
+		eq := equals(x[i], y[i])
+		if eq == nil {
+			return nil
+		}
+		r = r && *eq
+	}
+	return &r
+}
+
+// zero returns the zero value of type t.
+func zero(t types.Type) value {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return exact.MakeBool(false)
+		case t.Info()&types.IsString != 0:
+			return exact.MakeString("")
+		case t.Info()&types.IsNumeric != 0:
+			return exact.MakeInt64(0)
+		}
+	case *types.Struct:
+		s := make(structure, t.NumFields())
+		for i := range s {
+			s[i] = zero(t.Field(i).Type())
+		}
+		return s
+	case *types.Array:
+		a := make(array, t.Len())
+		for i := range a {
+			a[i] = zero(t.Elem())
+		}
+		return a
+	case *types.Tuple:
+		tup := make(tuple, t.Len())
+		for i := range tup {
+			tup[i] = zero(t.At(i).Type())
+		}
+		if t.Len() == 0 {
+			return nil
+		}
+		return tup
+	}
+	return nil
+}
+
+// copyVal returns a copy of v, which is distinct if v is a struct or
+// array.
+func copyVal(v value) value {
+	switch v := v.(type) {
+	case structure:
+		c := make(structure, len(v))
+		for i, elem := range v {
+			c[i] = copyVal(elem)
+		}
+		return c
+	case array:
+		c := make(array, len(v))
+		for i, elem := range v {
+			c[i] = copyVal(elem)
+		}
+		return c
+	}
+	return v
+}
+
+// convert returns the value of x, of type from, converted to type to.
+func convert(x value, from, to types.Type) value {
+	switch x := x.(type) {
+	case exact.Value:
+		b, ok := to.Underlying().(*types.Basic)
+		if !ok {
+			if s, ok := to.Underlying().(*types.Slice); ok && x.Kind() == exact.String {
+				var elems []value
+				if isByte(s.Elem()) {
+					for _, c := range []byte(exact.StringVal(x)) {
+						elems = append(elems, exact.MakeInt64(int64(c)))
+					}
+				} else {
+					for _, r := range exact.StringVal(x) {
+						elems = append(elems, exact.MakeInt64(int64(r)))
+					}
+				}
+				return elems
+			}
+			return unknown{}
+		}
+		switch {
+		case b.Info()&types.IsString != 0:
+			if x.Kind() == exact.Int {
+				r, _ := exact.Int64Val(x)
+				return exact.MakeString(string(rune(r)))
+			}
+			return x
+		case b.Info()&types.IsInteger != 0:
+			if x.Kind() == exact.Float {
+				x = exact.BinaryOp(exact.Num(x), token.QUO_ASSIGN, exact.Denom(x)) // truncate
+			}
+			return x
+		case b.Info()&types.IsNumeric != 0:
+			return round(x, to)
+		}
+		return x
+
+	case []value: // string([]byte) or string([]rune)
+		if !isString(to) {
+			return unknown{}
+		}
+		var runes []rune
+		var bytes []byte
+		s := from.Underlying().(*types.Slice)
+		for _, elem := range x {
+			c, ok := elem.(exact.Value)
+			if !ok {
+				return unknown{}
+			}
+			i, _ := exact.Int64Val(c)
+			runes = append(runes, rune(i))
+			bytes = append(bytes, byte(i))
+		}
+		if isByte(s.Elem()) {
+			return exact.MakeString(string(bytes))
+		}
+		return exact.MakeString(string(runes))
+	}
+	return x
+}
+
+// round returns x rounded to a floating-point value if t is a
+// floating-point type.
+func round(x exact.Value, t types.Type) value {
+	if b, ok := t.Underlying().(*types.Basic); ok && b.Info()&types.IsFloat != 0 && x.Kind() != exact.Unknown {
+		f, _ := exact.Float64Val(x)
+		return exact.MakeFloat64(f)
+	}
+	return x
+}
+
+func isInteger(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+func isByte(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// deref returns a pointer's element type; otherwise it returns typ.
+func deref(typ types.Type) types.Type {
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return typ
+}
\ No newline at end of file
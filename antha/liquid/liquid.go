@@ -0,0 +1,178 @@
+// antha-tools/antha/liquid/liquid.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// Package liquid lowers the SSA form of an Antha element into a
+// device-independent list of liquid-handling instructions, so that
+// what a protocol will do can be reviewed, and diffed between
+// versions, without running it against a driver.
+//
+// Lower evaluates the element's Steps function symbolically, given
+// values for some or all of its inputs.  Calls to the functions and
+// methods of the execution API, recognized by name, are not
+// evaluated but recorded as instructions; other calls are followed
+// into their callees if these have code.  The arguments of each API
+// call are resolved to constants and bound to the fields of its
+// instruction by the names of the callee's parameters:
+//
+//	parameter name ends with    instruction field
+//	"volume"                    Volume
+//	"well", "position", "location",
+//	or is "from", "to" or "at"  Location
+//	anything else               Settings[name]
+//
+// Evaluation fails if an instruction's location or volume, or the
+// direction of a branch, depends on an input that was not given, or
+// on the result of a function without code.  Evaluation is exact but
+// simple: integer overflow is not modelled, and goroutines run to
+// completion when started.
+//
+package liquid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+)
+
+// An Op is the operation of an instruction.
+type Op string
+
+const (
+	Aspirate Op = "aspirate" // draw liquid into the pipette
+	Dispense Op = "dispense" // expel liquid from the pipette
+	Move     Op = "move"     // move the pipette head
+	Incubate Op = "incubate" // hold a location at a temperature
+	Read     Op = "read"     // take a measurement
+)
+
+// An Instruction is a single liquid-handling operation.  Its
+// encoding as JSON is the output format of the backend.
+type Instruction struct {
+	Op       Op                     `json:"op"`
+	Location string                 `json:"location,omitempty"` // deck position or well
+	Volume   float64                `json:"volume,omitempty"`   // in the units of the API
+	Settings map[string]interface{} `json:"settings,omitempty"` // other arguments, by parameter name
+	Source   string                 `json:"source,omitempty"`   // position of the API call
+}
+
+// A Config specifies the execution API and the element's inputs.
+type Config struct {
+	// API maps the names of the functions and methods of the
+	// execution API to the operations they perform.
+	API map[string]Op
+
+	// Inputs gives values for the parameters of the Steps
+	// function, by name.  If a parameter is a struct, or a
+	// pointer to one, its fields may be given instead.  Values
+	// are booleans, strings or numbers; those of parameters and
+	// fields not given are unknown.
+	Inputs map[string]interface{}
+
+	// MaxSteps limits the number of SSA instructions evaluated;
+	// zero means a default of one million.
+	MaxSteps int
+}
+
+// DefaultConfig returns a new Config whose API is the functions and
+// methods named Aspirate, Dispense, Move, Incubate and Read.
+//
+func DefaultConfig() *Config {
+	return &Config{
+		API: map[string]Op{
+			"Aspirate": Aspirate,
+			"Dispense": Dispense,
+			"Move":     Move,
+			"Incubate": Incubate,
+			"Read":     Read,
+		},
+		Inputs: make(map[string]interface{}),
+	}
+}
+
+// An Error is a failure to lower an element, at a position in its
+// source.
+type Error struct {
+	Pos string // position of the instruction that could not be evaluated
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if e.Pos == "" {
+		return e.Msg
+	}
+	return e.Pos + ": " + e.Msg
+}
+
+// Lower evaluates the function steps, typically the Steps function
+// of an element, under config, and returns the instructions it
+// performs, in order.
+//
+// Precondition: the package of steps, and those of any functions it
+// calls, are built.
+//
+func Lower(config *Config, steps *ssa.Function) (instrs []*Instruction, err error) {
+	if steps.Blocks == nil {
+		return nil, fmt.Errorf("%s has no code", steps)
+	}
+	e := &evaluator{
+		config:   config,
+		prog:     steps.Prog,
+		maxSteps: config.MaxSteps,
+	}
+	if e.maxSteps == 0 {
+		e.maxSteps = 1000000
+	}
+	args, err := e.inputs(steps)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch x := recover().(type) {
+		case nil:
+		case *Error:
+			instrs, err = nil, x
+		default:
+			panic(x)
+		}
+	}()
+	if init := steps.Pkg.Func("init"); init != nil && init.Blocks != nil {
+		e.call(init, nil, nil) // initialize the package-level variables
+	}
+	e.call(steps, args, nil)
+	return e.instrs, nil
+}
+
+// field returns the instruction field to which a parameter named
+// name binds: "Volume", "Location" or "".
+func field(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, "volume"):
+		return "Volume"
+	case strings.HasSuffix(name, "well"), strings.HasSuffix(name, "position"), strings.HasSuffix(name, "location"),
+		name == "from", name == "to", name == "at":
+		return "Location"
+	}
+	return ""
+}
\ No newline at end of file
@@ -0,0 +1,138 @@
+// antha-tools/antha/liquid/liquid_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package liquid_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/liquid"
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+)
+
+func load(t *testing.T) *ssa.Package {
+	var conf loader.Config
+	f, err := conf.ParseFile("testdata/element.go", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("element", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssa.Create(iprog, 0)
+	pkg := prog.Package(iprog.Created[0].Pkg)
+	pkg.Build()
+	return pkg
+}
+
+// format returns a one-line summary of instr.
+func format(instr *liquid.Instruction) string {
+	s := fmt.Sprintf("%s %s %g", instr.Op, instr.Location, instr.Volume)
+	var keys []string
+	for k := range instr.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += fmt.Sprintf(" %s=%v", k, instr.Settings[k])
+	}
+	return s
+}
+
+func TestLower(t *testing.T) {
+	pkg := load(t)
+
+	config := liquid.DefaultConfig()
+	config.Inputs["Row"] = "B"
+	config.Inputs["Wells"] = 3
+	config.Inputs["TotalVolume"] = 100.0
+	config.Inputs["Factor"] = 4.0
+	instrs, err := liquid.Lower(config, pkg.Func("Steps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, instr := range instrs {
+		got = append(got, format(instr))
+		if !strings.HasPrefix(instr.Source, "testdata/element.go:") {
+			t.Errorf("%s: bad source position %q", format(instr), instr.Source)
+		}
+	}
+	want := []string{
+		"aspirate A1 75",
+		"dispense B1 75",
+		"aspirate A1 75",
+		"dispense B2 75",
+		"aspirate A1 75",
+		"dispense B3 75",
+		"aspirate A1 25",
+		"dispense B1 25",
+		"aspirate B1 25",
+		"move B2 0",
+		"dispense B2 25",
+		"aspirate B2 25",
+		"move B3 0",
+		"dispense B3 25",
+		"incubate B3 0 duration=3600 temperature=37",
+		"read B3 0 wavelength=600",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Lower returned:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLowerErrors(t *testing.T) {
+	pkg := load(t)
+
+	for _, test := range []struct {
+		fn     string
+		inputs map[string]interface{}
+		want   string
+	}{
+		// The number of wells is needed to decide the loops.
+		{"Steps", map[string]interface{}{"Row": "B", "TotalVolume": 100.0, "Factor": 4.0},
+			"branch depends on an unknown value"},
+		{"Steps", map[string]interface{}{"Row": "B", "Wells": 3, "TotalVolume": 100.0, "Factor": 0.0},
+			"division by zero"},
+		{"Steps", map[string]interface{}{"Row": "B", "Wells": 3.5},
+			"input Wells: 3.5 is not a valid int"},
+		{"Steps", map[string]interface{}{"Rows": "B"},
+			"has no parameter or field Rows"},
+		{"Unresolvable", map[string]interface{}{"Row": "B"},
+			"cannot resolve volume argument of Aspirate"},
+	} {
+		config := liquid.DefaultConfig()
+		config.Inputs = test.inputs
+		_, err := liquid.Lower(config, pkg.Func(test.fn))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s with %v: got error %v, want %q", test.fn, test.inputs, err, test.want)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,76 @@
+// antha-tools/antha/liquid/testdata/element.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// +build ignore
+
+package element
+
+// This file is the input to TestLower in liquid_test.go.  It is a
+// serial dilution: the diluent is added to each well of a row, then a
+// fixed volume is carried along the row, mixing as it goes.
+
+type Robot struct{ name string }
+
+func (r *Robot) Aspirate(well string, volume float64)                {}
+func (r *Robot) Dispense(well string, volume float64)                {}
+func (r *Robot) Move(to string)                                      {}
+func (r *Robot) Incubate(well string, temperature, duration float64) {}
+func (r *Robot) Read(well string, wavelength float64)                {}
+
+type Parameters struct {
+	Row         string
+	Wells       int
+	TotalVolume float64
+	Factor      float64
+}
+
+var diluent = "A1"
+
+func Steps(r *Robot, p *Parameters) {
+	carry := p.TotalVolume / p.Factor
+	for i := 1; i <= p.Wells; i++ {
+		r.Aspirate(diluent, p.TotalVolume-carry)
+		r.Dispense(name(p.Row, i), p.TotalVolume-carry)
+	}
+	r.Aspirate(diluent, carry)
+	for i := 1; i < p.Wells; i++ {
+		from, to := name(p.Row, i), name(p.Row, i+1)
+		r.Dispense(from, carry)
+		r.Aspirate(from, carry)
+		r.Move(to)
+	}
+	r.Dispense(name(p.Row, p.Wells), carry)
+	r.Incubate(name(p.Row, p.Wells), 37, 3600)
+	r.Read(name(p.Row, p.Wells), 600)
+}
+
+// name returns the name of well i of row, counting from 1.
+func name(row string, i int) string {
+	return row + string('0'+rune(i))
+}
+
+// Unresolvable takes half of the total volume, which is given only
+// when the element runs.
+func Unresolvable(r *Robot, p *Parameters) {
+	r.Aspirate(p.Row+"1", p.TotalVolume/2)
+}
\ No newline at end of file
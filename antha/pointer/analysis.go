@@ -104,6 +104,15 @@ type node struct {
 	// an object of aggregate type (struct, tuple, array) this is.
 	subelement *fieldInfo // e.g. ".a.b[*].c"
 
+	// Solver state for the pointer equivalence class of this node.
+	// Distinct nodes may share a solverState if they are known to
+	// have the same points-to set; see hvn.go and collapseCycles.
+	solve *solverState
+}
+
+// A solverState holds the points-to set and constraint graph edges
+// of a set of pointer-equivalent nodes.
+type solverState struct {
 	// Points-to sets.
 	pts     nodeset // points-to set of this node
 	prevPts nodeset // pts(n) in previous iteration (for difference propagation)
@@ -119,6 +128,10 @@ type node struct {
 	// - *untagConstraint      y=x.(C)
 	// - *invokeConstraint     y=x.f(params...)
 	complex constraintset
+
+	// The nodes that share this state; members[0] is the
+	// representative used for the worklist.
+	members []nodeid
 }
 
 // An analysis instance holds the state of a single pointer analysis problem.
@@ -138,7 +151,13 @@ type analysis struct {
 	globalobj   map[ssa.Value]nodeid        // maps v to sole member of pts(v), if singleton
 	localval    map[ssa.Value]nodeid        // node for each local ssa.Value
 	localobj    map[ssa.Value]nodeid        // maps v to sole member of pts(v), if singleton
+	contexts    map[contextKey]*context     // hash-consed calling contexts
+	contours    map[contourKey]nodeid       // function object for each context-sensitive contour
+	callEdges   map[callEdgeKey]bool        // edges already added to result.CallGraph
+	unsafeViews map[unsafeViewKey]nodeid    // view objects created by unsafe conversions
 	work        worklist                    // solver's worklist
+	lcdEdges    map[[2]nodeid]bool          // copy edges already checked for cycles
+	lcdQueue    []nodeid                    // nodes from which to detect cycles
 	result      *Result                     // results of the analysis
 	track       track                       // pointerlike types whose aliasing we track
 
@@ -189,6 +208,12 @@ func (a *analysis) warnf(pos token.Pos, format string, args ...interface{}) {
 
 // computeTrackBits sets a.track to the necessary 'track' bits for the pointer queries.
 func (a *analysis) computeTrackBits() {
+	if a.config.Unsafe || a.config.DebugRefs {
+		// Any object may be reinterpreted as pointerlike,
+		// or the client wants the entire solution.
+		a.track = trackAll
+		return
+	}
 	var queryTypes []types.Type
 	for v := range a.config.Queries {
 		queryTypes = append(queryTypes, v.Type())
@@ -238,14 +263,21 @@ func Analyze(config *Config) (result *Result, err error) {
 		prog:        config.prog(),
 		globalval:   make(map[ssa.Value]nodeid),
 		globalobj:   make(map[ssa.Value]nodeid),
+		contexts:    make(map[contextKey]*context),
+		contours:    make(map[contourKey]nodeid),
+		callEdges:   make(map[callEdgeKey]bool),
+		unsafeViews: make(map[unsafeViewKey]nodeid),
 		flattenMemo: make(map[types.Type][]*fieldInfo),
 		trackTypes:  make(map[types.Type]bool),
 		hasher:      typeutil.MakeHasher(),
 		intrinsics:  make(map[*ssa.Function]intrinsic),
-		work:        makeMapWorklist(),
+		work:        makeFIFOWorklist(),
+		lcdEdges:    make(map[[2]nodeid]bool),
 		result: &Result{
 			Queries:         make(map[ssa.Value]Pointer),
 			IndirectQueries: make(map[ssa.Value]Pointer),
+			ContextQueries:  make(map[ssa.Value][]Pointer),
+			DebugRefs:       make(map[*ssa.DebugRef]Pointer),
 		},
 	}
 
@@ -321,8 +353,9 @@ func Analyze(config *Config) (result *Result, err error) {
 	// Add dynamic edges to call graph.
 	for _, caller := range a.cgnodes {
 		for _, site := range caller.sites {
-			for callee := range a.nodes[site.targets].pts {
-				a.callEdge(caller, site, callee)
+			var space [50]int
+			for _, callee := range a.nodes[site.targets].solve.pts.AppendTo(space[:0]) {
+				a.callEdge(caller, site, nodeid(callee))
 			}
 		}
 	}
@@ -330,6 +363,12 @@ func Analyze(config *Config) (result *Result, err error) {
 	return a.result, nil
 }
 
+type callEdgeKey struct {
+	caller *ssa.Function
+	site   ssa.CallInstruction
+	callee *ssa.Function
+}
+
 // callEdge is called for each edge in the callgraph.
 // calleeid is the callee's object node (has otFunction flag).
 //
@@ -341,11 +380,13 @@ func (a *analysis) callEdge(caller *cgnode, site *callsite, calleeid nodeid) {
 	callee := obj.cgn
 
 	if cg := a.result.CallGraph; cg != nil {
-		// TODO(adonovan): opt: I would expect duplicate edges
-		// (to wrappers) to arise due to the elimination of
-		// context information, but I haven't observed any.
-		// Understand this better.
-		callgraph.AddEdge(cg.CreateNode(caller.fn), site.instr, cg.CreateNode(callee.fn))
+		// The callgraph merges contours of the same function,
+		// so context-sensitive analysis yields duplicate edges.
+		key := callEdgeKey{caller.fn, site.instr, callee.fn}
+		if !a.callEdges[key] {
+			a.callEdges[key] = true
+			callgraph.AddEdge(cg.CreateNode(caller.fn), site.instr, cg.CreateNode(callee.fn))
+		}
 	}
 
 	if a.log != nil {
@@ -32,6 +32,7 @@ import (
 	"github.com/antha-lang/antha-tools/antha/callgraph"
 	"github.com/antha-lang/antha-tools/antha/ssa"
 	"github.com/antha-lang/antha-tools/antha/types/typeutil"
+	"github.com/antha-lang/antha-tools/container/intsets"
 )
 
 // A Config formulates a pointer analysis problem for Analyze().
@@ -48,6 +49,25 @@ type Config struct {
 	// has not yet been reduced by presolver optimisation.
 	Reflection bool
 
+	// Unsafe determines whether to handle unsafe.Pointer
+	// conversions soundly, by tracking the physical offsets of
+	// fields as laid out on linux/amd64.  Otherwise each
+	// unsafe.Pointer->*T conversion is treated as an unaliased
+	// allocation and a warning is reported.
+	Unsafe bool
+
+	// Context selects the context-sensitivity policy, and K
+	// its depth: the number of call sites (CallStrings) or
+	// receiver allocation sites (ObjectSensitive) that
+	// distinguish contexts.  A K of zero is treated as 1.
+	//
+	// Context sensitivity improves precision, especially for
+	// shared helper functions, at the cost of analysing each
+	// function once per context.  Results for each context are
+	// available in Result.ContextQueries.
+	Context ContextMode
+	K       int
+
 	// BuildCallGraph determines whether to construct a callgraph.
 	// If enabled, the graph will be available in Result.CallGraph.
 	BuildCallGraph bool
@@ -66,14 +86,20 @@ type Config struct {
 	// variable for v or *v.  Upon completion the client can
 	// inspect that map for the results.
 	//
-	// TODO(adonovan): this API doesn't scale well for batch tools
-	// that want to dump the entire solution.  Perhaps optionally
-	// populate a map[*ssa.DebugRef]Pointer in the Result, one
-	// entry per source expression.
+	// Batch tools that want to dump the entire solution should
+	// use DebugRefs instead.
 	//
 	Queries         map[ssa.Value]struct{}
 	IndirectQueries map[ssa.Value]struct{}
 
+	// DebugRefs determines whether to compute the points-to set
+	// of every source expression of pointer-like type, for batch
+	// tools that want to dump the entire solution.  If enabled,
+	// the analysis populates Result.DebugRefs with an entry for
+	// each *ssa.DebugRef; these exist only in functions built in
+	// debug mode (see ssa.GlobalDebug).
+	DebugRefs bool
+
 	// If Log is non-nil, log messages are written to it.
 	// Logging is extremely verbose.
 	Log io.Writer
@@ -134,10 +160,12 @@ type Warning struct {
 // See Config for how to request the various Result components.
 //
 type Result struct {
-	CallGraph       *callgraph.Graph      // discovered call graph
-	Queries         map[ssa.Value]Pointer // pts(v) for each v in Config.Queries.
-	IndirectQueries map[ssa.Value]Pointer // pts(*v) for each v in Config.IndirectQueries.
-	Warnings        []Warning             // warnings of unsoundness
+	CallGraph       *callgraph.Graph          // discovered call graph
+	Queries         map[ssa.Value]Pointer     // pts(v) for each v in Config.Queries.
+	IndirectQueries map[ssa.Value]Pointer     // pts(*v) for each v in Config.IndirectQueries.
+	ContextQueries  map[ssa.Value][]Pointer   // pts(v) in each context, for each v in Config.Queries.
+	DebugRefs       map[*ssa.DebugRef]Pointer // pts(e) for each source expression e, if Config.DebugRefs.
+	Warnings        []Warning                 // warnings of unsoundness
 }
 
 // A Pointer is an equivalence class of pointer-like values.
@@ -146,23 +174,28 @@ type Result struct {
 // types may alias the same object.
 //
 type Pointer struct {
-	a *analysis
-	n nodeid // non-zero
+	a   *analysis
+	n   nodeid  // non-zero
+	cgn *cgnode // contour of a context-qualified pointer; nil if none
 }
 
 // A PointsToSet is a set of labels (locations or allocations).
 type PointsToSet struct {
 	a   *analysis // may be nil if pts is nil
-	pts nodeset
+	pts *nodeset
 }
 
 func (s PointsToSet) String() string {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "[")
-	sep := ""
-	for l := range s.pts {
-		fmt.Fprintf(&buf, "%s%s", sep, s.a.labelFor(l))
-		sep = ", "
+	if s.pts != nil {
+		var space [50]int
+		for i, l := range s.pts.AppendTo(space[:0]) {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(s.a.labelFor(nodeid(l)).String())
+		}
 	}
 	fmt.Fprintf(&buf, "]")
 	return buf.String()
@@ -172,8 +205,11 @@ func (s PointsToSet) String() string {
 // contains.
 func (s PointsToSet) Labels() []*Label {
 	var labels []*Label
-	for l := range s.pts {
-		labels = append(labels, s.a.labelFor(l))
+	if s.pts != nil {
+		var space [50]int
+		for _, l := range s.pts.AppendTo(space[:0]) {
+			labels = append(labels, s.a.labelFor(nodeid(l)))
+		}
 	}
 	return labels
 }
@@ -191,8 +227,13 @@ func (s PointsToSet) Labels() []*Label {
 //
 func (s PointsToSet) DynamicTypes() *typeutil.Map {
 	var tmap typeutil.Map
+	if s.pts == nil {
+		return &tmap
+	}
 	tmap.SetHasher(s.a.hasher)
-	for ifaceObjId := range s.pts {
+	var space [50]int
+	for _, x := range s.pts.AppendTo(space[:0]) {
+		ifaceObjId := nodeid(x)
 		if !s.a.isTaggedObject(ifaceObjId) {
 			continue // !CanHaveDynamicTypes(tDyn)
 		}
@@ -202,10 +243,10 @@ func (s PointsToSet) DynamicTypes() *typeutil.Map {
 		}
 		pts, ok := tmap.At(tDyn).(PointsToSet)
 		if !ok {
-			pts = PointsToSet{s.a, make(nodeset)}
+			pts = PointsToSet{s.a, new(nodeset)}
 			tmap.Set(tDyn, pts)
 		}
-		pts.pts.addAll(s.a.nodes[v].pts)
+		pts.pts.addAll(&s.a.nodes[v].solve.pts)
 	}
 	return &tmap
 }
@@ -213,21 +254,30 @@ func (s PointsToSet) DynamicTypes() *typeutil.Map {
 // Intersects reports whether this points-to set and the
 // argument points-to set contain common members.
 func (x PointsToSet) Intersects(y PointsToSet) bool {
-	for l := range x.pts {
-		if _, ok := y.pts[l]; ok {
-			return true
-		}
+	if x.pts == nil || y.pts == nil {
+		return false
 	}
-	return false
+	// This takes Θ(|x|+|y|) time.
+	var z intsets.Sparse
+	z.Intersection(&x.pts.Sparse, &y.pts.Sparse)
+	return !z.IsEmpty()
 }
 
 func (p Pointer) String() string {
 	return fmt.Sprintf("n%d", p.n)
 }
 
+// Context returns the contour in which this pointer's value was
+// computed.  Pointers in Result.Queries merge all contexts, so their
+// Context is the zero Context.
+//
+func (p Pointer) Context() Context {
+	return Context{p.cgn}
+}
+
 // PointsTo returns the points-to set of this pointer.
 func (p Pointer) PointsTo() PointsToSet {
-	return PointsToSet{p.a, p.a.nodes[p.n].pts}
+	return PointsToSet{p.a, &p.a.nodes[p.n].solve.pts}
 }
 
 // MayAlias reports whether the receiver pointer may alias
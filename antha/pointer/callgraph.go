@@ -37,6 +37,7 @@ type cgnode struct {
 	obj        nodeid      // start of this contour's object block
 	sites      []*callsite // ordered list of callsites within this function
 	callersite *callsite   // where called from, if known; nil for shared contours
+	ctx        *context    // calling context of this contour; nil if none
 }
 
 func (n *cgnode) String() string {
@@ -24,6 +24,7 @@
 package pointer
 
 import (
+	"github.com/antha-lang/antha-tools/antha/ssa"
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
@@ -47,7 +48,7 @@ type constraint interface {
 
 	// solve is called for complex constraints when the pts for
 	// the node to which they are attached has changed.
-	solve(a *analysis, n *node, delta nodeset)
+	solve(a *analysis, delta *nodeset)
 
 	String() string
 }
@@ -174,6 +175,8 @@ func (c *untagConstraint) renumber(mapping []nodeid) {
 // A complex constraint attached to iface.
 type invokeConstraint struct {
 	method *types.Func // the abstract method
+	caller *cgnode     // the calling contour
+	site   *callsite   // the call site
 	iface  nodeid      // (ptr) the interface
 	params nodeid      // (indirect) the first param in the params/results block
 }
@@ -183,4 +186,24 @@ func (c *invokeConstraint) indirect(nodes []nodeid) []nodeid { return append(nod
 func (c *invokeConstraint) renumber(mapping []nodeid) {
 	c.iface = mapping[c.iface]
 	c.params = mapping[c.params]
+}
+
+// recv.fn(params...)
+// A complex constraint attached to recv, for a static call of a
+// method whose contour depends on the receiver object.
+type objectSensitiveCallConstraint struct {
+	fn     *ssa.Function // the concrete method
+	caller *cgnode       // the calling contour
+	site   *callsite     // the call site
+	recv   nodeid        // (ptr) the receiver
+	params nodeid        // (indirect) the first param in the params/results block
+}
+
+func (c *objectSensitiveCallConstraint) ptr() nodeid { return c.recv }
+func (c *objectSensitiveCallConstraint) indirect(nodes []nodeid) []nodeid {
+	return append(nodes, c.params)
+}
+func (c *objectSensitiveCallConstraint) renumber(mapping []nodeid) {
+	c.recv = mapping[c.recv]
+	c.params = mapping[c.params]
 }
\ No newline at end of file
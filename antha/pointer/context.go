@@ -0,0 +1,240 @@
+// antha-tools/antha/pointer/context.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package pointer
+
+// This file defines the calling contexts used for context-sensitive
+// analysis (k-CFA and object sensitivity).
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+)
+
+// A ContextMode selects the context-sensitivity policy of the analysis.
+type ContextMode int
+
+const (
+	// ContextInsensitive analyses each function once, except for
+	// intrinsics and small accessor functions, which are analysed
+	// anew for each call site.
+	ContextInsensitive ContextMode = iota
+
+	// CallStrings (k-CFA) analyses a function once for each
+	// distinct sequence of the last K call sites leading to it.
+	CallStrings
+
+	// ObjectSensitive analyses a method once for each distinct
+	// receiver object, identified by its allocation site and the
+	// context of the function that allocated it, to a depth of K.
+	// Calls other than method calls are treated as by
+	// ContextInsensitive.
+	ObjectSensitive
+)
+
+// A context is a k-limited sequence of context elements, most recent
+// first.  Each element is the ssa.CallInstruction of a call site
+// (CallStrings) or the allocation data of a receiver object, as for
+// object.data (ObjectSensitive).
+//
+// Contexts are hash-consed by analysis.makeContext, so they may be
+// compared using ==.  The nil *context is the empty context.
+//
+type context struct {
+	elem   interface{}
+	parent *context
+	depth  int // number of elements
+}
+
+type contextKey struct {
+	elem   interface{}
+	parent *context
+}
+
+func (c *context) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for x := c; x != nil; x = x.parent {
+		if x != c {
+			buf.WriteString(", ")
+		}
+		switch elem := x.elem.(type) {
+		case ssa.CallInstruction:
+			fmt.Fprintf(&buf, "%s", elem.Common().Description())
+		case ssa.Value:
+			fmt.Fprintf(&buf, "%s", elem.Name())
+		default:
+			fmt.Fprintf(&buf, "%v", elem)
+		}
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+// makeContext returns the canonical context whose first element is
+// elem and whose remaining elements are those of parent.
+func (a *analysis) makeContext(elem interface{}, parent *context) *context {
+	key := contextKey{elem, parent}
+	c, ok := a.contexts[key]
+	if !ok {
+		c = &context{elem: elem, parent: parent, depth: 1}
+		if parent != nil {
+			c.depth += parent.depth
+		}
+		a.contexts[key] = c
+	}
+	return c
+}
+
+// truncContext returns the context consisting of the first k
+// elements of c.
+func (a *analysis) truncContext(c *context, k int) *context {
+	if c == nil || k <= 0 {
+		return nil
+	}
+	if c.depth <= k {
+		return c
+	}
+	return a.makeContext(c.elem, a.truncContext(c.parent, k-1))
+}
+
+// pushContext returns the k-limited context formed by prepending
+// elem to parent.
+func (a *analysis) pushContext(elem interface{}, parent *context) *context {
+	k := a.config.K
+	if k < 1 {
+		k = 1
+	}
+	return a.makeContext(elem, a.truncContext(parent, k-1))
+}
+
+// calleeContext returns the context for the contour of a function
+// called from site within caller.  recv is the label of the receiver
+// object if known, zero otherwise.  The result is nil if the policy
+// calls for the shared contour.
+//
+func (a *analysis) calleeContext(caller *cgnode, site *callsite, recv nodeid) *context {
+	switch a.config.Context {
+	case CallStrings:
+		if site.instr != nil {
+			return a.pushContext(site.instr, caller.ctx)
+		}
+	case ObjectSensitive:
+		if recv != 0 {
+			obj := a.enclosingObj(recv)
+			var parent *context
+			if obj.cgn != nil {
+				parent = obj.cgn.ctx
+			}
+			return a.pushContext(obj.data, parent)
+		}
+	}
+	return nil
+}
+
+// shouldUseCalleeContext reports whether calls to fn should be
+// analysed in a context given by calleeContext.  Package
+// initializers, functions without bodies, and intrinsics always use a
+// single shared contour.
+//
+func (a *analysis) shouldUseCalleeContext(fn *ssa.Function) bool {
+	if a.config.Context == ContextInsensitive {
+		return false
+	}
+	if fn.Blocks == nil || a.findIntrinsic(fn) != nil {
+		return false
+	}
+	if fn.Pkg != nil && fn == fn.Pkg.Func("init") {
+		return false
+	}
+	return true
+}
+
+// contour returns the function object for the contour of fn in
+// context ctx, creating it if necessary.  A nil context denotes the
+// shared contour.
+//
+func (a *analysis) contour(fn *ssa.Function, ctx *context) nodeid {
+	if ctx == nil {
+		return a.objectNode(nil, fn)
+	}
+	key := contourKey{fn, ctx}
+	obj, ok := a.contours[key]
+	if !ok {
+		obj = a.makeFunctionObject(fn, nil, ctx)
+		a.contours[key] = obj
+	}
+	return obj
+}
+
+type contourKey struct {
+	fn  *ssa.Function
+	ctx *context
+}
+
+// A Context identifies one contour of a function, that is, one of the
+// copies of its constraints that a context-sensitive analysis
+// generates for a particular calling context.
+//
+// The zero Context identifies no particular contour; it is used for
+// results that merge all contexts.
+//
+type Context struct {
+	cgn *cgnode
+}
+
+// Func returns the function of this contour, or nil for the zero Context.
+func (c Context) Func() *ssa.Function {
+	if c.cgn == nil {
+		return nil
+	}
+	return c.cgn.fn
+}
+
+// Elems returns the elements of the calling context of this contour,
+// most recent first.  Under CallStrings, each element is the
+// ssa.CallInstruction of a call site.  Under ObjectSensitive, each is
+// the allocation site of a receiver object, typically an ssa.Value
+// (see Label.Value).  The result is empty for shared contours.
+//
+func (c Context) Elems() []interface{} {
+	var elems []interface{}
+	if c.cgn != nil {
+		for x := c.cgn.ctx; x != nil; x = x.parent {
+			elems = append(elems, x.elem)
+		}
+	}
+	return elems
+}
+
+func (c Context) String() string {
+	if c.cgn == nil {
+		return "(all contexts)"
+	}
+	if c.cgn.ctx == nil {
+		return c.cgn.fn.String()
+	}
+	return c.cgn.fn.String() + " " + c.cgn.ctx.String()
+}
\ No newline at end of file
@@ -57,10 +57,12 @@ not "P must point to L".
 It is FIELD-SENSITIVE: it builds separate points-to sets for distinct
 fields, such as x and y in struct { x, y *int }.
 
-It is mostly CONTEXT-INSENSITIVE: most functions are analyzed once,
-so values can flow in at one call to the function and return out at
-another.  Only some smaller functions are analyzed with consideration
-to their calling context.
+By default it is mostly CONTEXT-INSENSITIVE: most functions are
+analyzed once, so values can flow in at one call to the function and
+return out at another.  Only some smaller functions are analyzed with
+consideration to their calling context.  Config.Context selects a
+k-limited CONTEXT-SENSITIVE policy instead: call strings (k-CFA) or
+receiver objects (object sensitivity).
 
 It has a CONTEXT-SENSITIVE HEAP: objects are named by both allocation
 site and context, so the objects returned by two distinct calls to f:
@@ -191,9 +193,11 @@ Basic types (bool, string, numbers, unsafe.Pointer)
   zero nodeid, and fields of these types within aggregate other types
   are omitted.
 
-  unsafe.Pointer conversions are not yet modelled as pointer
+  By default, unsafe.Pointer conversions are not modelled as pointer
   conversions.  Consequently uintptr is always a number and uintptr
-  nodes do not point to any object.
+  nodes do not point to any object.  Under Config.Unsafe, unsafe.Pointer
+  and uintptr nodes point to the objects from which they were
+  converted; see "Unsafe conversions" below.
 
 Channels
   An expression of type 'chan T' is a kind of pointer that points
@@ -373,11 +377,10 @@ Structs
   the identity node), so the sizes of the fields can be ignored by the
   analysis.
 
-  Sound treatment of unsafe.Pointer conversions (not yet implemented)
-  would require us to model memory layout using physical field offsets
-  to ascertain which object field(s) might be aliased by a given
-  FieldAddr of a different base pointer type.  It would also require
-  us to dispense with the identity node.
+  Sound treatment of unsafe.Pointer conversions (Config.Unsafe)
+  additionally models memory layout using physical field offsets to
+  ascertain which object field(s) might be aliased by a pointer of a
+  different type; see "Unsafe conversions" below.
 
   *ssa.Field y = x.f creates a simple edge to y from x's node at f's offset.
 
@@ -396,12 +399,29 @@ Arrays
   also a pointer to an array's identity node.)  The identity node
   allows us to distinguish a pointer to an array from a pointer to one
   of its elements, but it is rather costly because it introduces more
-  offset constraints into the system.  Furthermore, sound treatment of
-  unsafe.Pointer would require us to dispense with this node.
+  offset constraints into the system.  Since all elements share a node,
+  Config.Unsafe reduces physical offsets within an array modulo the
+  element size.
 
   Arrays may be allocated by Alloc, by make([]T), by calls to append,
   and via reflection.
 
+Unsafe conversions
+  Under Config.Unsafe, the conversions *T->unsafe.Pointer and
+  unsafe.Pointer<->uintptr are copy edges, and uintptr arithmetic
+  x+k, where k is a constant, offsets each label in pts(x) by k bytes
+  within its object (other arithmetic makes it point anywhere within
+  the object).  The conversion y = (*T)(x) of an unsafe.Pointer x
+  creates a complex constraint that, for each label in pts(x),
+  computes its physical offset within its object as laid out on
+  linux/amd64 and finds a node of type T at that offset.  If there is
+  none, the memory is being reinterpreted, and y points to a "view"
+  object of type T whose scalar nodes are linked by copy edges in both
+  directions to the nodes of the underlying object that they overlap.
+
+  Objects without a physical layout (functions, tagged objects, maps
+  and channels) are treated as without Config.Unsafe, with a warning.
+
 Tuples (T, ...)
   Tuples are treated like structs with naturally numbered fields.
   *ssa.Extract is analogous to *ssa.Field.
@@ -437,6 +457,17 @@ FUNCTION CALLS
       source of spurious confluences, though this has not yet been
       evaluated.
 
+      Under Config.Context, the remaining calls to functions with
+      bodies are analysed in a contour for their k-limited context:
+      the last k call sites (CallStrings), or the allocation sites
+      of the receiver object and of its allocator's context
+      (ObjectSensitive).  Contexts are hash-consed, and each
+      (function, context) contour is generated once.  Contours
+      for invoke calls, and for object-sensitive static method
+      calls, depend on points-to sets, so they are created during
+      solving.  Package initializers, intrinsics and calls via
+      func values always use the shared contour.
+
   Dynamic function calls
 
     Dynamic calls work in a similar manner except that the creation of
@@ -0,0 +1,165 @@
+// antha-tools/antha/pointer/export.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package pointer
+
+// This file defines a serialisable form of the solution, so that
+// tools may load a solved analysis without re-running it.
+
+import (
+	"github.com/antha-lang/antha/token"
+	"sort"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// A Solution is a self-contained summary of the points-to sets
+// computed by an analysis, containing no references to the program.
+// It may be encoded using encoding/json or encoding/gob.
+//
+type Solution struct {
+	Labels   []SolutionLabel   `json:"labels"`   // distinct labels, indexed by PointsTo
+	Pointers []SolutionPointer `json:"pointers"` // one per query or DebugRef, by kind and position
+}
+
+// A SolutionLabel describes a Label.
+type SolutionLabel struct {
+	Label string `json:"label"`         // printed form, e.g. "x.y[*].z"
+	Pos   string `json:"pos,omitempty"` // position of the label, if known
+}
+
+// A SolutionPointer describes the points-to set of a queried value,
+// or of the source expression of a DebugRef.
+type SolutionPointer struct {
+	Kind     string `json:"kind"`           // "query", "indirect" or "debugref"
+	Func     string `json:"func,omitempty"` // enclosing function, if any
+	Expr     string `json:"expr"`           // the value's name, or the source expression
+	Pos      string `json:"pos,omitempty"`  // position of the value or expression, if known
+	PointsTo []int  `json:"pts"`            // indices into Solution.Labels
+}
+
+// Export returns a Solution for the points-to sets of r's Queries,
+// IndirectQueries and DebugRefs.  fset is used to print positions.
+//
+func (r *Result) Export(fset *token.FileSet) *Solution {
+	sol := new(Solution)
+	index := make(map[Label]int)
+	add := func(kind string, fn *ssa.Function, expr string, pos token.Pos, p Pointer) {
+		sp := SolutionPointer{
+			Kind: kind,
+			Func: funcString(fn),
+			Expr: expr,
+			Pos:  posString(fset, pos),
+		}
+		for _, l := range p.PointsTo().Labels() {
+			i, ok := index[*l]
+			if !ok {
+				i = len(sol.Labels)
+				index[*l] = i
+				sol.Labels = append(sol.Labels, SolutionLabel{l.String(), posString(fset, l.Pos())})
+			}
+			sp.PointsTo = append(sp.PointsTo, i)
+		}
+		sol.Pointers = append(sol.Pointers, sp)
+	}
+
+	// Visit values in a deterministic order so that label
+	// indices are stable.
+	for _, v := range sortedValues(r.Queries) {
+		add("query", valueFunc(v), v.Name(), v.Pos(), r.Queries[v])
+	}
+	for _, v := range sortedValues(r.IndirectQueries) {
+		add("indirect", valueFunc(v), v.Name(), v.Pos(), r.IndirectQueries[v])
+	}
+	refs := make([]*ssa.DebugRef, 0, len(r.DebugRefs))
+	for ref := range r.DebugRefs {
+		refs = append(refs, ref)
+	}
+	sort.Sort(byDebugRefPos(refs))
+	for _, ref := range refs {
+		add("debugref", ref.Parent(), types.ExprString(ref.Expr), ref.Pos(), r.DebugRefs[ref])
+	}
+	return sol
+}
+
+// posString returns the printed form of pos, or "" if unknown.
+func posString(fset *token.FileSet, pos token.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	return fset.Position(pos).String()
+}
+
+// valueFunc returns the function enclosing v, or nil if v is global.
+func valueFunc(v ssa.Value) *ssa.Function {
+	if p, ok := v.(interface {
+		Parent() *ssa.Function
+	}); ok {
+		return p.Parent()
+	}
+	return nil
+}
+
+// funcString returns the name of fn, or "" if nil.
+func funcString(fn *ssa.Function) string {
+	if fn == nil {
+		return ""
+	}
+	return fn.String()
+}
+
+// sortedValues returns the keys of m ordered by position and name.
+func sortedValues(m map[ssa.Value]Pointer) []ssa.Value {
+	vs := make([]ssa.Value, 0, len(m))
+	for v := range m {
+		vs = append(vs, v)
+	}
+	sort.Sort(byValuePos(vs))
+	return vs
+}
+
+type byValuePos []ssa.Value
+
+func (s byValuePos) Len() int      { return len(s) }
+func (s byValuePos) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byValuePos) Less(i, j int) bool {
+	if s[i].Pos() != s[j].Pos() {
+		return s[i].Pos() < s[j].Pos()
+	}
+	if fi, fj := funcString(valueFunc(s[i])), funcString(valueFunc(s[j])); fi != fj {
+		return fi < fj
+	}
+	return s[i].Name() < s[j].Name()
+}
+
+type byDebugRefPos []*ssa.DebugRef
+
+func (s byDebugRefPos) Len() int      { return len(s) }
+func (s byDebugRefPos) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byDebugRefPos) Less(i, j int) bool {
+	if s[i].Pos() != s[j].Pos() {
+		return s[i].Pos() < s[j].Pos()
+	}
+	return funcString(s[i].Parent()) < funcString(s[j].Parent())
+}
\ No newline at end of file
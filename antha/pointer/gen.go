@@ -78,7 +78,11 @@ func (a *analysis) addNodes(typ types.Type, comment string) nodeid {
 //
 func (a *analysis) addOneNode(typ types.Type, comment string, subelement *fieldInfo) nodeid {
 	id := a.nextNode()
-	a.nodes = append(a.nodes, &node{typ: typ, subelement: subelement})
+	a.nodes = append(a.nodes, &node{
+		typ:        typ,
+		subelement: subelement,
+		solve:      &solverState{members: []nodeid{id}},
+	})
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\tcreate n%d %s for %s%s\n",
 			id, typ, comment, subelement.path())
@@ -109,11 +113,14 @@ func (a *analysis) setValueNode(v ssa.Value, id nodeid, cgn *cgnode) {
 		ptr, ok := a.result.Queries[v]
 		if !ok {
 			// First time?  Create the canonical query node.
-			ptr = Pointer{a, a.addNodes(t, "query")}
+			ptr = Pointer{a, a.addNodes(t, "query"), nil}
 			a.result.Queries[v] = ptr
 		}
 		a.result.Queries[v] = ptr
 		a.copy(ptr.n, id, a.sizeof(t))
+
+		// Also record the context-qualified pointer.
+		a.result.ContextQueries[v] = append(a.result.ContextQueries[v], Pointer{a, id, cgn})
 	}
 
 	// Record the (*v, id) relation if the client has queried pts(*v).
@@ -122,7 +129,7 @@ func (a *analysis) setValueNode(v ssa.Value, id nodeid, cgn *cgnode) {
 		ptr, ok := a.result.IndirectQueries[v]
 		if !ok {
 			// First time? Create the canonical indirect query node.
-			ptr = Pointer{a, a.addNodes(v.Type(), "query.indirect")}
+			ptr = Pointer{a, a.addNodes(v.Type(), "query.indirect"), nil}
 			a.result.IndirectQueries[v] = ptr
 		}
 		a.genLoad(cgn, ptr.n, v, 0, a.sizeof(t))
@@ -158,16 +165,17 @@ func (a *analysis) endObject(obj nodeid, cgn *cgnode, data interface{}) *object
 // enqueues fn for subsequent constraint generation.
 //
 // For a context-sensitive contour, callersite identifies the sole
-// callsite; for shared contours, caller is nil.
+// callsite; for shared contours, caller is nil.  ctx is the calling
+// context of the contour, if any.
 //
-func (a *analysis) makeFunctionObject(fn *ssa.Function, callersite *callsite) nodeid {
+func (a *analysis) makeFunctionObject(fn *ssa.Function, callersite *callsite, ctx *context) nodeid {
 	if a.log != nil {
 		fmt.Fprintf(a.log, "\t---- makeFunctionObject %s\n", fn)
 	}
 
 	// obj is the function object (identity, params, results).
 	obj := a.nextNode()
-	cgn := a.makeCGNode(fn, obj, callersite)
+	cgn := a.makeCGNode(fn, obj, callersite, ctx)
 	sig := fn.Signature
 	a.addOneNode(sig, "func.cgnode", nil) // (scalar with Signature type)
 	if recv := sig.Recv(); recv != nil {
@@ -433,6 +441,34 @@ func (a *analysis) copyElems(cgn *cgnode, typ types.Type, dst, src ssa.Value) {
 
 // ---------- Constraint generation ----------
 
+// debugRefType returns the type of the expression referred to by ref.
+func debugRefType(ref *ssa.DebugRef) types.Type {
+	if ref.IsAddr {
+		return mustDeref(ref.X.Type())
+	}
+	return ref.X.Type()
+}
+
+// genDebugRef records the points-to set of the expression referred
+// to by ref, if pointer-like, in Result.DebugRefs.
+func (a *analysis) genDebugRef(cgn *cgnode, ref *ssa.DebugRef) {
+	t := debugRefType(ref)
+	if !CanPoint(t) {
+		return
+	}
+	ptr, ok := a.result.DebugRefs[ref]
+	if !ok {
+		// First time?  Create the canonical node.
+		ptr = Pointer{a, a.addNodes(t, "debugref"), nil}
+		a.result.DebugRefs[ref] = ptr
+	}
+	if ref.IsAddr {
+		a.genLoad(cgn, ptr.n, ref.X, 0, a.sizeof(t))
+	} else {
+		a.copy(ptr.n, a.valueNode(ref.X), a.sizeof(t))
+	}
+}
+
 // genConv generates constraints for the conversion operation conv.
 func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 	res := a.valueNode(conv)
@@ -451,8 +487,9 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 	case *types.Pointer:
 		// *T -> unsafe.Pointer?
 		if tDst.Underlying() == tUnsafePtr {
-			// ignore for now
-			// a.copy(res, a.valueNode(conv.X), 1)
+			if a.config.Unsafe {
+				a.copy(res, a.valueNode(conv.X), 1)
+			}
 			return
 		}
 
@@ -461,6 +498,11 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 		case *types.Pointer:
 			// unsafe.Pointer -> *T?  (currently unsound)
 			if utSrc == tUnsafePtr {
+				if a.config.Unsafe {
+					a.genUnsafeConv(conv, cgn, res)
+					return
+				}
+
 				// For now, suppress unsafe.Pointer conversion
 				// warnings on "syscall" package.
 				// TODO(adonovan): audit for soundness.
@@ -472,8 +514,8 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 
 				// For now, we treat unsafe.Pointer->*T
 				// conversion like new(T) and create an
-				// unaliased object.  In future we may handle
-				// unsafe conversions soundly; see TODO file.
+				// unaliased object.  Config.Unsafe handles
+				// unsafe conversions soundly; see unsafe.go.
 				obj := a.addNodes(mustDeref(tDst), "unsafe.Pointer conversion")
 				a.endObject(obj, cgn, conv)
 				a.addressOf(tDst, res, obj)
@@ -504,7 +546,12 @@ func (a *analysis) genConv(conv *ssa.Convert, cgn *cgnode) {
 			// TODO(adonovan): we need more work before we can handle
 			// cryptopointers well.
 			if utSrc == tUnsafePtr || utDst == tUnsafePtr {
-				// Ignore for now.  See TODO file for ideas.
+				// Under Config.Unsafe, uintptr values
+				// carry the labels of the pointers from
+				// which they were converted.
+				if a.config.Unsafe {
+					a.copy(res, a.valueNode(conv.X), 1)
+				}
 				return
 			}
 
@@ -633,17 +680,26 @@ func (a *analysis) genStaticCall(caller *cgnode, site *callsite, call *ssa.CallC
 		return
 	}
 
+	sig := call.Signature()
+
 	// Ascertain the context (contour/cgnode) for a particular call.
 	var obj nodeid
 	if a.shouldUseContext(fn) {
-		obj = a.makeFunctionObject(fn, site) // new contour
+		obj = a.makeFunctionObject(fn, site, a.calleeContext(caller, site, 0)) // new contour
+	} else if a.shouldUseCalleeContext(fn) {
+		if recv := sig.Recv(); recv != nil && a.config.Context == ObjectSensitive {
+			if _, ok := recv.Type().Underlying().(*types.Pointer); ok {
+				// The contour depends on the receiver object.
+				a.genObjectSensitiveCall(caller, site, call, result)
+				return
+			}
+		}
+		obj = a.contour(fn, a.calleeContext(caller, site, 0)) // contour for calling context
 	} else {
 		obj = a.objectNode(nil, fn) // shared contour
 	}
 	a.callEdge(caller, site, obj)
 
-	sig := call.Signature()
-
 	// Copy receiver, if any.
 	params := a.funcParams(obj)
 	args := call.Args
@@ -668,6 +724,39 @@ func (a *analysis) genStaticCall(caller *cgnode, site *callsite, call *ssa.CallC
 	}
 }
 
+// genObjectSensitiveCall generates constraints for a static call to a
+// method with a pointer receiver, under ObjectSensitive.  The contour
+// of the callee depends on the receiver object, which is discovered
+// during solving, so the call is made dynamically, like an invoke.
+//
+// A call whose receiver points to nothing (e.g. nil) is not analysed.
+//
+func (a *analysis) genObjectSensitiveCall(caller *cgnode, site *callsite, call *ssa.CallCommon, result nodeid) {
+	fn := call.StaticCallee()
+	sig := fn.Signature
+
+	// Allocate a contiguous targets/params/results block for this call.
+	block := a.nextNode()
+	// pts(targets) will be the set of contours called.
+	site.targets = a.addOneNode(sig, "call.targets", nil)
+	p := a.addNodes(sig.Params(), "call.params")
+	r := a.addNodes(sig.Results(), "call.results")
+
+	// Copy the actual parameters (excluding the receiver) into
+	// the call's params block.
+	for i, n := 0, sig.Params().Len(); i < n; i++ {
+		sz := a.sizeof(sig.Params().At(i).Type())
+		a.copy(p, a.valueNode(call.Args[1+i]), sz)
+		p += nodeid(sz)
+	}
+	// Copy the call's results block to the actual results.
+	if result != 0 {
+		a.copy(result, r, a.sizeof(sig.Results()))
+	}
+
+	a.addConstraint(&objectSensitiveCallConstraint{fn, caller, site, a.valueNode(call.Args[0]), block})
+}
+
 // genDynamicCall generates constraints for a dynamic function call.
 func (a *analysis) genDynamicCall(caller *cgnode, site *callsite, call *ssa.CallCommon, result nodeid) {
 	// pts(targets) will be the set of possible call targets.
@@ -719,7 +808,7 @@ func (a *analysis) genInvoke(caller *cgnode, site *callsite, call *ssa.CallCommo
 	// We add a dynamic invoke constraint that will add
 	// edges from the caller's P/R block to the callee's
 	// P/R block for each discovered call target.
-	a.addConstraint(&invokeConstraint{call.Method, a.valueNode(call.Value), block})
+	a.addConstraint(&invokeConstraint{call.Method, caller, site, a.valueNode(call.Value), block})
 }
 
 // genInvokeReflectType is a specialization of genInvoke where the
@@ -745,7 +834,7 @@ func (a *analysis) genInvokeReflectType(caller *cgnode, site *callsite, call *ss
 	// Look up the concrete method.
 	fn := a.prog.LookupMethod(a.reflectRtypePtr, call.Method.Pkg(), call.Method.Name())
 
-	obj := a.makeFunctionObject(fn, site) // new contour for this call
+	obj := a.makeFunctionObject(fn, site, a.calleeContext(caller, site, 0)) // new contour for this call
 	a.callEdge(caller, site, obj)
 
 	// From now on, it's essentially a static call, but little is
@@ -833,7 +922,7 @@ func (a *analysis) objectNode(cgn *cgnode, v ssa.Value) nodeid {
 				a.endObject(obj, nil, v)
 
 			case *ssa.Function:
-				obj = a.makeFunctionObject(v, nil)
+				obj = a.makeFunctionObject(v, nil, nil)
 
 			case *ssa.Const:
 				// The only pointer-like Consts are nil.
@@ -957,7 +1046,9 @@ func (a *analysis) genInstr(cgn *cgnode, instr ssa.Instruction) {
 
 	switch instr := instr.(type) {
 	case *ssa.DebugRef:
-		// no-op.
+		if a.config.DebugRefs {
+			a.genDebugRef(cgn, instr)
+		}
 
 	case *ssa.UnOp:
 		switch instr.Op {
@@ -974,7 +1065,10 @@ func (a *analysis) genInstr(cgn *cgnode, instr ssa.Instruction) {
 		}
 
 	case *ssa.BinOp:
-		// All no-ops.
+		// All no-ops, except uintptr arithmetic under Config.Unsafe.
+		if a.config.Unsafe && isUintptr(instr.Type()) {
+			a.genUnsafeArith(instr)
+		}
 
 	case ssa.CallInstruction: // *ssa.Call, *ssa.Go, *ssa.Defer
 		a.genCall(cgn, instr)
@@ -1105,8 +1199,8 @@ func (a *analysis) genInstr(cgn *cgnode, instr ssa.Instruction) {
 	}
 }
 
-func (a *analysis) makeCGNode(fn *ssa.Function, obj nodeid, callersite *callsite) *cgnode {
-	cgn := &cgnode{fn: fn, obj: obj, callersite: callersite}
+func (a *analysis) makeCGNode(fn *ssa.Function, obj nodeid, callersite *callsite, ctx *context) *cgnode {
+	cgn := &cgnode{fn: fn, obj: obj, callersite: callersite, ctx: ctx}
 	a.cgnodes = append(a.cgnodes, cgn)
 	return cgn
 }
@@ -1120,7 +1214,7 @@ func (a *analysis) genRootCalls() *cgnode {
 	r.Prog = a.prog // hack.
 	r.Enclosing = r // hack, so Function.String() doesn't crash
 	r.String()      // (asserts that it doesn't crash)
-	root := a.makeCGNode(r, 0, nil)
+	root := a.makeCGNode(r, 0, nil, nil)
 
 	// TODO(adonovan): make an ssa utility to construct an actual
 	// root function so we don't need to special-case site-less
@@ -1265,11 +1359,7 @@ func (a *analysis) generate() {
 	}
 
 	// Generate constraints for entire program.
-	for len(a.genq) > 0 {
-		cgn := a.genq[0]
-		a.genq = a.genq[1:]
-		a.genFunc(cgn)
-	}
+	a.genQueued()
 
 	// The runtime magically allocates os.Args; so should we.
 	if os := a.prog.ImportedPackage("os"); os != nil {
@@ -1280,9 +1370,19 @@ func (a *analysis) generate() {
 		a.addressOf(T, a.objectNode(nil, os.Var("Args")), obj)
 	}
 
-	// Discard generation state, to avoid confusion after node renumbering.
-	a.panicNode = 0
-	a.globalval = nil
+	// Discard local generation state.  The global state is
+	// renumbered and retained, since under context sensitivity
+	// the solver may create new contours.
 	a.localval = nil
 	a.localobj = nil
+}
+
+// genQueued generates constraints for each function contour in the
+// queue, including those enqueued in the process.
+func (a *analysis) genQueued() {
+	for len(a.genq) > 0 {
+		cgn := a.genq[0]
+		a.genq = a.genq[1:]
+		a.genFunc(cgn)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,305 @@
+// antha-tools/antha/pointer/hvn.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+//
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package pointer
+
+// This file implements Hash-Value Numbering (HVN), a pre-solver
+// constraint optimization described in Hardekopf & Lin, SAS'07.
+//
+// HVN computes, for each node, a "pointer equivalence" label such
+// that nodes with equal labels are guaranteed to have equal
+// points-to sets in the solution.  Such nodes then share a single
+// solverState, so that the solver propagates each label once per
+// class rather than once per node.
+//
+// The analysis is performed on the "offline" graph of copy
+// constraints.  A node's label is determined by the labels of its
+// predecessors and by the objects whose address it receives from
+// addrConstraints.  Nodes whose points-to sets may change in ways not
+// represented in the offline graph---object nodes, which are
+// modified by stores, and the destinations of complex constraints,
+// which are modified during solving---are "indirect" and receive a
+// fresh label, so they are equivalent only to themselves and to their
+// copies.
+//
+// Nodes whose label set is empty are known never to point to
+// anything and share the state of node 0.  The solver's final check
+// that pts(0) is empty thus verifies the soundness of this
+// optimization.
+//
+// TODO(adonovan): implement HRU, which also unifies nodes related by
+// load and store constraints, and Location Equivalence.
+
+import (
+	"fmt"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha-tools/container/intsets"
+)
+
+// An hvn holds the state of the HVN computation.
+type hvn struct {
+	a        *analysis
+	preds    [][]nodeid     // copy edge predecessors of each node
+	addrs    [][]nodeid     // objects whose address each node receives
+	indirect []bool         // whether each node is indirect
+	peLabel  []int          // pointer-equivalence label of each node; 0 => empty
+	index    []int          // Tarjan: discovery index of each node, or 0 if unvisited
+	lowlink  []int          // Tarjan: lowlink of each node
+	stack    []nodeid       // Tarjan: stack of nodes in current components
+	onStack  []bool         // Tarjan: whether each node is on the stack
+	nextIdx  int            // Tarjan: next discovery index
+	nextPE   int            // next unused label
+	objLabel map[nodeid]int // label for the address of each object
+	setLabel map[string]int // label for each set of two or more labels
+	space    [50]int        // working space for AppendTo
+	labels   intsets.Sparse // working label set of the current component
+}
+
+// hvn computes pointer equivalence classes of nodes and arranges for
+// all nodes in the same class to share a solverState.
+//
+// It must be called after renumber and before the first call to
+// processNewConstraints.
+func (a *analysis) hvn() {
+	N := len(a.nodes)
+	h := &hvn{
+		a:        a,
+		preds:    make([][]nodeid, N),
+		addrs:    make([][]nodeid, N),
+		indirect: make([]bool, N),
+		peLabel:  make([]int, N),
+		index:    make([]int, N),
+		lowlink:  make([]int, N),
+		onStack:  make([]bool, N),
+		nextIdx:  1,
+		nextPE:   1,
+		objLabel: make(map[nodeid]int),
+		setLabel: make(map[string]int),
+	}
+
+	// All object nodes are indirect: they are modified by stores.
+	for id := 1; id < N; {
+		obj := a.nodes[id].obj
+		if obj == nil {
+			id++
+			continue
+		}
+		for end := id + int(obj.size); id < end; id++ {
+			h.indirect[id] = true
+		}
+	}
+
+	// Under context sensitivity, contours created during solving
+	// add constraints to existing nodes other than objects: the
+	// value nodes of globals (e.g. captures set by MakeClosure),
+	// the panic node, and query and DebugRef nodes.
+	if a.config.Context != ContextInsensitive {
+		for _, id := range a.globalval {
+			h.indirect[id] = true
+		}
+		h.indirect[a.panicNode] = true
+		for v, ptr := range a.result.Queries {
+			h.markBlock(ptr.n, a.sizeof(v.Type()))
+		}
+		for v, ptr := range a.result.IndirectQueries {
+			h.markBlock(ptr.n, a.sizeof(mustDeref(v.Type())))
+		}
+		for ref, ptr := range a.result.DebugRefs {
+			h.markBlock(ptr.n, a.sizeof(debugRefType(ref)))
+		}
+	}
+
+	// Build the offline graph.
+	for _, c := range a.constraints {
+		switch c := c.(type) {
+		case *addrConstraint:
+			h.addrs[c.dst] = append(h.addrs[c.dst], c.src)
+		case *copyConstraint:
+			h.preds[c.dst] = append(h.preds[c.dst], c.src)
+		default:
+			h.markIndirect(c)
+		}
+	}
+
+	// Compute labels, visiting each component after its predecessors.
+	for id := 0; id < N; id++ {
+		if h.index[id] == 0 {
+			h.visit(nodeid(id))
+		}
+	}
+
+	// Share solver states among nodes of the same class.
+	// Node 0 represents the class of the empty set.
+	if h.peLabel[0] != 0 {
+		panic("internal error: node 0 may point to something")
+	}
+	reps := make(map[int]*solverState)
+	reps[0] = a.nodes[0].solve
+	for id := 1; id < N; id++ {
+		n := a.nodes[id]
+		pe := h.peLabel[id]
+		if s := reps[pe]; s != nil {
+			n.solve = s
+			s.members = append(s.members, nodeid(id))
+		} else {
+			reps[pe] = n.solve
+		}
+	}
+
+	if a.log != nil {
+		fmt.Fprintf(a.log, "HVN: %d nodes in %d classes\n", N, len(reps))
+	}
+}
+
+// markIndirect marks as indirect each node that the complex
+// constraint c may modify during solving.
+func (h *hvn) markIndirect(c constraint) {
+	a := h.a
+	switch c := c.(type) {
+	case *untagConstraint:
+		// The payload of the tagged object is copied to a block.
+		h.markBlock(c.dst, a.sizeof(c.typ))
+
+	case *invokeConstraint:
+		// The callee's identity and results are written to
+		// the params/results block.
+		sig := c.method.Type().(*types.Signature)
+		h.markBlock(c.params, 1+a.sizeof(sig.Params())+a.sizeof(sig.Results()))
+
+	case *objectSensitiveCallConstraint:
+		// The contour's identity and results are written to
+		// the params/results block.
+		sig := c.fn.Signature
+		h.markBlock(c.params, 1+a.sizeof(sig.Params())+a.sizeof(sig.Results()))
+
+	case *runtimeSetFinalizerConstraint:
+		h.indirect[c.targets] = true
+
+	default:
+		var space [2]nodeid
+		for _, id := range c.indirect(space[:0]) {
+			h.indirect[id] = true
+		}
+	}
+}
+
+// markBlock marks as indirect the size nodes starting at id.
+func (h *hvn) markBlock(id nodeid, size uint32) {
+	for i := uint32(0); i < size; i++ {
+		h.indirect[id+nodeid(i)] = true
+	}
+}
+
+// visit implements Tarjan's SCC algorithm over the predecessor
+// graph, so each component is labelled after all of its
+// predecessors.
+func (h *hvn) visit(x nodeid) {
+	h.index[x] = h.nextIdx
+	h.lowlink[x] = h.nextIdx
+	h.nextIdx++
+	h.stack = append(h.stack, x)
+	h.onStack[x] = true
+
+	for _, y := range h.preds[x] {
+		if h.index[y] == 0 {
+			h.visit(y)
+			if h.lowlink[y] < h.lowlink[x] {
+				h.lowlink[x] = h.lowlink[y]
+			}
+		} else if h.onStack[y] && h.index[y] < h.lowlink[x] {
+			h.lowlink[x] = h.index[y]
+		}
+	}
+
+	if h.lowlink[x] != h.index[x] {
+		return // x is not the root of a component
+	}
+
+	// Pop the component and compute the union of its labels.
+	h.labels.Clear()
+	var scc []nodeid
+	indirect := false
+	for {
+		y := h.stack[len(h.stack)-1]
+		h.stack = h.stack[:len(h.stack)-1]
+		h.onStack[y] = false
+		scc = append(scc, y)
+		if h.indirect[y] {
+			indirect = true
+		}
+		for _, obj := range h.addrs[y] {
+			h.labels.Insert(h.addrLabel(obj))
+		}
+		if y == x {
+			break
+		}
+	}
+	for _, y := range scc {
+		for _, z := range h.preds[y] {
+			// Members of this component are unlabelled (0)
+			// since their label is not yet known.
+			if pe := h.peLabel[z]; pe != 0 {
+				h.labels.Insert(pe)
+			}
+		}
+	}
+	if indirect {
+		h.labels.Insert(h.fresh())
+	}
+
+	var pe int
+	switch h.labels.Len() {
+	case 0:
+		pe = 0 // points to nothing
+	case 1:
+		pe = h.labels.Min() // equivalent to its sole source
+	default:
+		key := fmt.Sprint(h.labels.AppendTo(h.space[:0]))
+		pe = h.setLabel[key]
+		if pe == 0 {
+			pe = h.fresh()
+			h.setLabel[key] = pe
+		}
+	}
+	for _, y := range scc {
+		h.peLabel[y] = pe
+	}
+}
+
+// addrLabel returns the label of the set {obj}.
+func (h *hvn) addrLabel(obj nodeid) int {
+	pe := h.objLabel[obj]
+	if pe == 0 {
+		pe = h.fresh()
+		h.objLabel[obj] = pe
+	}
+	return pe
+}
+
+// fresh returns a new label.
+func (h *hvn) fresh() int {
+	pe := h.nextPE
+	h.nextPE++
+	return pe
+}
\ No newline at end of file
@@ -171,12 +171,12 @@ func init() {
 		"sync/atomic.CompareAndSwapUintptr":     ext۰NoEffect,
 		"sync/atomic.LoadInt32":                 ext۰NoEffect,
 		"sync/atomic.LoadInt64":                 ext۰NoEffect,
-		"sync/atomic.LoadPointer":               ext۰NoEffect, // ignore unsafe.Pointer for now
+		"sync/atomic.LoadPointer":               ext۰sync۰atomic۰LoadPointer,
 		"sync/atomic.LoadUint32":                ext۰NoEffect,
 		"sync/atomic.LoadUint64":                ext۰NoEffect,
 		"sync/atomic.LoadUintptr":               ext۰NoEffect,
 		"sync/atomic.StoreInt32":                ext۰NoEffect,
-		"sync/atomic.StorePointer":              ext۰NoEffect, // ignore unsafe.Pointer for now
+		"sync/atomic.StorePointer":              ext۰sync۰atomic۰StorePointer,
 		"sync/atomic.StoreUint32":               ext۰NoEffect,
 		"sync/atomic.StoreUintptr":              ext۰NoEffect,
 		"syscall.Close":                         ext۰NoEffect,
@@ -291,8 +291,10 @@ func (c *runtimeSetFinalizerConstraint) String() string {
 	return fmt.Sprintf("runtime.SetFinalizer(n%d, n%d)", c.x, c.f)
 }
 
-func (c *runtimeSetFinalizerConstraint) solve(a *analysis, _ *node, delta nodeset) {
-	for fObj := range delta {
+func (c *runtimeSetFinalizerConstraint) solve(a *analysis, delta *nodeset) {
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		fObj := nodeid(x)
 		tDyn, f, indirect := a.taggedValue(fObj)
 		if indirect {
 			// TODO(adonovan): we'll need to implement this
@@ -336,4 +338,24 @@ func ext۰runtime۰SetFinalizer(a *analysis, cgn *cgnode) {
 		x:       params,
 		f:       params + 1,
 	})
+}
+
+// ---------- func sync/atomic.{Load,Store}Pointer ----------
+
+// These are no-ops unless Config.Unsafe is set, as unsafe.Pointer
+// values are otherwise ignored.
+
+// sync/atomic.LoadPointer(addr *unsafe.Pointer) unsafe.Pointer
+func ext۰sync۰atomic۰LoadPointer(a *analysis, cgn *cgnode) {
+	if a.config.Unsafe {
+		a.load(a.funcResults(cgn.obj), a.funcParams(cgn.obj), 0, 1)
+	}
+}
+
+// sync/atomic.StorePointer(addr *unsafe.Pointer, val unsafe.Pointer)
+func ext۰sync۰atomic۰StorePointer(a *analysis, cgn *cgnode) {
+	if a.config.Unsafe {
+		params := a.funcParams(cgn.obj)
+		a.store(params, params+1, 0, 1)
+	}
 }
\ No newline at end of file
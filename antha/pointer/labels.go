@@ -64,6 +64,14 @@ func (l Label) Value() ssa.Value {
 	return val
 }
 
+// Context returns the contour in which this label's object was
+// allocated, or the zero Context for global and intrinsic objects.
+// For a function object, it is the contour of the function itself.
+//
+func (l Label) Context() Context {
+	return Context{l.obj.cgn}
+}
+
 // ReflectType returns the type represented by this label if it is an
 // reflect.rtype instance object or *reflect.rtype-tagged object.
 //
@@ -29,11 +29,22 @@ import (
 	"fmt"
 )
 
+// optimizeSolver enables the optimisations that let nodes share
+// solver state: HVN before solving, and cycle collapsing during it.
+// They must not change the solution; the tests check this by
+// comparing the results with and without them.
+var optimizeSolver = true
+
 func (a *analysis) optimize() {
 	a.renumber()
 
-	// TODO(adonovan): opt:
-	// PE, LE, HVN, HRU, sparse bitsets, etc.
+	// Share solver state among nodes with provably equal
+	// points-to sets.
+	if optimizeSolver {
+		a.hvn()
+	}
+
+	// TODO(adonovan): opt: HRU, LE, HCD.
 }
 
 // renumber permutes a.nodes so that all nodes within an addressable
@@ -120,11 +131,29 @@ func (a *analysis) renumber() {
 		ptr.n = renumbering[ptr.n]
 		a.result.IndirectQueries[v] = ptr
 	}
+	for ref, ptr := range a.result.DebugRefs {
+		ptr.n = renumbering[ptr.n]
+		a.result.DebugRefs[ref] = ptr
+	}
+	for _, ptrs := range a.result.ContextQueries {
+		for i := range ptrs {
+			ptrs[i].n = renumbering[ptrs[i].n]
+		}
+	}
 
-	// Renumber nodeids in global objects.
+	// Renumber nodeids in global values and objects.
+	for v, id := range a.globalval {
+		a.globalval[v] = renumbering[id]
+	}
 	for v, id := range a.globalobj {
 		a.globalobj[v] = renumbering[id]
 	}
+	a.panicNode = renumbering[a.panicNode]
+
+	// Renumber nodeids in context-sensitive contours.
+	for k, id := range a.contours {
+		a.contours[k] = renumbering[id]
+	}
 
 	// Renumber nodeids in constraints.
 	for _, c := range a.constraints {
@@ -140,4 +169,14 @@ func (a *analysis) renumber() {
 	}
 
 	a.nodes = newNodes
+
+	// Renumber nodeids in solver states.
+	for id, n := range a.nodes {
+		for i, m := range n.solve.members {
+			n.solve.members[i] = renumbering[m]
+		}
+		if n.solve.members[0] != nodeid(id) {
+			panic(fmt.Sprintf("internal error: n%d has foreign solver state", id))
+		}
+	}
 }
\ No newline at end of file
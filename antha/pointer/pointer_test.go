@@ -29,13 +29,17 @@ package pointer_test
 
 import (
 	"bytes"
+	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/antha-lang/antha/parser"
 	"github.com/antha-lang/antha/token"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -566,6 +570,111 @@ func TestInput(t *testing.T) {
 	}
 }
 
+// TestSolverOptimizations checks that the solver's optimisations,
+// which share solver state between nodes, do not change the points-to
+// sets or call graph computed for the test inputs.
+func TestSolverOptimizations(t *testing.T) {
+	for _, filename := range inputs {
+		conf := loader.Config{SourceImports: true}
+		f, err := conf.ParseFile(filename, nil)
+		if err != nil {
+			t.Errorf("%s: %s", filename, err)
+			continue
+		}
+		conf.CreateFromFiles("main", f)
+		iprog, err := conf.Load()
+		if err != nil {
+			t.Errorf("%s: %s", filename, err)
+			continue
+		}
+		prog := ssa.Create(iprog, 0)
+		prog.BuildAll()
+		mainpkg := prog.Package(iprog.Created[0].Pkg)
+		if mainpkg.Func("main") == nil {
+			mainpkg = prog.CreateTestMainPackage(mainpkg)
+		}
+
+		var got [2][]string
+		for i, optimize := range []bool{true, false} {
+			old := pointer.SetOptimizeSolver(optimize)
+			got[i], err = solution(prog, mainpkg)
+			pointer.SetOptimizeSolver(old)
+			if err != nil {
+				t.Errorf("%s: %s", filename, err)
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		if len(got[0]) != len(got[1]) {
+			t.Errorf("%s: %d facts with optimisations, %d without", filename, len(got[0]), len(got[1]))
+			continue
+		}
+		for i := range got[0] {
+			if got[0][i] != got[1][i] {
+				t.Errorf("%s: with optimisations %s, without %s", filename, got[0][i], got[1][i])
+				break
+			}
+		}
+	}
+}
+
+// solution analyzes the program whose main package is mainpkg and
+// returns, in order, the points-to set of each pointer-like value of
+// the main package and each edge of the call graph.
+func solution(prog *ssa.Program, mainpkg *ssa.Package) ([]string, error) {
+	config := &pointer.Config{
+		Reflection:     true,
+		BuildCallGraph: true,
+		Mains:          []*ssa.Package{mainpkg},
+	}
+	names := make(map[ssa.Value]string) // maps each queried value to its name
+	for fn := range ssautil.AllFunctions(prog) {
+		if fn.Pkg != mainpkg {
+			continue
+		}
+		var values []ssa.Value
+		for _, p := range fn.Params {
+			values = append(values, p)
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if v, ok := instr.(ssa.Value); ok {
+					values = append(values, v)
+				}
+			}
+		}
+		for _, v := range values {
+			if pointer.CanPoint(v.Type()) {
+				names[v] = fmt.Sprintf("%s.%s", fn, v.Name())
+				config.AddQuery(v)
+			}
+		}
+	}
+
+	result, err := pointer.Analyze(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var facts []string
+	for v, ptr := range result.Queries {
+		var labels []string
+		for _, l := range ptr.PointsTo().Labels() {
+			labels = append(labels, fmt.Sprintf("%s@%s", l, prog.Fset.Position(l.Pos())))
+		}
+		sort.Strings(labels)
+		facts = append(facts, fmt.Sprintf("pts(%s) = {%s}", names[v], strings.Join(labels, ", ")))
+	}
+	callgraph.GraphVisitEdges(result.CallGraph, func(e *callgraph.Edge) error {
+		facts = append(facts, fmt.Sprintf("%s --> %s", e.Caller.Func, e.Callee.Func))
+		return nil
+	})
+	sort.Strings(facts)
+	return facts, nil
+}
+
 // join joins the elements of multiset with " | "s.
 func join(set map[string]int) string {
 	var buf bytes.Buffer
@@ -589,4 +698,358 @@ func split(s, sep string) (r []string) {
 		}
 	}
 	return
-}
\ No newline at end of file
+}
+
+const contextInput = `package main
+
+type T int
+
+func transfer(p *int) *int {
+	if p == nil {
+		panic("nil")
+	}
+	return p
+}
+
+func (t *T) id(p *int) *int {
+	if t == nil {
+		return nil
+	}
+	return p
+}
+
+func main() {
+	var a, b int
+	t1, t2 := new(T), new(T)
+	print(transfer(&a), transfer(&b), t1.id(&a), t2.id(&b))
+}
+`
+
+// TestContextSensitivity checks the precision of each context
+// policy on calls to a shared helper function and method.
+func TestContextSensitivity(t *testing.T) {
+	for _, test := range []struct {
+		mode     pointer.ContextMode
+		precise  map[string]bool // callee name -> whether results are unmerged
+		contexts int             // expected number of contexts of transfer's param
+	}{
+		{pointer.ContextInsensitive, map[string]bool{"transfer": false, "id": false}, 1},
+		{pointer.CallStrings, map[string]bool{"transfer": true, "id": true}, 2},
+		{pointer.ObjectSensitive, map[string]bool{"transfer": false, "id": true}, 1},
+	} {
+		conf := loader.Config{SourceImports: true}
+		f, err := conf.ParseFile("context.go", contextInput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles("main", f)
+		iprog, err := conf.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog := ssa.Create(iprog, 0)
+		prog.BuildAll()
+		mainpkg := prog.Package(iprog.Created[0].Pkg)
+
+		config := &pointer.Config{
+			Mains:   []*ssa.Package{mainpkg},
+			Context: test.mode,
+		}
+		var calls []*ssa.Call
+		for _, b := range mainpkg.Func("main").Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok && call.Call.StaticCallee() != nil {
+					calls = append(calls, call)
+					config.AddQuery(call)
+				}
+			}
+		}
+		param := mainpkg.Func("transfer").Params[0]
+		config.AddQuery(param)
+
+		result, err := pointer.Analyze(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, call := range calls {
+			callee := call.Call.StaticCallee().Name()
+			labels := result.Queries[call].PointsTo().Labels()
+			if got, want := len(labels) == 1, test.precise[callee]; got != want {
+				t.Errorf("mode %d: %s: got %d labels, want precise=%t", test.mode, call, len(labels), want)
+			}
+		}
+		if got := len(result.ContextQueries[param]); got != test.contexts {
+			t.Errorf("mode %d: got %d contexts of %s, want %d", test.mode, got, param, test.contexts)
+		}
+		for _, ptr := range result.ContextQueries[param] {
+			if ptr.Context().Func() != param.Parent() {
+				t.Errorf("mode %d: %s: context %s is not a contour of %s", test.mode, param, ptr.Context(), param.Parent())
+			}
+		}
+	}
+}
+
+const unsafeInput = `package main
+
+import "unsafe"
+
+type S struct {
+	x, y *int
+}
+
+type P struct {
+	a, b *int
+}
+
+func main() {
+	var i, j int
+	s := &S{&i, &j}
+	p := (*P)(unsafe.Pointer(s))
+	py := (**int)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + unsafe.Offsetof(s.y)))
+	q := (*int)(unsafe.Pointer(&i))
+	buf := (*[16]byte)(unsafe.Pointer(s))
+	s2 := (*S)(unsafe.Pointer(buf))
+	print(p.b, *py, q, s2.x)
+}
+`
+
+// TestUnsafe checks the treatment of unsafe.Pointer conversions
+// with and without Config.Unsafe.
+func TestUnsafe(t *testing.T) {
+	for _, test := range []struct {
+		unsafe   bool
+		want     []string // for each print operand, a variable it must point to
+		exact    []bool   // for each print operand, whether it must point only there
+		warnings int
+	}{
+		{false, []string{"", "", "", ""}, []bool{false, false, false, false}, 5},
+		{true, []string{"j", "j", "i", "i"}, []bool{true, true, true, false}, 0},
+	} {
+		conf := loader.Config{SourceImports: true}
+		f, err := conf.ParseFile("unsafe.go", unsafeInput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.CreateFromFiles("main", f)
+		iprog, err := conf.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog := ssa.Create(iprog, 0)
+		prog.BuildAll()
+		mainpkg := prog.Package(iprog.Created[0].Pkg)
+
+		config := &pointer.Config{
+			Mains:  []*ssa.Package{mainpkg},
+			Unsafe: test.unsafe,
+		}
+		var args []ssa.Value
+		for _, b := range mainpkg.Func("main").Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok {
+					if _, ok := call.Call.Value.(*ssa.Builtin); ok {
+						args = call.Call.Args
+					}
+				}
+			}
+		}
+		for _, arg := range args {
+			config.AddQuery(arg)
+		}
+
+		result, err := pointer.Analyze(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := len(result.Warnings); got != test.warnings {
+			t.Errorf("Unsafe=%t: got %d warnings, want %d", test.unsafe, got, test.warnings)
+		}
+		for i, arg := range args {
+			var names []string
+			found := false
+			for _, l := range result.Queries[arg].PointsTo().Labels() {
+				name := "?"
+				if alloc, ok := l.Value().(*ssa.Alloc); ok {
+					name = alloc.Comment
+				}
+				names = append(names, name)
+				if name == test.want[i] {
+					found = true
+				}
+			}
+			if test.want[i] != "" && !found {
+				t.Errorf("Unsafe=%t: print operand #%d points to %v, want %s", test.unsafe, i, names, test.want[i])
+			}
+			if test.exact[i] && len(names) != 1 {
+				t.Errorf("Unsafe=%t: print operand #%d points to %v, want only %s", test.unsafe, i, names, test.want[i])
+			}
+		}
+	}
+}
+
+const exportInput = `package main
+
+type T struct {
+	p *int
+}
+
+func main() {
+	var x, y int
+	t := &T{&x}
+	q := &y
+	t.p = q
+	print(t.p)
+}
+`
+
+// TestExport checks Config.DebugRefs and the serialisation of the
+// solution.
+func TestExport(t *testing.T) {
+	conf := loader.Config{SourceImports: true}
+	f, err := conf.ParseFile("export.go", exportInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssa.Create(iprog, ssa.GlobalDebug)
+	prog.BuildAll()
+	mainpkg := prog.Package(iprog.Created[0].Pkg)
+
+	result, err := pointer.Analyze(&pointer.Config{
+		Mains:     []*ssa.Package{mainpkg},
+		DebugRefs: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Union the pts of all references to each expression.
+	got := make(map[string]map[string]bool)
+	for ref, ptr := range result.DebugRefs {
+		e := types.ExprString(ref.Expr)
+		if got[e] == nil {
+			got[e] = make(map[string]bool)
+		}
+		for _, l := range ptr.PointsTo().Labels() {
+			got[e][l.String()] = true
+		}
+	}
+	for e, want := range map[string][]string{
+		"q":   {"y"},
+		"t":   {"complit"},
+		"t.p": {"x", "y"},
+	} {
+		if len(got[e]) != len(want) {
+			t.Errorf("pts(%s) = %v, want %v", e, got[e], want)
+			continue
+		}
+		for _, l := range want {
+			if !got[e][l] {
+				t.Errorf("pts(%s) = %v, want %v", e, got[e], want)
+			}
+		}
+	}
+
+	sol := result.Export(prog.Fset)
+	if len(sol.Pointers) != len(result.DebugRefs) {
+		t.Errorf("Export: got %d pointers, want %d", len(sol.Pointers), len(result.DebugRefs))
+	}
+	for _, p := range sol.Pointers {
+		if p.Kind != "debugref" || p.Func != "main.main" || p.Pos == "" {
+			t.Errorf("Export: bad pointer %+v", p)
+		}
+		for _, i := range p.PointsTo {
+			if i < 0 || i >= len(sol.Labels) {
+				t.Errorf("Export: %s: label index %d out of range", p.Expr, i)
+			}
+		}
+	}
+	if again := result.Export(prog.Fset); !reflect.DeepEqual(sol, again) {
+		t.Errorf("Export is not deterministic")
+	}
+
+	// Round-trip through each encoding.
+	var jsonSol, gobSol pointer.Solution
+	data, err := json.Marshal(sol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &jsonSol); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sol, &jsonSol) {
+		t.Errorf("JSON round trip: got %+v, want %+v", jsonSol, *sol)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sol); err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewDecoder(&buf).Decode(&gobSol); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sol, &gobSol) {
+		t.Errorf("gob round trip: got %+v, want %+v", gobSol, *sol)
+	}
+}
+
+// stdlibPkgs are the standard packages whose tests form the program
+// analysed by the benchmarks.
+var stdlibPkgs = []string{
+	"bytes",
+	"encoding/json",
+	"fmt",
+	"go/parser",
+	"net/http",
+	"sort",
+	"strconv",
+	"text/template",
+}
+
+// benchmarkStdlib measures whole-program analysis of the tests of
+// stdlibPkgs.  Compare results across revisions with benchcmp.
+func benchmarkStdlib(b *testing.B, reflection bool) {
+	conf := loader.Config{SourceImports: true}
+	for _, path := range stdlibPkgs {
+		if err := conf.ImportWithTests(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+	iprog, err := conf.Load()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	prog := ssa.Create(iprog, 0)
+	prog.BuildAll()
+
+	var pkgs []*ssa.Package
+	for _, info := range iprog.InitialPackages() {
+		pkgs = append(pkgs, prog.Package(info.Pkg))
+	}
+	testmain := prog.CreateTestMainPackage(pkgs...)
+	if testmain == nil {
+		b.Fatal("no tests")
+	}
+
+	config := &pointer.Config{
+		Reflection:     reflection,
+		BuildCallGraph: true,
+		Mains:          []*ssa.Package{testmain},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pointer.Analyze(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStdlib(b *testing.B)           { benchmarkStdlib(b, false) }
+func BenchmarkStdlibReflection(b *testing.B) { benchmarkStdlib(b, true) }
\ No newline at end of file
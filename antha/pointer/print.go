@@ -57,6 +57,10 @@ func (c *invokeConstraint) String() string {
 	return fmt.Sprintf("invoke n%d.%s(n%d ...)", c.iface, c.method.Name(), c.params+1)
 }
 
+func (c *objectSensitiveCallConstraint) String() string {
+	return fmt.Sprintf("call n%d.%s(n%d ...)", c.recv, c.fn.Name(), c.params+1)
+}
+
 func (n nodeid) String() string {
 	return fmt.Sprintf("n%d", n)
 }
\ No newline at end of file
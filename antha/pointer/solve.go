@@ -23,8 +23,9 @@
 
 package pointer
 
-// This file defines a naive Andersen-style solver for the inclusion
-// constraint system.
+// This file defines an Andersen-style solver for the inclusion
+// constraint system, using difference propagation and lazy cycle
+// detection (Hardekopf & Lin, PLDI'07) over sparse bit vectors.
 
 import (
 	"fmt"
@@ -32,6 +33,10 @@ import (
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
+// lcdBatchSize is the number of candidate cycles that the solver
+// accumulates before searching for them all at once.
+const lcdBatchSize = 100
+
 func (a *analysis) solve() {
 	// Solver main loop.
 	for round := 1; ; round++ {
@@ -39,9 +44,14 @@ func (a *analysis) solve() {
 			fmt.Fprintf(a.log, "Solving, round %d\n", round)
 		}
 
+		// Generate constraints for contours created during
+		// solving by context-sensitive calls.
+		a.genQueued()
+
 		// Add new constraints to the graph:
 		// static constraints from SSA on round 1,
-		// dynamic constraints from reflection thereafter.
+		// dynamic constraints from reflection and new contours
+		// thereafter.
 		a.processNewConstraints()
 
 		id := a.work.take()
@@ -52,25 +62,34 @@ func (a *analysis) solve() {
 			fmt.Fprintf(a.log, "\tnode n%d\n", id)
 		}
 
-		n := a.nodes[id]
+		n := a.nodes[id].solve
 
 		// Difference propagation.
-		delta := n.pts.diff(n.prevPts)
-		if delta == nil {
+		var delta nodeset
+		delta.Difference(&n.pts.Sparse, &n.prevPts.Sparse)
+		if delta.IsEmpty() {
 			continue
 		}
-		n.prevPts = n.pts.clone()
+		n.prevPts.Copy(&n.pts.Sparse)
 
 		// Apply all resolution rules attached to n.
-		a.solveConstraints(n, delta)
+		a.solveConstraints(n, &delta)
 
 		if a.log != nil {
-			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, n.pts)
+			fmt.Fprintf(a.log, "\t\tpts(n%d) = %s\n", id, &n.pts)
+		}
+
+		// Periodically collapse any cycles revealed by
+		// copy edges that failed to grow their destination.
+		if len(a.lcdQueue) >= lcdBatchSize {
+			roots := a.lcdQueue
+			a.lcdQueue = nil
+			a.collapseCycles(roots)
 		}
 	}
 
-	if len(a.nodes[0].pts) > 0 {
-		panic(fmt.Sprintf("pts(0) is nonempty: %s", a.nodes[0].pts))
+	if !a.nodes[0].solve.pts.IsEmpty() {
+		panic(fmt.Sprintf("pts(0) is nonempty: %s", &a.nodes[0].solve.pts))
 	}
 
 	if a.log != nil {
@@ -78,8 +97,8 @@ func (a *analysis) solve() {
 
 		// Dump solution.
 		for i, n := range a.nodes {
-			if n.pts != nil {
-				fmt.Fprintf(a.log, "pts(n%d) = %s : %s\n", i, n.pts, n.typ)
+			if !n.solve.pts.IsEmpty() {
+				fmt.Fprintf(a.log, "pts(n%d) = %s : %s\n", i, &n.solve.pts, n.typ)
 			}
 		}
 	}
@@ -99,14 +118,14 @@ func (a *analysis) processNewConstraints() {
 	// Initialize points-to sets from addr-of (base) constraints.
 	for _, c := range constraints {
 		if c, ok := c.(*addrConstraint); ok {
-			dst := a.nodes[c.dst]
+			dst := a.nodes[c.dst].solve
 			dst.pts.add(c.src)
 
 			// Populate the worklist with nodes that point to
 			// something initially (due to addrConstraints) and
 			// have other constraints attached.
 			// (A no-op in round 1.)
-			if dst.copyTo != nil || dst.complex != nil {
+			if !dst.copyTo.IsEmpty() || dst.complex != nil {
 				a.addWork(c.dst)
 			}
 		}
@@ -123,33 +142,37 @@ func (a *analysis) processNewConstraints() {
 		case *copyConstraint:
 			// simple (copy) constraint
 			id = c.src
-			a.nodes[id].copyTo.add(c.dst)
+			if a.nodes[c.dst].solve == a.nodes[id].solve {
+				continue // self-edge, e.g. between pointer-equivalent nodes
+			}
+			a.nodes[id].solve.copyTo.add(c.dst)
 		default:
 			// complex constraint
 			id = c.ptr()
-			a.nodes[id].complex.add(c)
+			a.nodes[id].solve.complex.add(c)
 		}
 
-		if n := a.nodes[id]; len(n.pts) > 0 {
-			if len(n.prevPts) > 0 {
+		if n := a.nodes[id].solve; !n.pts.IsEmpty() {
+			if !n.prevPts.IsEmpty() {
 				stale.add(id)
 			}
 			a.addWork(id)
 		}
 	}
 	// Apply new constraints to pre-existing PTS labels.
-	for id := range stale {
-		n := a.nodes[id]
-		a.solveConstraints(n, n.prevPts)
+	var space [50]int
+	for _, id := range stale.AppendTo(space[:0]) {
+		n := a.nodes[nodeid(id)].solve
+		a.solveConstraints(n, &n.prevPts)
 	}
 }
 
-// solveConstraints applies each resolution rule attached to node n to
-// the set of labels delta.  It may generate new constraints in
-// a.constraints.
+// solveConstraints applies each resolution rule attached to the
+// solver state n to the set of labels delta.  It may generate new
+// constraints in a.constraints.
 //
-func (a *analysis) solveConstraints(n *node, delta nodeset) {
-	if delta == nil {
+func (a *analysis) solveConstraints(n *solverState, delta *nodeset) {
+	if delta.IsEmpty() {
 		return
 	}
 
@@ -158,25 +181,155 @@ func (a *analysis) solveConstraints(n *node, delta nodeset) {
 		if a.log != nil {
 			fmt.Fprintf(a.log, "\t\tconstraint %s\n", c)
 		}
-		// TODO(adonovan): parameter n is never needed, since
-		// it's equal to c.ptr().  Remove.
-		c.solve(a, n, delta)
+		c.solve(a, delta)
 	}
 
 	// Process copy constraints.
-	var copySeen nodeset
-	for mid := range n.copyTo {
-		if copySeen.add(mid) {
-			if a.nodes[mid].pts.addAll(delta) {
-				a.addWork(mid)
+	var space [50]int
+	for _, x := range n.copyTo.AppendTo(space[:0]) {
+		mid := nodeid(x)
+		m := a.nodes[mid].solve
+		if m == n {
+			continue
+		}
+		if m.pts.addAll(delta) {
+			a.addWork(mid)
+		} else if optimizeSolver && a.lcdCandidate(n, m) {
+			// Lazy cycle detection: a copy edge n->m that
+			// leaves pts(m) == pts(n) may be part of a cycle.
+			a.lcdQueue = append(a.lcdQueue, mid)
+		}
+	}
+}
+
+// lcdCandidate reports whether the copy edge n->m, which did not
+// change pts(m), should trigger cycle detection.  Each edge
+// triggers at most once.
+//
+func (a *analysis) lcdCandidate(n, m *solverState) bool {
+	edge := [2]nodeid{n.members[0], m.members[0]}
+	if a.lcdEdges[edge] || !m.pts.Equals(&n.pts.Sparse) {
+		return false
+	}
+	a.lcdEdges[edge] = true
+	return true
+}
+
+// collapseCycles finds the strongly connected components of the
+// copy graph reachable from the specified nodes using Tarjan's
+// algorithm, and merges the solver states of each nontrivial
+// component, since all nodes in a cycle of copy edges have the same
+// points-to set.
+//
+// To bound the cost of the search, it follows only those edges whose
+// endpoints currently have equal points-to sets; any cycle so found
+// is a cycle in the complete graph, though some may be missed.
+//
+func (a *analysis) collapseCycles(roots []nodeid) {
+	type info struct {
+		index, lowlink int
+		onStack        bool
+	}
+	var (
+		infos = make(map[*solverState]*info)
+		stack []*solverState
+	)
+	var visit func(s *solverState) *info
+	visit = func(s *solverState) *info {
+		si := &info{index: len(infos), lowlink: len(infos), onStack: true}
+		infos[s] = si
+		stack = append(stack, s)
+
+		for _, x := range s.copyTo.AppendTo(nil) {
+			t := a.nodes[nodeid(x)].solve
+			if ti, ok := infos[t]; !ok {
+				if !t.pts.Equals(&s.pts.Sparse) {
+					continue
+				}
+				ti = visit(t)
+				if ti.lowlink < si.lowlink {
+					si.lowlink = ti.lowlink
+				}
+			} else if ti.onStack && ti.index < si.lowlink {
+				si.lowlink = ti.index
+			}
+		}
+
+		if si.lowlink == si.index {
+			// s is the root of a component.
+			var scc []*solverState
+			for {
+				t := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				infos[t].onStack = false
+				scc = append(scc, t)
+				if t == s {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				a.mergeStates(scc)
 			}
 		}
+		return si
+	}
+	for _, root := range roots {
+		if s := a.nodes[root].solve; infos[s] == nil {
+			visit(s)
+		}
 	}
 }
 
+// mergeStates merges the solver states of a set of nodes known to
+// have equal points-to sets, and returns the resulting state.
+//
+// Each constraint must see each label exactly once since some
+// (e.g. reflection) are not idempotent, so before two states are
+// merged, the constraints and edges of each are applied to the
+// labels previously seen only by the other.
+//
+func (a *analysis) mergeStates(states []*solverState) *solverState {
+	// Keep the state with the most members, so that each node is
+	// updated at most O(log N) times.
+	rep := states[0]
+	for _, s := range states[1:] {
+		if len(s.members) > len(rep.members) {
+			rep = s
+		}
+	}
+	for _, s := range states {
+		if s == rep {
+			continue
+		}
+		var delta nodeset
+		delta.Difference(&rep.prevPts.Sparse, &s.prevPts.Sparse)
+		a.solveConstraints(s, &delta)
+		delta.Difference(&s.prevPts.Sparse, &rep.prevPts.Sparse)
+		a.solveConstraints(rep, &delta)
+
+		rep.pts.addAll(&s.pts)
+		rep.prevPts.addAll(&s.prevPts)
+		rep.copyTo.addAll(&s.copyTo)
+		for c := range s.complex {
+			rep.complex.add(c)
+		}
+		for _, id := range s.members {
+			a.nodes[id].solve = rep
+		}
+		rep.members = append(rep.members, s.members...)
+	}
+	if a.log != nil {
+		fmt.Fprintf(a.log, "\t\tmerged %d nodes into n%d\n", len(rep.members), rep.members[0])
+	}
+	if !rep.pts.Equals(&rep.prevPts.Sparse) {
+		a.addWork(rep.members[0])
+	}
+	return rep
+}
+
 // addLabel adds label to the points-to set of ptr and reports whether the set grew.
 func (a *analysis) addLabel(ptr, label nodeid) bool {
-	return a.nodes[ptr].pts.add(label)
+	return a.nodes[ptr].solve.pts.add(label)
 }
 
 func (a *analysis) addWork(id nodeid) {
@@ -194,8 +347,9 @@ func (a *analysis) addWork(id nodeid) {
 // It returns true if pts(dst) changed.
 //
 func (a *analysis) onlineCopy(dst, src nodeid) bool {
-	if dst != src {
-		if nsrc := a.nodes[src]; nsrc.copyTo.add(dst) {
+	nsrc, ndst := a.nodes[src].solve, a.nodes[dst].solve
+	if nsrc != ndst {
+		if nsrc.copyTo.add(dst) {
 			if a.log != nil {
 				fmt.Fprintf(a.log, "\t\t\tdynamic copy n%d <- n%d\n", dst, src)
 			}
@@ -203,7 +357,7 @@ func (a *analysis) onlineCopy(dst, src nodeid) bool {
 			// are followed by addWork, possibly batched
 			// via a 'changed' flag; see if there's a
 			// noticeable penalty to calling addWork here.
-			return a.nodes[dst].pts.addAll(nsrc.pts)
+			return ndst.pts.addAll(&nsrc.pts)
 		}
 	}
 	return false
@@ -226,10 +380,11 @@ func (a *analysis) onlineCopyN(dst, src nodeid, sizeof uint32) uint32 {
 	return sizeof
 }
 
-func (c *loadConstraint) solve(a *analysis, n *node, delta nodeset) {
+func (c *loadConstraint) solve(a *analysis, delta *nodeset) {
 	var changed bool
-	for k := range delta {
-		koff := k + nodeid(c.offset)
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		koff := nodeid(x) + nodeid(c.offset)
 		if a.onlineCopy(c.dst, koff) {
 			changed = true
 		}
@@ -239,26 +394,30 @@ func (c *loadConstraint) solve(a *analysis, n *node, delta nodeset) {
 	}
 }
 
-func (c *storeConstraint) solve(a *analysis, n *node, delta nodeset) {
-	for k := range delta {
-		koff := k + nodeid(c.offset)
+func (c *storeConstraint) solve(a *analysis, delta *nodeset) {
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		koff := nodeid(x) + nodeid(c.offset)
 		if a.onlineCopy(koff, c.src) {
 			a.addWork(koff)
 		}
 	}
 }
 
-func (c *offsetAddrConstraint) solve(a *analysis, n *node, delta nodeset) {
-	dst := a.nodes[c.dst]
-	for k := range delta {
-		if dst.pts.add(k + nodeid(c.offset)) {
+func (c *offsetAddrConstraint) solve(a *analysis, delta *nodeset) {
+	dst := a.nodes[c.dst].solve
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		if dst.pts.add(nodeid(x) + nodeid(c.offset)) {
 			a.addWork(c.dst)
 		}
 	}
 }
 
-func (c *typeFilterConstraint) solve(a *analysis, n *node, delta nodeset) {
-	for ifaceObj := range delta {
+func (c *typeFilterConstraint) solve(a *analysis, delta *nodeset) {
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		ifaceObj := nodeid(x)
 		tDyn, _, indirect := a.taggedValue(ifaceObj)
 		if indirect {
 			// TODO(adonovan): we'll need to implement this
@@ -274,12 +433,14 @@ func (c *typeFilterConstraint) solve(a *analysis, n *node, delta nodeset) {
 	}
 }
 
-func (c *untagConstraint) solve(a *analysis, n *node, delta nodeset) {
+func (c *untagConstraint) solve(a *analysis, delta *nodeset) {
 	predicate := types.AssignableTo
 	if c.exact {
 		predicate = types.Identical
 	}
-	for ifaceObj := range delta {
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		ifaceObj := nodeid(x)
 		tDyn, v, indirect := a.taggedValue(ifaceObj)
 		if indirect {
 			// TODO(adonovan): we'll need to implement this
@@ -299,8 +460,10 @@ func (c *untagConstraint) solve(a *analysis, n *node, delta nodeset) {
 	}
 }
 
-func (c *invokeConstraint) solve(a *analysis, n *node, delta nodeset) {
-	for ifaceObj := range delta {
+func (c *invokeConstraint) solve(a *analysis, delta *nodeset) {
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		ifaceObj := nodeid(x)
 		tDyn, v, indirect := a.taggedValue(ifaceObj)
 		if indirect {
 			// TODO(adonovan): we may need to implement this if
@@ -316,10 +479,15 @@ func (c *invokeConstraint) solve(a *analysis, n *node, delta nodeset) {
 		}
 		sig := fn.Signature
 
-		fnObj := a.globalobj[fn] // dynamic calls use shared contour
-		if fnObj == 0 {
-			// a.objectNode(fn) was not called during gen phase.
-			panic(fmt.Sprintf("a.globalobj[%s]==nil", fn))
+		var fnObj nodeid
+		if a.shouldUseCalleeContext(fn) {
+			fnObj = a.contour(fn, a.calleeContext(c.caller, c.site, ifaceObj))
+		} else {
+			fnObj = a.globalobj[fn] // dynamic calls use shared contour
+			if fnObj == 0 {
+				// a.objectNode(fn) was not called during gen phase.
+				panic(fmt.Sprintf("a.globalobj[%s]==nil", fn))
+			}
 		}
 
 		// Make callsite's fn variable point to identity of
@@ -348,10 +516,42 @@ func (c *invokeConstraint) solve(a *analysis, n *node, delta nodeset) {
 	}
 }
 
-func (c *addrConstraint) solve(a *analysis, n *node, delta nodeset) {
+func (c *objectSensitiveCallConstraint) solve(a *analysis, delta *nodeset) {
+	sig := c.fn.Signature
+	paramsSize := a.sizeof(sig.Params())
+	resultsSize := a.sizeof(sig.Results())
+	var space [50]int
+	for _, x := range delta.AppendTo(space[:0]) {
+		recvObj := nodeid(x)
+
+		// Look up the contour for this receiver object.
+		fnObj := a.contour(c.fn, a.calleeContext(c.caller, c.site, recvObj))
+
+		// Make callsite's targets variable point to the contour.
+		a.addLabel(c.params, fnObj)
+
+		// The receiver param points to this object alone.
+		arg0 := a.funcParams(fnObj)
+		if a.addLabel(arg0, recvObj) {
+			a.addWork(arg0)
+		}
+
+		// Copy caller's argument block to method formal parameters.
+		src := c.params + 1 // skip past identity
+		dst := arg0 + 1     // skip past receiver
+		a.onlineCopyN(dst, src, paramsSize)
+		src += nodeid(paramsSize)
+		dst += nodeid(paramsSize)
+
+		// Copy method results to caller's result block.
+		a.onlineCopyN(src, dst, resultsSize)
+	}
+}
+
+func (c *addrConstraint) solve(a *analysis, delta *nodeset) {
 	panic("addr is not a complex constraint")
 }
 
-func (c *copyConstraint) solve(a *analysis, n *node, delta nodeset) {
+func (c *copyConstraint) solve(a *analysis, delta *nodeset) {
 	panic("copy is not a complex constraint")
 }
\ No newline at end of file
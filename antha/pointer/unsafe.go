@@ -0,0 +1,435 @@
+// antha-tools/antha/pointer/unsafe.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package pointer
+
+// This file implements the sound treatment of unsafe.Pointer
+// conversions, enabled by Config.Unsafe.
+//
+// Under this option, unsafe.Pointer and uintptr values carry the
+// labels of the pointers from which they were converted, and a
+// conversion unsafe.Pointer->*T is resolved during solving by
+// computing the physical offset of each label within its object, as
+// laid out by the gc compiler on linux/amd64.
+//
+// Objects are still represented by their logical nodes (see
+// doc.go), so a physical offset corresponds to a set of nodes: the
+// identity nodes of any structs or arrays that start there, and the
+// scalar that occupies it.  If one of them has the type T, the
+// conversion yields a pointer to it.  Otherwise the conversion
+// reinterprets memory, and yields a pointer to a "view" object of
+// type T, each of whose scalar nodes is linked by copy edges in both
+// directions to every node of the underlying object that it overlaps.
+//
+// Arithmetic on uintptr values offsets their labels by a constant
+// number of bytes, if known, or otherwise makes them point anywhere
+// within their objects.  All elements of an array share a node, so
+// an offset within an array is reduced modulo the element size.
+//
+// Objects without a physical layout (functions, tagged objects, maps
+// and channels) cannot be resolved; a conversion from a pointer into
+// one is treated as an unaliased allocation, as when Config.Unsafe
+// is false, and a warning is reported.
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// unsafeSizes is the memory layout assumed for unsafe conversions.
+var unsafeSizes = &types.StdSizes{WordSize: 8, MaxAlign: 8}
+
+// ---------- Constraint generation ----------
+
+// genUnsafeConv generates constraints for the conversion
+// res = (*T)(conv.X) where conv.X is an unsafe.Pointer.
+func (a *analysis) genUnsafeConv(conv *ssa.Convert, cgn *cgnode, res nodeid) {
+	a.addConstraint(&unsafeConvConstraint{
+		cgn:  cgn,
+		conv: conv,
+		typ:  mustDeref(conv.Type()),
+		dst:  res,
+		src:  a.valueNode(conv.X),
+	})
+}
+
+// genUnsafeArith generates constraints for the uintptr arithmetic
+// operation instr, which may offset a converted pointer.
+func (a *analysis) genUnsafeArith(instr *ssa.BinOp) {
+	var offset int64
+	exact := false
+	switch instr.Op {
+	case token.ADD:
+		if k, ok := instr.Y.(*ssa.Const); ok {
+			offset, exact = k.Int64(), true
+		} else if k, ok := instr.X.(*ssa.Const); ok {
+			offset, exact = k.Int64(), true
+		}
+	case token.SUB:
+		if k, ok := instr.Y.(*ssa.Const); ok {
+			offset, exact = -k.Int64(), true
+		}
+	}
+
+	res := a.valueNode(instr)
+	for _, x := range [2]ssa.Value{instr.X, instr.Y} {
+		if _, ok := x.(*ssa.Const); ok {
+			continue
+		}
+		a.addConstraint(&unsafeOffsetConstraint{
+			offset: offset,
+			exact:  exact,
+			dst:    res,
+			src:    a.valueNode(x),
+		})
+	}
+}
+
+// isUintptr reports whether T is uintptr or a named type based on it.
+func isUintptr(T types.Type) bool {
+	b, ok := T.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uintptr
+}
+
+// ---------- Physical layout ----------
+
+// objectLayout returns the first node and type of the object
+// containing node id, if it has a physical layout.
+func (a *analysis) objectLayout(id nodeid) (start nodeid, T types.Type, ok bool) {
+	for start = id; a.nodes[start].obj == nil; start-- {
+	}
+	obj := a.nodes[start].obj
+	if obj.size == 0 {
+		return 0, nil, false
+	}
+	if obj.flags&(otFunction|otTagged) != 0 {
+		return 0, nil, false
+	}
+	switch obj.data.(type) {
+	case *ssa.MakeMap, *ssa.MakeChan:
+		return 0, nil, false
+	}
+	T = a.nodes[start].typ
+	if a.sizeof(T) != obj.size {
+		return 0, nil, false
+	}
+	return start, T, true
+}
+
+// isScalar reports whether type T is represented by a single node.
+func (a *analysis) isScalar(T types.Type) bool {
+	return a.sizeof(T) == 1
+}
+
+// physOffset returns the physical offset in bytes of the node with
+// logical index idx within an object of type T.  All elements of an
+// array are represented by the first.
+func (a *analysis) physOffset(T types.Type, idx uint32) int64 {
+	if idx == 0 {
+		return 0
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		offsets := structOffsets(u)
+		j := uint32(1)
+		for i, n := 0, u.NumFields(); i < n; i++ {
+			ft := u.Field(i).Type()
+			sz := a.sizeof(ft)
+			if idx < j+sz {
+				return offsets[i] + a.physOffset(ft, idx-j)
+			}
+			j += sz
+		}
+	case *types.Array:
+		return a.physOffset(u.Elem(), idx-1)
+	}
+	panic(fmt.Sprintf("physOffset(%s, %d): no such node", T, idx))
+}
+
+// nodesAt appends to res the nodes of an object of type T, whose
+// first node is base, that start at physical offset off, outermost
+// first.
+func (a *analysis) nodesAt(T types.Type, off int64, base nodeid, res []nodeid) []nodeid {
+	if off < 0 {
+		return res
+	}
+	if a.isScalar(T) {
+		if off == 0 {
+			res = append(res, base)
+		}
+		return res
+	}
+	if off == 0 {
+		res = append(res, base) // identity node
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		offsets := structOffsets(u)
+		idx := base + 1
+		for i, n := 0, u.NumFields(); i < n; i++ {
+			ft := u.Field(i).Type()
+			if rel := off - offsets[i]; rel >= 0 && (rel == 0 || rel < unsafeSizes.Sizeof(ft)) {
+				res = a.nodesAt(ft, rel, idx, res)
+			}
+			idx += nodeid(a.sizeof(ft))
+		}
+	case *types.Array:
+		if es := unsafeSizes.Sizeof(u.Elem()); es > 0 {
+			res = a.nodesAt(u.Elem(), off%es, base+1, res)
+		}
+	}
+	return res
+}
+
+// overlapping appends to res the scalar nodes of an object of type T,
+// whose first node is base, that may overlap the bytes [lo, hi).
+// The result may contain duplicates.
+func (a *analysis) overlapping(T types.Type, lo, hi int64, base nodeid, res []nodeid) []nodeid {
+	if hi <= lo || hi <= 0 {
+		return res
+	}
+	if a.isScalar(T) {
+		if size := unsafeSizes.Sizeof(T); lo < size || lo <= 0 {
+			res = append(res, base)
+		}
+		return res
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		offsets := structOffsets(u)
+		idx := base + 1
+		for i, n := 0, u.NumFields(); i < n; i++ {
+			ft := u.Field(i).Type()
+			res = a.overlapping(ft, lo-offsets[i], hi-offsets[i], idx, res)
+			idx += nodeid(a.sizeof(ft))
+		}
+	case *types.Array:
+		es := unsafeSizes.Sizeof(u.Elem())
+		if es == 0 {
+			return res
+		}
+		if lo < 0 {
+			lo = 0
+		}
+		if hi-lo >= es {
+			return a.overlapping(u.Elem(), 0, es, base+1, res)
+		}
+		r := lo % es
+		res = a.overlapping(u.Elem(), r, r+hi-lo, base+1, res)
+		if r+hi-lo > es {
+			// The range wraps into the next element.
+			res = a.overlapping(u.Elem(), 0, r+hi-lo-es, base+1, res)
+		}
+	}
+	return res
+}
+
+// structOffsets returns the physical offsets of the fields of struct s.
+func structOffsets(s *types.Struct) []int64 {
+	fields := make([]*types.Var, s.NumFields())
+	for i := range fields {
+		fields[i] = s.Field(i)
+	}
+	return unsafeSizes.Offsetsof(fields)
+}
+
+// ---------- Constraints ----------
+
+// dst = (*T)(src)   where src is an unsafe.Pointer
+// A complex constraint attached to src.
+type unsafeConvConstraint struct {
+	cgn      *cgnode
+	conv     *ssa.Convert
+	typ      types.Type // T
+	dst      nodeid     // (indirect)
+	src      nodeid     // (ptr)
+	fallback nodeid     // unaliased object, once needed
+}
+
+func (c *unsafeConvConstraint) ptr() nodeid                      { return c.src }
+func (c *unsafeConvConstraint) indirect(nodes []nodeid) []nodeid { return append(nodes, c.dst) }
+func (c *unsafeConvConstraint) renumber(mapping []nodeid) {
+	c.dst = mapping[c.dst]
+	c.src = mapping[c.src]
+}
+
+func (c *unsafeConvConstraint) String() string {
+	return fmt.Sprintf("n%d = (*%s)(n%d)", c.dst, c.typ, c.src)
+}
+
+func (c *unsafeConvConstraint) solve(a *analysis, delta *nodeset) {
+	changed := false
+	var space [4]nodeid
+	var dspace [50]int
+	for _, x := range delta.AppendTo(dspace[:0]) {
+		label := nodeid(x)
+		start, T, ok := a.objectLayout(label)
+		if !ok {
+			if c.fallback == 0 {
+				a.warnf(c.conv.Pos(),
+					"unsound: %s contains an unsafe.Pointer conversion (to *%s) from a pointer into an object without a physical layout",
+					c.conv.Parent(), c.typ)
+				c.fallback = a.addNodes(c.typ, "unsafe.Pointer conversion")
+				a.endObject(c.fallback, c.cgn, c.conv)
+			}
+			if a.addLabel(c.dst, c.fallback) {
+				changed = true
+			}
+			continue
+		}
+		off := a.physOffset(T, uint32(label-start))
+
+		// Is there a node of the same type at this offset?
+		target := nodeid(0)
+		for _, id := range a.nodesAt(T, off, start, space[:0]) {
+			if types.Identical(a.nodes[id].typ.Underlying(), c.typ.Underlying()) {
+				target = id
+				break
+			}
+		}
+		if target == 0 {
+			target = a.unsafeView(c, start, T, off)
+		}
+		if a.addLabel(c.dst, target) {
+			changed = true
+		}
+	}
+	if changed {
+		a.addWork(c.dst)
+	}
+}
+
+type unsafeViewKey struct {
+	start nodeid
+	off   int64
+	typ   types.Type
+}
+
+// unsafeView returns a view object of type c.typ of the object of
+// type T starting at node start, at physical offset off, creating it
+// if necessary.
+func (a *analysis) unsafeView(c *unsafeConvConstraint, start nodeid, T types.Type, off int64) nodeid {
+	key := unsafeViewKey{start, off, c.typ}
+	if view, ok := a.unsafeViews[key]; ok {
+		return view
+	}
+	view := a.addNodes(c.typ, "unsafe.Pointer view")
+	a.endObject(view, c.cgn, c.conv)
+	a.unsafeViews[key] = view
+
+	// Link each scalar of the view to the scalars it overlaps.
+	var space [8]nodeid
+	fl := a.flatten(c.typ)
+	for i := range fl {
+		if !a.isScalar(fl[i].typ) {
+			continue // identity node
+		}
+		v := view + nodeid(i)
+		lo := off + a.physOffset(c.typ, uint32(i))
+		hi := lo + unsafeSizes.Sizeof(fl[i].typ)
+		if hi == lo {
+			hi++
+		}
+		for _, o := range a.overlapping(T, lo, hi, start, space[:0]) {
+			if a.onlineCopy(v, o) {
+				a.addWork(v)
+			}
+			if a.onlineCopy(o, v) {
+				a.addWork(o)
+			}
+		}
+	}
+	return view
+}
+
+// dst = src + offset   where src is a uintptr
+// A complex constraint attached to src.
+// If !exact, the offset is unknown.
+type unsafeOffsetConstraint struct {
+	offset int64  // in bytes
+	exact  bool   // whether offset is known
+	dst    nodeid // (indirect)
+	src    nodeid // (ptr)
+}
+
+func (c *unsafeOffsetConstraint) ptr() nodeid                      { return c.src }
+func (c *unsafeOffsetConstraint) indirect(nodes []nodeid) []nodeid { return append(nodes, c.dst) }
+func (c *unsafeOffsetConstraint) renumber(mapping []nodeid) {
+	c.dst = mapping[c.dst]
+	c.src = mapping[c.src]
+}
+
+func (c *unsafeOffsetConstraint) String() string {
+	if !c.exact {
+		return fmt.Sprintf("n%d = n%d + ?", c.dst, c.src)
+	}
+	return fmt.Sprintf("n%d = n%d + %d", c.dst, c.src, c.offset)
+}
+
+func (c *unsafeOffsetConstraint) solve(a *analysis, delta *nodeset) {
+	changed := false
+	var space [8]nodeid
+	var dspace [50]int
+	for _, x := range delta.AppendTo(dspace[:0]) {
+		label := nodeid(x)
+		start, T, ok := a.objectLayout(label)
+		if !ok {
+			// No layout: leave the label unchanged, and let
+			// unsafeConvConstraint report it.
+			if a.addLabel(c.dst, label) {
+				changed = true
+			}
+			continue
+		}
+
+		var targets []nodeid
+		if c.exact {
+			off := a.physOffset(T, uint32(label-start)) + c.offset
+			targets = a.nodesAt(T, off, start, space[:0])
+			if len(targets) == 0 {
+				// Not at the start of a node,
+				// e.g. within a string header.
+				targets = a.overlapping(T, off, off+1, start, space[:0])
+			}
+			if len(targets) > 1 {
+				targets = targets[:1] // outermost
+			}
+		} else {
+			// Anywhere within the object.
+			for i := uint32(0); i < a.nodes[start].obj.size; i++ {
+				targets = append(targets, start+nodeid(i))
+			}
+		}
+		for _, t := range targets {
+			if a.addLabel(c.dst, t) {
+				changed = true
+			}
+		}
+	}
+	if changed {
+		a.addWork(c.dst)
+	}
+}
\ No newline at end of file
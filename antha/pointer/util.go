@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha-tools/container/intsets"
 )
 
 // CanPoint reports whether the type T is pointerlike,
@@ -265,66 +266,35 @@ func sliceToArray(slice types.Type) *types.Array {
 
 // Node set -------------------------------------------------------------------
 
-// NB, mutator methods are attached to *nodeset.
-// nodeset may be a reference, but its address matters!
-type nodeset map[nodeid]struct{}
-
-// ---- Accessors ----
+// A nodeset is a set of nodeids represented as a sparse bit vector.
+// The zero value is an empty set.
+//
+// Like intsets.Sparse, a nodeset must not be copied by assignment;
+// its address matters!
+type nodeset struct {
+	intsets.Sparse
+}
 
-func (ns nodeset) String() string {
+func (ns *nodeset) String() string {
 	var buf bytes.Buffer
 	buf.WriteRune('{')
-	var sep string
-	for n := range ns {
-		fmt.Fprintf(&buf, "%sn%d", sep, n)
-		sep = ", "
+	var space [50]int
+	for i, n := range ns.AppendTo(space[:0]) {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "n%d", n)
 	}
 	buf.WriteRune('}')
 	return buf.String()
 }
 
-// diff returns the set-difference x - y.  nil => empty.
-//
-// TODO(adonovan): opt: extremely inefficient.  BDDs do this in
-// constant time.  Sparse bitvectors are linear but very fast.
-func (x nodeset) diff(y nodeset) nodeset {
-	var z nodeset
-	for k := range x {
-		if _, ok := y[k]; !ok {
-			z.add(k)
-		}
-	}
-	return z
-}
-
-// clone() returns an unaliased copy of x.
-func (x nodeset) clone() nodeset {
-	return x.diff(nil)
-}
-
-// ---- Mutators ----
-
 func (ns *nodeset) add(n nodeid) bool {
-	sz := len(*ns)
-	if *ns == nil {
-		*ns = make(nodeset)
-	}
-	(*ns)[n] = struct{}{}
-	return len(*ns) > sz
+	return ns.Sparse.Insert(int(n))
 }
 
-func (x *nodeset) addAll(y nodeset) bool {
-	if y == nil {
-		return false
-	}
-	sz := len(*x)
-	if *x == nil {
-		*x = make(nodeset)
-	}
-	for n := range y {
-		(*x)[n] = struct{}{}
-	}
-	return len(*x) > sz
+func (x *nodeset) addAll(y *nodeset) bool {
+	return x.UnionWith(&y.Sparse)
 }
 
 // Constraint set -------------------------------------------------------------
@@ -349,23 +319,35 @@ type worklist interface {
 	take() nodeid // Takes a node from the set and returns it, or empty
 }
 
-// Simple nondeterministic worklist based on a built-in map.
-type mapWorklist struct {
-	set nodeset
+// Deterministic FIFO worklist.
+// Nodes are visited in the order they were first added,
+// which propagates points-to sets breadth-first along copy edges
+// and needs far fewer rounds than visiting the least node first.
+type fifoWorklist struct {
+	queue []nodeid
+	in    []bool // in[n] iff n is in queue
 }
 
-func (w *mapWorklist) add(n nodeid) {
-	w.set[n] = struct{}{}
+func (w *fifoWorklist) add(n nodeid) {
+	for int(n) >= len(w.in) {
+		w.in = append(w.in, false)
+	}
+	if !w.in[n] {
+		w.in[n] = true
+		w.queue = append(w.queue, n)
+	}
 }
 
-func (w *mapWorklist) take() nodeid {
-	for k := range w.set {
-		delete(w.set, k)
-		return k
+func (w *fifoWorklist) take() nodeid {
+	if len(w.queue) == 0 {
+		return empty
 	}
-	return empty
+	n := w.queue[0]
+	w.queue = w.queue[1:]
+	w.in[n] = false
+	return n
 }
 
-func makeMapWorklist() worklist {
-	return &mapWorklist{make(nodeset)}
+func makeFIFOWorklist() worklist {
+	return new(fifoWorklist)
 }
\ No newline at end of file
@@ -256,4 +256,67 @@ func TestTypesWithMethodSets(t *testing.T) {
 			t.Errorf("test 'package %s': got %q, want %q", f.Name.Name, typstrs, test.want)
 		}
 	}
+}
+
+// Tests that the OptimizeFunctions mode folds constants, deletes
+// unreachable code and eliminates stores to unread locals.
+func TestOptimizeFunctions(t *testing.T) {
+	test := `
+package main
+
+type T struct{ a, b int }
+
+func f(x int) int {
+	const debug = false
+	n := 3
+	m := n * 4
+	if debug || m < 10 {
+		println("unreachable")
+	}
+	var t T
+	t.a = x
+	t.b = m
+	return m
+}
+
+func main() { f(0) }
+`
+
+	var conf loader.Config
+	f, err := conf.ParseFile("<input>", test)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.CreateFromFiles("main", f)
+
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions|ssa.OptimizeFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	fn := mainPkg.Func("f")
+	if len(fn.Blocks) != 1 {
+		t.Errorf("f has %d blocks, want 1", len(fn.Blocks))
+	}
+	if len(fn.Locals) != 0 {
+		t.Errorf("f has locals %v, want none", fn.Locals)
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Return:
+				if c, ok := instr.Results[0].(*ssa.Const); !ok || c.Int64() != 12 {
+					t.Errorf("f returns %s, want 12", instr.Results[0])
+				}
+			default:
+				t.Errorf("unexpected instruction in f: %s", instr)
+			}
+		}
+	}
 }
\ No newline at end of file
@@ -46,6 +46,7 @@ const (
 	NaiveForm                                    // Build naïve SSA form: don't replace local loads/stores with registers
 	BuildSerially                                // Build packages serially, not in parallel.
 	GlobalDebug                                  // Enable debug info for all packages
+	OptimizeFunctions                            // Apply constant/copy propagation and dead code elimination
 )
 
 // Create returns a new SSA Program.  An SSA Package is created for
@@ -57,14 +58,7 @@ const (
 // mode controls diagnostics and checking during SSA construction.
 //
 func Create(iprog *loader.Program, mode BuilderMode) *Program {
-	prog := &Program{
-		Fset:                iprog.Fset,
-		imported:            make(map[string]*Package),
-		packages:            make(map[*types.Package]*Package),
-		boundMethodWrappers: make(map[*types.Func]*Function),
-		ifaceMethodWrappers: make(map[*types.Func]*Function),
-		mode:                mode,
-	}
+	prog := NewProgram(iprog.Fset, mode)
 
 	for _, info := range iprog.AllPackages {
 		// TODO(adonovan): relax this constraint if the
@@ -77,6 +71,24 @@ func Create(iprog *loader.Program, mode BuilderMode) *Program {
 	return prog
 }
 
+// NewProgram returns a new SSA Program containing no packages.
+// Packages are added to it by CreatePackage, which allows clients
+// that type-check packages themselves, without a loader.Program, to
+// build SSA code.
+//
+// mode controls diagnostics and checking during SSA construction.
+//
+func NewProgram(fset *token.FileSet, mode BuilderMode) *Program {
+	return &Program{
+		Fset:                fset,
+		imported:            make(map[string]*Package),
+		packages:            make(map[*types.Package]*Package),
+		boundMethodWrappers: make(map[*types.Func]*Function),
+		ifaceMethodWrappers: make(map[*types.Func]*Function),
+		mode:                mode,
+	}
+}
+
 // memberFromObject populates package pkg with a member for the
 // typechecker object obj.
 //
@@ -54,7 +54,9 @@
 // using dominance and dataflow are then performed as a second pass
 // called "lifting" to improve the accuracy and performance of
 // subsequent analyses; this pass can be skipped by setting the
-// NaiveForm builder flag.
+// NaiveForm builder flag.  If the OptimizeFunctions builder flag is
+// set, lifting is followed by constant propagation, copy propagation
+// and dead code elimination.
 //
 // The primary interfaces of this package are:
 //
@@ -127,6 +129,10 @@
 // either accurate or unambiguous.  The public API exposes a number of
 // name-based maps for client convenience.
 //
+// WriteText and ParseText convert Functions to and from a textual
+// form from which they can be rebuilt, so that SSA inputs to the
+// tests of analyses may be written by hand.
+//
 // The ssa/ssautil package provides various utilities that depend only
 // on the public API of this package.
 //
@@ -0,0 +1,634 @@
+// antha-tools/antha/ssa/escape/escape.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// Package escape reports which of the Alloc instructions of the SSA
+// form of a package allocate variables that escape, that is, that may
+// be referenced after the function that allocates them returns, and
+// why.  Such variables must live in the heap, where they add to the
+// work of the garbage collector; those that do not escape may live in
+// the frame of their function.
+//
+// A variable escapes if a pointer to it may be returned, stored in a
+// package-level variable, sent on a channel, captured by a closure
+// that escapes, stored through a pointer received from the caller,
+// passed to a function started as a goroutine, or passed to a
+// function whose effect is not known.  Objects other than variables,
+// such as maps, slices and closures, are modelled too, and the
+// variables to which an escaping object points escape with it.
+//
+// The analysis is flow-insensitive within a function: it computes for
+// each value the set of objects to which it may point, and for each
+// object the set of objects to which its contents may point.  It is
+// interprocedural within a package: each function is summarized by
+// which of its parameters escape, and which it may return, and the
+// summaries are used at its static call sites, including calls of
+// closures created in the same function.  Calls of other functions,
+// whether dynamic, through an interface, or to functions of other
+// packages, are assumed to let their arguments escape.
+//
+package escape
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"sort"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// A Reason is the way in which a variable escapes.
+type Reason int
+
+const (
+	Return  Reason = iota // returned by its function
+	Global                // stored in a package-level variable
+	Send                  // sent on a channel
+	Capture               // captured by a closure that escapes
+	Param                 // stored through a pointer received from the caller
+	Go                    // passed to a function started as a goroutine
+	Call                  // passed to a function whose effect is unknown
+)
+
+var reasonNames = [...]string{
+	Return:  "returned",
+	Global:  "stored in a global",
+	Send:    "sent on a channel",
+	Capture: "captured by an escaping closure",
+	Param:   "stored through a parameter",
+	Go:      "passed to a goroutine",
+	Call:    "passed to an unknown function",
+}
+
+func (r Reason) String() string { return reasonNames[r] }
+
+// An Escape describes how a variable escapes.
+type Escape struct {
+	Reason Reason
+	Pos    token.Pos     // position of the operation by which it escapes
+	Fn     *ssa.Function // function containing that operation
+}
+
+// Description returns a description of e, such as "returned at
+// prog.go:10:2", relative to the function fn that allocated the
+// variable.
+func (e *Escape) Description(fn *ssa.Function) string {
+	s := e.Reason.String()
+	if e.Fn != fn {
+		s += " by " + e.Fn.String()
+	}
+	if e.Pos.IsValid() {
+		s += " at " + fn.Prog.Fset.Position(e.Pos).String()
+	}
+	return s
+}
+
+// A Result holds the results of an escape analysis.
+type Result struct {
+	escapes map[*ssa.Alloc]*Escape
+}
+
+// Escape returns how the variable allocated by a escapes, or nil if it
+// does not.
+func (r *Result) Escape(a *ssa.Alloc) *Escape { return r.escapes[a] }
+
+// AnalyzePackage analyzes every function of pkg that has a body,
+// including methods and anonymous functions.  The summaries of
+// functions that call each other recursively are computed by
+// iteration until they no longer change.
+//
+// Precondition: pkg is built.
+//
+func AnalyzePackage(pkg *ssa.Package) *Result {
+	fns := ssautil.PackageFunctions(pkg)
+	summaries := make(map[*ssa.Function]*summary)
+	for _, fn := range fns {
+		n := len(fn.Params) + len(fn.FreeVars)
+		summaries[fn] = &summary{escapes: make([]*Escape, n), returns: make([]bool, n)}
+	}
+
+	var states []*state
+	for changed := true; changed; {
+		changed = false
+		states = states[:0]
+		for _, fn := range fns {
+			s := analyze(fn, summaries)
+			states = append(states, s)
+			if summaries[fn].update(s) {
+				changed = true
+			}
+		}
+	}
+
+	r := &Result{escapes: make(map[*ssa.Alloc]*Escape)}
+	for _, s := range states {
+		for _, o := range s.objects {
+			if a, ok := o.v.(*ssa.Alloc); ok {
+				r.escapes[a] = o.escape
+			}
+		}
+	}
+	return r
+}
+
+// A summary describes the effect of a function on the objects to which
+// its parameters, followed by its free variables, point.
+type summary struct {
+	escapes []*Escape // how each escapes, or nil
+	returns []bool    // whether each may be returned
+}
+
+// update adds the facts found by s to the summary and reports whether
+// it changed.
+func (sum *summary) update(s *state) bool {
+	changed := false
+	for i, o := range s.params {
+		if sum.escapes[i] == nil && o.escape != nil {
+			sum.escapes[i] = o.escape
+			changed = true
+		}
+		if !sum.returns[i] && s.returned[o] {
+			sum.returns[i] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// An object is an abstract memory location: a variable, map, slice,
+// channel or closure created by the function, or an external object
+// to which its parameters, free variables or globals point.
+type object struct {
+	id       int                   // order of creation
+	v        ssa.Value             // instruction creating the object, or nil if external
+	external *Escape               // for objects of other functions, how what is stored in them escapes
+	contents map[*object]token.Pos // objects to which the contents point, and where they were stored
+	escape   *Escape
+}
+
+// A set is a set of objects.
+type set map[*object]bool
+
+// sorted returns the objects of m, a set or the contents of an
+// object, in order of creation, so that the first reason found for
+// each escape does not depend on the order of iteration over maps.
+func sorted(m interface{}) []*object {
+	var objs []*object
+	switch m := m.(type) {
+	case set:
+		for o := range m {
+			objs = append(objs, o)
+		}
+	case map[*object]token.Pos:
+		for o := range m {
+			objs = append(objs, o)
+		}
+	}
+	sort.Sort(byID(objs))
+	return objs
+}
+
+type byID []*object
+
+func (p byID) Len() int           { return len(p) }
+func (p byID) Less(i, j int) bool { return p[i].id < p[j].id }
+func (p byID) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// state holds the analysis of a single function.
+type state struct {
+	fn        *ssa.Function
+	summaries map[*ssa.Function]*summary
+	pts       map[ssa.Value]set // objects to which each value may point
+	objects   map[ssa.Value]*object
+	nobjects  int
+	params    []*object // objects to which params and free vars point
+	global    *object   // the objects to which globals point
+	unknown   *object   // the objects returned by unknown functions
+	returned  set       // objects returned by fn
+	changed   bool
+}
+
+func analyze(fn *ssa.Function, summaries map[*ssa.Function]*summary) *state {
+	s := &state{
+		fn:        fn,
+		summaries: summaries,
+		pts:       make(map[ssa.Value]set),
+		objects:   make(map[ssa.Value]*object),
+		returned:  make(set),
+	}
+	s.global = s.externalObject(Global)
+	s.unknown = s.externalObject(Call)
+	for _, p := range fn.Params {
+		s.param(p)
+	}
+	for _, fv := range fn.FreeVars {
+		s.param(fv)
+	}
+
+	// Compute the points-to sets, iterating until they no longer
+	// change.
+	for s.changed = true; s.changed; {
+		s.changed = false
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				s.flow(instr)
+			}
+		}
+	}
+
+	// Find the objects that escape.
+	for _, o := range []*object{s.global, s.unknown} {
+		s.mark(o, o.external)
+	}
+	for _, o := range s.params {
+		for _, c := range sorted(o.contents) {
+			s.mark(c, &Escape{Param, o.contents[c], fn})
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			s.escapes(instr)
+		}
+	}
+	return s
+}
+
+// param records the object to which the parameter or free variable p
+// points.
+func (s *state) param(p ssa.Value) {
+	o := s.externalObject(Param)
+	s.params = append(s.params, o)
+	s.add(p, o)
+}
+
+// externalObject returns a new external object, whose contents escape
+// for the reason r.
+func (s *state) externalObject(r Reason) *object {
+	s.nobjects++
+	return &object{id: s.nobjects, external: &Escape{r, token.NoPos, s.fn}}
+}
+
+// newObject returns the object created by v.
+func (s *state) newObject(v ssa.Value) *object {
+	o, ok := s.objects[v]
+	if !ok {
+		s.nobjects++
+		o = &object{id: s.nobjects, v: v}
+		s.objects[v] = o
+	}
+	return o
+}
+
+// add adds o to the points-to set of v, unless v holds no pointers.
+func (s *state) add(v ssa.Value, o *object) {
+	if !hasPointers(v.Type()) {
+		return
+	}
+	pts, ok := s.pts[v]
+	if !ok {
+		pts = make(set)
+		s.pts[v] = pts
+	}
+	if !pts[o] {
+		pts[o] = true
+		s.changed = true
+	}
+}
+
+// copy adds the points-to set of src to that of dst.
+func (s *state) copy(dst, src ssa.Value) {
+	for o := range s.pts[src] {
+		s.add(dst, o)
+	}
+}
+
+// store adds the points-to set of v to the contents of the objects to
+// which addr points.
+func (s *state) store(addr, v ssa.Value, pos token.Pos) {
+	for o := range s.pts[addr] {
+		for c := range s.pts[v] {
+			s.storeObject(o, c, pos)
+		}
+	}
+}
+
+func (s *state) storeObject(o, c *object, pos token.Pos) {
+	if o.contents == nil {
+		o.contents = make(map[*object]token.Pos)
+	}
+	if _, ok := o.contents[c]; !ok {
+		o.contents[c] = pos
+		s.changed = true
+	}
+}
+
+// load adds the contents of the objects to which addr points to the
+// points-to set of v.  The contents of an external object are
+// external.
+func (s *state) load(v, addr ssa.Value) {
+	for o := range s.pts[addr] {
+		if o.external != nil {
+			s.add(v, o)
+		}
+		for c := range o.contents {
+			s.add(v, c)
+		}
+	}
+}
+
+// flow updates the points-to sets for the instruction instr.
+func (s *state) flow(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Alloc, *ssa.MakeMap, *ssa.MakeSlice, *ssa.MakeChan:
+		v := instr.(ssa.Value)
+		s.add(v, s.newObject(v))
+
+	case *ssa.MakeClosure:
+		o := s.newObject(instr)
+		s.add(instr, o)
+		for _, b := range instr.Bindings {
+			for c := range s.pts[b] {
+				s.storeObject(o, c, instr.Pos())
+			}
+		}
+
+	case *ssa.Store:
+		s.store(instr.Addr, instr.Val, instr.Pos())
+
+	case *ssa.MapUpdate:
+		s.store(instr.Map, instr.Key, instr.Pos())
+		s.store(instr.Map, instr.Value, instr.Pos())
+
+	case *ssa.UnOp:
+		switch instr.Op {
+		case token.MUL:
+			s.load(instr, instr.X)
+		case token.ARROW:
+			s.add(instr, s.unknown)
+		}
+
+	case *ssa.Lookup:
+		s.load(instr, instr.X)
+
+	case *ssa.Next:
+		s.load(instr, instr.Iter)
+
+	case *ssa.Select:
+		s.add(instr, s.unknown)
+
+	case *ssa.FieldAddr:
+		s.copy(instr, instr.X)
+	case *ssa.IndexAddr:
+		s.copy(instr, instr.X)
+	case *ssa.Field:
+		s.copy(instr, instr.X)
+	case *ssa.Index:
+		s.copy(instr, instr.X)
+	case *ssa.Slice:
+		s.copy(instr, instr.X)
+	case *ssa.Range:
+		s.copy(instr, instr.X)
+	case *ssa.ChangeType:
+		s.copy(instr, instr.X)
+	case *ssa.ChangeInterface:
+		s.copy(instr, instr.X)
+	case *ssa.Convert:
+		s.copy(instr, instr.X)
+	case *ssa.MakeInterface:
+		s.copy(instr, instr.X)
+	case *ssa.TypeAssert:
+		s.copy(instr, instr.X)
+	case *ssa.Extract:
+		s.copy(instr, instr.Tuple)
+
+	case *ssa.Phi:
+		for _, edge := range instr.Edges {
+			s.copy(instr, edge)
+		}
+
+	case *ssa.Call:
+		s.flowCall(instr)
+	}
+
+	// Globals point to external objects.
+	for _, op := range instr.Operands(nil) {
+		if g, ok := (*op).(*ssa.Global); ok {
+			s.add(g, s.global)
+		}
+	}
+}
+
+// flowCall updates the points-to set of the result of call.
+func (s *state) flowCall(call *ssa.Call) {
+	c := &call.Call
+	if b, ok := c.Value.(*ssa.Builtin); ok {
+		switch b.Name() {
+		case "append":
+			// The result may be the first argument, or a new
+			// slice holding the elements of both.
+			s.copy(call, c.Args[0])
+			s.add(call, s.newObject(call))
+			for _, arg := range c.Args {
+				for o := range s.pts[arg] {
+					for e, pos := range o.contents {
+						s.storeObject(s.newObject(call), e, pos)
+					}
+				}
+			}
+		case "copy":
+			for o := range s.pts[c.Args[1]] {
+				for e := range o.contents {
+					for d := range s.pts[c.Args[0]] {
+						s.storeObject(d, e, call.Pos())
+					}
+				}
+			}
+		}
+		return
+	}
+
+	callee, args := s.callee(c)
+	sum := s.summaries[callee]
+	if sum == nil {
+		s.add(call, s.unknown)
+		return
+	}
+	for i, arg := range args {
+		if sum.returns[i] {
+			s.copy(call, arg)
+			s.copyReachable(call, arg)
+		}
+	}
+}
+
+// copyReachable adds to the points-to set of dst the objects reachable
+// from the contents of those to which src points.
+func (s *state) copyReachable(dst, src ssa.Value) {
+	seen := make(set)
+	var visit func(o *object)
+	visit = func(o *object) {
+		if seen[o] {
+			return
+		}
+		seen[o] = true
+		for c := range o.contents {
+			s.add(dst, c)
+			visit(c)
+		}
+	}
+	for o := range s.pts[src] {
+		visit(o)
+	}
+}
+
+// callee returns the function called by c, if known, and the values
+// of its parameters followed by its free variables.
+func (s *state) callee(c *ssa.CallCommon) (*ssa.Function, []ssa.Value) {
+	if c.IsInvoke() {
+		return nil, c.Args
+	}
+	switch fn := c.Value.(type) {
+	case *ssa.Function:
+		return fn, c.Args
+	case *ssa.MakeClosure:
+		args := append(c.Args[:len(c.Args):len(c.Args)], fn.Bindings...)
+		return fn.Fn.(*ssa.Function), args
+	}
+	return nil, c.Args
+}
+
+// escapes marks the objects that escape by the instruction instr.
+func (s *state) escapes(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Return:
+		for _, r := range instr.Results {
+			for _, o := range sorted(s.pts[r]) {
+				s.returned[o] = true
+				if o.external == nil {
+					s.mark(o, &Escape{Return, instr.Pos(), s.fn})
+				}
+			}
+		}
+
+	case *ssa.Send:
+		s.markValue(instr.X, &Escape{Send, instr.Pos(), s.fn})
+
+	case *ssa.Panic:
+		s.markValue(instr.X, &Escape{Call, instr.Pos(), s.fn})
+
+	case *ssa.Go:
+		e := &Escape{Go, instr.Pos(), s.fn}
+		_, args := s.callee(&instr.Call)
+		for _, arg := range args {
+			s.markValue(arg, e)
+		}
+		if mc, ok := instr.Call.Value.(*ssa.MakeClosure); ok {
+			s.mark(s.newObject(mc), e)
+		} else if instr.Call.IsInvoke() {
+			s.markValue(instr.Call.Value, e)
+		}
+
+	case ssa.CallInstruction:
+		c := instr.Common()
+		if b, ok := c.Value.(*ssa.Builtin); ok {
+			if b.Name() == "panic" {
+				s.markValue(c.Args[0], &Escape{Call, instr.Pos(), s.fn})
+			}
+			return
+		}
+		callee, args := s.callee(c)
+		sum := s.summaries[callee]
+		for i, arg := range args {
+			if sum == nil {
+				s.markValue(arg, &Escape{Call, instr.Pos(), s.fn})
+			} else if e := sum.escapes[i]; e != nil {
+				s.markValue(arg, e)
+			}
+		}
+		if c.IsInvoke() {
+			s.markValue(c.Value, &Escape{Call, instr.Pos(), s.fn})
+		}
+	}
+}
+
+// markValue marks the objects to which v points as escaping by e.
+func (s *state) markValue(v ssa.Value, e *Escape) {
+	for _, o := range sorted(s.pts[v]) {
+		s.mark(o, e)
+	}
+}
+
+// mark marks o, and the objects to which its contents point, as
+// escaping by e.  The objects stored in an external object escape
+// where they are stored, and those captured by a closure escape by
+// being captured.
+func (s *state) mark(o *object, e *Escape) {
+	if o.escape != nil {
+		return
+	}
+	o.escape = e
+	for _, c := range sorted(o.contents) {
+		switch v := o.v.(type) {
+		case nil:
+			s.mark(c, &Escape{o.external.Reason, o.contents[c], s.fn})
+		case *ssa.MakeClosure:
+			s.mark(c, &Escape{Capture, v.Fn.Pos(), s.fn})
+		default:
+			s.mark(c, e)
+		}
+	}
+}
+
+// hasPointers reports whether values of type t may hold pointers.
+func hasPointers(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		return t.Kind() == types.UnsafePointer
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasPointers(t.Field(i).Type()) {
+				return true
+			}
+		}
+		return false
+	case *types.Array:
+		return hasPointers(t.Elem())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if hasPointers(t.At(i).Type()) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// String returns a description of o, for debugging.
+func (o *object) String() string {
+	if o.v == nil {
+		return "external"
+	}
+	return fmt.Sprintf("%s = %s", o.v.Name(), o.v)
+}
\ No newline at end of file
@@ -0,0 +1,132 @@
+// antha-tools/antha/ssa/escape/escape_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package escape_test
+
+import (
+	"github.com/antha-lang/antha/parser"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/escape"
+)
+
+func TestAnalyzePackage(t *testing.T) {
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("testdata/escape.go", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	// Map each line to the reasons expected in its comment.
+	want := make(map[int]string)
+	for _, c := range f.Comments {
+		if text := strings.TrimSpace(c.Text()); isExpectation(text) {
+			want[iprog.Fset.Position(c.Pos()).Line] = text
+		}
+	}
+
+	// Describe the objects allocated by new and composite literals
+	// on each line, in order.
+	result := escape.AnalyzePackage(mainPkg)
+	got := make(map[int][]string)
+	for fn := range allFunctions(mainPkg) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				alloc, ok := instr.(*ssa.Alloc)
+				if !ok || alloc.Comment != "new" && alloc.Comment != "complit" {
+					continue
+				}
+				line := prog.Fset.Position(alloc.Pos()).Line
+				desc := "local"
+				if e := result.Escape(alloc); e != nil {
+					desc = e.Reason.String()
+				}
+				got[line] = append(got[line], desc)
+			}
+		}
+	}
+
+	for line, descs := range got {
+		if s := strings.Join(descs, "; "); s != want[line] {
+			t.Errorf("line %d: got %q, want %q", line, s, want[line])
+		}
+	}
+	for line, s := range want {
+		if got[line] == nil {
+			t.Errorf("line %d: no allocation, want %q", line, s)
+		}
+	}
+}
+
+// isExpectation reports whether the comment text is a list of
+// reasons, or "local", separated by semicolons.
+func isExpectation(text string) bool {
+outer:
+	for _, s := range strings.Split(text, "; ") {
+		if s == "local" {
+			continue
+		}
+		for r := escape.Return; r <= escape.Call; r++ {
+			if s == r.String() {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// allFunctions returns the functions of pkg that have bodies.
+func allFunctions(pkg *ssa.Package) map[*ssa.Function]bool {
+	fns := make(map[*ssa.Function]bool)
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn.Blocks != nil {
+			fns[fn] = true
+		}
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+	for _, mem := range pkg.Members {
+		if fn, ok := mem.(*ssa.Function); ok {
+			add(fn)
+		}
+	}
+	return fns
+}
\ No newline at end of file
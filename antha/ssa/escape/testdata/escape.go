@@ -0,0 +1,110 @@
+// antha-tools/antha/ssa/escape/testdata/escape.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// +build ignore
+
+package main
+
+// This file is the input to TestAnalyzePackage in escape_test.go.
+// Each variable allocated by new or &T{} is commented with the reason
+// it escapes, or "local" if it does not.
+
+type T struct {
+	p *T
+	n int
+}
+
+var global *T
+
+var ch = make(chan *T, 1)
+
+func returned() *T {
+	return new(T) // returned
+}
+
+func indirect() T {
+	t := &T{p: &T{}} // local; returned
+	return *t
+}
+
+func stored() {
+	global = new(T) // stored in a global
+	var t T
+	t.p = new(T) // local
+	print(t.p.n)
+}
+
+func sent() {
+	ch <- new(T) // sent on a channel
+}
+
+func captured() func() int {
+	t := new(T) // captured by an escaping closure
+	return func() int { return t.n }
+}
+
+func calledClosure() int {
+	t := new(T) // local
+	f := func() int { return t.n }
+	return f()
+}
+
+func throughParam(p *T) {
+	p.p = new(T) // stored through a parameter
+}
+
+func keep(t *T) {
+	global = t
+}
+
+func id(t *T) *T {
+	return t
+}
+
+func interprocedural() int {
+	keep(new(T))         // stored in a global
+	t := id(new(T))      // local
+	throughParam(new(T)) // local
+	return t.n
+}
+
+func goroutine() {
+	go keep(new(T)) // passed to a goroutine
+}
+
+func unknown(f func(*T)) {
+	f(new(T)) // passed to an unknown function
+}
+
+func recursive(n int, t *T) *T {
+	if n == 0 {
+		return t
+	}
+	return recursive(n-1, &T{p: t}) // returned
+}
+
+func maps() {
+	m := make(map[int]*T)
+	m[0] = new(T) // local
+	print(m[1].n)
+}
\ No newline at end of file
@@ -355,6 +355,10 @@ func (f *Function) finishBody() {
 
 	f.namedResults = nil // (used by lifting)
 
+	if f.Prog.mode&OptimizeFunctions != 0 {
+		optimize(f)
+	}
+
 	numberRegisters(f)
 
 	if f.Prog.mode&LogFunctions != 0 {
@@ -553,6 +557,15 @@ func (f *Function) WriteTo(w io.Writer) (int64, error) {
 
 // WriteFunction writes to buf a human-readable "disassembly" of f.
 func WriteFunction(buf *bytes.Buffer, f *Function) {
+	WriteFunctionNotes(buf, f, nil)
+}
+
+// WriteFunctionNotes is like WriteFunction, but follows each
+// instruction by the comment returned for it by note, if non-empty,
+// so that the results of analyses may be displayed with the code.
+// note may be nil.
+//
+func WriteFunctionNotes(buf *bytes.Buffer, f *Function, note func(Instruction) string) {
 	fmt.Fprintf(buf, "# Name: %s\n", f.String())
 	if f.Pkg != nil {
 		fmt.Fprintf(buf, "# Package: %s\n", f.Pkg.Object.Path())
@@ -639,6 +652,11 @@ func WriteFunction(buf *bytes.Buffer, f *Function) {
 			default:
 				buf.WriteString(instr.String())
 			}
+			if note != nil && instr != nil {
+				if s := note(instr); s != "" {
+					buf.WriteString("\t# " + s)
+				}
+			}
 			buf.WriteString("\n")
 		}
 	}
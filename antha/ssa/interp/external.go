@@ -27,7 +27,6 @@ package interp
 // external or because they use "unsafe" or "reflect" operations.
 
 import (
-	"math"
 	"os"
 	"runtime"
 	"syscall"
@@ -85,18 +84,10 @@ func init() {
 		"(reflect.rtype).Out":              ext۰reflect۰rtype۰Out,
 		"(reflect.rtype).Size":             ext۰reflect۰rtype۰Size,
 		"(reflect.rtype).String":           ext۰reflect۰rtype۰String,
+		"bytes.Compare":                    ext۰bytes۰Compare,
 		"bytes.Equal":                      ext۰bytes۰Equal,
 		"bytes.IndexByte":                  ext۰bytes۰IndexByte,
 		"hash/crc32.haveSSE42":             ext۰crc32۰haveSSE42,
-		"math.Abs":                         ext۰math۰Abs,
-		"math.Exp":                         ext۰math۰Exp,
-		"math.Float32bits":                 ext۰math۰Float32bits,
-		"math.Float32frombits":             ext۰math۰Float32frombits,
-		"math.Float64bits":                 ext۰math۰Float64bits,
-		"math.Float64frombits":             ext۰math۰Float64frombits,
-		"math.Ldexp":                       ext۰math۰Ldexp,
-		"math.Log":                         ext۰math۰Log,
-		"math.Min":                         ext۰math۰Min,
 		"reflect.New":                      ext۰reflect۰New,
 		"reflect.TypeOf":                   ext۰reflect۰TypeOf,
 		"reflect.ValueOf":                  ext۰reflect۰ValueOf,
@@ -146,6 +137,9 @@ func init() {
 		"syscall.Write":                    ext۰syscall۰Write,
 		"time.Sleep":                       ext۰time۰Sleep,
 		"time.now":                         ext۰time۰now,
+		"time.runtimeNano":                 ext۰time۰runtimeNano,
+		"time.startTimer":                  ext۰time۰startTimer,
+		"time.stopTimer":                   ext۰time۰stopTimer,
 	}
 }
 
@@ -171,6 +165,27 @@ func ext۰sync۰Pool۰Put(fr *frame, args []value) value {
 	return nil
 }
 
+func ext۰bytes۰Compare(fr *frame, args []value) value {
+	// func Compare(a, b []byte) int
+	a := args[0].([]value)
+	b := args[1].([]value)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x, y := a[i].(byte), b[i].(byte); x != y {
+			if x < y {
+				return -1
+			}
+			return +1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return +1
+	}
+	return 0
+}
+
 func ext۰bytes۰Equal(fr *frame, args []value) value {
 	// func Equal(a, b []byte) bool
 	a := args[0].([]value)
@@ -202,42 +217,6 @@ func ext۰crc32۰haveSSE42(fr *frame, args []value) value {
 	return false
 }
 
-func ext۰math۰Float64frombits(fr *frame, args []value) value {
-	return math.Float64frombits(args[0].(uint64))
-}
-
-func ext۰math۰Float64bits(fr *frame, args []value) value {
-	return math.Float64bits(args[0].(float64))
-}
-
-func ext۰math۰Float32frombits(fr *frame, args []value) value {
-	return math.Float32frombits(args[0].(uint32))
-}
-
-func ext۰math۰Abs(fr *frame, args []value) value {
-	return math.Abs(args[0].(float64))
-}
-
-func ext۰math۰Exp(fr *frame, args []value) value {
-	return math.Exp(args[0].(float64))
-}
-
-func ext۰math۰Float32bits(fr *frame, args []value) value {
-	return math.Float32bits(args[0].(float32))
-}
-
-func ext۰math۰Min(fr *frame, args []value) value {
-	return math.Min(args[0].(float64), args[1].(float64))
-}
-
-func ext۰math۰Ldexp(fr *frame, args []value) value {
-	return math.Ldexp(args[0].(float64), args[1].(int))
-}
-
-func ext۰math۰Log(fr *frame, args []value) value {
-	return math.Log(args[0].(float64))
-}
-
 func ext۰runtime۰Breakpoint(fr *frame, args []value) value {
 	runtime.Breakpoint()
 	return nil
@@ -0,0 +1,296 @@
+// antha-tools/antha/ssa/interp/external_fast.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+// Fast paths for standard library functions.
+//
+// The functions below have SSA code and could be interpreted, but
+// they are called so often by real programs (number formatting,
+// string searching, sorting) that emulating them with the host's
+// implementation makes a substantial difference to running time.  Only functions whose
+// results contain no values of package-specific types (such as
+// *strconv.NumError) are emulated, so that the target program cannot
+// observe the difference.
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// The fast paths are pure functions, so they are not traced:
+	// doing so would only bloat the trace and make its replay
+	// depend on them.
+	for name, fn := range map[string]externalFn{
+		"bytes.Contains":      ext۰bytes۰Contains,
+		"bytes.Count":         ext۰bytes۰Count,
+		"bytes.EqualFold":     ext۰bytes۰EqualFold,
+		"bytes.HasPrefix":     ext۰bytes۰HasPrefix,
+		"bytes.HasSuffix":     ext۰bytes۰HasSuffix,
+		"bytes.Index":         ext۰bytes۰Index,
+		"bytes.IndexRune":     ext۰bytes۰IndexRune,
+		"bytes.LastIndex":     ext۰bytes۰LastIndex,
+		"sort.Float64s":       ext۰sort۰Float64s,
+		"sort.Ints":           ext۰sort۰Ints,
+		"sort.Strings":        ext۰sort۰Strings,
+		"strconv.FormatFloat": ext۰strconv۰FormatFloat,
+		"strconv.FormatInt":   ext۰strconv۰FormatInt,
+		"strconv.FormatUint":  ext۰strconv۰FormatUint,
+		"strconv.Itoa":        ext۰strconv۰Itoa,
+		"strconv.Quote":       ext۰strconv۰Quote,
+		"strings.Contains":    ext۰strings۰Contains,
+		"strings.Count":       ext۰strings۰Count,
+		"strings.EqualFold":   ext۰strings۰EqualFold,
+		"strings.Fields":      ext۰strings۰Fields,
+		"strings.HasPrefix":   ext۰strings۰HasPrefix,
+		"strings.HasSuffix":   ext۰strings۰HasSuffix,
+		"strings.Index":       ext۰strings۰Index,
+		"strings.IndexAny":    ext۰strings۰IndexAny,
+		"strings.IndexRune":   ext۰strings۰IndexRune,
+		"strings.Join":        ext۰strings۰Join,
+		"strings.LastIndex":   ext۰strings۰LastIndex,
+		"strings.Repeat":      ext۰strings۰Repeat,
+		"strings.Replace":     ext۰strings۰Replace,
+		"strings.Split":       ext۰strings۰Split,
+		"strings.ToLower":     ext۰strings۰ToLower,
+		"strings.ToUpper":     ext۰strings۰ToUpper,
+		"strings.TrimSpace":   ext۰strings۰TrimSpace,
+	} {
+		externals[name] = fn
+		untracedExternals[name] = true
+	}
+}
+
+// The bytes fast paths are only those whose results are not slices,
+// which would have to alias their arguments.
+
+func ext۰bytes۰Contains(fr *frame, args []value) value {
+	// func Contains(b, subslice []byte) bool
+	return bytes.Contains(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰Count(fr *frame, args []value) value {
+	// func Count(s, sep []byte) int
+	return bytes.Count(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰EqualFold(fr *frame, args []value) value {
+	// func EqualFold(s, t []byte) bool
+	return bytes.EqualFold(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰HasPrefix(fr *frame, args []value) value {
+	// func HasPrefix(s, prefix []byte) bool
+	return bytes.HasPrefix(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰HasSuffix(fr *frame, args []value) value {
+	// func HasSuffix(s, suffix []byte) bool
+	return bytes.HasSuffix(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰Index(fr *frame, args []value) value {
+	// func Index(s, sep []byte) int
+	return bytes.Index(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰bytes۰IndexRune(fr *frame, args []value) value {
+	// func IndexRune(s []byte, r rune) int
+	return bytes.IndexRune(valueToBytes(args[0]), args[1].(rune))
+}
+
+func ext۰bytes۰LastIndex(fr *frame, args []value) value {
+	// func LastIndex(s, sep []byte) int
+	return bytes.LastIndex(valueToBytes(args[0]), valueToBytes(args[1]))
+}
+
+func ext۰sort۰Float64s(fr *frame, args []value) value {
+	// func Float64s(a []float64)
+	a := args[0].([]value)
+	fs := make([]float64, len(a))
+	for i, v := range a {
+		fs[i] = v.(float64)
+	}
+	sort.Float64s(fs)
+	for i, f := range fs {
+		a[i] = f
+	}
+	return nil
+}
+
+func ext۰sort۰Ints(fr *frame, args []value) value {
+	// func Ints(a []int)
+	a := args[0].([]value)
+	is := make([]int, len(a))
+	for i, v := range a {
+		is[i] = v.(int)
+	}
+	sort.Ints(is)
+	for i, x := range is {
+		a[i] = x
+	}
+	return nil
+}
+
+func ext۰sort۰Strings(fr *frame, args []value) value {
+	// func Strings(a []string)
+	a := args[0].([]value)
+	ss := make([]string, len(a))
+	for i, v := range a {
+		ss[i] = v.(string)
+	}
+	sort.Strings(ss)
+	for i, s := range ss {
+		a[i] = s
+	}
+	return nil
+}
+
+func ext۰strconv۰FormatFloat(fr *frame, args []value) value {
+	// func FormatFloat(f float64, fmt byte, prec, bitSize int) string
+	return strconv.FormatFloat(args[0].(float64), args[1].(byte), args[2].(int), args[3].(int))
+}
+
+func ext۰strconv۰FormatInt(fr *frame, args []value) value {
+	// func FormatInt(i int64, base int) string
+	return strconv.FormatInt(args[0].(int64), args[1].(int))
+}
+
+func ext۰strconv۰FormatUint(fr *frame, args []value) value {
+	// func FormatUint(i uint64, base int) string
+	return strconv.FormatUint(args[0].(uint64), args[1].(int))
+}
+
+func ext۰strconv۰Itoa(fr *frame, args []value) value {
+	// func Itoa(i int) string
+	return strconv.Itoa(args[0].(int))
+}
+
+func ext۰strconv۰Quote(fr *frame, args []value) value {
+	// func Quote(s string) string
+	return strconv.Quote(args[0].(string))
+}
+
+func ext۰strings۰Contains(fr *frame, args []value) value {
+	// func Contains(s, substr string) bool
+	return strings.Contains(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰Index(fr *frame, args []value) value {
+	// func Index(s, sep string) int
+	return strings.Index(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰LastIndex(fr *frame, args []value) value {
+	// func LastIndex(s, sep string) int
+	return strings.LastIndex(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰Count(fr *frame, args []value) value {
+	// func Count(s, sep string) int
+	return strings.Count(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰EqualFold(fr *frame, args []value) value {
+	// func EqualFold(s, t string) bool
+	return strings.EqualFold(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰Fields(fr *frame, args []value) value {
+	// func Fields(s string) []string
+	return stringsToValues(strings.Fields(args[0].(string)))
+}
+
+func ext۰strings۰HasPrefix(fr *frame, args []value) value {
+	// func HasPrefix(s, prefix string) bool
+	return strings.HasPrefix(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰HasSuffix(fr *frame, args []value) value {
+	// func HasSuffix(s, suffix string) bool
+	return strings.HasSuffix(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰IndexAny(fr *frame, args []value) value {
+	// func IndexAny(s, chars string) int
+	return strings.IndexAny(args[0].(string), args[1].(string))
+}
+
+func ext۰strings۰IndexRune(fr *frame, args []value) value {
+	// func IndexRune(s string, r rune) int
+	return strings.IndexRune(args[0].(string), args[1].(rune))
+}
+
+func ext۰strings۰Join(fr *frame, args []value) value {
+	// func Join(a []string, sep string) string
+	var a []string
+	for _, s := range args[0].([]value) {
+		a = append(a, s.(string))
+	}
+	return strings.Join(a, args[1].(string))
+}
+
+func ext۰strings۰Repeat(fr *frame, args []value) value {
+	// func Repeat(s string, count int) string
+	return strings.Repeat(args[0].(string), args[1].(int))
+}
+
+func ext۰strings۰Replace(fr *frame, args []value) value {
+	// func Replace(s, old, new string, n int) string
+	return strings.Replace(args[0].(string), args[1].(string), args[2].(string), args[3].(int))
+}
+
+func ext۰strings۰Split(fr *frame, args []value) value {
+	// func Split(s, sep string) []string
+	return stringsToValues(strings.Split(args[0].(string), args[1].(string)))
+}
+
+func ext۰strings۰ToLower(fr *frame, args []value) value {
+	// func ToLower(s string) string
+	return strings.ToLower(args[0].(string))
+}
+
+func ext۰strings۰ToUpper(fr *frame, args []value) value {
+	// func ToUpper(s string) string
+	return strings.ToUpper(args[0].(string))
+}
+
+func ext۰strings۰TrimSpace(fr *frame, args []value) value {
+	// func TrimSpace(s string) string
+	return strings.TrimSpace(args[0].(string))
+}
+
+// stringsToValues returns the interpreted form of the []string ss.
+func stringsToValues(ss []string) value {
+	if ss == nil {
+		return []value(nil)
+	}
+	vs := make([]value, len(ss))
+	for i, s := range ss {
+		vs[i] = s
+	}
+	return vs
+}
\ No newline at end of file
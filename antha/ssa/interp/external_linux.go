@@ -0,0 +1,41 @@
+// antha-tools/antha/ssa/interp/external_linux.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// +build linux
+
+package interp
+
+import "syscall"
+
+func init() {
+	externals["syscall.Pipe2"] = ext۰syscall۰Pipe2
+}
+
+func ext۰syscall۰Pipe2(fr *frame, args []value) value {
+	// func Pipe2(p []int, flags int) (err error)
+	p := args[0].([]value)
+	var fds [2]int
+	err := syscall.Pipe2(fds[:], args[1].(int))
+	p[0], p[1] = fds[0], fds[1]
+	return wrapError(err)
+}
\ No newline at end of file
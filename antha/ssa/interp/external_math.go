@@ -0,0 +1,158 @@
+// antha-tools/antha/ssa/interp/external_math.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+// Emulated "math" functions.
+//
+// Many math functions are implemented in assembly on some platforms
+// and so have no SSA code; the rest are emulated anyway since calling
+// the host's implementation is far faster than interpreting it.
+// Functions are grouped by signature so that each group needs only a
+// single adapter.  Like the fast paths, they are pure and so are not
+// traced.
+
+import "math"
+
+func init() {
+	for name, fn := range map[string]func(float64) float64{
+		"Abs":   math.Abs,
+		"Acos":  math.Acos,
+		"Acosh": math.Acosh,
+		"Asin":  math.Asin,
+		"Asinh": math.Asinh,
+		"Atan":  math.Atan,
+		"Atanh": math.Atanh,
+		"Cbrt":  math.Cbrt,
+		"Ceil":  math.Ceil,
+		"Cos":   math.Cos,
+		"Cosh":  math.Cosh,
+		"Erf":   math.Erf,
+		"Erfc":  math.Erfc,
+		"Exp":   math.Exp,
+		"Exp2":  math.Exp2,
+		"Expm1": math.Expm1,
+		"Floor": math.Floor,
+		"Gamma": math.Gamma,
+		"Log":   math.Log,
+		"Log10": math.Log10,
+		"Log1p": math.Log1p,
+		"Log2":  math.Log2,
+		"Logb":  math.Logb,
+		"Sin":   math.Sin,
+		"Sinh":  math.Sinh,
+		"Sqrt":  math.Sqrt,
+		"Tan":   math.Tan,
+		"Tanh":  math.Tanh,
+		"Trunc": math.Trunc,
+	} {
+		externals["math."+name] = mathUnary(fn)
+		untracedExternals["math."+name] = true
+	}
+
+	for name, fn := range map[string]func(float64, float64) float64{
+		"Atan2":     math.Atan2,
+		"Dim":       math.Dim,
+		"Hypot":     math.Hypot,
+		"Max":       math.Max,
+		"Min":       math.Min,
+		"Mod":       math.Mod,
+		"Nextafter": math.Nextafter,
+		"Pow":       math.Pow,
+		"Remainder": math.Remainder,
+	} {
+		externals["math."+name] = mathBinary(fn)
+		untracedExternals["math."+name] = true
+	}
+
+	for name, fn := range map[string]externalFn{
+		"Float32bits":     ext۰math۰Float32bits,
+		"Float32frombits": ext۰math۰Float32frombits,
+		"Float64bits":     ext۰math۰Float64bits,
+		"Float64frombits": ext۰math۰Float64frombits,
+		"Frexp":           ext۰math۰Frexp,
+		"Ilogb":           ext۰math۰Ilogb,
+		"Ldexp":           ext۰math۰Ldexp,
+		"Modf":            ext۰math۰Modf,
+		"Sincos":          ext۰math۰Sincos,
+	} {
+		externals["math."+name] = fn
+		untracedExternals["math."+name] = true
+	}
+}
+
+// mathUnary adapts a host function of type func(float64) float64.
+func mathUnary(fn func(float64) float64) externalFn {
+	return func(fr *frame, args []value) value {
+		return fn(args[0].(float64))
+	}
+}
+
+// mathBinary adapts a host function of type func(float64, float64) float64.
+func mathBinary(fn func(float64, float64) float64) externalFn {
+	return func(fr *frame, args []value) value {
+		return fn(args[0].(float64), args[1].(float64))
+	}
+}
+
+func ext۰math۰Float32bits(fr *frame, args []value) value {
+	return math.Float32bits(args[0].(float32))
+}
+
+func ext۰math۰Float32frombits(fr *frame, args []value) value {
+	return math.Float32frombits(args[0].(uint32))
+}
+
+func ext۰math۰Float64bits(fr *frame, args []value) value {
+	return math.Float64bits(args[0].(float64))
+}
+
+func ext۰math۰Float64frombits(fr *frame, args []value) value {
+	return math.Float64frombits(args[0].(uint64))
+}
+
+func ext۰math۰Frexp(fr *frame, args []value) value {
+	// func Frexp(f float64) (frac float64, exp int)
+	frac, exp := math.Frexp(args[0].(float64))
+	return tuple{frac, exp}
+}
+
+func ext۰math۰Ilogb(fr *frame, args []value) value {
+	return math.Ilogb(args[0].(float64))
+}
+
+func ext۰math۰Ldexp(fr *frame, args []value) value {
+	return math.Ldexp(args[0].(float64), args[1].(int))
+}
+
+func ext۰math۰Modf(fr *frame, args []value) value {
+	// func Modf(f float64) (int float64, frac float64)
+	i, frac := math.Modf(args[0].(float64))
+	return tuple{i, frac}
+}
+
+func ext۰math۰Sincos(fr *frame, args []value) value {
+	// func Sincos(x float64) (sin, cos float64)
+	sin, cos := math.Sincos(args[0].(float64))
+	return tuple{sin, cos}
+}
\ No newline at end of file
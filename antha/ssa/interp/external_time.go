@@ -0,0 +1,110 @@
+// antha-tools/antha/ssa/interp/external_time.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+// Emulated timers for the "time" package.
+//
+// The runtime implements the time package's timers: startTimer
+// arranges for t.f(now, t.arg) to be called at time t.when, and every
+// t.period nanoseconds thereafter if t.period > 0; stopTimer cancels
+// it.  We emulate each active timer with a host goroutine.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha/token"
+)
+
+var (
+	timersMu sync.Mutex
+	timers   = make(map[*value]chan struct{}) // maps each active *runtimeTimer to its stop channel
+)
+
+// timerField returns the index of the named field of time.runtimeTimer.
+func timerField(i *interpreter, name string) int {
+	obj := i.prog.ImportedPackage("time").Type("runtimeTimer").Object()
+	_, index, _ := types.LookupFieldOrMethod(obj.Type(), obj.Pkg(), name)
+	return index[0]
+}
+
+func ext۰time۰runtimeNano(fr *frame, args []value) value {
+	// func runtimeNano() int64
+	return time.Now().UnixNano()
+}
+
+func ext۰time۰startTimer(fr *frame, args []value) value {
+	// func startTimer(*runtimeTimer)
+	t := args[0].(*value)
+	fields := (*t).(structure)
+	when := fields[timerField(fr.i, "when")].(int64)
+	period := fields[timerField(fr.i, "period")].(int64)
+	f := fields[timerField(fr.i, "f")]
+	arg := fields[timerField(fr.i, "arg")]
+
+	stop := make(chan struct{})
+	timersMu.Lock()
+	timers[t] = stop
+	timersMu.Unlock()
+
+	// The callback runs on its own goroutine, whose identity
+	// must be allocated now, by its creator.
+	g := fr.g.spawn()
+	i := fr.i
+	go func() {
+		d := time.Duration(when - time.Now().UnixNano())
+		for {
+			select {
+			case <-time.After(d):
+			case <-stop:
+				return
+			}
+			callOn(i, g, nil, token.NoPos, f, []value{time.Now().UnixNano(), arg})
+			if period <= 0 {
+				timersMu.Lock()
+				if timers[t] == stop {
+					delete(timers, t)
+				}
+				timersMu.Unlock()
+				return
+			}
+			d = time.Duration(period)
+		}
+	}()
+	return nil
+}
+
+func ext۰time۰stopTimer(fr *frame, args []value) value {
+	// func stopTimer(*runtimeTimer) bool
+	t := args[0].(*value)
+	timersMu.Lock()
+	defer timersMu.Unlock()
+	stop, ok := timers[t]
+	if ok {
+		close(stop)
+		delete(timers, t)
+	}
+	return ok
+}
\ No newline at end of file
@@ -25,24 +25,78 @@
 
 package interp
 
-import "syscall"
-
-func fillStat(st *syscall.Stat_t, stat structure) {
-	stat[0] = st.Dev
-	stat[1] = st.Ino
-	stat[2] = st.Nlink
-	stat[3] = st.Mode
-	stat[4] = st.Uid
-	stat[5] = st.Gid
-
-	stat[7] = st.Rdev
-	stat[8] = st.Size
-	stat[9] = st.Blksize
-	stat[10] = st.Blocks
-	// TODO(adonovan): fix: copy Timespecs.
-	// stat[11] = st.Atim
-	// stat[12] = st.Mtim
-	// stat[13] = st.Ctim
+import (
+	"reflect"
+	"syscall"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+func init() {
+	externals["syscall.Chdir"] = ext۰syscall۰Chdir
+	externals["syscall.Chmod"] = ext۰syscall۰Chmod
+	externals["syscall.Chown"] = ext۰syscall۰Chown
+	externals["syscall.Dup"] = ext۰syscall۰Dup
+	externals["syscall.Fchdir"] = ext۰syscall۰Fchdir
+	externals["syscall.Fchmod"] = ext۰syscall۰Fchmod
+	externals["syscall.Fchown"] = ext۰syscall۰Fchown
+	externals["syscall.Fsync"] = ext۰syscall۰Fsync
+	externals["syscall.Ftruncate"] = ext۰syscall۰Ftruncate
+	externals["syscall.Getegid"] = ext۰syscall۰Getegid
+	externals["syscall.Geteuid"] = ext۰syscall۰Geteuid
+	externals["syscall.Getgid"] = ext۰syscall۰Getgid
+	externals["syscall.Getgroups"] = ext۰syscall۰Getgroups
+	externals["syscall.Getpagesize"] = ext۰syscall۰Getpagesize
+	externals["syscall.Getppid"] = ext۰syscall۰Getppid
+	externals["syscall.Getuid"] = ext۰syscall۰Getuid
+	externals["syscall.Lchown"] = ext۰syscall۰Lchown
+	externals["syscall.Link"] = ext۰syscall۰Link
+	externals["syscall.Mkdir"] = ext۰syscall۰Mkdir
+	externals["syscall.Pipe"] = ext۰syscall۰Pipe
+	externals["syscall.Pread"] = ext۰syscall۰Pread
+	externals["syscall.Pwrite"] = ext۰syscall۰Pwrite
+	externals["syscall.Readlink"] = ext۰syscall۰Readlink
+	externals["syscall.Rename"] = ext۰syscall۰Rename
+	externals["syscall.Rmdir"] = ext۰syscall۰Rmdir
+	externals["syscall.Seek"] = ext۰syscall۰Seek
+	externals["syscall.Symlink"] = ext۰syscall۰Symlink
+	externals["syscall.Truncate"] = ext۰syscall۰Truncate
+	externals["syscall.Umask"] = ext۰syscall۰Umask
+	externals["syscall.Unlink"] = ext۰syscall۰Unlink
+	externals["syscall.UtimesNano"] = ext۰syscall۰UtimesNano
+}
+
+// fillStat copies st to stat, the interpreted syscall.Stat_t, field by
+// field, since the layout of Stat_t varies with the operating system
+// and architecture.  Timespecs are copied too; padding is not.
+func fillStat(fr *frame, st *syscall.Stat_t, stat structure) {
+	obj := fr.i.prog.ImportedPackage("syscall").Type("Stat_t").Object()
+	v := reflect.ValueOf(st).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		_, index, _ := types.LookupFieldOrMethod(obj.Type(), obj.Pkg(), v.Type().Field(i).Name)
+		if len(index) != 1 {
+			continue
+		}
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Array:
+			// padding
+		case reflect.Struct:
+			// Timespec
+			stat[index[0]] = structure{f.Field(0).Interface(), f.Field(1).Interface()}
+		default:
+			stat[index[0]] = f.Interface()
+		}
+	}
+}
+
+// timespec returns the host form of v, an interpreted syscall.Timespec.
+func timespec(v value) syscall.Timespec {
+	var ts syscall.Timespec
+	t := reflect.ValueOf(&ts).Elem()
+	for i, f := range v.(structure) {
+		t.Field(i).Set(reflect.ValueOf(f))
+	}
+	return ts
 }
 
 func ext۰syscall۰Close(fr *frame, args []value) value {
@@ -57,7 +111,7 @@ func ext۰syscall۰Fstat(fr *frame, args []value) value {
 
 	var st syscall.Stat_t
 	err := syscall.Fstat(fd, &st)
-	fillStat(&st, stat)
+	fillStat(fr, &st, stat)
 	return wrapError(err)
 }
 
@@ -85,7 +139,7 @@ func ext۰syscall۰Lstat(fr *frame, args []value) value {
 
 	var st syscall.Stat_t
 	err := syscall.Lstat(name, &st)
-	fillStat(&st, stat)
+	fillStat(fr, &st, stat)
 	return wrapError(err)
 }
 
@@ -132,7 +186,7 @@ func ext۰syscall۰Stat(fr *frame, args []value) value {
 
 	var st syscall.Stat_t
 	err := syscall.Stat(name, &st)
-	fillStat(&st, stat)
+	fillStat(fr, &st, stat)
 	return wrapError(err)
 }
 
@@ -142,6 +196,184 @@ func ext۰syscall۰Write(fr *frame, args []value) value {
 	return tuple{n, wrapError(err)}
 }
 
+func ext۰syscall۰Chdir(fr *frame, args []value) value {
+	// func Chdir(path string) (err error)
+	return wrapError(syscall.Chdir(args[0].(string)))
+}
+
+func ext۰syscall۰Chmod(fr *frame, args []value) value {
+	// func Chmod(path string, mode uint32) (err error)
+	return wrapError(syscall.Chmod(args[0].(string), args[1].(uint32)))
+}
+
+func ext۰syscall۰Chown(fr *frame, args []value) value {
+	// func Chown(path string, uid int, gid int) (err error)
+	return wrapError(syscall.Chown(args[0].(string), args[1].(int), args[2].(int)))
+}
+
+func ext۰syscall۰Dup(fr *frame, args []value) value {
+	// func Dup(oldfd int) (fd int, err error)
+	fd, err := syscall.Dup(args[0].(int))
+	return tuple{fd, wrapError(err)}
+}
+
+func ext۰syscall۰Fchdir(fr *frame, args []value) value {
+	// func Fchdir(fd int) (err error)
+	return wrapError(syscall.Fchdir(args[0].(int)))
+}
+
+func ext۰syscall۰Fchmod(fr *frame, args []value) value {
+	// func Fchmod(fd int, mode uint32) (err error)
+	return wrapError(syscall.Fchmod(args[0].(int), args[1].(uint32)))
+}
+
+func ext۰syscall۰Fchown(fr *frame, args []value) value {
+	// func Fchown(fd int, uid int, gid int) (err error)
+	return wrapError(syscall.Fchown(args[0].(int), args[1].(int), args[2].(int)))
+}
+
+func ext۰syscall۰Fsync(fr *frame, args []value) value {
+	// func Fsync(fd int) (err error)
+	return wrapError(syscall.Fsync(args[0].(int)))
+}
+
+func ext۰syscall۰Ftruncate(fr *frame, args []value) value {
+	// func Ftruncate(fd int, length int64) (err error)
+	return wrapError(syscall.Ftruncate(args[0].(int), args[1].(int64)))
+}
+
+func ext۰syscall۰Getegid(fr *frame, args []value) value {
+	return syscall.Getegid()
+}
+
+func ext۰syscall۰Geteuid(fr *frame, args []value) value {
+	return syscall.Geteuid()
+}
+
+func ext۰syscall۰Getgid(fr *frame, args []value) value {
+	return syscall.Getgid()
+}
+
+func ext۰syscall۰Getgroups(fr *frame, args []value) value {
+	// func Getgroups() (gids []int, err error)
+	gids, err := syscall.Getgroups()
+	var igids []value
+	for _, gid := range gids {
+		igids = append(igids, gid)
+	}
+	return tuple{igids, wrapError(err)}
+}
+
+func ext۰syscall۰Getpagesize(fr *frame, args []value) value {
+	return syscall.Getpagesize()
+}
+
+func ext۰syscall۰Getppid(fr *frame, args []value) value {
+	return syscall.Getppid()
+}
+
+func ext۰syscall۰Getuid(fr *frame, args []value) value {
+	return syscall.Getuid()
+}
+
+func ext۰syscall۰Lchown(fr *frame, args []value) value {
+	// func Lchown(path string, uid int, gid int) (err error)
+	return wrapError(syscall.Lchown(args[0].(string), args[1].(int), args[2].(int)))
+}
+
+func ext۰syscall۰Link(fr *frame, args []value) value {
+	// func Link(oldpath string, newpath string) (err error)
+	return wrapError(syscall.Link(args[0].(string), args[1].(string)))
+}
+
+func ext۰syscall۰Mkdir(fr *frame, args []value) value {
+	// func Mkdir(path string, mode uint32) (err error)
+	return wrapError(syscall.Mkdir(args[0].(string), args[1].(uint32)))
+}
+
+func ext۰syscall۰Pipe(fr *frame, args []value) value {
+	// func Pipe(p []int) (err error)
+	p := args[0].([]value)
+	var fds [2]int
+	err := syscall.Pipe(fds[:])
+	p[0], p[1] = fds[0], fds[1]
+	return wrapError(err)
+}
+
+func ext۰syscall۰Pread(fr *frame, args []value) value {
+	// func Pread(fd int, p []byte, offset int64) (n int, err error)
+	fd := args[0].(int)
+	p := args[1].([]value)
+	b := make([]byte, len(p))
+	n, err := syscall.Pread(fd, b, args[2].(int64))
+	for i := 0; i < n; i++ {
+		p[i] = b[i]
+	}
+	return tuple{n, wrapError(err)}
+}
+
+func ext۰syscall۰Pwrite(fr *frame, args []value) value {
+	// func Pwrite(fd int, p []byte, offset int64) (n int, err error)
+	n, err := syscall.Pwrite(args[0].(int), valueToBytes(args[1]), args[2].(int64))
+	return tuple{n, wrapError(err)}
+}
+
+func ext۰syscall۰Readlink(fr *frame, args []value) value {
+	// func Readlink(path string, buf []byte) (n int, err error)
+	p := args[1].([]value)
+	b := make([]byte, len(p))
+	n, err := syscall.Readlink(args[0].(string), b)
+	for i := 0; i < n; i++ {
+		p[i] = b[i]
+	}
+	return tuple{n, wrapError(err)}
+}
+
+func ext۰syscall۰Rename(fr *frame, args []value) value {
+	// func Rename(oldpath string, newpath string) (err error)
+	return wrapError(syscall.Rename(args[0].(string), args[1].(string)))
+}
+
+func ext۰syscall۰Rmdir(fr *frame, args []value) value {
+	// func Rmdir(path string) (err error)
+	return wrapError(syscall.Rmdir(args[0].(string)))
+}
+
+func ext۰syscall۰Seek(fr *frame, args []value) value {
+	// func Seek(fd int, offset int64, whence int) (off int64, err error)
+	off, err := syscall.Seek(args[0].(int), args[1].(int64), args[2].(int))
+	return tuple{off, wrapError(err)}
+}
+
+func ext۰syscall۰Symlink(fr *frame, args []value) value {
+	// func Symlink(oldpath string, newpath string) (err error)
+	return wrapError(syscall.Symlink(args[0].(string), args[1].(string)))
+}
+
+func ext۰syscall۰Truncate(fr *frame, args []value) value {
+	// func Truncate(path string, length int64) (err error)
+	return wrapError(syscall.Truncate(args[0].(string), args[1].(int64)))
+}
+
+func ext۰syscall۰Umask(fr *frame, args []value) value {
+	// func Umask(mask int) (oldmask int)
+	return syscall.Umask(args[0].(int))
+}
+
+func ext۰syscall۰Unlink(fr *frame, args []value) value {
+	// func Unlink(path string) (err error)
+	return wrapError(syscall.Unlink(args[0].(string)))
+}
+
+func ext۰syscall۰UtimesNano(fr *frame, args []value) value {
+	// func UtimesNano(path string, ts []Timespec) (err error)
+	var ts []syscall.Timespec
+	for _, t := range args[1].([]value) {
+		ts = append(ts, timespec(t))
+	}
+	return wrapError(syscall.UtimesNano(args[0].(string), ts))
+}
+
 func ext۰syscall۰RawSyscall(fr *frame, args []value) value {
 	return tuple{uintptr(0), uintptr(0), uintptr(syscall.ENOSYS)}
 }
@@ -66,6 +66,7 @@ package interp
 import (
 	"fmt"
 	"github.com/antha-lang/antha/token"
+	"io"
 	"os"
 	"reflect"
 	"runtime"
@@ -102,6 +103,8 @@ type interpreter struct {
 	rtypeMethods       methodSet            // the method set of rtype, which implements the reflect.Type interface.
 	runtimeErrorString types.Type           // the runtime.errorString type
 	sizes              types.Sizes          // the effective type-sizing function
+	trace              *tracer              // records or replays nondeterministic events; may be nil
+	main               *goroutine           // the main goroutine
 }
 
 type deferred struct {
@@ -113,6 +116,7 @@ type deferred struct {
 
 type frame struct {
 	i                *interpreter
+	g                *goroutine
 	caller           *frame
 	fn               *ssa.Function
 	block, prevBlock *ssa.BasicBlock
@@ -208,6 +212,11 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 		// no-op
 
 	case *ssa.UnOp:
+		if t := fr.i.trace; t != nil && instr.Op == token.ARROW {
+			v, ok := t.recv(fr.g, instr.Pos(), fr.get(instr.X).(chan value))
+			fr.env[instr] = recvResult(instr, v, ok)
+			break
+		}
 		fr.env[instr] = unop(instr, fr.get(instr.X))
 
 	case *ssa.BinOp:
@@ -257,6 +266,10 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 		panic(targetPanic{fr.get(instr.X)})
 
 	case *ssa.Send:
+		if t := fr.i.trace; t != nil {
+			t.send(fr.g, instr.Pos(), fr.get(instr.Chan).(chan value), copyVal(fr.get(instr.X)))
+			break
+		}
 		fr.get(instr.Chan).(chan value) <- copyVal(fr.get(instr.X))
 
 	case *ssa.Store:
@@ -285,7 +298,7 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 
 	case *ssa.Go:
 		fn, args := prepareCall(fr, &instr.Call)
-		go call(fr.i, nil, instr.Pos(), fn, args)
+		go callOn(fr.i, fr.g.spawn(), nil, instr.Pos(), fn, args)
 
 	case *ssa.MakeChan:
 		fr.env[instr] = make(chan value, asInt(fr.get(instr.Size)))
@@ -380,32 +393,21 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 		}
 
 	case *ssa.Select:
-		var cases []reflect.SelectCase
-		if !instr.Blocking {
-			cases = append(cases, reflect.SelectCase{
-				Dir: reflect.SelectDefault,
-			})
-		}
-		for _, state := range instr.States {
-			var dir reflect.SelectDir
-			if state.Dir == types.RecvOnly {
-				dir = reflect.SelectRecv
-			} else {
-				dir = reflect.SelectSend
-			}
-			var send reflect.Value
-			if state.Send != nil {
-				send = reflect.ValueOf(fr.get(state.Send))
+		var chosen int
+		var recv value
+		var recvOk bool
+		if t := fr.i.trace; t != nil {
+			var cases []selectCase
+			for _, state := range instr.States {
+				c := selectCase{ch: fr.get(state.Chan).(chan value), send: state.Dir != types.RecvOnly}
+				if state.Send != nil {
+					c.v = fr.get(state.Send)
+				}
+				cases = append(cases, c)
 			}
-			cases = append(cases, reflect.SelectCase{
-				Dir:  dir,
-				Chan: reflect.ValueOf(fr.get(state.Chan)),
-				Send: send,
-			})
-		}
-		chosen, recv, recvOk := reflect.Select(cases)
-		if !instr.Blocking {
-			chosen-- // default case should have index -1.
+			chosen, recv, recvOk = t.selectStmt(fr.g, instr.Pos(), cases, instr.Blocking)
+		} else {
+			chosen, recv, recvOk = hostSelect(fr, instr)
 		}
 		r := tuple{chosen, recvOk}
 		for i, st := range instr.States {
@@ -413,7 +415,7 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 				var v value
 				if i == chosen && recvOk {
 					// No need to copy since send makes an unaliased copy.
-					v = recv.Interface().(value)
+					v = recv
 				} else {
 					v = zero(st.Chan.Type().Underlying().(*types.Chan).Elem())
 				}
@@ -433,6 +435,45 @@ func visitInstr(fr *frame, instr ssa.Instruction) continuation {
 	return kNext
 }
 
+// hostSelect performs select statement instr using the host's
+// channels.  It returns the index of the chosen case (-1 for the
+// default case) and the value received, if any, and whether it was
+// sent.
+func hostSelect(fr *frame, instr *ssa.Select) (int, value, bool) {
+	var cases []reflect.SelectCase
+	if !instr.Blocking {
+		cases = append(cases, reflect.SelectCase{
+			Dir: reflect.SelectDefault,
+		})
+	}
+	for _, state := range instr.States {
+		var dir reflect.SelectDir
+		if state.Dir == types.RecvOnly {
+			dir = reflect.SelectRecv
+		} else {
+			dir = reflect.SelectSend
+		}
+		var send reflect.Value
+		if state.Send != nil {
+			send = reflect.ValueOf(fr.get(state.Send))
+		}
+		cases = append(cases, reflect.SelectCase{
+			Dir:  dir,
+			Chan: reflect.ValueOf(fr.get(state.Chan)),
+			Send: send,
+		})
+	}
+	chosen, recv, recvOk := reflect.Select(cases)
+	if !instr.Blocking {
+		chosen-- // default case should have index -1.
+	}
+	var v value
+	if recvOk {
+		v = recv.Interface().(value)
+	}
+	return chosen, v, recvOk
+}
+
 // prepareCall determines the function value and argument values for a
 // function call in a Call, Go or Defer instruction, performing
 // interface method lookup if needed.
@@ -467,15 +508,36 @@ func prepareCall(fr *frame, call *ssa.CallCommon) (fn value, args []value) {
 // callpos is the position of the callsite.
 //
 func call(i *interpreter, caller *frame, callpos token.Pos, fn value, args []value) value {
+	g := i.main
+	if caller != nil {
+		g = caller.g
+	}
+	return callOn(i, g, caller, callpos, fn, args)
+}
+
+// callOn is like call, but the call is made on goroutine g.
+func callOn(i *interpreter, g *goroutine, caller *frame, callpos token.Pos, fn value, args []value) value {
 	switch fn := fn.(type) {
 	case *ssa.Function:
 		if fn == nil {
 			panic("call of nil function") // nil of func type
 		}
-		return callSSA(i, caller, callpos, fn, args, nil)
+		return callSSA(i, g, caller, callpos, fn, args, nil)
 	case *closure:
-		return callSSA(i, caller, callpos, fn.Fn, args, fn.Env)
+		return callSSA(i, g, caller, callpos, fn.Fn, args, fn.Env)
 	case *ssa.Builtin:
+		// The tracer keeps the state of traced channels.
+		if t := i.trace; t != nil && len(args) == 1 {
+			if ch, ok := args[0].(chan value); ok {
+				switch fn.Name() {
+				case "close":
+					t.closeChan(g, callpos, ch)
+					return nil
+				case "len":
+					return t.chanLen(g, callpos, ch)
+				}
+			}
+		}
 		return callBuiltin(caller, callpos, fn, args)
 	}
 	panic(fmt.Sprintf("cannot call %T", fn))
@@ -489,10 +551,10 @@ func loc(fset *token.FileSet, pos token.Pos) string {
 }
 
 // callSSA interprets a call to function fn with arguments args,
-// and lexical environment env, returning its result.
+// and lexical environment env, on goroutine g, returning its result.
 // callpos is the position of the callsite.
 //
-func callSSA(i *interpreter, caller *frame, callpos token.Pos, fn *ssa.Function, args []value, env []value) value {
+func callSSA(i *interpreter, g *goroutine, caller *frame, callpos token.Pos, fn *ssa.Function, args []value, env []value) value {
 	if i.mode&EnableTracing != 0 {
 		fset := fn.Prog.Fset
 		// TODO(adonovan): fix: loc() lies for external functions.
@@ -505,6 +567,7 @@ func callSSA(i *interpreter, caller *frame, callpos token.Pos, fn *ssa.Function,
 	}
 	fr := &frame{
 		i:      i,
+		g:      g,
 		caller: caller, // for panic/recover
 		fn:     fn,
 	}
@@ -514,6 +577,9 @@ func callSSA(i *interpreter, caller *frame, callpos token.Pos, fn *ssa.Function,
 			if i.mode&EnableTracing != 0 {
 				fmt.Fprintln(os.Stderr, "\t(external)")
 			}
+			if i.trace != nil {
+				return i.trace.external(fr, name, ext, args)
+			}
 			return ext(fr, args)
 		}
 		if fn.Blocks == nil {
@@ -543,6 +609,12 @@ func callSSA(i *interpreter, caller *frame, callpos token.Pos, fn *ssa.Function,
 	return fr.result
 }
 
+// CanInterpret reports whether the interpreter can execute a call to
+// fn, i.e. whether fn has SSA code or an emulated implementation.
+func CanInterpret(fn *ssa.Function) bool {
+	return fn.Blocks != nil || externals[fn.String()] != nil
+}
+
 // runFrame executes SSA instructions starting at fr.block and
 // continuing until a return, a panic, or a recovered panic.
 //
@@ -620,6 +692,9 @@ func doRecover(caller *frame) value {
 		case targetPanic:
 			// The target program explicitly called panic().
 			return p.v
+		case replayDivergence:
+			// The replayed execution departed from its trace.
+			panic(p)
 		case runtime.Error:
 			// The interpreter encountered a runtime error.
 			return iface{caller.i.runtimeErrorString, p.Error()}
@@ -653,11 +728,38 @@ func setGlobal(i *interpreter, pkg *ssa.Package, name string, v value) {
 // The SSA program must include the "runtime" package.
 //
 func Interpret(mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename string, args []string) (exitCode int) {
+	return interpret(mainpkg, mode, sizes, filename, args, nil)
+}
+
+// Record is like Interpret, but also writes to w a trace of all
+// nondeterministic events in the execution; see trace.go.
+func Record(w io.Writer, mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename string, args []string) (exitCode int) {
+	return interpret(mainpkg, mode, sizes, filename, args, newRecorder(w))
+}
+
+// Replay is like Interpret, but reproduces the execution whose trace
+// is read from r.  It returns 1 if the trace cannot be read.
+func Replay(r io.Reader, mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename string, args []string) (exitCode int) {
+	t, err := newReplayer(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return interpret(mainpkg, mode, sizes, filename, args, t)
+}
+
+// interpret implements Interpret, Record and Replay; trace may be nil.
+func interpret(mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename string, args []string, trace *tracer) (exitCode int) {
 	i := &interpreter{
 		prog:    mainpkg.Prog,
 		globals: make(map[ssa.Value]*value),
 		mode:    mode,
 		sizes:   sizes,
+		trace:   trace,
+		main:    &goroutine{id: "0"},
+	}
+	if trace != nil {
+		trace.fset = i.prog.Fset
 	}
 	runtimePkg := i.prog.ImportedPackage("runtime")
 	if runtimePkg == nil {
@@ -713,6 +815,8 @@ func Interpret(mainpkg *ssa.Package, mode Mode, sizes types.Sizes, filename stri
 			return
 		case targetPanic:
 			fmt.Fprintln(os.Stderr, "panic:", toString(p.v))
+		case replayDivergence:
+			fmt.Fprintln(os.Stderr, p)
 		case runtime.Error:
 			fmt.Fprintln(os.Stderr, "panic:", p.Error())
 		case string:
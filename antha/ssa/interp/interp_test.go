@@ -163,6 +163,8 @@ var testdataTests = []string{
 	"mrvchain.go",
 	"recover.go",
 	"callstack.go",
+	"osfile.go",
+	"strings.go",
 }
 
 // These are files and packages in $GOROOT/src/pkg/.
@@ -189,7 +191,15 @@ var gorootSrcPkgTests = []string{
 
 type successPredicate func(exitcode int, output string) error
 
+// An interpretFunc is Interpret or one of its variants.
+type interpretFunc func(mainpkg *ssa.Package, mode interp.Mode, sizes types.Sizes, filename string, args []string) int
+
 func run(t *testing.T, dir, input string, success successPredicate) bool {
+	return runWith(t, dir, input, success, interp.Interpret)
+}
+
+// runWith is like run, but interprets the program using interpret.
+func runWith(t *testing.T, dir, input string, success successPredicate, interpret interpretFunc) bool {
 	fmt.Printf("Input: %s\n", input)
 
 	start := time.Now()
@@ -260,7 +270,7 @@ func run(t *testing.T, dir, input string, success successPredicate) bool {
 	interp.CapturedOutput = &out
 
 	hint = fmt.Sprintf("To trace execution, run:\n%% antha build code.google.com/p/go.tools/cmd/ssadump && ./ssadump -build=C -run --interp=T %s\n", input)
-	exitCode := interp.Interpret(mainPkg, 0, &types.StdSizes{8, 8}, inputs[0], []string{})
+	exitCode := interpret(mainPkg, 0, &types.StdSizes{8, 8}, inputs[0], []string{})
 
 	// The definition of success varies with each file.
 	if err := success(exitCode, out.String()); err != nil {
@@ -307,6 +317,37 @@ func TestTestdataFiles(t *testing.T) {
 	printFailures(failures)
 }
 
+// TestRecordReplay checks that replaying the trace of a
+// nondeterministic program reproduces its output.
+func TestRecordReplay(t *testing.T) {
+	var trace bytes.Buffer
+	var recorded, replayed string
+
+	record := func(mainpkg *ssa.Package, mode interp.Mode, sizes types.Sizes, filename string, args []string) int {
+		return interp.Record(&trace, mainpkg, mode, sizes, filename, args)
+	}
+	if !runWith(t, "testdata"+slash, "recordreplay.go", func(exitcode int, output string) error {
+		recorded = output
+		return exitsZero(exitcode, output)
+	}, record) {
+		return
+	}
+
+	replay := func(mainpkg *ssa.Package, mode interp.Mode, sizes types.Sizes, filename string, args []string) int {
+		return interp.Replay(&trace, mainpkg, mode, sizes, filename, args)
+	}
+	if !runWith(t, "testdata"+slash, "recordreplay.go", func(exitcode int, output string) error {
+		replayed = output
+		return exitsZero(exitcode, output)
+	}, replay) {
+		return
+	}
+
+	if recorded != replayed {
+		t.Errorf("replayed output differs from recorded output:\nrecorded:\n%s\nreplayed:\n%s", recorded, replayed)
+	}
+}
+
 // TestGorootTest runs the interpreter on $GOROOT/test/*.go.
 func TestGorootTest(t *testing.T) {
 	if testing.Short() {
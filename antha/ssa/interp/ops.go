@@ -818,17 +818,23 @@ func eqnil(t types.Type, x, y value) bool {
 	return equals(t, x, y)
 }
 
+// recvResult returns the result of receive instruction instr, given
+// the value v received and whether it was sent.
+func recvResult(instr *ssa.UnOp, v value, ok bool) value {
+	if !ok {
+		v = zero(instr.X.Type().Underlying().(*types.Chan).Elem())
+	}
+	if instr.CommaOk {
+		v = tuple{v, ok}
+	}
+	return v
+}
+
 func unop(instr *ssa.UnOp, x value) value {
 	switch instr.Op {
 	case token.ARROW: // receive
 		v, ok := <-x.(chan value)
-		if !ok {
-			v = zero(instr.X.Type().Underlying().(*types.Chan).Elem())
-		}
-		if instr.CommaOk {
-			v = tuple{v, ok}
-		}
-		return v
+		return recvResult(instr, v, ok)
 	case token.SUB:
 		switch x := x.(type) {
 		case int:
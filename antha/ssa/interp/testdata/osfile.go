@@ -0,0 +1,109 @@
+// antha-tools/antha/ssa/interp/testdata/osfile.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package main
+
+// Tests of file operations of the os package, which the interpreter
+// executes using the emulated system calls.
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	dir, err := ioutil.TempDir("", "osfile")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "f")
+
+	f, err := os.Create(name)
+	if err != nil {
+		panic(err)
+	}
+	if n, err := f.Write([]byte("hello, world\n")); n != 13 || err != nil {
+		panic(err)
+	}
+	if err := f.Close(); err != nil {
+		panic(err)
+	}
+
+	f, err = os.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	buf := make([]byte, 5)
+	if n, err := f.Read(buf); n != 5 || err != nil || string(buf) != "hello" {
+		panic(string(buf[:n]))
+	}
+	if off, err := f.Seek(7, 0); off != 7 || err != nil {
+		panic(err)
+	}
+	if n, err := f.Read(buf); n != 5 || err != nil || string(buf) != "world" {
+		panic(string(buf[:n]))
+	}
+	if n, err := f.ReadAt(buf, 0); n != 5 || err != nil || string(buf) != "hello" {
+		panic(string(buf[:n]))
+	}
+	if _, err := f.Read(buf[:1]); err != nil {
+		panic(err)
+	}
+	if _, err := f.Read(buf); err != io.EOF {
+		panic(err)
+	}
+	if err := f.Close(); err != nil {
+		panic(err)
+	}
+
+	mtime := time.Date(2014, 7, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		panic(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		panic(err)
+	}
+	if info.Name() != "f" || info.Size() != 13 || info.IsDir() || !info.ModTime().Equal(mtime) {
+		panic(info)
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "f" {
+		panic(infos)
+	}
+
+	if err := os.Remove(name); err != nil {
+		panic(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		panic(err)
+	}
+}
\ No newline at end of file
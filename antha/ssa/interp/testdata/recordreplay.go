@@ -0,0 +1,87 @@
+// antha-tools/antha/ssa/interp/testdata/recordreplay.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+package main
+
+// Tests of record and replay.  The control decisions of this program
+// depend on the clock and on the scheduler; replaying its trace must
+// reproduce them.
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+func worker(id int, out chan<- int) {
+	for i := 0; i < 3; i++ {
+		out <- id*10 + i
+	}
+}
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	a, b := make(chan int), make(chan int)
+	go worker(1, a)
+	go worker(2, b)
+	for i := 0; i < 6; i++ {
+		select {
+		case x := <-a:
+			fmt.Println("a", x)
+		case x := <-b:
+			fmt.Println("b", x)
+		}
+	}
+
+	// Two goroutines race to send on the same channel.
+	c, done := make(chan int), make(chan bool, 2)
+	for id := 1; id <= 2; id++ {
+		go func(id int) {
+			c <- id
+			done <- true
+		}(id)
+	}
+	fmt.Println("first", <-c, "second", <-c)
+	<-done
+	fmt.Println("buffered", len(done))
+	<-done
+
+	// A receiver ranges over a channel until it is closed.
+	q := make(chan int, 2)
+	go func() {
+		for i := 0; i < 3; i++ {
+			q <- i
+		}
+		close(q)
+	}()
+	for x := range q {
+		fmt.Println("q", x)
+	}
+
+	if t := time.Now(); t.Nanosecond()%2 == 0 {
+		fmt.Println("even", t.UnixNano())
+	} else {
+		fmt.Println("odd", t.UnixNano())
+	}
+	fmt.Println("rand", rand.Intn(1000))
+}
\ No newline at end of file
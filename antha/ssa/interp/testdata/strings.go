@@ -0,0 +1,102 @@
+// antha-tools/antha/ssa/interp/testdata/strings.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package main
+
+// Tests of the functions of the strings and bytes packages that the
+// interpreter emulates.
+
+import (
+	"bytes"
+	"strings"
+)
+
+func main() {
+	if n := strings.Count("cheese", "e"); n != 3 {
+		panic(n)
+	}
+	if !strings.EqualFold("Go", "GO") || strings.EqualFold("Go", "Ga") {
+		panic("EqualFold")
+	}
+	if f := strings.Fields("  a b  c "); len(f) != 3 || f[0] != "a" || f[2] != "c" {
+		panic(f)
+	}
+	if f := strings.Fields(" "); len(f) != 0 {
+		panic(f)
+	}
+	if !strings.HasPrefix("element", "ele") || !strings.HasSuffix("element", "ment") {
+		panic("HasPrefix/HasSuffix")
+	}
+	if i := strings.IndexAny("golang", "ny"); i != 4 {
+		panic(i)
+	}
+	if i := strings.IndexRune("chicken", 'k'); i != 4 {
+		panic(i)
+	}
+	if s := strings.Join([]string{"a", "b", "c"}, ", "); s != "a, b, c" {
+		panic(s)
+	}
+	if s := strings.Repeat("na", 2); s != "nana" {
+		panic(s)
+	}
+	if s := strings.Replace("oink oink oink", "k", "ky", 2); s != "oinky oinky oink" {
+		panic(s)
+	}
+	if s := strings.Split("a,b,c", ","); len(s) != 3 || s[1] != "b" {
+		panic(s)
+	}
+	s := strings.Split("a,b", ",")
+	s[0] = "x" // the result is an ordinary, mutable slice
+	if s[0] != "x" {
+		panic(s)
+	}
+	if s := strings.ToLower("Gopher") + strings.ToUpper("Gopher"); s != "gopherGOPHER" {
+		panic(s)
+	}
+	if s := strings.TrimSpace(" \t hi \n"); s != "hi" {
+		panic(s)
+	}
+
+	b := []byte("seafood")
+	if !bytes.Contains(b, []byte("foo")) || bytes.Contains(b, []byte("bar")) {
+		panic("Contains")
+	}
+	if n := bytes.Count(b, []byte("o")); n != 2 {
+		panic(n)
+	}
+	if !bytes.EqualFold(b, []byte("SeaFood")) {
+		panic("EqualFold")
+	}
+	if !bytes.HasPrefix(b, []byte("sea")) || !bytes.HasSuffix(b, []byte("food")) {
+		panic("HasPrefix/HasSuffix")
+	}
+	if i := bytes.Index(b, []byte("o")); i != 4 {
+		panic(i)
+	}
+	if i := bytes.IndexRune(b, 'f'); i != 3 {
+		panic(i)
+	}
+	if i := bytes.LastIndex(b, []byte("o")); i != 5 {
+		panic(i)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,466 @@
+// antha-tools/antha/ssa/interp/trace.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+// Record and replay of interpreted executions.
+//
+// When recording (see Record), the interpreter logs every event whose
+// outcome is not determined by the program text alone: every call to
+// an external function (with its arguments, results and any output
+// parameters), the order in which goroutines arrive at channel
+// operations, and the case chosen by each select statement.  Sources
+// of time and randomness are covered because they bottom out in
+// externals (time.now, syscall.Read of /dev/urandom, and so on);
+// math/rand is deterministic once its seed is fixed.
+//
+// When replaying (see Replay), the interpreter reads such a log and
+// feeds it back in.  Calls to externals return their recorded
+// results without being executed, except for writes to file
+// descriptors 1 and 2, which are performed so that the program's
+// output is visible.  Goroutines arrive at channel operations in the
+// recorded order and select statements take the recorded case.  Any deviation
+// from the log causes the interpreter to stop with a replayDivergence
+// panic.
+//
+// Goroutines are identified by their position in the tree of go
+// statements ("0" is the main goroutine, "0.2" is the second goroutine
+// it spawned), which is independent of scheduling.  External calls and
+// select choices are matched against the log per goroutine; arrivals
+// at channel operations are matched globally.  Traced channels are
+// implemented by the tracer itself, so that the order of arrivals
+// determines the outcome of every channel operation; see tracechan.go.
+//
+// The log is a sequence of JSON objects, one per line.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/antha-lang/antha/token"
+)
+
+// A replayDivergence is the panic value raised when a replayed
+// execution departs from its trace.  Target programs cannot recover it.
+type replayDivergence string
+
+func (d replayDivergence) String() string { return "replay diverged: " + string(d) }
+
+// Externals that are neither logged nor replayed but always
+// executed, because they never return normally, because their
+// effects are on the interpreter itself, or because they are pure
+// functions emulated only for speed (see external_fast.go and
+// external_math.go).
+var untracedExternals = map[string]bool{
+	"runtime.Goexit":  true,
+	"syscall.Exit":    true,
+	"time.startTimer": true,
+	"time.stopTimer":  true,
+}
+
+// A goroutine identifies an interpreted goroutine for the purposes
+// of tracing.
+type goroutine struct {
+	id    string
+	nkids int // number of goroutines spawned so far; accessed only by this goroutine
+}
+
+// spawn returns the identity of the next goroutine started by g.
+func (g *goroutine) spawn() *goroutine {
+	g.nkids++
+	return &goroutine{id: g.id + "." + strconv.Itoa(g.nkids)}
+}
+
+// A traceEvent is a single entry in the trace.
+type traceEvent struct {
+	Kind   string        `json:"kind"` // "call", "chan" or "select"
+	G      string        `json:"g"`    // goroutine identity
+	Name   string        `json:"name,omitempty"`
+	Pos    string        `json:"pos,omitempty"`
+	Args   []*traceValue `json:"args,omitempty"`
+	Outs   []*traceValue `json:"outs,omitempty"` // final state of slice and pointer args
+	Result *traceValue   `json:"result,omitempty"`
+	Opaque bool          `json:"opaque,omitempty"` // result cannot be logged; call is re-executed
+	Op     string        `json:"op,omitempty"`     // for "chan": "send", "recv", "select", "close" or "len"
+	Chosen int           `json:"chosen,omitempty"` // for "select": index of chosen case
+}
+
+// A tracer records or replays the trace of a single interpretation.
+type tracer struct {
+	fset   *token.FileSet
+	mu     sync.Mutex
+	replay bool
+	states map[chan value]*chanState // state of each traced channel; see tracechan.go
+
+	// Recording.
+	enc *json.Encoder
+
+	// Replaying.
+	cond     *sync.Cond               // signalled when arrivals is popped
+	calls    map[string][]*traceEvent // per-goroutine "call" and "select" events
+	arrivals []*traceEvent            // "chan" events, in global order
+}
+
+// newRecorder returns a tracer that writes the trace to w.
+func newRecorder(w io.Writer) *tracer {
+	return &tracer{
+		enc:    json.NewEncoder(w),
+		states: make(map[chan value]*chanState),
+	}
+}
+
+// newReplayer returns a tracer that replays the trace read from r.
+func newReplayer(r io.Reader) (*tracer, error) {
+	t := &tracer{
+		replay: true,
+		states: make(map[chan value]*chanState),
+		calls:  make(map[string][]*traceEvent),
+	}
+	t.cond = sync.NewCond(&t.mu)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e traceEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading trace: %s", err)
+		}
+		switch e.Kind {
+		case "call", "select":
+			t.calls[e.G] = append(t.calls[e.G], &e)
+		case "chan":
+			t.arrivals = append(t.arrivals, &e)
+		default:
+			return nil, fmt.Errorf("reading trace: unknown event kind %q", e.Kind)
+		}
+	}
+	return t, nil
+}
+
+// log appends event e to the trace being recorded.
+func (t *tracer) log(e *traceEvent) {
+	t.mu.Lock()
+	t.enc.Encode(e) // ignore errors
+	t.mu.Unlock()
+}
+
+// next removes and returns the next "call" or "select" event for
+// goroutine g, panicking if it is not of the expected kind.
+func (t *tracer) next(g *goroutine, kind, name string) *traceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q := t.calls[g.id]
+	if len(q) == 0 {
+		panic(replayDivergence(fmt.Sprintf("goroutine %s: unexpected %s %s: trace has no more events", g.id, kind, name)))
+	}
+	e := q[0]
+	if e.Kind != kind || e.Name != name {
+		panic(replayDivergence(fmt.Sprintf("goroutine %s: got %s %s, trace has %s %s", g.id, kind, name, e.Kind, e.Name)))
+	}
+	t.calls[g.id] = q[1:]
+	return e
+}
+
+// external executes, records or replays a call to the external
+// function ext named name.
+func (t *tracer) external(fr *frame, name string, ext externalFn, args []value) value {
+	if untracedExternals[name] {
+		return ext(fr, args)
+	}
+
+	if !t.replay {
+		e := &traceEvent{Kind: "call", G: fr.g.id, Name: name}
+		for _, arg := range args {
+			e.Args = append(e.Args, encodeValue(arg))
+		}
+		result := ext(fr, args)
+		e.Outs = encodeOuts(args)
+		if e.Result = encodeValue(result); e.Result == nil {
+			e.Opaque = true
+		}
+		t.log(e)
+		return result
+	}
+
+	e := t.next(fr.g, "call", name)
+	for i, arg := range args {
+		if i < len(e.Args) && e.Args[i] != nil {
+			if got := encodeValue(arg); got != nil && !reflect.DeepEqual(got, e.Args[i]) {
+				panic(replayDivergence(fmt.Sprintf("goroutine %s: %s called with argument #%d = %s, trace has %s",
+					fr.g.id, name, i, got, e.Args[i])))
+			}
+		}
+	}
+	if e.Opaque {
+		return ext(fr, args)
+	}
+	if name == "syscall.Write" {
+		if fd := args[0].(int); fd == 1 || fd == 2 {
+			write(fd, valueToBytes(args[1]))
+		}
+	}
+	for i, out := range e.Outs {
+		if out != nil && i < len(args) {
+			restoreOut(args[i], out)
+		}
+	}
+	return decodeValue(e.Result)
+}
+
+// -- value encoding ---------------------------------------------------
+
+// A traceValue is the logged form of an interpreter value.  Only
+// values built from basic types, slices, arrays, structs, tuples,
+// pointers to such values and error interfaces can be logged.
+type traceValue struct {
+	T     string        `json:"t"`
+	V     string        `json:"v,omitempty"`
+	Elems []*traceValue `json:"elems,omitempty"`
+}
+
+func (v *traceValue) String() string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// encodeValue returns the logged form of v, or nil if v cannot be
+// logged.
+func encodeValue(v value) *traceValue {
+	switch v := v.(type) {
+	case nil:
+		return &traceValue{T: "nil"}
+	case bool:
+		return &traceValue{T: "bool", V: strconv.FormatBool(v)}
+	case int:
+		return &traceValue{T: "int", V: strconv.FormatInt(int64(v), 10)}
+	case int8:
+		return &traceValue{T: "int8", V: strconv.FormatInt(int64(v), 10)}
+	case int16:
+		return &traceValue{T: "int16", V: strconv.FormatInt(int64(v), 10)}
+	case int32:
+		return &traceValue{T: "int32", V: strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return &traceValue{T: "int64", V: strconv.FormatInt(v, 10)}
+	case uint:
+		return &traceValue{T: "uint", V: strconv.FormatUint(uint64(v), 10)}
+	case uint8:
+		return &traceValue{T: "uint8", V: strconv.FormatUint(uint64(v), 10)}
+	case uint16:
+		return &traceValue{T: "uint16", V: strconv.FormatUint(uint64(v), 10)}
+	case uint32:
+		return &traceValue{T: "uint32", V: strconv.FormatUint(uint64(v), 10)}
+	case uint64:
+		return &traceValue{T: "uint64", V: strconv.FormatUint(v, 10)}
+	case uintptr:
+		return &traceValue{T: "uintptr", V: strconv.FormatUint(uint64(v), 10)}
+	case float32:
+		return &traceValue{T: "float32", V: strconv.FormatFloat(float64(v), 'g', -1, 32)}
+	case float64:
+		return &traceValue{T: "float64", V: strconv.FormatFloat(v, 'g', -1, 64)}
+	case complex64:
+		return &traceValue{T: "complex64", Elems: []*traceValue{
+			encodeValue(real(v)), encodeValue(imag(v)),
+		}}
+	case complex128:
+		return &traceValue{T: "complex128", Elems: []*traceValue{
+			encodeValue(real(v)), encodeValue(imag(v)),
+		}}
+	case string:
+		return &traceValue{T: "string", V: v}
+	case []value:
+		if v == nil {
+			return &traceValue{T: "slice", V: "nil"}
+		}
+		return encodeElems("slice", v)
+	case array:
+		return encodeElems("array", v)
+	case structure:
+		return encodeElems("struct", v)
+	case tuple:
+		return encodeElems("tuple", v)
+	case *value:
+		if v == nil {
+			return &traceValue{T: "ptr"}
+		}
+		if elem := encodeValue(*v); elem != nil {
+			return &traceValue{T: "ptr", Elems: []*traceValue{elem}}
+		}
+	case iface:
+		switch v.t {
+		case nil:
+			return &traceValue{T: "iface"}
+		case errorType:
+			return &traceValue{T: "error", V: v.v.(string)}
+		}
+	}
+	return nil
+}
+
+func encodeElems(kind string, elems []value) *traceValue {
+	tv := &traceValue{T: kind}
+	for _, elem := range elems {
+		e := encodeValue(elem)
+		if e == nil {
+			return nil
+		}
+		tv.Elems = append(tv.Elems, e)
+	}
+	return tv
+}
+
+// encodeOuts returns the logged form of those args through which an
+// external function may return results, i.e. slices and pointers.
+func encodeOuts(args []value) []*traceValue {
+	var outs []*traceValue
+	logged := false
+	for _, arg := range args {
+		var out *traceValue
+		switch arg.(type) {
+		case []value, *value:
+			out = encodeValue(arg)
+		}
+		if out != nil {
+			logged = true
+		}
+		outs = append(outs, out)
+	}
+	if !logged {
+		return nil
+	}
+	return outs
+}
+
+// restoreOut updates the variables referenced by arg, a slice or
+// pointer, with their logged final state.
+func restoreOut(arg value, out *traceValue) {
+	switch arg := arg.(type) {
+	case []value:
+		copy(arg, decodeValue(out).([]value))
+	case *value:
+		if arg != nil && len(out.Elems) == 1 {
+			*arg = decodeValue(out.Elems[0])
+		}
+	}
+}
+
+// decodeValue returns the interpreter value logged as tv.
+func decodeValue(tv *traceValue) value {
+	parseInt := func(bits int) int64 {
+		i, err := strconv.ParseInt(tv.V, 10, bits)
+		if err != nil {
+			panic(replayDivergence(fmt.Sprintf("bad trace value %s: %s", tv, err)))
+		}
+		return i
+	}
+	parseUint := func(bits int) uint64 {
+		u, err := strconv.ParseUint(tv.V, 10, bits)
+		if err != nil {
+			panic(replayDivergence(fmt.Sprintf("bad trace value %s: %s", tv, err)))
+		}
+		return u
+	}
+	parseFloat := func(bits int) float64 {
+		f, err := strconv.ParseFloat(tv.V, bits)
+		if err != nil {
+			panic(replayDivergence(fmt.Sprintf("bad trace value %s: %s", tv, err)))
+		}
+		return f
+	}
+	elems := func() []value {
+		var vs []value
+		for _, e := range tv.Elems {
+			vs = append(vs, decodeValue(e))
+		}
+		return vs
+	}
+
+	switch tv.T {
+	case "nil":
+		return nil
+	case "bool":
+		return tv.V == "true"
+	case "int":
+		return int(parseInt(0))
+	case "int8":
+		return int8(parseInt(8))
+	case "int16":
+		return int16(parseInt(16))
+	case "int32":
+		return int32(parseInt(32))
+	case "int64":
+		return parseInt(64)
+	case "uint":
+		return uint(parseUint(0))
+	case "uint8":
+		return uint8(parseUint(8))
+	case "uint16":
+		return uint16(parseUint(16))
+	case "uint32":
+		return uint32(parseUint(32))
+	case "uint64":
+		return parseUint(64)
+	case "uintptr":
+		return uintptr(parseUint(64))
+	case "float32":
+		return float32(parseFloat(32))
+	case "float64":
+		return parseFloat(64)
+	case "complex64":
+		return complex(decodeValue(tv.Elems[0]).(float32), decodeValue(tv.Elems[1]).(float32))
+	case "complex128":
+		return complex(decodeValue(tv.Elems[0]).(float64), decodeValue(tv.Elems[1]).(float64))
+	case "string":
+		return tv.V
+	case "slice":
+		if tv.V == "nil" {
+			return []value(nil)
+		}
+		vs := elems()
+		if vs == nil {
+			vs = []value{}
+		}
+		return vs
+	case "array":
+		return array(elems())
+	case "struct":
+		return structure(elems())
+	case "tuple":
+		return tuple(elems())
+	case "ptr":
+		if len(tv.Elems) == 0 {
+			return (*value)(nil)
+		}
+		v := decodeValue(tv.Elems[0])
+		return &v
+	case "iface":
+		return iface{}
+	case "error":
+		return iface{t: errorType, v: tv.V}
+	}
+	panic(replayDivergence(fmt.Sprintf("bad trace value %s", tv)))
+}
\ No newline at end of file
@@ -0,0 +1,324 @@
+// antha-tools/antha/ssa/interp/tracechan.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+// Traced channel operations.
+//
+// The outcome of a channel operation performed with the host's
+// channels depends on the order in which the host runtime enqueues
+// racing goroutines, which the interpreter can neither observe nor
+// control.  So when an execution is traced, the tracer implements
+// channels itself: it keeps the buffer and the queues of blocked
+// goroutines of every channel, and each operation arrives, takes
+// effect or blocks while holding the tracer's lock.  The order of
+// arrivals then determines the outcome of every operation; it is
+// recorded, and on replay goroutines wait for their turn to arrive.
+//
+// The host channel created by make serves only as the identity and
+// capacity of a traced channel.  It is closed together with the
+// traced channel, so that operations that must panic (sending on a
+// closed channel, closing it twice) or block forever (on a nil
+// channel) can simply be performed on it.
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/antha-lang/antha/token"
+)
+
+// A chanState is the state of a traced channel.
+type chanState struct {
+	buf    []value       // buffered values, oldest first
+	closed bool          // the channel has been closed
+	sendq  []*chanWaiter // blocked senders, oldest first
+	recvq  []*chanWaiter // blocked receivers, oldest first
+}
+
+// A chanWait is a blocked send, receive or select statement.
+type chanWait struct {
+	done   chan struct{} // closed when the wait is over
+	fired  bool          // the wait is over; its queue entries are stale
+	chosen int           // index of the case that completed
+	v      value         // value received
+	ok     bool          // the operation succeeded (false: the channel was closed)
+}
+
+// A chanWaiter is an entry in the queue of a channel on which a
+// chanWait is blocked.
+type chanWaiter struct {
+	w *chanWait
+	i int   // index of the case
+	v value // value to send
+}
+
+// A selectCase is a case of a traced channel operation.
+type selectCase struct {
+	ch   chan value
+	send bool
+	v    value // value to send
+}
+
+// fire completes the wait of e with result v, ok.
+func (e *chanWaiter) fire(v value, ok bool) {
+	e.w.fired = true
+	e.w.chosen = e.i
+	e.w.v = v
+	e.w.ok = ok
+	close(e.w.done)
+}
+
+// dequeue removes and returns the oldest live entry of queue q, or
+// returns nil if there is none.
+func dequeue(q *[]*chanWaiter) *chanWaiter {
+	for len(*q) > 0 {
+		e := (*q)[0]
+		*q = (*q)[1:]
+		if !e.w.fired {
+			return e
+		}
+	}
+	return nil
+}
+
+// waiting reports whether queue q has a live entry.
+func waiting(q *[]*chanWaiter) bool {
+	for len(*q) > 0 && (*q)[0].w.fired {
+		*q = (*q)[1:]
+	}
+	return len(*q) > 0
+}
+
+// state returns the state of the non-nil traced channel ch.
+// t.mu must be held.
+func (t *tracer) state(ch chan value) *chanState {
+	st := t.states[ch]
+	if st == nil {
+		st = new(chanState)
+		t.states[ch] = st
+	}
+	return st
+}
+
+// arrive records or replays the arrival of goroutine g at channel
+// operation op at pos.  When replaying, it waits until the trace says
+// it is g's turn.  t.mu must be held; it is released if arrive panics.
+func (t *tracer) arrive(g *goroutine, op string, pos token.Pos) {
+	p := t.fset.Position(pos).String()
+	if !t.replay {
+		t.enc.Encode(&traceEvent{Kind: "chan", G: g.id, Op: op, Pos: p}) // ignore errors
+		return
+	}
+
+	for len(t.arrivals) > 0 && t.arrivals[0].G != g.id {
+		t.cond.Wait()
+	}
+	if len(t.arrivals) == 0 {
+		t.mu.Unlock()
+		panic(replayDivergence(fmt.Sprintf("goroutine %s: unexpected %s at %s: trace has no more channel operations", g.id, op, p)))
+	}
+	if e := t.arrivals[0]; e.Op != op || e.Pos != p {
+		t.mu.Unlock()
+		panic(replayDivergence(fmt.Sprintf("goroutine %s: got %s at %s, trace has %s at %s", g.id, op, p, e.Op, e.Pos)))
+	}
+	t.arrivals = t.arrivals[1:]
+	t.cond.Broadcast()
+}
+
+// ready reports whether case c can proceed without blocking.
+// t.mu must be held.
+func (t *tracer) ready(c selectCase) bool {
+	if c.ch == nil {
+		return false
+	}
+	st := t.state(c.ch)
+	if c.send {
+		return st.closed || waiting(&st.recvq) || len(st.buf) < cap(c.ch)
+	}
+	return len(st.buf) > 0 || waiting(&st.sendq) || st.closed
+}
+
+// proceed performs the ready case c, returning the value received,
+// if any, and whether the channel was open.  t.mu must be held.
+func (t *tracer) proceed(c selectCase) (value, bool) {
+	st := t.state(c.ch)
+	if c.send {
+		if st.closed {
+			return nil, false
+		}
+		if e := dequeue(&st.recvq); e != nil {
+			e.fire(c.v, true)
+		} else {
+			st.buf = append(st.buf, c.v)
+		}
+		return nil, true
+	}
+	if len(st.buf) > 0 {
+		v := st.buf[0]
+		st.buf = st.buf[1:]
+		if e := dequeue(&st.sendq); e != nil {
+			st.buf = append(st.buf, e.v)
+			e.fire(nil, true)
+		}
+		return v, true
+	}
+	if e := dequeue(&st.sendq); e != nil {
+		e.fire(nil, true)
+		return e.v, true
+	}
+	return nil, false // closed
+}
+
+// chanOp performs op, a traced send, receive or select statement
+// whose cases are cases, on behalf of goroutine g.  want is the index
+// of the case that must be taken (-1 for the default case of a
+// non-blocking select), or -2 if any ready case may be.  chanOp
+// returns the index of the case taken and the value received, if
+// any, and whether the channel was open; the caller must panic if it
+// was a send on a closed channel.
+func (t *tracer) chanOp(g *goroutine, op string, pos token.Pos, cases []selectCase, blocking bool, want int) (int, value, bool) {
+	t.mu.Lock()
+	t.arrive(g, op, pos)
+
+	chosen := -1
+	switch {
+	case want >= 0:
+		if t.ready(cases[want]) {
+			chosen = want
+		}
+	case want == -2:
+		var ready []int
+		for i, c := range cases {
+			if t.ready(c) {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) > 0 {
+			chosen = ready[rand.Intn(len(ready))]
+		}
+	}
+	if chosen >= 0 {
+		c := cases[chosen]
+		v, ok := t.proceed(c)
+		t.mu.Unlock()
+		return chosen, v, ok
+	}
+	if !blocking || want == -1 {
+		t.mu.Unlock()
+		return -1, nil, false
+	}
+
+	// Block until another goroutine completes one of the cases.
+	w := &chanWait{done: make(chan struct{})}
+	n := 0
+	for i, c := range cases {
+		if c.ch == nil || want >= 0 && i != want {
+			continue
+		}
+		st := t.state(c.ch)
+		e := &chanWaiter{w: w, i: i, v: c.v}
+		if c.send {
+			st.sendq = append(st.sendq, e)
+		} else {
+			st.recvq = append(st.recvq, e)
+		}
+		n++
+	}
+	t.mu.Unlock()
+	if n == 0 {
+		select {} // only nil channels: block forever
+	}
+	<-w.done
+	return w.chosen, w.v, w.ok
+}
+
+// send performs a traced send of v on ch by goroutine g.
+func (t *tracer) send(g *goroutine, pos token.Pos, ch chan value, v value) {
+	if _, _, ok := t.chanOp(g, "send", pos, []selectCase{{ch: ch, send: true, v: v}}, true, -2); !ok {
+		ch <- v // panics: send on closed channel
+	}
+}
+
+// recv performs a traced receive from ch by goroutine g.
+func (t *tracer) recv(g *goroutine, pos token.Pos, ch chan value) (value, bool) {
+	_, v, ok := t.chanOp(g, "recv", pos, []selectCase{{ch: ch}}, true, -2)
+	return v, ok
+}
+
+// selectStmt performs a traced select statement by goroutine g.  The
+// chosen case is recorded or replayed per goroutine.
+func (t *tracer) selectStmt(g *goroutine, pos token.Pos, cases []selectCase, blocking bool) (int, value, bool) {
+	var chosen int
+	var v value
+	var ok bool
+	if !t.replay {
+		chosen, v, ok = t.chanOp(g, "select", pos, cases, blocking, -2)
+		t.log(&traceEvent{Kind: "select", G: g.id, Chosen: chosen})
+	} else {
+		want := t.next(g, "select", "").Chosen
+		if want < -1 || want >= len(cases) || want == -1 && blocking {
+			panic(replayDivergence(fmt.Sprintf("goroutine %s: select at %s has no case %d", g.id, t.fset.Position(pos), want)))
+		}
+		chosen, v, ok = t.chanOp(g, "select", pos, cases, blocking, want)
+		if chosen != want {
+			panic(replayDivergence(fmt.Sprintf("goroutine %s: select at %s took case %d, trace has %d", g.id, t.fset.Position(pos), chosen, want)))
+		}
+	}
+	if chosen >= 0 && cases[chosen].send && !ok {
+		cases[chosen].ch <- cases[chosen].v // panics: send on closed channel
+	}
+	return chosen, v, ok
+}
+
+// closeChan performs a traced close of ch by goroutine g.
+func (t *tracer) closeChan(g *goroutine, pos token.Pos, ch chan value) {
+	t.mu.Lock()
+	t.arrive(g, "close", pos)
+	if ch == nil || t.state(ch).closed {
+		t.mu.Unlock()
+		close(ch) // panics: close of nil or closed channel
+	}
+	close(ch)
+	st := t.state(ch)
+	st.closed = true
+	for e := dequeue(&st.recvq); e != nil; e = dequeue(&st.recvq) {
+		e.fire(nil, false)
+	}
+	for e := dequeue(&st.sendq); e != nil; e = dequeue(&st.sendq) {
+		e.fire(nil, false)
+	}
+	t.mu.Unlock()
+}
+
+// chanLen returns the number of values buffered in traced channel ch,
+// as observed by goroutine g.
+func (t *tracer) chanLen(g *goroutine, pos token.Pos, ch chan value) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.arrive(g, "len", pos)
+	if ch == nil {
+		return 0
+	}
+	return len(t.state(ch).buf)
+}
\ No newline at end of file
@@ -0,0 +1,91 @@
+// antha-tools/antha/ssa/interp/tracechan_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package interp
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/antha-lang/antha/token"
+)
+
+// racers starts n goroutines that race to send their index on an
+// unbuffered channel and n+2 goroutines that race to receive from it
+// in a select statement, and closes a second channel once all values
+// are sent, to release the receivers left over.  It returns the
+// outcome of each receiver's select statement.
+func racers(tr *tracer, n int) []value {
+	main := &goroutine{id: "0"}
+	ch, quit := make(chan value), make(chan value)
+
+	var senders, receivers sync.WaitGroup
+	for i := 0; i < n; i++ {
+		senders.Add(1)
+		go func(g *goroutine, i int) {
+			defer senders.Done()
+			tr.send(g, token.NoPos, ch, i)
+		}(main.spawn(), i)
+	}
+	got := make([]value, n+2)
+	for i := range got {
+		receivers.Add(1)
+		go func(g *goroutine, i int) {
+			defer receivers.Done()
+			cases := []selectCase{{ch: ch}, {ch: quit}}
+			chosen, v, ok := tr.selectStmt(g, token.NoPos, cases, true)
+			got[i] = tuple{chosen, v, ok}
+		}(main.spawn(), i)
+	}
+	senders.Wait()
+	tr.closeChan(main, token.NoPos, quit)
+	receivers.Wait()
+	return got
+}
+
+// TestReplayRacingChannels checks that replaying a trace reproduces
+// the outcome of goroutines racing to the same channels.
+func TestReplayRacingChannels(t *testing.T) {
+	const n = 8
+	fset := token.NewFileSet()
+	var trace bytes.Buffer
+	rec := newRecorder(&trace)
+	rec.fset = fset
+	want := racers(rec, n)
+
+	for i := 0; i < 10; i++ {
+		rep, err := newReplayer(bytes.NewReader(trace.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep.fset = fset
+		if got := racers(rep, n); !reflect.DeepEqual(got, want) {
+			t.Fatalf("replay #%d: got %v, recorded %v", i, got, want)
+		}
+		if len(rep.arrivals) != 0 {
+			t.Fatalf("replay #%d: %d channel operations not replayed", i, len(rep.arrivals))
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,397 @@
+// antha-tools/antha/ssa/interval/analysis.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+
+package interval
+
+// This file defines the abstract interpretation of SSA functions.
+//
+// The analysis is a standard iterative dataflow computation over the
+// SSA graph.  Every tracked value starts out Empty and grows
+// monotonically; φ-nodes are widened after they have changed a few
+// times, so that loops converge.  Because SSA values are immutable,
+// the condition of a branch that dominates a block holds of its
+// operands throughout that block; At exploits this by narrowing the
+// range of a value by the conditions of all the branches leading to
+// the block in which it is used.
+
+import (
+	"math"
+
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// widenAfter is the number of times a φ-node may change before it
+// is widened.
+const widenAfter = 3
+
+// A Result holds the ranges computed for the values of one function.
+type Result struct {
+	fn     *ssa.Function
+	values map[ssa.Value]Interval
+	phis   map[*ssa.Phi]int // number of times each φ-node has changed
+}
+
+// Analyze computes ranges for the numeric values of fn, which must
+// have a body.  params, if non-nil, gives the ranges of some of fn's
+// parameters; the others range over all values of their type.
+//
+func Analyze(fn *ssa.Function, params map[*ssa.Parameter]Interval) *Result {
+	r := &Result{
+		fn:     fn,
+		values: make(map[ssa.Value]Interval),
+		phis:   make(map[*ssa.Phi]int),
+	}
+	for _, p := range fn.Params {
+		if x, ok := params[p]; ok {
+			r.values[p] = x.Meet(rangeOf(p.Type()))
+		} else {
+			r.values[p] = rangeOf(p.Type())
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(ssa.Value); ok && isNumeric(v.Type()) {
+				r.values[v] = Empty
+			}
+		}
+	}
+
+	blocks := fn.DomPreorder()
+	for changed := true; changed; {
+		changed = false
+		for _, b := range blocks {
+			for _, instr := range b.Instrs {
+				v, ok := instr.(ssa.Value)
+				if !ok || !isNumeric(v.Type()) {
+					continue
+				}
+				old := r.values[v]
+				x := old.Join(r.transfer(v, b))
+				if phi, ok := v.(*ssa.Phi); ok && x != old {
+					if r.phis[phi]++; r.phis[phi] > widenAfter {
+						x = old.Widen(x).Meet(rangeOf(v.Type()))
+					}
+				}
+				if x != old {
+					r.values[v] = x
+					changed = true
+				}
+			}
+		}
+	}
+	return r
+}
+
+// Of returns the range of v, a value of the analyzed function or a
+// constant.  Values of non-numeric type range over Top.
+func (r *Result) Of(v ssa.Value) Interval {
+	if c, ok := v.(*ssa.Const); ok {
+		if c.Value == nil || !isNumeric(c.Type()) {
+			return Top
+		}
+		return Const(c.Float64())
+	}
+	if x, ok := r.values[v]; ok {
+		return x
+	}
+	if isNumeric(v.Type()) {
+		return rangeOf(v.Type()) // e.g. a free variable
+	}
+	return Top
+}
+
+// At returns the range of v within block b: that of Of(v), narrowed
+// by the conditions of the branches that must have been taken to
+// reach b.  b must be a block of the analyzed function.
+//
+// A floating-point value is narrowed only by the branches on which
+// its comparison holds: every ordered comparison involving NaN is
+// false, so a comparison that fails implies nothing.
+func (r *Result) At(v ssa.Value, b *ssa.BasicBlock) Interval {
+	x := r.Of(v)
+	for c := b; c != nil; c = c.Idom() {
+		if len(c.Preds) == 1 {
+			x = x.Meet(r.refine(v, c.Preds[0], c))
+		}
+	}
+	return x
+}
+
+// refine returns the range implied for v by the control-flow edge
+// p->c, or Top if the edge implies nothing about v.
+func (r *Result) refine(v ssa.Value, p, c *ssa.BasicBlock) Interval {
+	if len(p.Succs) != 2 || p.Succs[0] == p.Succs[1] {
+		return Top
+	}
+	ifInstr, ok := p.Instrs[len(p.Instrs)-1].(*ssa.If)
+	if !ok {
+		return Top
+	}
+	cond, truth := ifInstr.Cond, c == p.Succs[0]
+	for {
+		not, ok := cond.(*ssa.UnOp)
+		if !ok || not.Op != token.NOT {
+			break
+		}
+		cond, truth = not.X, !truth
+	}
+	bin, ok := cond.(*ssa.BinOp)
+	if !ok || !isNumeric(bin.X.Type()) {
+		return Top
+	}
+
+	op := bin.Op
+	var other ssa.Value
+	switch v {
+	case bin.X:
+		other = bin.Y
+	case bin.Y:
+		other, op = bin.X, swapped[op]
+	default:
+		return Top
+	}
+	if !truth {
+		// The negation of an ordered comparison of floats also
+		// holds when either operand is NaN, so it bounds nothing.
+		if !isInteger(v.Type()) {
+			return Top
+		}
+		op = negated[op]
+	}
+
+	// Within an integer type, a strict comparison excludes the
+	// neighbouring integer too.
+	var step float64
+	if isInteger(v.Type()) {
+		step = 1
+	}
+	y := r.Of(other)
+	if y.IsEmpty() {
+		return Empty
+	}
+	switch op {
+	case token.LSS:
+		return Interval{math.Inf(-1), y.Hi - step}
+	case token.LEQ:
+		return Interval{math.Inf(-1), y.Hi}
+	case token.GTR:
+		return Interval{y.Lo + step, math.Inf(+1)}
+	case token.GEQ:
+		return Interval{y.Lo, math.Inf(+1)}
+	case token.EQL:
+		return y
+	}
+	return Top
+}
+
+// swapped maps each comparison x op y to the equivalent y op' x.
+var swapped = map[token.Token]token.Token{
+	token.EQL: token.EQL,
+	token.NEQ: token.NEQ,
+	token.LSS: token.GTR,
+	token.LEQ: token.GEQ,
+	token.GTR: token.LSS,
+	token.GEQ: token.LEQ,
+}
+
+// negated maps each comparison to its negation.
+var negated = map[token.Token]token.Token{
+	token.EQL: token.NEQ,
+	token.NEQ: token.EQL,
+	token.LSS: token.GEQ,
+	token.LEQ: token.GTR,
+	token.GTR: token.LEQ,
+	token.GEQ: token.LSS,
+}
+
+// transfer returns the range of the numeric value v, defined in block
+// b, given the current ranges of its operands.
+func (r *Result) transfer(v ssa.Value, b *ssa.BasicBlock) Interval {
+	T := v.Type()
+	switch v := v.(type) {
+	case *ssa.Phi:
+		x := Empty
+		for i, edge := range v.Edges {
+			p := b.Preds[i]
+			x = x.Join(r.At(edge, p).Meet(r.refine(edge, p, b)))
+		}
+		return x
+
+	case *ssa.BinOp:
+		return fit(binop(v.Op, r.At(v.X, b), r.At(v.Y, b), T), T)
+
+	case *ssa.UnOp:
+		x := r.At(v.X, b)
+		switch v.Op {
+		case token.SUB:
+			return fit(x.Neg(), T)
+		case token.XOR:
+			return fit(x.Neg().Sub(Const(1)), T) // ^x == -x-1
+		}
+
+	case *ssa.ChangeType:
+		return r.At(v.X, b)
+
+	case *ssa.Convert:
+		if !isNumeric(v.X.Type()) {
+			break
+		}
+		x := r.At(v.X, b)
+		if isInteger(T) && !isInteger(v.X.Type()) && !x.IsEmpty() {
+			x = Interval{math.Trunc(x.Lo), math.Trunc(x.Hi)}
+		}
+		return fit(x, T)
+
+	case *ssa.Call:
+		if fn, ok := v.Call.Value.(*ssa.Builtin); ok {
+			switch fn.Name() {
+			case "len", "cap":
+				return rangeOf(T).Meet(Interval{0, math.Inf(+1)})
+			}
+		}
+	}
+	return rangeOf(T)
+}
+
+// binop returns the range of x op y for operands of type T.
+func binop(op token.Token, x, y Interval, T types.Type) Interval {
+	if x.IsEmpty() || y.IsEmpty() {
+		return Empty
+	}
+	switch op {
+	case token.ADD:
+		return x.Add(y)
+	case token.SUB:
+		return x.Sub(y)
+	case token.MUL:
+		return x.Mul(y)
+	case token.QUO:
+		if !isInteger(T) {
+			return x.Quo(y)
+		}
+		// Division by zero panics, so a zero bound of y is
+		// excluded; the quotient then truncates towards zero.
+		if y.Lo == 0 {
+			y.Lo = 1
+		}
+		if y.Hi == 0 {
+			y.Hi = -1
+		}
+		if y.IsEmpty() {
+			return Empty
+		}
+		if y.Contains(0) {
+			m := math.Max(-x.Lo, x.Hi)
+			return Interval{-m, m}.Meet(rangeOf(T))
+		}
+		z := x.Quo(y)
+		return Interval{math.Trunc(z.Lo), math.Trunc(z.Hi)}
+	case token.REM:
+		// |x % y| < |y|, and the result has the sign of x.
+		m := math.Max(-y.Lo, y.Hi) - 1
+		if m < 0 {
+			return Empty // y == 0: panics
+		}
+		return Interval{math.Min(math.Max(x.Lo, -m), 0), math.Max(math.Min(x.Hi, m), 0)}
+	case token.AND:
+		switch {
+		case x.Lo >= 0 && y.Lo >= 0:
+			return Interval{0, math.Min(x.Hi, y.Hi)}
+		case x.Lo >= 0:
+			return Interval{0, x.Hi}
+		case y.Lo >= 0:
+			return Interval{0, y.Hi}
+		}
+	case token.SHR:
+		if x.Lo >= 0 {
+			return Interval{0, x.Hi}
+		}
+	}
+	return rangeOf(T)
+}
+
+// fit returns x, or the range of all values of T if x may exceed the
+// values of T on some platform, causing wraparound.
+func fit(x Interval, T types.Type) Interval {
+	if isInteger(T) && !noWrap(T).Includes(x) {
+		return rangeOf(T)
+	}
+	return x
+}
+
+func isNumeric(T types.Type) bool {
+	b, ok := T.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsInteger|types.IsFloat) != 0
+}
+
+func isInteger(T types.Type) bool {
+	b, ok := T.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0
+}
+
+// rangeOf returns the range of all values of the numeric type T on
+// any platform, or Top for other types.  Bounds too large to be
+// represented exactly by a float64 are infinite.
+func rangeOf(T types.Type) Interval {
+	if b, ok := T.Underlying().(*types.Basic); ok {
+		switch b.Kind() {
+		case types.Int8:
+			return Interval{math.MinInt8, math.MaxInt8}
+		case types.Int16:
+			return Interval{math.MinInt16, math.MaxInt16}
+		case types.Int32:
+			return Interval{math.MinInt32, math.MaxInt32}
+		case types.Uint8:
+			return Interval{0, math.MaxUint8}
+		case types.Uint16:
+			return Interval{0, math.MaxUint16}
+		case types.Uint32:
+			return Interval{0, math.MaxUint32}
+		case types.Uint, types.Uint64, types.Uintptr:
+			return Interval{0, math.Inf(+1)}
+		}
+	}
+	return Top
+}
+
+// noWrap returns the range of values of the integer type T that are
+// representable on every platform: int, uint and uintptr may be as
+// small as 32 bits.  64-bit values beyond 2^53 are not represented
+// exactly by a float64 bound, so they are assumed to wrap.
+func noWrap(T types.Type) Interval {
+	switch T.Underlying().(*types.Basic).Kind() {
+	case types.Int:
+		return Interval{math.MinInt32, math.MaxInt32}
+	case types.Uint, types.Uintptr:
+		return Interval{0, math.MaxUint32}
+	case types.Int64:
+		return Interval{-1 << 53, 1 << 53}
+	case types.Uint64:
+		return Interval{0, 1 << 53}
+	}
+	return rangeOf(T)
+}
\ No newline at end of file
@@ -0,0 +1,195 @@
+// antha-tools/antha/ssa/interval/interval.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+
+// Package interval computes conservative ranges for the numeric
+// values of SSA functions by abstract interpretation.
+//
+// The abstract domain is the lattice of closed intervals [Lo, Hi] of
+// real numbers, whose bounds may be infinite.  Analyze computes, for
+// each value of numeric type in a function, an interval containing
+// every value it may take at run time, given ranges for the
+// function's parameters.  The ranges are derived from constants, from
+// arithmetic, from conversions and from the conditions of the
+// branches that dominate each use.  AnalyzePackage does the same for
+// every function of a package, deriving the ranges of the parameters
+// of functions that are only called directly from within the package
+// from the arguments at their call sites.
+//
+// The analysis is sound for integer types, including wraparound on
+// overflow, but it does not model the NaN values of floating-point
+// types.  Values of other types are not tracked; their range is Top.
+//
+package interval
+
+import (
+	"fmt"
+	"math"
+)
+
+// An Interval is the closed range [Lo, Hi] of real numbers.  Either
+// bound may be infinite.  An interval whose Lo exceeds its Hi is
+// empty.
+type Interval struct {
+	Lo, Hi float64
+}
+
+var (
+	// Empty contains no values.
+	Empty = Interval{math.Inf(+1), math.Inf(-1)}
+
+	// Top contains all values.
+	Top = Interval{math.Inf(-1), math.Inf(+1)}
+)
+
+// Const returns the interval containing only x.
+func Const(x float64) Interval {
+	return Interval{x, x}
+}
+
+// IsEmpty reports whether x contains no values.
+func (x Interval) IsEmpty() bool {
+	return x.Lo > x.Hi
+}
+
+// Contains reports whether v lies within x.
+func (x Interval) Contains(v float64) bool {
+	return x.Lo <= v && v <= x.Hi
+}
+
+// Includes reports whether every value of y lies within x.
+func (x Interval) Includes(y Interval) bool {
+	return y.IsEmpty() || x.Lo <= y.Lo && y.Hi <= x.Hi
+}
+
+func (x Interval) String() string {
+	if x.IsEmpty() {
+		return "[]"
+	}
+	return fmt.Sprintf("[%g, %g]", x.Lo, x.Hi)
+}
+
+// Join returns the smallest interval containing both x and y.
+func (x Interval) Join(y Interval) Interval {
+	switch {
+	case x.IsEmpty():
+		return y
+	case y.IsEmpty():
+		return x
+	}
+	return Interval{math.Min(x.Lo, y.Lo), math.Max(x.Hi, y.Hi)}
+}
+
+// Meet returns the intersection of x and y.
+func (x Interval) Meet(y Interval) Interval {
+	z := Interval{math.Max(x.Lo, y.Lo), math.Min(x.Hi, y.Hi)}
+	if z.IsEmpty() {
+		return Empty
+	}
+	return z
+}
+
+// Widen returns the result of widening x, the previous approximation
+// of a value, by y, the next one: each bound of x that y exceeds is
+// moved to infinity.  Repeated widening reaches a fixed point in a
+// bounded number of steps.
+func (x Interval) Widen(y Interval) Interval {
+	switch {
+	case x.IsEmpty():
+		return y
+	case y.IsEmpty():
+		return x
+	}
+	z := x
+	if y.Lo < x.Lo {
+		z.Lo = math.Inf(-1)
+	}
+	if y.Hi > x.Hi {
+		z.Hi = math.Inf(+1)
+	}
+	return z
+}
+
+// Neg returns the interval of -v for v in x.
+func (x Interval) Neg() Interval {
+	if x.IsEmpty() {
+		return Empty
+	}
+	return Interval{-x.Hi, -x.Lo}
+}
+
+// Add returns the interval of u+v for u in x and v in y.
+func (x Interval) Add(y Interval) Interval {
+	if x.IsEmpty() || y.IsEmpty() {
+		return Empty
+	}
+	return bounds(x.Lo+y.Lo, x.Hi+y.Hi)
+}
+
+// Sub returns the interval of u-v for u in x and v in y.
+func (x Interval) Sub(y Interval) Interval {
+	return x.Add(y.Neg())
+}
+
+// Mul returns the interval of u*v for u in x and v in y.
+func (x Interval) Mul(y Interval) Interval {
+	if x.IsEmpty() || y.IsEmpty() {
+		return Empty
+	}
+	return bounds(mul(x.Lo, y.Lo), mul(x.Lo, y.Hi), mul(x.Hi, y.Lo), mul(x.Hi, y.Hi))
+}
+
+// Quo returns the interval of u/v for u in x and v in y.  If y
+// contains zero, the result is Top.
+func (x Interval) Quo(y Interval) Interval {
+	if x.IsEmpty() || y.IsEmpty() {
+		return Empty
+	}
+	if y.Contains(0) {
+		return Top
+	}
+	return bounds(x.Lo/y.Lo, x.Lo/y.Hi, x.Hi/y.Lo, x.Hi/y.Hi)
+}
+
+// mul returns a*b, treating zero times infinity as zero: a zero
+// bound denotes a value that is exactly zero.
+func mul(a, b float64) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return a * b
+}
+
+// bounds returns the smallest interval containing all of vs.  If any
+// of vs is NaN, as when infinities of opposite sign are added, the
+// result is Top.
+func bounds(vs ...float64) Interval {
+	z := Empty
+	for _, v := range vs {
+		if math.IsNaN(v) {
+			return Top
+		}
+		z = z.Join(Const(v))
+	}
+	return z
+}
\ No newline at end of file
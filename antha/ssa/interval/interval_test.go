@@ -0,0 +1,114 @@
+// antha-tools/antha/ssa/interval/interval_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+
+package interval_test
+
+import (
+	"github.com/antha-lang/antha/parser"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/interval"
+)
+
+func TestArithmetic(t *testing.T) {
+	inf := math.Inf(+1)
+	x := interval.Interval{-2, 3}
+	for _, test := range []struct {
+		got, want interval.Interval
+	}{
+		{x.Add(interval.Const(1)), interval.Interval{-1, 4}},
+		{x.Sub(x), interval.Interval{-5, 5}},
+		{x.Mul(x), interval.Interval{-6, 9}},
+		{x.Mul(interval.Interval{0, inf}), interval.Interval{-inf, inf}},
+		{interval.Const(0).Mul(interval.Top), interval.Const(0)},
+		{x.Quo(interval.Interval{1, 2}), interval.Interval{-2, 3}},
+		{x.Quo(interval.Interval{-1, 1}), interval.Top},
+		{x.Neg(), interval.Interval{-3, 2}},
+		{x.Join(interval.Const(10)), interval.Interval{-2, 10}},
+		{x.Meet(interval.Interval{0, inf}), interval.Interval{0, 3}},
+		{x.Meet(interval.Const(4)), interval.Empty},
+		{x.Widen(interval.Interval{-2, 4}), interval.Interval{-2, inf}},
+		{interval.Top.Add(interval.Top), interval.Top},
+		{interval.Empty.Add(x), interval.Empty},
+	} {
+		if test.got != test.want {
+			t.Errorf("got %s, want %s", test.got, test.want)
+		}
+	}
+}
+
+func TestAnalyzePackage(t *testing.T) {
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("testdata/ranges.go", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	// Map each line to the range expected in its comment.
+	want := make(map[int]string)
+	for _, c := range f.Comments {
+		text := strings.TrimSpace(c.Text())
+		if strings.HasPrefix(text, "[") {
+			want[iprog.Fset.Position(c.Pos()).Line] = text
+		}
+	}
+
+	results := interval.AnalyzePackage(mainPkg)
+	seen := 0
+	for fn, r := range results {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok || call.Call.Value.Name() != "println" {
+					continue
+				}
+				line := prog.Fset.Position(call.Pos()).Line
+				got := r.At(call.Call.Args[0], b).String()
+				if got != want[line] {
+					t.Errorf("line %d: got %s, want %s", line, got, want[line])
+				}
+				seen++
+			}
+		}
+	}
+	if seen != len(want) {
+		t.Errorf("found %d calls to println, want %d", seen, len(want))
+	}
+}
\ No newline at end of file
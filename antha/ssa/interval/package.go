@@ -0,0 +1,140 @@
+// antha-tools/antha/ssa/interval/package.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+
+package interval
+
+// This file defines the interprocedural analysis of a package.
+
+import (
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+)
+
+// maxRounds is the number of rounds of interprocedural analysis after
+// which the ranges of parameters are widened.
+const maxRounds = 5
+
+// AnalyzePackage computes ranges for the numeric values of every
+// function of pkg that has a body, including methods and anonymous
+// functions.
+//
+// The parameters of an unexported package-level function that is
+// used only as the callee of static calls within pkg range over the
+// arguments of those calls; all other parameters range over all values
+// of their type.  Since call sites depend on the ranges of their
+// callers, the analysis is repeated until the parameter ranges no
+// longer change.
+//
+// Precondition: pkg is built.
+//
+func AnalyzePackage(pkg *ssa.Package) map[*ssa.Function]*Result {
+	fns := ssautil.PackageFunctions(pkg)
+	sites := callSites(pkg, fns)
+
+	params := make(map[*ssa.Parameter]Interval)
+	for fn := range sites {
+		for _, p := range fn.Params {
+			params[p] = Empty
+		}
+	}
+
+	results := make(map[*ssa.Function]*Result)
+	for round := 1; ; round++ {
+		for _, fn := range fns {
+			results[fn] = Analyze(fn, params)
+		}
+		changed := false
+		for fn, calls := range sites {
+			for i, p := range fn.Params {
+				x := Empty
+				for _, call := range calls {
+					caller := results[call.Parent()]
+					x = x.Join(caller.At(call.Common().Args[i], call.Block()))
+				}
+				old := params[p]
+				x = old.Join(x)
+				if round > maxRounds {
+					x = old.Widen(x)
+				}
+				if x != old {
+					params[p] = x
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			return results
+		}
+	}
+}
+
+// callSites returns, for each unexported package-level function of
+// fns that is used only as the callee of static calls, the set of
+// those calls.
+func callSites(pkg *ssa.Package, fns []*ssa.Function) map[*ssa.Function][]ssa.CallInstruction {
+	sites := make(map[*ssa.Function][]ssa.CallInstruction)
+	for _, fn := range fns {
+		if fn.Pkg == pkg && fn.Enclosing == nil && fn.Signature.Recv() == nil &&
+			fn.Object() != nil && !fn.Object().Exported() && fn.Name() != "init" && fn.Name() != "main" {
+			sites[fn] = nil
+		}
+	}
+
+	escapes := make(map[*ssa.Function]bool)
+	var rands []*ssa.Value
+	for _, fn := range fns {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				var callee *ssa.Value
+				if call, ok := instr.(ssa.CallInstruction); ok && !call.Common().IsInvoke() {
+					callee = &call.Common().Value
+				}
+				rands = instr.Operands(rands[:0])
+				for _, rand := range rands {
+					g, ok := (*rand).(*ssa.Function)
+					if !ok {
+						continue
+					}
+					if _, ok := sites[g]; !ok {
+						continue
+					}
+					if rand == callee {
+						sites[g] = append(sites[g], instr.(ssa.CallInstruction))
+					} else {
+						escapes[g] = true
+					}
+				}
+			}
+		}
+	}
+
+	// Functions that are referenced other than by calls, and those
+	// that are never called at all, receive arbitrary arguments.
+	for fn := range sites {
+		if escapes[fn] || sites[fn] == nil {
+			delete(sites, fn)
+		}
+	}
+	return sites
+}
\ No newline at end of file
@@ -0,0 +1,95 @@
+// antha-tools/antha/ssa/interval/testdata/ranges.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+// +build ignore
+
+package main
+
+// This file is the input to TestAnalyzePackage in interval_test.go.
+// Each call to println must have a comment giving the range of its
+// argument.
+
+const maxVolume = 200.0
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > maxVolume {
+		return maxVolume
+	}
+	println(v) // [-1, 500]
+	return v
+}
+
+func ClampInt(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > maxVolume {
+		return maxVolume
+	}
+	println(v) // [0, 200]
+	return v
+}
+
+func dilute(stock float64, parts int) {
+	if parts <= 0 {
+		return
+	}
+	println(parts)         // [1, 3]
+	println(stock)         // [10, 50]
+	println(stock * 2)     // [20, 100]
+	println(stock - 100.0) // [-90, -50]
+}
+
+func loop() {
+	total := 0
+	for i := 0; i < 10; i++ {
+		println(i) // [0, 9]
+		total += i
+	}
+	println(total) // [-Inf, +Inf]
+
+	var small uint8 = 250
+	small += 10
+	println(small) // [0, 255]
+}
+
+func Arith(x int) {
+	println(x % 8)  // [-7, 7]
+	println(x & 15) // [0, 15]
+	if x >= 3 && x < 7 {
+		println(x)     // [3, 6]
+		println(x / 2) // [1, 3]
+		println(-x)    // [-6, -3]
+	}
+	println(len("hello")) // [5, 5]
+}
+
+func main() {
+	println(clamp(-1)) // [-Inf, +Inf]
+	clamp(500)
+	dilute(10, 1)
+	dilute(50, 3)
+	loop()
+}
\ No newline at end of file
@@ -0,0 +1,524 @@
+// antha-tools/antha/ssa/nilness/nilness.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// Package nilness reports operations on the SSA form of a function
+// that panic because a pointer, map, function or interface value is
+// nil.
+//
+// The analysis is flow-sensitive.  It walks the dominator tree of each
+// function, accumulating facts about the nilness of values from the
+// conditions of the branches that dominate each block, such as
+// "x != nil", and from the operations already performed on them: a
+// value that has been dereferenced without panicking is not nil.
+// Values are nil if they are the nil constant, and are not nil if
+// they are the result of an allocation, the address of a variable,
+// field or element, or a function.  A φ-node is nil if all its
+// operands are, and is possibly nil if some are.
+//
+// Loads from the same variable or field are assumed to yield the same
+// value if the function does not store to it and makes no call
+// between them.
+//
+// Dereferences, map updates and calls of values known to be nil are
+// reported as definite.  Those of values not known to be non-nil are
+// reported as possible if the value is possibly nil, if it is
+// compared with nil elsewhere in the function, or if it is loaded from
+// a field of a struct type named Inputs, the parameters of an Antha
+// element, whose pointer fields are optional.  Values about which
+// nothing is known are not reported.
+//
+// Blocks that cannot be reached, because the condition of a
+// branch to them contradicts what is known, are not examined.
+//
+package nilness
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"sort"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// A Report describes an operation that may panic on a nil value.
+type Report struct {
+	Pos      token.Pos       // position of the operation
+	Instr    ssa.Instruction // the operation
+	Definite bool            // the value is nil on every path to Instr
+	Msg      string
+}
+
+func (r *Report) String() string { return r.Msg }
+
+// Analyze returns the reports for the function fn, ordered by
+// position.
+//
+// Precondition: fn is built.
+//
+func Analyze(fn *ssa.Function) []*Report {
+	if fn.Blocks == nil {
+		return nil
+	}
+	a := &analysis{fn: fn, compared: make(map[ssa.Value]bool)}
+	a.canonicalize()
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if x, _ := nilComparison(instr); x != nil {
+				a.compared[a.canonical(x)] = true
+			}
+		}
+	}
+	a.visit(fn.Blocks[0], nil)
+	if fn.Recover != nil {
+		a.visit(fn.Recover, nil)
+	}
+	sort.Sort(byPos(a.reports))
+	return a.reports
+}
+
+// AnalyzePackage returns the reports for every function of pkg that
+// has a body, including methods and anonymous functions, ordered by
+// position.
+//
+// Precondition: pkg is built.
+//
+func AnalyzePackage(pkg *ssa.Package) []*Report {
+	var reports []*Report
+	for _, fn := range ssautil.PackageFunctions(pkg) {
+		reports = append(reports, Analyze(fn)...)
+	}
+	sort.Sort(byPos(reports))
+	return reports
+}
+
+// A nilness is what is known of whether a value is nil.
+type nilness int
+
+const (
+	unknown  nilness = iota
+	isnil            // nil on every path
+	maybenil         // nil on some path
+	isnonnil         // never nil
+
+	cyclic nilness = -1 // a φ-node being evaluated, reached again by a cycle
+)
+
+// A fact records the nilness of a value within a dominator subtree.
+type fact struct {
+	v ssa.Value
+	n nilness
+}
+
+type analysis struct {
+	fn       *ssa.Function
+	canon    map[ssa.Value]ssa.Value // maps loads to equivalent loads
+	compared map[ssa.Value]bool      // canonical values compared with nil in fn
+	reports  []*Report
+}
+
+// visit examines the block b, within which facts hold, and the blocks
+// it dominates.
+func (a *analysis) visit(b *ssa.BasicBlock, facts []fact) {
+	for _, instr := range b.Instrs {
+		x, what := nilOperand(instr)
+		if x == nil {
+			continue
+		}
+		a.check(instr, x, what, facts)
+		// Beyond a successful operation, x is not nil.
+		facts = append(facts[:len(facts):len(facts)], fact{a.canonical(x), isnonnil})
+	}
+
+	for _, d := range b.Dominees() {
+		dfacts := facts[:len(facts):len(facts)]
+		if f, ok := a.branchFact(b, d, facts); ok {
+			if n := a.nilnessOf(f.v, facts); n == isnil && f.n == isnonnil || n == isnonnil && f.n == isnil {
+				continue // d is unreachable
+			}
+			dfacts = append(dfacts, f)
+		}
+		a.visit(d, dfacts)
+	}
+}
+
+// branchFact returns the fact established on entry to d by the
+// terminating branch of its immediate dominator b, if any.
+func (a *analysis) branchFact(b, d *ssa.BasicBlock, facts []fact) (fact, bool) {
+	if len(d.Preds) != 1 || len(b.Instrs) == 0 {
+		return fact{}, false
+	}
+	If, ok := b.Instrs[len(b.Instrs)-1].(*ssa.If)
+	if !ok {
+		return fact{}, false
+	}
+	cond, ok := If.Cond.(*ssa.BinOp)
+	if !ok {
+		return fact{}, false
+	}
+	x, eq := nilComparison(cond)
+	if x == nil {
+		return fact{}, false
+	}
+	if d == b.Succs[1] {
+		eq = !eq
+	}
+	if eq {
+		return fact{a.canonical(x), isnil}, true
+	}
+	return fact{a.canonical(x), isnonnil}, true
+}
+
+// nilComparison returns x if instr is a comparison "x == nil" or
+// "x != nil", and reports whether it is the former.
+func nilComparison(instr ssa.Instruction) (x ssa.Value, eq bool) {
+	binop, ok := instr.(*ssa.BinOp)
+	if !ok || binop.Op != token.EQL && binop.Op != token.NEQ {
+		return nil, false
+	}
+	if isNilConst(binop.Y) {
+		x = binop.X
+	} else if isNilConst(binop.X) {
+		x = binop.Y
+	}
+	if x == nil || isNilConst(x) {
+		return nil, false
+	}
+	return x, binop.Op == token.EQL
+}
+
+func isNilConst(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.IsNil()
+}
+
+// nilOperand returns the operand of instr that must not be nil, and a
+// description of the operation, or nil if there is none.
+func nilOperand(instr ssa.Instruction) (ssa.Value, string) {
+	switch instr := instr.(type) {
+	case *ssa.UnOp:
+		if instr.Op == token.MUL {
+			return instr.X, "nil dereference in load"
+		}
+	case *ssa.Store:
+		return instr.Addr, "nil dereference in store"
+	case *ssa.FieldAddr:
+		return instr.X, "nil dereference in field selection"
+	case *ssa.IndexAddr:
+		if _, ok := instr.X.Type().Underlying().(*types.Pointer); ok {
+			return instr.X, "nil dereference in index operation"
+		}
+	case *ssa.MapUpdate:
+		return instr.Map, "nil map write"
+	case ssa.CallInstruction:
+		c := instr.Common()
+		if c.IsInvoke() {
+			return c.Value, "method call on nil interface value"
+		}
+		switch c.Value.(type) {
+		case *ssa.Function, *ssa.Builtin, *ssa.MakeClosure:
+			return nil, ""
+		}
+		return c.Value, "nil function call"
+	}
+	return nil, ""
+}
+
+// check reports the operation instr if its operand x, described by
+// what, may be nil.
+func (a *analysis) check(instr ssa.Instruction, x ssa.Value, what string, facts []fact) {
+	r := &Report{Pos: instr.Pos(), Instr: instr}
+	if !r.Pos.IsValid() {
+		r.Pos = x.Pos()
+	}
+	switch a.nilnessOf(x, facts) {
+	case isnil:
+		r.Definite = true
+		r.Msg = what
+	case maybenil:
+		r.Msg = "possible " + what
+	case unknown:
+		if a.compared[a.canonical(x)] {
+			r.Msg = fmt.Sprintf("possible %s: %s is compared with nil elsewhere", what, describe(x))
+		} else if name := optionalInput(x); name != "" {
+			r.Msg = fmt.Sprintf("possible %s: optional input %s is not checked", what, name)
+		} else {
+			return
+		}
+	default:
+		return
+	}
+	a.reports = append(a.reports, r)
+}
+
+// describe returns a description of the value v for a report.
+func describe(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Parameter:
+		return v.Name()
+	case *ssa.UnOp:
+		if fa, ok := v.X.(*ssa.FieldAddr); ok && v.Op == token.MUL {
+			return "field " + fieldName(fa.X.Type(), fa.Field)
+		}
+		if g, ok := v.X.(*ssa.Global); ok && v.Op == token.MUL {
+			return g.Name()
+		}
+	case *ssa.Field:
+		return "field " + fieldName(v.X.Type(), v.Field)
+	}
+	return "the value"
+}
+
+// optionalInput returns the name of the field if v is loaded from a
+// nilable field of a struct type named Inputs, or "" otherwise.
+func optionalInput(v ssa.Value) string {
+	var T types.Type
+	var index int
+	switch v := v.(type) {
+	case *ssa.UnOp:
+		fa, ok := v.X.(*ssa.FieldAddr)
+		if !ok || v.Op != token.MUL {
+			return ""
+		}
+		T, index = deref(fa.X.Type()), fa.Field
+	case *ssa.Field:
+		T, index = v.X.Type(), v.Field
+	default:
+		return ""
+	}
+	if named, ok := T.(*types.Named); !ok || named.Obj().Name() != "Inputs" {
+		return ""
+	}
+	return "Inputs." + fieldName(T, index)
+}
+
+func fieldName(T types.Type, index int) string {
+	return deref(T).Underlying().(*types.Struct).Field(index).Name()
+}
+
+// nilnessOf returns what is known of whether v is nil, given facts.
+func (a *analysis) nilnessOf(v ssa.Value, facts []fact) nilness {
+	return a.nilnessRec(v, facts, make(map[*ssa.Phi]bool))
+}
+
+func (a *analysis) nilnessRec(v ssa.Value, facts []fact, phis map[*ssa.Phi]bool) nilness {
+	c := a.canonical(v)
+	for i := len(facts) - 1; i >= 0; i-- {
+		if facts[i].v == c {
+			return facts[i].n
+		}
+	}
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.IsNil() {
+			return isnil
+		}
+		return isnonnil
+	case *ssa.Alloc, *ssa.FieldAddr, *ssa.IndexAddr, *ssa.Global,
+		*ssa.Function, *ssa.Builtin, *ssa.MakeClosure, *ssa.MakeMap,
+		*ssa.MakeChan, *ssa.MakeInterface:
+		return isnonnil
+	case *ssa.ChangeType:
+		return a.nilnessRec(v.X, facts, phis)
+	case *ssa.ChangeInterface:
+		return a.nilnessRec(v.X, facts, phis)
+	case *ssa.Phi:
+		if phis[v] {
+			return cyclic
+		}
+		phis[v] = true
+		defer delete(phis, v)
+		nils, nonnils, n := 0, 0, 0
+		for _, edge := range v.Edges {
+			switch a.nilnessRec(edge, facts, phis) {
+			case cyclic:
+				continue // contributes nothing
+			case isnil:
+				nils++
+			case maybenil:
+				return maybenil
+			case isnonnil:
+				nonnils++
+			}
+			n++
+		}
+		switch {
+		case n == 0:
+			return unknown
+		case nils == n:
+			return isnil
+		case nonnils == n:
+			return isnonnil
+		case nils > 0:
+			return maybenil
+		}
+	}
+	return unknown
+}
+
+// canonicalize maps each load in a.fn to the first load from the same
+// access path, a variable followed by a sequence of field selections
+// and indirections, if the function stores to no prefix of the path
+// and no call may execute between two of its loads.  Such loads are
+// assumed to yield the same value.
+func (a *analysis) canonicalize() {
+	var stores []string
+	var calls []ssa.Instruction
+	loads := make(map[string][]ssa.Instruction)
+	var order []string
+	for _, b := range a.fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				stores = append(stores, addrPath(instr.Addr))
+			case *ssa.UnOp:
+				if instr.Op == token.MUL {
+					p := addrPath(instr.X)
+					if loads[p] == nil {
+						order = append(order, p)
+					}
+					loads[p] = append(loads[p], instr)
+				}
+			case *ssa.Call, *ssa.Go, *ssa.RunDefers:
+				// The callee may store to any path.
+				if c, ok := instr.(ssa.CallInstruction); ok {
+					if _, ok := c.Common().Value.(*ssa.Builtin); ok {
+						continue
+					}
+				}
+				calls = append(calls, instr)
+			}
+		}
+	}
+
+	a.canon = make(map[ssa.Value]ssa.Value)
+	reach := reachable(a.fn)
+outer:
+	for _, p := range order {
+		for _, s := range stores {
+			if p == s || strings.HasPrefix(p, s+"/") {
+				continue outer
+			}
+		}
+		for _, c := range calls {
+			for i, x := range loads[p] {
+				for j, y := range loads[p] {
+					if i != j && reach.between(x, c, y) {
+						continue outer
+					}
+				}
+			}
+		}
+		first := loads[p][0].(ssa.Value)
+		for _, load := range loads[p][1:] {
+			a.canon[load.(ssa.Value)] = first
+		}
+	}
+}
+
+// reachability records, for each block of a function, the blocks
+// reachable from it along one or more edges.
+type reachability map[*ssa.BasicBlock]map[*ssa.BasicBlock]bool
+
+func reachable(fn *ssa.Function) reachability {
+	r := make(reachability)
+	for _, b := range fn.Blocks {
+		seen := make(map[*ssa.BasicBlock]bool)
+		stack := append([]*ssa.BasicBlock(nil), b.Succs...)
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !seen[c] {
+				seen[c] = true
+				stack = append(stack, c.Succs...)
+			}
+		}
+		r[b] = seen
+	}
+	return r
+}
+
+// reaches reports whether y may execute after x.
+func (r reachability) reaches(x, y ssa.Instruction) bool {
+	if x.Block() == y.Block() && index(x) < index(y) {
+		return true
+	}
+	return r[x.Block()][y.Block()]
+}
+
+// between reports whether c may execute after x and before y.
+func (r reachability) between(x, c, y ssa.Instruction) bool {
+	return r.reaches(x, c) && r.reaches(c, y)
+}
+
+// index returns the index of instr within its block.
+func index(instr ssa.Instruction) int {
+	for i, x := range instr.Block().Instrs {
+		if x == instr {
+			return i
+		}
+	}
+	return -1
+}
+
+// canonical returns the load equivalent to v, or v itself.
+func (a *analysis) canonical(v ssa.Value) ssa.Value {
+	if c, ok := a.canon[v]; ok {
+		return c
+	}
+	return v
+}
+
+// addrPath returns the access path of the address addr, with
+// components separated by slashes.
+func addrPath(addr ssa.Value) string {
+	if fa, ok := addr.(*ssa.FieldAddr); ok {
+		return fmt.Sprintf("%s/%d", valuePath(fa.X), fa.Field)
+	}
+	return valuePath(addr)
+}
+
+// valuePath returns the access path of the pointer v.
+func valuePath(v ssa.Value) string {
+	if load, ok := v.(*ssa.UnOp); ok && load.Op == token.MUL {
+		return addrPath(load.X) + "/*"
+	}
+	return fmt.Sprintf("%p", v)
+}
+
+// deref returns a pointer's element type; otherwise it returns typ.
+func deref(typ types.Type) types.Type {
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return typ
+}
+
+type byPos []*Report
+
+func (p byPos) Len() int           { return len(p) }
+func (p byPos) Less(i, j int) bool { return p[i].Pos < p[j].Pos }
+func (p byPos) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
\ No newline at end of file
@@ -0,0 +1,86 @@
+// antha-tools/antha/ssa/nilness/nilness_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package nilness_test
+
+import (
+	"github.com/antha-lang/antha/parser"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/nilness"
+)
+
+func TestAnalyzePackage(t *testing.T) {
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("testdata/nilness.go", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	// Map each line to the report expected in its comment.
+	want := make(map[int]string)
+	for _, c := range f.Comments {
+		text := strings.TrimSpace(c.Text())
+		if strings.HasPrefix(text, "definite: ") || strings.HasPrefix(text, "possible: ") {
+			want[iprog.Fset.Position(c.Pos()).Line] = text
+		}
+	}
+
+	got := make(map[int]string)
+	for _, r := range nilness.AnalyzePackage(mainPkg) {
+		kind := "possible: "
+		if r.Definite {
+			kind = "definite: "
+		}
+		line := prog.Fset.Position(r.Pos).Line
+		if got[line] != "" {
+			t.Errorf("line %d: more than one report: %s", line, r.Msg)
+		}
+		got[line] = kind + r.Msg
+	}
+	for line, msg := range got {
+		if msg != want[line] {
+			t.Errorf("line %d: got %q, want %q", line, msg, want[line])
+		}
+	}
+	for line, msg := range want {
+		if got[line] == "" {
+			t.Errorf("line %d: no report, want %q", line, msg)
+		}
+	}
+}
\ No newline at end of file
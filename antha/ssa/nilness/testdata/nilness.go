@@ -0,0 +1,135 @@
+// antha-tools/antha/ssa/nilness/testdata/nilness.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// +build ignore
+
+package main
+
+// This file is the input to TestAnalyzePackage in nilness_test.go.
+// Each line on which an operation is reported has a comment giving
+// the message of the report, prefixed by "definite:" or "possible:".
+
+type T struct {
+	f int
+	m map[string]int
+}
+
+type Component struct{ Volume float64 }
+
+type Inputs struct {
+	Buffer   *Component // optional
+	Diluent  Component
+	Replicas int
+}
+
+func definite(p *T) {
+	if p == nil {
+		print(p.f) // definite: nil dereference in field selection
+		return
+	}
+	print(p.f)
+
+	var m map[string]int
+	m["a"] = 1 // definite: nil map write
+
+	var fn func()
+	fn() // definite: nil function call
+
+	var q *int
+	*q = 1 // definite: nil dereference in store
+}
+
+func guarded(p *T, x *int) int {
+	if p != nil && p.m != nil {
+		p.m["a"] = 1
+		return p.f
+	}
+	if x == nil {
+		return 0
+	}
+	n := *x
+	return n + *x
+}
+
+func phi(b bool) int {
+	var p *T
+	if b {
+		p = new(T)
+	}
+	return p.f // possible: possible nil dereference in field selection
+}
+
+func checkedLater(p *T) int {
+	n := p.f // possible: possible nil dereference in field selection: p is compared with nil elsewhere
+	if p == nil {
+		return 0
+	}
+	return n
+}
+
+func unreachable() {
+	p := new(T)
+	if p == nil {
+		print(p.f) // not reached
+	}
+}
+
+func unknown(p *T, fn func() int, i interface {
+	M()
+}) int {
+	i.M()
+	return p.f + fn()
+}
+
+func steps(in *Inputs) float64 {
+	v := in.Diluent.Volume
+	if in.Replicas > 1 {
+		v += in.Buffer.Volume // possible: possible nil dereference in field selection: optional input Inputs.Buffer is not checked
+	}
+	return v
+}
+
+func stepsChecked(in *Inputs) float64 {
+	if in.Buffer == nil {
+		return 0
+	}
+	return in.Buffer.Volume // a different load of the same field
+}
+
+func fill(p *T) { p.m = make(map[string]int) }
+
+func refilled(p *T) {
+	if p.m == nil {
+		fill(p)
+		p.m["a"] = 1 // fill may have set p.m: a different value
+	}
+}
+
+func closure(p *T) func() int {
+	return func() int {
+		if p == nil {
+			return p.f // definite: nil dereference in field selection
+		}
+		return p.f
+	}
+}
\ No newline at end of file
@@ -0,0 +1,700 @@
+// antha-tools/antha/ssa/opt.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa
+
+// This file defines the optional optimization passes, which are
+// applied to each function after lifting when the OptimizeFunctions
+// builder mode is set:
+//
+// - sparse conditional constant propagation (Wegman & Zadeck, 1991),
+//   which also replaces branches on constant conditions by jumps and
+//   deletes the blocks thereby made unreachable;
+//
+// - copy propagation, which replaces each trivial φ-node by the
+//   single value it merges;
+//
+// - dead code elimination, which deletes instructions that have no
+//   effect and whose results are unused, including stores to local
+//   memory that is never read.
+//
+// The passes are conservative: they never delete an instruction that
+// might panic, nor fold an operation whose result depends on the
+// target's word size or floating-point precision.
+
+import (
+	"os"
+
+	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha/token"
+)
+
+// optimize applies the optimization passes to f, whose referrers and
+// dominator tree must be up to date.  If the SanityCheckFunctions mode
+// is set, f is checked after each pass.
+//
+func optimize(f *Function) {
+	passes := []struct {
+		name string
+		run  func(*Function)
+	}{
+		{"constant propagation", propagateConstants},
+		{"copy propagation", propagateCopies},
+		{"dead code elimination", eliminateDeadCode},
+		{"block optimization", optimizeBlocks},
+	}
+	for _, pass := range passes {
+		pass.run(f)
+		if f.Prog.mode&SanityCheckFunctions != 0 && !sanityCheck(f, nil) {
+			f.WriteTo(os.Stderr)
+			panic("SanityCheck failed after " + pass.name)
+		}
+	}
+	buildDomTree(f)
+}
+
+// detach removes instr from the referrers of each of its operands, in
+// preparation for its deletion.
+func detach(instr Instruction) {
+	var rands [10]*Value // reuse storage
+	for _, rand := range instr.Operands(rands[:0]) {
+		if *rand == nil {
+			continue
+		}
+		if refs := (*rand).Referrers(); refs != nil {
+			j := 0
+			for _, ref := range *refs {
+				if ref != instr {
+					(*refs)[j] = ref
+					j++
+				}
+			}
+			// Nil out (*refs)[j:] to aid GC.
+			for i := j; i < len(*refs); i++ {
+				(*refs)[i] = nil
+			}
+			*refs = (*refs)[:j]
+		}
+	}
+}
+
+// removeLocals removes the deleted Allocs in dead from f.Locals.
+func removeLocals(f *Function, dead map[*Alloc]bool) {
+	if len(dead) == 0 {
+		return
+	}
+	j := 0
+	for _, l := range f.Locals {
+		if !dead[l] {
+			f.Locals[j] = l
+			j++
+		}
+	}
+	// Nil out f.Locals[j:] to aid GC.
+	for i := j; i < len(f.Locals); i++ {
+		f.Locals[i] = nil
+	}
+	f.Locals = f.Locals[:j]
+}
+
+// -- Constant propagation ---------------------------------------------
+
+// A latticeValue is an element of the constant propagation lattice.
+// The zero value is ⊤ (no information yet); if c is non-nil, the value
+// is that constant; if over is set, the value is ⊥ (overdefined: not
+// a constant).
+type latticeValue struct {
+	c    *Const
+	over bool
+}
+
+var overdefined = latticeValue{over: true}
+
+// height returns 0, 1 or 2 for ⊤, a constant, or ⊥, respectively.
+// Values only ever move down the lattice.
+func (lv latticeValue) height() int {
+	switch {
+	case lv.over:
+		return 2
+	case lv.c != nil:
+		return 1
+	}
+	return 0
+}
+
+// meet returns the greatest lower bound of x and y.
+func meet(x, y latticeValue) latticeValue {
+	switch {
+	case x.height() == 0:
+		return y
+	case y.height() == 0:
+		return x
+	case x.over || y.over || !sameConst(x.c, y.c):
+		return overdefined
+	}
+	return x
+}
+
+// sameConst reports whether x and y denote the same constant value.
+func sameConst(x, y *Const) bool {
+	if x.Value == nil || y.Value == nil {
+		return x.Value == nil && y.Value == nil && types.Identical(x.Type(), y.Type())
+	}
+	return x.Value.Kind() == y.Value.Kind() && exact.Compare(x.Value, token.EQL, y.Value)
+}
+
+// constProp holds the state of sparse conditional constant propagation.
+type constProp struct {
+	values    map[Value]latticeValue  // lattice value of each instruction; absent means ⊤
+	reachable map[*BasicBlock]bool    // blocks that may be executed
+	edges     map[[2]*BasicBlock]bool // CFG edges that may be taken
+	blocks    []*BasicBlock           // worklist of newly reachable blocks
+	instrs    []Instruction           // worklist of instructions whose operands have changed
+}
+
+// propagateConstants performs sparse conditional constant propagation
+// on f, replacing each value found to be constant by a Const and each
+// If whose condition is constant by a Jump, and deleting unreachable
+// blocks.
+//
+func propagateConstants(f *Function) {
+	p := &constProp{
+		values:    make(map[Value]latticeValue),
+		reachable: make(map[*BasicBlock]bool),
+		edges:     make(map[[2]*BasicBlock]bool),
+	}
+	p.reach(nil, f.Blocks[0])
+	if f.Recover != nil {
+		p.reach(nil, f.Recover)
+	}
+	for len(p.blocks) > 0 || len(p.instrs) > 0 {
+		for len(p.blocks) > 0 {
+			b := p.blocks[len(p.blocks)-1]
+			p.blocks = p.blocks[:len(p.blocks)-1]
+			for _, instr := range b.Instrs {
+				p.visit(instr)
+			}
+		}
+		for len(p.instrs) > 0 {
+			instr := p.instrs[len(p.instrs)-1]
+			p.instrs = p.instrs[:len(p.instrs)-1]
+			if p.reachable[instr.Block()] {
+				p.visit(instr)
+			}
+		}
+	}
+
+	// Replace constant-valued instructions by constants.
+	for _, b := range f.Blocks {
+		if !p.reachable[b] {
+			continue
+		}
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(Value); ok {
+				if c := p.values[v].c; c != nil {
+					replaceAll(v, c)
+				}
+			}
+		}
+	}
+
+	// Replace branches on constant conditions by jumps.
+	for _, b := range f.Blocks {
+		if !p.reachable[b] {
+			continue
+		}
+		last := len(b.Instrs) - 1
+		if instr, ok := b.Instrs[last].(*If); ok {
+			if c, ok := instr.Cond.(*Const); ok {
+				taken, other := b.Succs[0], b.Succs[1]
+				if !exact.BoolVal(c.Value) {
+					taken, other = other, taken
+				}
+				if taken != other {
+					other.removePred(b)
+					jump := new(Jump)
+					jump.setBlock(b)
+					b.Instrs[last] = jump
+					b.Succs = append(b.succs2[:0], taken)
+				}
+			}
+		}
+	}
+
+	// Delete unreachable blocks.
+	dead := make(map[*Alloc]bool)
+	for i, b := range f.Blocks {
+		if p.reachable[b] {
+			continue
+		}
+		for _, c := range b.Succs {
+			if p.reachable[c] {
+				c.removePred(b)
+			}
+		}
+		for _, instr := range b.Instrs {
+			detach(instr)
+			if alloc, ok := instr.(*Alloc); ok {
+				dead[alloc] = true
+			}
+		}
+		f.Blocks[i] = nil
+	}
+	f.removeNilBlocks()
+	removeLocals(f, dead)
+}
+
+// reach records that the CFG edge from→to may be taken.
+// from is nil for the entry and recover blocks.
+func (p *constProp) reach(from, to *BasicBlock) {
+	if from != nil {
+		e := [2]*BasicBlock{from, to}
+		if p.edges[e] {
+			return
+		}
+		p.edges[e] = true
+	}
+	if !p.reachable[to] {
+		p.reachable[to] = true
+		p.blocks = append(p.blocks, to)
+		return
+	}
+	// A new edge into a reachable block affects only its φ-nodes.
+	for _, instr := range to.Instrs {
+		phi, ok := instr.(*Phi)
+		if !ok {
+			break
+		}
+		p.visit(phi)
+	}
+}
+
+// lookup returns the lattice value of v.
+func (p *constProp) lookup(v Value) latticeValue {
+	if c, ok := v.(*Const); ok {
+		return latticeValue{c: c}
+	}
+	if _, ok := v.(Instruction); ok {
+		return p.values[v]
+	}
+	return overdefined // parameter, free variable, global or function
+}
+
+// visit evaluates instr, updating the lattice or the set of
+// reachable edges.
+func (p *constProp) visit(instr Instruction) {
+	switch instr := instr.(type) {
+	case *Jump:
+		p.reach(instr.Block(), instr.Block().Succs[0])
+
+	case *If:
+		b := instr.Block()
+		cond := p.lookup(instr.Cond)
+		switch {
+		case cond.over:
+			p.reach(b, b.Succs[0])
+			p.reach(b, b.Succs[1])
+		case cond.c != nil:
+			if exact.BoolVal(cond.c.Value) {
+				p.reach(b, b.Succs[0])
+			} else {
+				p.reach(b, b.Succs[1])
+			}
+		}
+
+	case Value:
+		lv := p.eval(instr)
+		if lv.height() <= p.values[instr].height() {
+			return // no change
+		}
+		p.values[instr] = lv
+		if refs := instr.Referrers(); refs != nil {
+			p.instrs = append(p.instrs, *refs...)
+		}
+	}
+}
+
+// eval returns the lattice value of the instruction v given the
+// current lattice values of its operands.
+func (p *constProp) eval(v Value) latticeValue {
+	switch v := v.(type) {
+	case *Phi:
+		var lv latticeValue
+		b := v.Block()
+		for i, edge := range v.Edges {
+			if p.edges[[2]*BasicBlock{b.Preds[i], b}] {
+				lv = meet(lv, p.lookup(edge))
+			}
+		}
+		return lv
+
+	case *BinOp:
+		x, y := p.lookup(v.X), p.lookup(v.Y)
+		if x.over || y.over {
+			return overdefined
+		}
+		if x.c == nil || y.c == nil {
+			return latticeValue{}
+		}
+		if c := foldBinOp(v, x.c, y.c); c != nil {
+			return latticeValue{c: c}
+		}
+
+	case *UnOp:
+		x := p.lookup(v.X)
+		if x.c == nil {
+			if v.Op == token.SUB || v.Op == token.NOT || v.Op == token.XOR {
+				return x
+			}
+			return overdefined
+		}
+		if c := foldUnOp(v, x.c); c != nil {
+			return latticeValue{c: c}
+		}
+
+	case *ChangeType:
+		x := p.lookup(v.X)
+		if x.c != nil {
+			return latticeValue{c: NewConst(x.c.Value, v.Type())}
+		}
+		return x
+	}
+	return overdefined
+}
+
+// foldBinOp returns the constant result of the integer, boolean or
+// string operation instr applied to x and y, or nil if it cannot be
+// (or should not be) computed at build time.
+//
+func foldBinOp(instr *BinOp, x, y *Const) *Const {
+	if x.Value == nil || y.Value == nil {
+		return nil
+	}
+	t, ok := x.Type().Underlying().(*types.Basic)
+	if !ok || t.Info()&(types.IsInteger|types.IsBoolean|types.IsString) == 0 {
+		return nil
+	}
+	var z exact.Value
+	switch instr.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return NewConst(exact.MakeBool(exact.Compare(x.Value, instr.Op, y.Value)), instr.Type())
+
+	case token.SHL, token.SHR:
+		s, ok := exact.Uint64Val(y.Value)
+		if !ok || s >= 64 {
+			return nil
+		}
+		z = exact.Shift(x.Value, instr.Op, uint(s))
+
+	case token.QUO, token.REM:
+		if exact.Sign(y.Value) == 0 {
+			return nil // division by zero panics at run time
+		}
+		op := instr.Op
+		if op == token.QUO {
+			op = token.QUO_ASSIGN // force integer division
+		}
+		z = exact.BinaryOp(x.Value, op, y.Value)
+
+	default:
+		z = exact.BinaryOp(x.Value, instr.Op, y.Value)
+	}
+	if !representable(z, t) {
+		return nil
+	}
+	return NewConst(z, instr.Type())
+}
+
+// foldUnOp returns the constant result of the integer or boolean
+// operation instr applied to x, or nil if it cannot be computed at
+// build time.
+//
+func foldUnOp(instr *UnOp, x *Const) *Const {
+	if x.Value == nil {
+		return nil
+	}
+	t, ok := instr.Type().Underlying().(*types.Basic)
+	if !ok || t.Info()&(types.IsInteger|types.IsBoolean) == 0 {
+		return nil
+	}
+	size := -1 // signed ^x needs no mask
+	switch instr.Op {
+	case token.SUB, token.NOT:
+	case token.XOR:
+		if t.Info()&types.IsUnsigned != 0 {
+			switch t.Kind() {
+			case types.Uint8:
+				size = 1
+			case types.Uint16:
+				size = 2
+			case types.Uint32:
+				size = 4
+			case types.Uint64:
+				size = 8
+			default:
+				return nil // size depends on target
+			}
+		}
+	default:
+		return nil
+	}
+	z := exact.UnaryOp(instr.Op, x.Value, size)
+	if !representable(z, t) {
+		return nil
+	}
+	return NewConst(z, instr.Type())
+}
+
+// representable reports whether z is a value of type t on all
+// targets.  Results that overflow are not folded: at run time they
+// wrap around.  The int, uint and uintptr types are assumed to have
+// only 32 bits.
+//
+func representable(z exact.Value, t *types.Basic) bool {
+	switch z.Kind() {
+	case exact.Bool, exact.String:
+		return true
+	case exact.Int:
+	default:
+		return false
+	}
+	var bits uint = 32
+	switch t.Kind() {
+	case types.Int8, types.Uint8:
+		bits = 8
+	case types.Int16, types.Uint16:
+		bits = 16
+	case types.Int64, types.Uint64:
+		bits = 64
+	}
+	if t.Info()&types.IsUnsigned != 0 {
+		u, ok := exact.Uint64Val(z)
+		return ok && (bits == 64 || u < 1<<bits)
+	}
+	i, ok := exact.Int64Val(z)
+	return ok && (bits == 64 || -1<<(bits-1) <= i && i < 1<<(bits-1))
+}
+
+// -- Copy propagation -------------------------------------------------
+
+// propagateCopies replaces each trivial φ-node of f—one whose edges
+// are all the same value, ignoring self-references—by that value.
+// Since removing one φ-node may make another trivial, it iterates
+// until no further progress is made.
+//
+func propagateCopies(f *Function) {
+	for changed := true; changed; {
+		changed = false
+		for _, b := range f.Blocks {
+			j := 0
+			for _, instr := range b.Instrs {
+				if phi, ok := instr.(*Phi); ok {
+					if v := trivialPhiValue(phi); v != nil {
+						replaceAll(phi, v)
+						detach(phi)
+						changed = true
+						continue
+					}
+				}
+				b.Instrs[j] = instr
+				j++
+			}
+			// Nil out b.Instrs[j:] to aid GC.
+			for i := j; i < len(b.Instrs); i++ {
+				b.Instrs[i] = nil
+			}
+			b.Instrs = b.Instrs[:j]
+		}
+	}
+}
+
+// trivialPhiValue returns the sole value, other than phi itself,
+// merged by phi, or nil if there is not exactly one.
+func trivialPhiValue(phi *Phi) Value {
+	var v Value
+	for _, edge := range phi.Edges {
+		switch {
+		case edge == phi:
+			// self-refs don't count
+		case v == nil:
+			v = edge
+		case edge != v:
+			c1, ok1 := edge.(*Const)
+			c2, ok2 := v.(*Const)
+			if !ok1 || !ok2 || !sameConst(c1, c2) {
+				return nil
+			}
+		}
+	}
+	return v
+}
+
+// -- Dead code elimination --------------------------------------------
+
+// eliminateDeadCode deletes each instruction of f that has no effect
+// and whose result, if any, is used only by other deleted
+// instructions.  Stores to local memory that is never read are
+// deleted too.
+//
+func eliminateDeadCode(f *Function) {
+	writeOnly := make(map[*Alloc]bool)
+	isDeadStore := func(instr Instruction) bool {
+		store, ok := instr.(*Store)
+		if !ok {
+			return false
+		}
+		alloc := localAlloc(store.Addr)
+		if alloc == nil {
+			return false
+		}
+		wo, ok := writeOnly[alloc]
+		if !ok {
+			wo = isWriteOnly(alloc)
+			writeOnly[alloc] = wo
+		}
+		return wo
+	}
+
+	// Mark: the live instructions are those with effects,
+	// and, transitively, the definitions of their operands.
+	live := make(map[Instruction]bool)
+	var worklist []Instruction
+	for _, b := range f.Blocks {
+		for _, instr := range b.Instrs {
+			if !isPure(instr) && !isDeadStore(instr) {
+				live[instr] = true
+				worklist = append(worklist, instr)
+			}
+		}
+	}
+	var rands []*Value
+	for len(worklist) > 0 {
+		instr := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		rands = instr.Operands(rands[:0]) // recycle storage
+		for _, rand := range rands {
+			if def, ok := (*rand).(Instruction); ok && !live[def] {
+				live[def] = true
+				worklist = append(worklist, def)
+			}
+		}
+	}
+
+	// Sweep.
+	dead := make(map[*Alloc]bool)
+	for _, b := range f.Blocks {
+		j := 0
+		for _, instr := range b.Instrs {
+			if live[instr] {
+				b.Instrs[j] = instr
+				j++
+				continue
+			}
+			detach(instr)
+			if alloc, ok := instr.(*Alloc); ok {
+				dead[alloc] = true
+			}
+		}
+		// Nil out b.Instrs[j:] to aid GC.
+		for i := j; i < len(b.Instrs); i++ {
+			b.Instrs[i] = nil
+		}
+		b.Instrs = b.Instrs[:j]
+	}
+	removeLocals(f, dead)
+}
+
+// isPure reports whether instr may be deleted if its result is
+// unused: it has no effect and cannot panic.
+func isPure(instr Instruction) bool {
+	switch instr := instr.(type) {
+	case *Alloc, *Phi, *ChangeType, *ChangeInterface, *Convert,
+		*MakeInterface, *MakeClosure, *Extract, *Field:
+		return true
+
+	case *UnOp:
+		return instr.Op == token.SUB || instr.Op == token.NOT || instr.Op == token.XOR
+
+	case *BinOp:
+		switch instr.Op {
+		case token.QUO, token.REM:
+			if t, ok := instr.X.Type().Underlying().(*types.Basic); ok && t.Info()&types.IsInteger != 0 {
+				c, ok := instr.Y.(*Const)
+				return ok && c.Value != nil && exact.Sign(c.Value) != 0
+			}
+		case token.EQL, token.NEQ:
+			// Comparing interfaces (or structs or arrays
+			// containing them) panics if the dynamic type
+			// is not comparable.
+			switch instr.X.Type().Underlying().(type) {
+			case *types.Basic, *types.Pointer, *types.Chan:
+			default:
+				return false
+			}
+		}
+		return true
+
+	case *TypeAssert:
+		return instr.CommaOk
+
+	case *FieldAddr:
+		return localAlloc(instr) != nil // address of a local cannot be nil
+	}
+	return false
+}
+
+// localAlloc returns the Alloc of which addr is the address, or the
+// address of a (nested) field, or nil if there is none.
+func localAlloc(addr Value) *Alloc {
+	for {
+		switch v := addr.(type) {
+		case *Alloc:
+			return v
+		case *FieldAddr:
+			addr = v.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isWriteOnly reports whether the memory at addr, an Alloc or
+// FieldAddr, is only ever stored to, never loaded or otherwise used.
+func isWriteOnly(addr Value) bool {
+	for _, ref := range *addr.Referrers() {
+		switch ref := ref.(type) {
+		case *Store:
+			if ref.Addr != addr || ref.Val == addr {
+				return false // address escapes
+			}
+		case *FieldAddr:
+			if !isWriteOnly(ref) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
\ No newline at end of file
@@ -0,0 +1,393 @@
+// antha-tools/antha/ssa/postdom.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa
+
+// This file defines post-dominance and control dependence.
+//
+// Unlike the dominator tree, which is built for every function and
+// stored in its blocks, these relations are computed on demand by
+// each call to Function.PostDomTree or Function.ControlDeps.
+//
+// Post-dominance is defined relative to a virtual exit node, whose
+// CFG predecessors are the blocks with no successors (those ending in
+// Return or Panic).  A block from which no exit is reachable, i.e.
+// one in an infinite loop, would otherwise have no post-dominators;
+// following common practice we add a virtual edge to the exit from
+// one block of each such loop.  Such blocks are reported by
+// PostDomTree.LoopExits.
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+)
+
+// A PostDomTree is the post-dominator tree of a function's CFG.
+// Its root, the virtual exit node, is represented by nil.
+//
+type PostDomTree struct {
+	fn        *Function
+	info      []domInfo     // info[b.Index] is b's post-dominance information
+	roots     []*BasicBlock // children of the exit node
+	loopExits []*BasicBlock // blocks given a virtual edge to the exit
+}
+
+// PostDomTree computes the post-dominator tree of f.
+//
+func (f *Function) PostDomTree() *PostDomTree {
+	n := len(f.Blocks)
+	t := &PostDomTree{fn: f, info: make([]domInfo, n)}
+
+	// Number the nodes of the reverse CFG in postorder,
+	// treating n as the exit.
+	order := make([]int, 0, n+1) // postorder
+	seen := make([]bool, n)
+	var visit func(b *BasicBlock)
+	visit = func(b *BasicBlock) {
+		seen[b.Index] = true
+		for _, pred := range b.Preds {
+			if !seen[pred.Index] {
+				visit(pred)
+			}
+		}
+		order = append(order, b.Index)
+	}
+	exits := make([]bool, n) // blocks with a (virtual) edge to the exit
+	for _, b := range f.Blocks {
+		if len(b.Succs) == 0 {
+			exits[b.Index] = true
+			visit(b)
+		}
+	}
+	// Connect each infinite loop to the exit, preferring the
+	// highest-numbered block, which is usually the loop's tail.
+	for i := n - 1; i >= 0; i-- {
+		if !seen[i] {
+			b := f.Blocks[i]
+			exits[i] = true
+			t.loopExits = append(t.loopExits, b)
+			visit(b)
+		}
+	}
+	order = append(order, n)
+	po := make([]int, n+1) // po[i] is the postorder number of node i
+	for i, v := range order {
+		po[v] = i
+	}
+
+	// Compute immediate post-dominators using the algorithm of
+	// Cooper, Harvey & Kennedy. 2001. A simple, fast dominance
+	// algorithm.
+	ipdom := make([]int, n+1)
+	for i := range ipdom {
+		ipdom[i] = -1
+	}
+	ipdom[n] = n
+	intersect := func(x, y int) int {
+		for x != y {
+			for po[x] < po[y] {
+				x = ipdom[x]
+			}
+			for po[y] < po[x] {
+				y = ipdom[y]
+			}
+		}
+		return x
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(order) - 2; i >= 0; i-- { // reverse postorder, skipping exit
+			v := order[i]
+			newIpdom := -1
+			if exits[v] {
+				newIpdom = n
+			}
+			for _, succ := range f.Blocks[v].Succs {
+				s := succ.Index
+				switch {
+				case ipdom[s] < 0:
+					// not yet processed
+				case newIpdom < 0:
+					newIpdom = s
+				default:
+					newIpdom = intersect(s, newIpdom)
+				}
+			}
+			if ipdom[v] != newIpdom {
+				ipdom[v] = newIpdom
+				changed = true
+			}
+		}
+	}
+
+	// Build the tree.
+	for i, b := range f.Blocks {
+		if p := ipdom[i]; p == n {
+			t.roots = append(t.roots, b)
+		} else {
+			t.info[i].idom = f.Blocks[p]
+			t.info[p].children = append(t.info[p].children, b)
+		}
+	}
+	var pre, post int32
+	for _, root := range t.roots {
+		pre, post = t.number(root, pre, post)
+	}
+
+	if f.Prog.mode&SanityCheckFunctions != 0 {
+		sanityCheckPostDomTree(t)
+	}
+	return t
+}
+
+// number sets the pre- and post-order numbers of a depth-first
+// traversal of the post-dominator tree rooted at b.
+//
+func (t *PostDomTree) number(b *BasicBlock, pre, post int32) (int32, int32) {
+	info := &t.info[b.Index]
+	info.pre = pre
+	pre++
+	for _, child := range info.children {
+		pre, post = t.number(child, pre, post)
+	}
+	info.post = post
+	post++
+	return pre, post
+}
+
+// Ipdom returns the block that immediately post-dominates b: its
+// parent in the post-dominator tree, or nil if that is the exit node.
+//
+func (t *PostDomTree) Ipdom(b *BasicBlock) *BasicBlock { return t.info[b.Index].idom }
+
+// PostDominees returns the list of blocks that b immediately
+// post-dominates: its children in the post-dominator tree.  If b is
+// nil, it returns the blocks immediately post-dominated by the exit.
+//
+func (t *PostDomTree) PostDominees(b *BasicBlock) []*BasicBlock {
+	if b == nil {
+		return t.roots
+	}
+	return t.info[b.Index].children
+}
+
+// PostDominates reports whether b post-dominates c, i.e. every path
+// from c to the exit passes through b.  Every block post-dominates
+// itself.
+//
+func (t *PostDomTree) PostDominates(b, c *BasicBlock) bool {
+	x, y := &t.info[b.Index], &t.info[c.Index]
+	return x.pre <= y.pre && y.post <= x.post
+}
+
+// LoopExits returns the blocks of infinite loops that were given a
+// virtual edge to the exit node.
+//
+func (t *PostDomTree) LoopExits() []*BasicBlock { return t.loopExits }
+
+// String returns the post-dominator tree as text, using indentation.
+func (t *PostDomTree) String() string {
+	var buf bytes.Buffer
+	var printTree func(b *BasicBlock, indent int)
+	printTree = func(b *BasicBlock, indent int) {
+		fmt.Fprintf(&buf, "%*s%s\n", 4*indent, "", b)
+		for _, child := range t.info[b.Index].children {
+			printTree(child, indent+1)
+		}
+	}
+	buf.WriteString("exit\n")
+	for _, root := range t.roots {
+		printTree(root, 1)
+	}
+	return buf.String()
+}
+
+// DomFrontier returns the dominance frontier of each block of f,
+// indexed by Block.Index: the set of blocks c such that b dominates a
+// predecessor of c but does not strictly dominate c.  Each set is
+// sorted by Block.Index.
+//
+func (f *Function) DomFrontier() [][]*BasicBlock {
+	df := buildDomFrontier(f)
+	for i, blocks := range df {
+		df[i] = sortedBlockSet(blocks)
+	}
+	return df
+}
+
+// A ControlDeps is the control dependence graph of a function.
+//
+// Block b is control dependent on block a if a ends in a branch one
+// of whose edges leads inevitably to b and another of which may avoid
+// it; that is, whether b executes is decided by a.  Equivalently, a
+// belongs to the dominance frontier of b in the reverse CFG.
+//
+type ControlDeps struct {
+	deps       [][]*BasicBlock // deps[b.Index] are the blocks on which b depends
+	dependents [][]*BasicBlock // dependents[a.Index] are the blocks that depend on a
+}
+
+// ControlDeps computes the control dependence graph of f, using the
+// algorithm of Ferrante, Ottenstein & Warren. 1987. The program
+// dependence graph and its use in optimization.
+//
+func (f *Function) ControlDeps() *ControlDeps {
+	return f.PostDomTree().ControlDeps()
+}
+
+// ControlDeps computes the control dependence graph of t's function.
+func (t *PostDomTree) ControlDeps() *ControlDeps {
+	n := len(t.fn.Blocks)
+	cd := &ControlDeps{
+		deps:       make([][]*BasicBlock, n),
+		dependents: make([][]*BasicBlock, n),
+	}
+	for _, a := range t.fn.Blocks {
+		if len(a.Succs) < 2 {
+			continue // only branches decide anything
+		}
+		stop := t.Ipdom(a)
+		for _, b := range a.Succs {
+			// Every block on the post-dominator tree path from
+			// b up to (but excluding) ipdom(a) depends on a.
+			for c := b; c != stop; c = t.Ipdom(c) {
+				cd.deps[c.Index] = append(cd.deps[c.Index], a)
+				cd.dependents[a.Index] = append(cd.dependents[a.Index], c)
+			}
+		}
+	}
+	for i := range cd.deps {
+		cd.deps[i] = sortedBlockSet(cd.deps[i])
+		cd.dependents[i] = sortedBlockSet(cd.dependents[i])
+	}
+	return cd
+}
+
+// Deps returns the blocks on which b is control dependent, sorted by
+// Block.Index.  It is empty if b executes whenever the function is
+// called (or panics first).
+//
+func (cd *ControlDeps) Deps(b *BasicBlock) []*BasicBlock { return cd.deps[b.Index] }
+
+// Dependents returns the blocks that are control dependent on b,
+// sorted by Block.Index.  It is empty unless b ends in an If.
+//
+func (cd *ControlDeps) Dependents(b *BasicBlock) []*BasicBlock { return cd.dependents[b.Index] }
+
+type byIndex []*BasicBlock
+
+func (a byIndex) Len() int           { return len(a) }
+func (a byIndex) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byIndex) Less(i, j int) bool { return a[i].Index < a[j].Index }
+
+// sortedBlockSet sorts blocks by Index and removes duplicates,
+// in place.
+func sortedBlockSet(blocks []*BasicBlock) []*BasicBlock {
+	sort.Sort(byIndex(blocks))
+	j := 0
+	for i, b := range blocks {
+		if i == 0 || b != blocks[j-1] {
+			blocks[j] = b
+			j++
+		}
+	}
+	return blocks[:j]
+}
+
+// sanityCheckPostDomTree checks the correctness of the post-dominator
+// tree by comparing against the relation computed by a naive backward
+// dataflow analysis, as sanityCheckDomTree does for dominance.
+//
+func sanityCheckPostDomTree(t *PostDomTree) {
+	f := t.fn
+	n := len(f.Blocks)
+
+	// PD[i] is the set of blocks that post-dominate f.Blocks[i],
+	// represented as a bit-set of block indices.
+	PD := make([]big.Int, n)
+
+	one := big.NewInt(1)
+
+	// all is the set of all blocks; constant.
+	var all big.Int
+	all.Set(one).Lsh(&all, uint(n)).Sub(&all, one)
+
+	exits := make([]bool, n)
+	for _, b := range f.Blocks {
+		exits[b.Index] = len(b.Succs) == 0
+	}
+	for _, b := range t.loopExits {
+		exits[b.Index] = true
+	}
+
+	// Initialization.
+	for i := range f.Blocks {
+		if exits[i] {
+			// An exit block is post-dominated only by itself.
+			PD[i].SetBit(&PD[i], i, 1)
+		} else {
+			PD[i].Set(&all)
+		}
+	}
+
+	// Iteration until fixed point.
+	for changed := true; changed; {
+		changed = false
+		for i, b := range f.Blocks {
+			if exits[i] {
+				continue
+			}
+			// Compute intersection across successors.
+			var x big.Int
+			x.Set(&all)
+			for _, succ := range b.Succs {
+				x.And(&x, &PD[succ.Index])
+			}
+			x.SetBit(&x, i, 1) // a block always post-dominates itself.
+			if PD[i].Cmp(&x) != 0 {
+				PD[i].Set(&x)
+				changed = true
+			}
+		}
+	}
+
+	// Check the entire relation.  O(n^2).
+	ok := true
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			b, c := f.Blocks[i], f.Blocks[j]
+			actual := t.PostDominates(b, c)
+			expected := PD[j].Bit(i) == 1
+			if actual != expected {
+				fmt.Fprintf(os.Stderr, "postdominates(%s, %s)==%t, want %t\n", b, c, actual, expected)
+				ok = false
+			}
+		}
+	}
+	if !ok {
+		panic("sanityCheckPostDomTree failed for " + f.String())
+	}
+}
\ No newline at end of file
@@ -0,0 +1,136 @@
+// antha-tools/antha/ssa/postdom_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa_test
+
+import (
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+)
+
+func TestControlDeps(t *testing.T) {
+	test := `
+package main
+
+func valid(x int) bool { return x > 0 }
+
+func step(int) {}
+
+func f(x int) {
+	if valid(x) {
+		step(1)
+		if x > 10 {
+			step(2)
+		}
+	}
+	step(3)
+}
+
+func loop() {
+	step(4)
+	for {
+	}
+}
+
+func main() {}
+`
+
+	var conf loader.Config
+	f, err := conf.ParseFile("<input>", test)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.CreateFromFiles("main", f)
+
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	// stepBlock returns the block of fn that calls step(i).
+	stepBlock := func(fn *ssa.Function, i int64) *ssa.BasicBlock {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok {
+					if c, ok := call.Call.Args[0].(*ssa.Const); ok && c.Int64() == i {
+						return b
+					}
+				}
+			}
+		}
+		t.Fatalf("no call to step(%d) in %s", i, fn)
+		return nil
+	}
+
+	fn := mainPkg.Func("f")
+	entry := fn.Blocks[0]
+	b1, b2, b3 := stepBlock(fn, 1), stepBlock(fn, 2), stepBlock(fn, 3)
+
+	pdt := fn.PostDomTree()
+	if !pdt.PostDominates(b3, entry) {
+		t.Errorf("step(3) block does not post-dominate entry:\n%s", pdt)
+	}
+	if pdt.PostDominates(b1, entry) || pdt.PostDominates(b2, b1) {
+		t.Errorf("conditional step blocks post-dominate their guards:\n%s", pdt)
+	}
+
+	cd := fn.ControlDeps()
+	for _, test := range []struct {
+		b    *ssa.BasicBlock
+		want *ssa.BasicBlock // nil => no dependencies
+	}{
+		{entry, nil},
+		{b1, entry},
+		{b2, b1},
+		{b3, nil},
+	} {
+		deps := cd.Deps(test.b)
+		if test.want == nil && len(deps) != 0 || test.want != nil && (len(deps) != 1 || deps[0] != test.want) {
+			t.Errorf("Deps(%s) = %s, want %v", test.b, deps, test.want)
+		}
+	}
+
+	df := fn.DomFrontier()
+	found := false
+	for _, c := range df[b1.Index] {
+		if c == b3 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("DomFrontier[%s] = %s, want it to contain %s", b1, df[b1.Index], b3)
+	}
+
+	// An infinite loop is given a virtual exit.
+	if exits := mainPkg.Func("loop").PostDomTree().LoopExits(); len(exits) != 1 {
+		t.Errorf("loop has %d loop exits, want 1", len(exits))
+	}
+}
\ No newline at end of file
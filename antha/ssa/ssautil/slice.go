@@ -0,0 +1,361 @@
+// antha-tools/antha/ssa/ssautil/slice.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssautil
+
+// This file defines backward program slicing.
+//
+// TODO: the slice is context-insensitive: a parameter depends on the
+// corresponding argument at every call site of its function, not
+// just the one through which the criterion was reached.  Writes by
+// built-ins such as copy and append, and by reflection, are not
+// modelled.
+
+import (
+	"github.com/antha-lang/antha-tools/antha/callgraph"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha/token"
+)
+
+// BackwardSlice returns the set of instructions that may affect the
+// value v, which must be a function-local value (or parameter or free
+// variable) of a function in the call graph cg, or a global.  If
+// isAddr is set, v is the address of a variable, and the slice is
+// that of the variable's contents, not just of its address.
+//
+// The slice follows these dependences, transitively:
+//
+//   - data: an instruction depends on the definitions of its operands;
+//     a parameter on the corresponding argument of each call site,
+//     and a free variable on the corresponding binding of each
+//     MakeClosure;
+//   - control: an instruction depends on each branch that decides
+//     whether it executes, and a φ-node on each branch that decides
+//     which edge it takes; every instruction of a function depends
+//     on the function's call sites;
+//   - memory: a load, map lookup or range, or channel receive
+//     depends on every store, map update or send whose address, map
+//     or channel may alias its own;
+//   - calls: the result of a call depends on the returns of each
+//     callee that has SSA code; a call to any other function depends
+//     on all its arguments.
+//
+// mayAlias reports whether two pointer-like values may alias, e.g.
+// using the pointer analysis.  If it is nil, values are assumed to
+// alias whenever their types are identical.  That default is sound
+// but very imprecise: a load through a *T depends on every store
+// through any *T in the program, so callers should supply the
+// results of the pointer analysis where they are available.
+func BackwardSlice(cg *callgraph.Graph, mayAlias func(x, y ssa.Value) bool, v ssa.Value, isAddr bool) map[ssa.Instruction]bool {
+	if mayAlias == nil {
+		mayAlias = func(x, y ssa.Value) bool {
+			return types.Identical(x.Type().Underlying(), y.Type().Underlying())
+		}
+	}
+	s := &slicer{
+		cg:       cg,
+		mayAlias: mayAlias,
+		slice:    make(map[ssa.Instruction]bool),
+		explored: make(map[ssa.Instruction]bool),
+		entered:  make(map[*ssa.Function]bool),
+		cdg:      make(map[*ssa.Function]*ssa.ControlDeps),
+		closures: make(map[*ssa.Function][]*ssa.MakeClosure),
+	}
+	for fn := range cg.Nodes {
+		if fn == nil {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Store, *ssa.MapUpdate, *ssa.Send, *ssa.Select:
+					s.writers = append(s.writers, instr)
+				case *ssa.MakeClosure:
+					fn := instr.Fn.(*ssa.Function)
+					s.closures[fn] = append(s.closures[fn], instr)
+				}
+			}
+		}
+	}
+	s.value(v)
+	if isAddr {
+		s.memory(v, storeAddr)
+	}
+	for len(s.queue) > 0 {
+		instr := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.explore(instr)
+	}
+	return s.slice
+}
+
+// slicer holds the state of a BackwardSlice computation.
+type slicer struct {
+	cg       *callgraph.Graph
+	mayAlias func(x, y ssa.Value) bool
+	slice    map[ssa.Instruction]bool             // instructions in the slice
+	explored map[ssa.Instruction]bool             // instructions whose dependences are in the slice
+	queue    []ssa.Instruction                    // instructions in the slice not yet explored
+	entered  map[*ssa.Function]bool               // functions whose call sites are in the slice
+	cdg      map[*ssa.Function]*ssa.ControlDeps   // control dependence graphs, built lazily
+	writers  []ssa.Instruction                    // all Stores, MapUpdates, Sends and Selects
+	closures map[*ssa.Function][]*ssa.MakeClosure // MakeClosures of each function
+}
+
+// add adds instr to the slice and schedules it to be explored.
+func (s *slicer) add(instr ssa.Instruction) {
+	if !s.explored[instr] {
+		s.explored[instr] = true
+		s.slice[instr] = true
+		s.queue = append(s.queue, instr)
+	}
+}
+
+// include adds instr to the slice without following its data
+// dependences, only its control dependences.  It is used for call
+// sites and MakeClosures, only one of whose operands is relevant.
+func (s *slicer) include(instr ssa.Instruction) {
+	if !s.slice[instr] {
+		s.slice[instr] = true
+		s.control(instr)
+	}
+}
+
+// value adds the definition of v to the slice.
+func (s *slicer) value(v ssa.Value) {
+	switch v := v.(type) {
+	case ssa.Instruction:
+		s.add(v)
+
+	case *ssa.Parameter:
+		fn := v.Parent()
+		index := -1
+		for i, p := range fn.Params {
+			if p == v {
+				index = i
+			}
+		}
+		for _, site := range s.sites(fn) {
+			common := site.Common()
+			var arg ssa.Value
+			switch {
+			case common.IsInvoke() && index == 0:
+				arg = common.Value
+			case common.IsInvoke():
+				if index-1 < len(common.Args) {
+					arg = common.Args[index-1]
+				}
+			case index < len(common.Args):
+				arg = common.Args[index]
+			}
+			s.include(site)
+			if arg != nil {
+				s.value(arg)
+			}
+		}
+
+	case *ssa.Capture:
+		fn := v.Parent()
+		for i, fv := range fn.FreeVars {
+			if fv == v {
+				for _, mc := range s.closures[fn] {
+					s.include(mc)
+					s.value(mc.Bindings[i])
+				}
+			}
+		}
+	}
+	// Consts, Globals, Functions and Builtins have no dependences.
+	// (Globals are addresses; their contents are memory.)
+}
+
+// sites returns the call sites of fn in the call graph.
+func (s *slicer) sites(fn *ssa.Function) []ssa.CallInstruction {
+	var sites []ssa.CallInstruction
+	if node := s.cg.Nodes[fn]; node != nil {
+		for _, e := range node.In {
+			if e.Site != nil {
+				sites = append(sites, e.Site)
+			}
+		}
+	}
+	return sites
+}
+
+// control adds to the slice the branches that decide whether instr
+// executes, including the call sites of its function.
+func (s *slicer) control(instr ssa.Instruction) {
+	b := instr.Block()
+	if b == nil {
+		return // e.g. an instruction of a deleted block
+	}
+	s.controlBlock(b)
+}
+
+func (s *slicer) controlBlock(b *ssa.BasicBlock) {
+	fn := b.Parent()
+	cd := s.cdg[fn]
+	if cd == nil {
+		cd = fn.ControlDeps()
+		s.cdg[fn] = cd
+	}
+	for _, a := range cd.Deps(b) {
+		s.add(a.Instrs[len(a.Instrs)-1])
+	}
+	if !s.entered[fn] {
+		s.entered[fn] = true
+		for _, site := range s.sites(fn) {
+			s.include(site)
+		}
+	}
+}
+
+// explore adds the dependences of instr, already in the slice.
+func (s *slicer) explore(instr ssa.Instruction) {
+	s.control(instr)
+
+	switch instr := instr.(type) {
+	case *ssa.Phi:
+		// A φ-node depends on the branches that select its edge.
+		for _, pred := range instr.Block().Preds {
+			if last := pred.Instrs[len(pred.Instrs)-1]; len(pred.Succs) > 1 {
+				s.add(last)
+			}
+			s.controlBlock(pred)
+		}
+
+	case *ssa.Call:
+		if s.calls(instr) {
+			return // arguments flow via the callees' parameters
+		}
+
+	case *ssa.UnOp:
+		switch instr.Op {
+		case token.MUL:
+			s.memory(instr.X, storeAddr)
+		case token.ARROW:
+			s.receive(instr.X)
+		}
+
+	case *ssa.Lookup:
+		if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+			s.mapUpdates(instr.X)
+		}
+
+	case *ssa.Range:
+		if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+			s.mapUpdates(instr.X)
+		}
+
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == types.RecvOnly {
+				s.receive(st.Chan)
+			}
+		}
+	}
+
+	var rands [10]*ssa.Value // reuse storage
+	for _, rand := range instr.Operands(rands[:0]) {
+		if *rand != nil {
+			s.value(*rand)
+		}
+	}
+}
+
+// calls adds the returns of the callees of call to the slice.
+// It reports whether all the callees have SSA code, so that
+// the call's arguments need not be added.
+func (s *slicer) calls(call *ssa.Call) bool {
+	node := s.cg.Nodes[call.Parent()]
+	if node == nil {
+		return false
+	}
+	found := false
+	for _, e := range node.Out {
+		if e.Site != ssa.CallInstruction(call) {
+			continue
+		}
+		fn := e.Callee.Func
+		if fn.Blocks == nil {
+			return false // external or intrinsic
+		}
+		found = true
+		for _, b := range fn.Blocks {
+			if ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+				s.add(ret)
+			}
+		}
+	}
+	if found && call.Call.StaticCallee() == nil {
+		s.value(call.Call.Value) // a dynamic call depends on its callee
+	}
+	return found
+}
+
+// memory adds to the slice each writer w for which addr(w) is non-nil
+// and may alias x.
+func (s *slicer) memory(x ssa.Value, addr func(w ssa.Instruction) ssa.Value) {
+	for _, w := range s.writers {
+		if y := addr(w); y != nil && s.mayAlias(x, y) {
+			s.add(w)
+		}
+	}
+}
+
+// storeAddr returns the address written by w, if it is a Store.
+func storeAddr(w ssa.Instruction) ssa.Value {
+	if store, ok := w.(*ssa.Store); ok {
+		return store.Addr
+	}
+	return nil
+}
+
+// mapUpdates adds to the slice the updates of maps that may alias m.
+func (s *slicer) mapUpdates(m ssa.Value) {
+	s.memory(m, func(w ssa.Instruction) ssa.Value {
+		if update, ok := w.(*ssa.MapUpdate); ok {
+			return update.Map
+		}
+		return nil
+	})
+}
+
+// receive adds to the slice the sends on channels that may alias ch.
+func (s *slicer) receive(ch ssa.Value) {
+	for _, w := range s.writers {
+		switch w := w.(type) {
+		case *ssa.Send:
+			if s.mayAlias(ch, w.Chan) {
+				s.add(w)
+			}
+		case *ssa.Select:
+			for _, st := range w.States {
+				if st.Dir == types.SendOnly && s.mayAlias(ch, st.Chan) {
+					s.add(w)
+				}
+			}
+		}
+	}
+}
\ No newline at end of file
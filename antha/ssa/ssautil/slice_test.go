@@ -0,0 +1,111 @@
+// antha-tools/antha/ssa/ssautil/slice_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssautil_test
+
+import (
+	"github.com/antha-lang/antha/parser"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/callgraph"
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+)
+
+// staticCallGraph returns the call graph of the static calls in prog.
+func staticCallGraph(prog *ssa.Program) *callgraph.Graph {
+	cg := callgraph.New(nil)
+	for fn := range ssautil.AllFunctions(prog) {
+		caller := cg.CreateNode(fn)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if site, ok := instr.(ssa.CallInstruction); ok {
+					if callee := site.Common().StaticCallee(); callee != nil {
+						callgraph.AddEdge(caller, site, cg.CreateNode(callee))
+					}
+				}
+			}
+		}
+	}
+	return cg
+}
+
+func TestBackwardSlice(t *testing.T) {
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("testdata/slice.go", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, 0)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	var want []int
+	for _, c := range f.Comments {
+		if strings.TrimSpace(c.Text()) == "in slice" {
+			want = append(want, iprog.Fset.Position(c.Pos()).Line)
+		}
+	}
+
+	v := mainPkg.Func("report").Params[0]
+	slice := ssautil.BackwardSlice(staticCallGraph(prog), nil, v, false)
+	entry := mainPkg.Func("measure").Blocks[0]
+	if branch := entry.Instrs[len(entry.Instrs)-1]; !slice[branch] {
+		t.Errorf("slice does not contain branch %s", branch)
+	}
+
+	lines := make(map[int]bool)
+	for instr := range slice {
+		if pos := instr.Pos(); pos.IsValid() {
+			lines[iprog.Fset.Position(pos).Line] = true
+		}
+	}
+	var got []int
+	for line := range lines {
+		got = append(got, line)
+	}
+	sort.Ints(got)
+
+	if len(got) != len(want) {
+		t.Errorf("slice contains lines %v, want %v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("slice contains lines %v, want %v", got, want)
+			return
+		}
+	}
+}
\ No newline at end of file
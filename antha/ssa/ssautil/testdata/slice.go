@@ -0,0 +1,57 @@
+// antha-tools/antha/ssa/ssautil/testdata/slice.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+// +build ignore
+
+package main
+
+// This file is the input to TestBackwardSlice in slice_test.go.
+// The slice of the parameter of report must include exactly the
+// lines commented "in slice", and the branch on valid (whose If
+// instruction has no position).
+
+var log []string
+
+func scale(x, k int) int {
+	return x * k // in slice
+}
+
+func record(s string) {
+	log = append(log, s)
+}
+
+func measure(volume int, valid bool) int {
+	total := 0 // in slice
+	if valid {
+		total = scale(volume, 2) // in slice
+	}
+	record("measured")
+	return total // in slice
+}
+
+func report(v int) {}
+
+func main() {
+	v := measure(10, true) // in slice
+	report(v)              // in slice
+	record("done")
+}
\ No newline at end of file
@@ -23,7 +23,10 @@
 
 package ssautil
 
-import "github.com/antha-lang/antha-tools/antha/ssa"
+import (
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
 
 // This file defines utilities for visiting the SSA representation of
 // a Program.
@@ -82,4 +85,39 @@ func (visit *visitor) function(fn *ssa.Function) {
 			}
 		}
 	}
+}
+
+// PackageFunctions returns the functions of pkg that have bodies: its
+// package-level functions, the methods of its named types other than
+// synthetic wrappers, and the anonymous functions within them.
+//
+// Precondition: pkg is built.
+//
+func PackageFunctions(pkg *ssa.Package) []*ssa.Function {
+	var fns []*ssa.Function
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn.Blocks != nil {
+			fns = append(fns, fn)
+		}
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+	for _, mem := range pkg.Members {
+		switch mem := mem.(type) {
+		case *ssa.Function:
+			add(mem)
+		case *ssa.Type:
+			for _, T := range []types.Type{mem.Type(), types.NewPointer(mem.Type())} {
+				mset := pkg.Prog.MethodSets.MethodSet(T)
+				for i, n := 0, mset.Len(); i < n; i++ {
+					if fn := pkg.Prog.Method(mset.At(i)); fn.Pkg == pkg && fn.Synthetic == "" {
+						add(fn)
+					}
+				}
+			}
+		}
+	}
+	return fns
 }
\ No newline at end of file
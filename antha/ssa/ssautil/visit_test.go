@@ -0,0 +1,75 @@
+// antha-tools/antha/ssa/ssautil/visit_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssautil_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+)
+
+const packageInput = `package p
+
+type T struct{ U }
+
+type U struct{}
+
+func (T) M()  {}
+func (*U) N() {}
+
+func F() func() {
+	return func() {}
+}
+`
+
+func TestPackageFunctions(t *testing.T) {
+	conf := loader.Config{}
+	f, err := conf.ParseFile("p.go", packageInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("p", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssa.Create(iprog, 0)
+	pkg := prog.Package(iprog.Created[0].Pkg)
+	pkg.Build()
+
+	var got []string
+	for _, fn := range ssautil.PackageFunctions(pkg) {
+		got = append(got, fn.String())
+	}
+	sort.Strings(got)
+	// The wrappers (*T).M and (*T).N are synthetic, and so excluded.
+	want := "(*p.U).N (p.T).M F$1 p.F p.init"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
\ No newline at end of file
@@ -24,7 +24,8 @@
 package ssa_test
 
 // This file runs the SSA builder in sanity-checking mode on all
-// packages beneath $GOROOT and prints some summary information.
+// packages beneath $GOROOT and prints some summary information,
+// including which packages the SSA interpreter can execute.
 //
 // Run test with GOMAXPROCS=8.
 
@@ -33,13 +34,16 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/antha-lang/antha-tools/antha/loader"
 	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/interp"
 	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+	"github.com/antha-lang/antha-tools/antha/types"
 )
 
 func allPackages() []string {
@@ -142,4 +146,61 @@ func TestStdlib(t *testing.T) {
 	t.Log("#Functions:           ", len(allFuncs))
 	t.Log("#Instructions:        ", numInstrs)
 	t.Log("#MB:                  ", int64(memstats.Alloc-alloc)/1000000)
+
+	reportExecutable(t, prog, allFuncs)
+}
+
+// reportExecutable logs which packages of prog the SSA interpreter
+// can execute, i.e. those whose functions, and those of the packages
+// they import, all have SSA code or an emulated implementation.  For
+// the others, it logs the functions the interpreter lacks.
+func reportExecutable(t *testing.T, prog *ssa.Program, allFuncs map[*ssa.Function]bool) {
+	missing := make(map[*types.Package][]string)
+	for fn := range allFuncs {
+		if fn.Pkg != nil && fn.Enclosing == nil && fn.Synthetic == "" && !interp.CanInterpret(fn) {
+			pkg := fn.Pkg.Object
+			missing[pkg] = append(missing[pkg], fn.String())
+		}
+	}
+
+	// A package is executable if it and all its imports are.
+	executable := make(map[*types.Package]bool)
+	var visit func(pkg *types.Package) bool
+	visit = func(pkg *types.Package) bool {
+		if ok, seen := executable[pkg]; seen {
+			return ok
+		}
+		executable[pkg] = true // break cycles (unsafe, runtime)
+		ok := len(missing[pkg]) == 0
+		for _, imp := range pkg.Imports() {
+			if !visit(imp) {
+				ok = false
+			}
+		}
+		executable[pkg] = ok
+		return ok
+	}
+
+	var yes, no []string
+	for _, pkg := range prog.AllPackages() {
+		if visit(pkg.Object) {
+			yes = append(yes, pkg.Object.Path())
+		} else {
+			no = append(no, pkg.Object.Path())
+		}
+	}
+	sort.Strings(yes)
+	sort.Strings(no)
+
+	t.Log("#Executable packages: ", len(yes), "of", len(yes)+len(no))
+	t.Log("Executable:           ", strings.Join(yes, " "))
+	for _, path := range no {
+		fns := missing[prog.ImportedPackage(path).Object]
+		sort.Strings(fns)
+		if len(fns) == 0 {
+			t.Logf("Not executable:        %s (imports a non-executable package)", path)
+		} else {
+			t.Logf("Not executable:        %s (lacks %s)", path, strings.Join(fns, ", "))
+		}
+	}
 }
\ No newline at end of file
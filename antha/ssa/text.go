@@ -0,0 +1,677 @@
+// antha-tools/antha/ssa/text.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa
+
+// This file defines the textual form of SSA functions, written by
+// WriteText and read by ParseText (see textparse.go).
+//
+// Unlike the disassembly printed by WriteFunction, which is meant
+// for people, the textual form records everything needed to rebuild
+// a function, so that SSA-level test inputs can be written by hand
+// and analyses tested without going through the parser and the type
+// checker.  Its lexical structure is that of Go, except that names
+// may contain '$', as in "main$1".  It is line-oriented:
+//
+//	package main
+//
+//	import "fmt"
+//	import errs "errors"
+//
+//	func f(x int, p *T) (int, error)
+//	0: "entry"
+//		t0 bool = x < 0:int
+//		if t0 goto 1 else 2
+//	1: "if.then" <- 0
+//		t1 error = errs.New("negative":string)
+//		return 0:int, t1
+//	2: "if.done" <- 0
+//		t2 *int = &p.n
+//		*t2 = x
+//		return x, nil:error
+//
+// The package clause must name the package passed to ParseText, and
+// each package other than that one whose types, functions or
+// variables are mentioned must be imported.  Types are written in Go
+// syntax; constants as value:type, where a floating-point value may
+// be a fraction, as in 1/3:float64, and a complex one is written
+// complex(re, im); and functions as in the Name of a Function, except
+// that methods are written (T).f in all cases and bound method
+// wrappers as bound$(T).f.
+//
+// A function definition begins with a signature in declaration
+// syntax, whose parameter names become those of the Parameters,
+// followed by any of these attributes, each on a line of its own:
+//
+//	enclosing f        the enclosing function of an anonymous function
+//	free x *int        a free variable; one line per variable, in order
+//	recover 3          the index of the Recover block
+//	synthetic "..."    the provenance of a synthetic function
+//
+// Then come the blocks, if the function is not external.  Each is
+// introduced by a line holding its index, an optional comment, and
+// the indices of its predecessors in order after "<-".  Each
+// instruction occupies an indented line; those that define a value
+// begin with its name, which must be of the form t<n>, and type.  The
+// forms of instructions follow their String methods, except for:
+//
+//	t0 *T = local "x"                 Alloc
+//	t1 *T = new "complit"             Alloc with Heap set
+//	t2 int = phi [0: t0, 1: t1] "x"   Phi, with optional comment
+//	t3 I = change interface t2        ChangeInterface
+//	t4 I = make interface t3          MakeInterface
+//	t5 func() = make closure f$1 [t4] MakeClosure
+//	t6 map[int]int = make map t5      MakeMap; the reservation is optional
+//	t7 chan int = make chan t6        MakeChan
+//	t8 []int = make slice t6 t6       MakeSlice
+//	t9 int = extract t8 1             Extract
+//	t10 *int = &t9.name               FieldAddr; Field omits the &
+//	t11 int = changetype t10          ChangeType
+//	t12 float64 = convert t11         Convert
+//	jump 1                            Jump
+//
+// Index and Lookup share the form x[i], told apart by the type of x.
+// A map update is written m[k] = v, with no space before the bracket.
+// DebugRef instructions have no textual form and are omitted.
+// Comments run from "//" to the end of the line.
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// WriteText writes to buf the textual form of the functions fns,
+// each followed by the anonymous functions within it, for reading by
+// ParseText.  The functions must belong to the same package; wrappers
+// created by the SSA builder on demand belong to none.
+//
+func WriteText(buf *bytes.Buffer, fns ...*Function) error {
+	if len(fns) == 0 {
+		return fmt.Errorf("no functions")
+	}
+	pkg := fns[0].Pkg
+	if pkg == nil {
+		return fmt.Errorf("%s does not belong to a package", fns[0])
+	}
+	w := &textWriter{
+		pkg:     pkg.Object,
+		imports: make(map[*types.Package]string),
+		taken:   make(map[string]bool),
+	}
+	var all []*Function
+	var addAnon func(fn *Function)
+	addAnon = func(fn *Function) {
+		all = append(all, fn)
+		for _, anon := range fn.AnonFuncs {
+			addAnon(anon)
+		}
+	}
+	for _, fn := range fns {
+		if fn.Pkg != pkg {
+			return fmt.Errorf("%s does not belong to %s", fn, pkg)
+		}
+		addAnon(fn)
+	}
+
+	// Import names must not be mistaken for local names.
+	for _, fn := range all {
+		for _, p := range fn.Params {
+			w.taken[p.Name()] = true
+		}
+		for _, fv := range fn.FreeVars {
+			w.taken[fv.Name()] = true
+		}
+	}
+
+	for _, fn := range all {
+		w.function(fn)
+	}
+
+	fmt.Fprintf(buf, "package %s\n\n", pkg.Object.Name())
+	var paths []string
+	names := make(map[string]string)
+	for p, name := range w.imports {
+		paths = append(paths, p.Path())
+		names[p.Path()] = name
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if name := names[path]; name != defaultImportName(path) {
+			fmt.Fprintf(buf, "import %s %q\n", name, path)
+		} else {
+			fmt.Fprintf(buf, "import %q\n", path)
+		}
+	}
+	if len(paths) > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.Write(w.buf.Bytes())
+	return nil
+}
+
+// defaultImportName returns the name by which ParseText knows an
+// imported package if the import does not name it.
+func defaultImportName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+type textWriter struct {
+	buf     bytes.Buffer              // the function definitions
+	pkg     *types.Package            // the package being written
+	imports map[*types.Package]string // name of each imported package
+	taken   map[string]bool           // names unavailable for imports
+}
+
+// qualify returns the name by which the package-level object name
+// of package pkg is written.
+func (w *textWriter) qualify(pkg *types.Package, name string) string {
+	if pkg == nil || pkg == w.pkg {
+		return name
+	}
+	id, ok := w.imports[pkg]
+	if !ok {
+		id = defaultImportName(pkg.Path())
+		for i := 2; w.taken[id] || !isIdent(id); i++ {
+			id = fmt.Sprintf("%s%d", pkg.Name(), i)
+		}
+		w.imports[pkg] = id
+		w.taken[id] = true
+	}
+	return id + "." + name
+}
+
+// isIdent reports whether s is a Go identifier.
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (w *textWriter) function(fn *Function) {
+	buf := &w.buf
+	buf.WriteString("func ")
+	params := fn.Params
+	if fn.Signature.Recv() != nil {
+		fmt.Fprintf(buf, "(%s %s) ", params[0].Name(), w.typ(params[0].Type()))
+		params = params[1:]
+	}
+	buf.WriteString(fn.Name())
+	buf.WriteByte('(')
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(p.Name())
+		buf.WriteByte(' ')
+		if fn.Signature.Variadic() && i == len(params)-1 {
+			fmt.Fprintf(buf, "...%s", w.typ(p.Type().(*types.Slice).Elem()))
+		} else {
+			buf.WriteString(w.typ(p.Type()))
+		}
+	}
+	buf.WriteByte(')')
+	if res := fn.Signature.Results(); res.Len() > 0 {
+		buf.WriteByte(' ')
+		if res.Len() == 1 && res.At(0).Name() == "" {
+			buf.WriteString(w.typ(res.At(0).Type()))
+		} else {
+			buf.WriteString(w.typ(res))
+		}
+	}
+	buf.WriteByte('\n')
+
+	if fn.Enclosing != nil {
+		fmt.Fprintf(buf, "\tenclosing %s\n", fn.Enclosing.Name())
+	}
+	for _, fv := range fn.FreeVars {
+		fmt.Fprintf(buf, "\tfree %s %s\n", fv.Name(), w.typ(fv.Type()))
+	}
+	if fn.Recover != nil {
+		fmt.Fprintf(buf, "\trecover %d\n", fn.Recover.Index)
+	}
+	if fn.Synthetic != "" && !strings.HasPrefix(fn.Synthetic, "loaded from") {
+		fmt.Fprintf(buf, "\tsynthetic %q\n", fn.Synthetic)
+	}
+
+	for _, b := range fn.Blocks {
+		fmt.Fprintf(buf, "%d:", b.Index)
+		if b.Comment != "" {
+			fmt.Fprintf(buf, " %q", b.Comment)
+		}
+		for i, pred := range b.Preds {
+			if i == 0 {
+				buf.WriteString(" <- ")
+			} else {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(buf, "%d", pred.Index)
+		}
+		buf.WriteByte('\n')
+		for _, instr := range b.Instrs {
+			if _, ok := instr.(*DebugRef); ok {
+				continue
+			}
+			buf.WriteByte('\t')
+			if v, ok := instr.(Value); ok {
+				fmt.Fprintf(buf, "%s %s = ", v.Name(), w.typ(v.Type()))
+			}
+			buf.WriteString(w.instr(instr))
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+// instr returns the textual form of instr, less the name and type of
+// any value it defines.
+func (w *textWriter) instr(instr Instruction) string {
+	switch instr := instr.(type) {
+	case *Alloc:
+		op := "local"
+		if instr.Heap {
+			op = "new"
+		}
+		return fmt.Sprintf("%s %q", op, instr.Comment)
+
+	case *Phi:
+		var b bytes.Buffer
+		b.WriteString("phi [")
+		for i, edge := range instr.Edges {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%d: %s", instr.block.Preds[i].Index, w.value(edge))
+		}
+		b.WriteByte(']')
+		if instr.Comment != "" {
+			fmt.Fprintf(&b, " %q", instr.Comment)
+		}
+		return b.String()
+
+	case *Call:
+		return w.call(&instr.Call)
+	case *BinOp:
+		return fmt.Sprintf("%s %s %s", w.value(instr.X), instr.Op, w.value(instr.Y))
+	case *UnOp:
+		return fmt.Sprintf("%s%s%s", instr.Op, w.value(instr.X), commaOk(instr.CommaOk))
+	case *ChangeType:
+		return "changetype " + w.value(instr.X)
+	case *Convert:
+		return "convert " + w.value(instr.X)
+	case *ChangeInterface:
+		return "change interface " + w.value(instr.X)
+	case *MakeInterface:
+		return "make interface " + w.value(instr.X)
+
+	case *MakeClosure:
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "make closure %s [", w.value(instr.Fn))
+		for i, v := range instr.Bindings {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(w.value(v))
+		}
+		b.WriteByte(']')
+		return b.String()
+
+	case *MakeMap:
+		if instr.Reserve == nil {
+			return "make map"
+		}
+		return "make map " + w.value(instr.Reserve)
+	case *MakeChan:
+		return "make chan " + w.value(instr.Size)
+	case *MakeSlice:
+		return fmt.Sprintf("make slice %s %s", w.value(instr.Len), w.value(instr.Cap))
+
+	case *Slice:
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "slice %s[", w.value(instr.X))
+		if instr.Low != nil {
+			b.WriteString(w.value(instr.Low))
+		}
+		b.WriteByte(':')
+		if instr.High != nil {
+			b.WriteString(w.value(instr.High))
+		}
+		if instr.Max != nil {
+			b.WriteByte(':')
+			b.WriteString(w.value(instr.Max))
+		}
+		b.WriteByte(']')
+		return b.String()
+
+	case *FieldAddr:
+		st := deref(instr.X.Type()).Underlying().(*types.Struct)
+		return fmt.Sprintf("&%s.%s", w.value(instr.X), st.Field(instr.Field).Name())
+	case *Field:
+		st := instr.X.Type().Underlying().(*types.Struct)
+		return fmt.Sprintf("%s.%s", w.value(instr.X), st.Field(instr.Field).Name())
+	case *IndexAddr:
+		return fmt.Sprintf("&%s[%s]", w.value(instr.X), w.value(instr.Index))
+	case *Index:
+		return fmt.Sprintf("%s[%s]", w.value(instr.X), w.value(instr.Index))
+	case *Lookup:
+		return fmt.Sprintf("%s[%s]%s", w.value(instr.X), w.value(instr.Index), commaOk(instr.CommaOk))
+
+	case *Select:
+		var b bytes.Buffer
+		b.WriteString("select ")
+		if !instr.Blocking {
+			b.WriteString("non")
+		}
+		b.WriteString("blocking [")
+		for i, st := range instr.States {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if st.Dir == types.RecvOnly {
+				fmt.Fprintf(&b, "<-%s", w.value(st.Chan))
+			} else {
+				fmt.Fprintf(&b, "%s<-%s", w.value(st.Chan), w.value(st.Send))
+			}
+		}
+		b.WriteByte(']')
+		return b.String()
+
+	case *Range:
+		return "range " + w.value(instr.X)
+	case *Next:
+		return "next " + w.value(instr.Iter)
+	case *TypeAssert:
+		return fmt.Sprintf("typeassert%s %s.(%s)", commaOk(instr.CommaOk), w.value(instr.X), w.typ(instr.AssertedType))
+	case *Extract:
+		return fmt.Sprintf("extract %s %d", w.value(instr.Tuple), instr.Index)
+
+	case *Jump:
+		return fmt.Sprintf("jump %d", instr.block.Succs[0].Index)
+	case *If:
+		return fmt.Sprintf("if %s goto %d else %d", w.value(instr.Cond), instr.block.Succs[0].Index, instr.block.Succs[1].Index)
+	case *Return:
+		var b bytes.Buffer
+		b.WriteString("return")
+		for i, r := range instr.Results {
+			if i == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(", ")
+			}
+			b.WriteString(w.value(r))
+		}
+		return b.String()
+	case *RunDefers:
+		return "rundefers"
+	case *Panic:
+		return "panic " + w.value(instr.X)
+	case *Go:
+		return "go " + w.call(&instr.Call)
+	case *Defer:
+		return "defer " + w.call(&instr.Call)
+	case *Send:
+		return fmt.Sprintf("send %s <- %s", w.value(instr.Chan), w.value(instr.X))
+	case *Store:
+		return fmt.Sprintf("*%s = %s", w.value(instr.Addr), w.value(instr.Val))
+	case *MapUpdate:
+		return fmt.Sprintf("%s[%s] = %s", w.value(instr.Map), w.value(instr.Key), w.value(instr.Value))
+	}
+	panic(fmt.Sprintf("unexpected instruction: %T", instr))
+}
+
+func (w *textWriter) call(c *CallCommon) string {
+	var b bytes.Buffer
+	if c.IsInvoke() {
+		fmt.Fprintf(&b, "invoke %s.%s", w.value(c.Value), c.Method.Name())
+	} else {
+		b.WriteString(w.value(c.Value))
+	}
+	b.WriteByte('(')
+	for i, arg := range c.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(w.value(arg))
+	}
+	if c.Signature().Variadic() {
+		b.WriteString("...")
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// value returns the textual form of a reference to v.
+func (w *textWriter) value(v Value) string {
+	switch v := v.(type) {
+	case *Const:
+		return w.constant(v)
+	case *Global:
+		return w.qualify(v.Pkg.Object, v.Name())
+	case *Function:
+		return w.funcRef(v)
+	}
+	return v.Name()
+}
+
+func (w *textWriter) funcRef(fn *Function) string {
+	switch {
+	case fn.Enclosing != nil:
+		return fn.Name()
+	case fn.Synthetic != "" && strings.HasPrefix(fn.name, "bound$"):
+		// The name of the method follows the receiver type.
+		name := fn.name[strings.LastIndex(fn.name, ".")+1:]
+		return fmt.Sprintf("bound$(%s).%s", w.typ(fn.FreeVars[0].Type()), name)
+	case fn.Signature.Recv() != nil:
+		return fmt.Sprintf("(%s).%s", w.typ(fn.Signature.Recv().Type()), fn.Name())
+	}
+	return w.qualify(fn.pkgobj(), fn.Name())
+}
+
+func (w *textWriter) constant(c *Const) string {
+	var s string
+	switch {
+	case c.Value == nil:
+		s = "nil"
+	case c.Value.Kind() == exact.Complex:
+		s = fmt.Sprintf("complex(%s, %s)", realString(exact.Real(c.Value)), realString(exact.Imag(c.Value)))
+	case c.Value.Kind() == exact.Float:
+		s = realString(c.Value)
+	default:
+		s = c.Value.String() // bool, string or int
+	}
+	return s + ":" + w.typ(c.Type())
+}
+
+// realString returns the textual form of the integer or
+// floating-point constant x: its shortest decimal form if that is
+// exact, or else a fraction.
+func realString(x exact.Value) string {
+	if x.Kind() == exact.Int {
+		return x.String()
+	}
+	if f, ok := exact.Float64Val(x); ok {
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if exact.Compare(exact.MakeFromLiteral(s, token.FLOAT), token.EQL, x) {
+			return s
+		}
+	}
+	return exact.Num(x).String() + "/" + exact.Denom(x).String()
+}
+
+// typ returns the textual form of type t.  Unlike types.TypeString,
+// it refers to types of other packages by their import names.
+func (w *textWriter) typ(t types.Type) string {
+	var b bytes.Buffer
+	w.writeType(&b, t)
+	return b.String()
+}
+
+func (w *textWriter) writeType(b *bytes.Buffer, t types.Type) {
+	switch t := t.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.UnsafePointer:
+			b.WriteString(w.qualify(types.Unsafe, "Pointer"))
+		case types.Invalid:
+			b.WriteString("invalid")
+		default:
+			b.WriteString(t.Name())
+		}
+
+	case *types.Named:
+		obj := t.Obj()
+		b.WriteString(w.qualify(obj.Pkg(), obj.Name()))
+
+	case *types.Pointer:
+		b.WriteByte('*')
+		w.writeType(b, t.Elem())
+
+	case *types.Slice:
+		b.WriteString("[]")
+		w.writeType(b, t.Elem())
+
+	case *types.Array:
+		fmt.Fprintf(b, "[%d]", t.Len())
+		w.writeType(b, t.Elem())
+
+	case *types.Map:
+		b.WriteString("map[")
+		w.writeType(b, t.Key())
+		b.WriteByte(']')
+		w.writeType(b, t.Elem())
+
+	case *types.Chan:
+		switch t.Dir() {
+		case types.SendOnly:
+			b.WriteString("chan<- ")
+		case types.RecvOnly:
+			b.WriteString("<-chan ")
+		default:
+			b.WriteString("chan ")
+		}
+		// chan (<-chan T) requires parentheses.
+		elem, _ := t.Elem().(*types.Chan)
+		paren := t.Dir() == types.SendRecv && elem != nil && elem.Dir() == types.RecvOnly
+		if paren {
+			b.WriteByte('(')
+		}
+		w.writeType(b, t.Elem())
+		if paren {
+			b.WriteByte(')')
+		}
+
+	case *types.Struct:
+		b.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			if !f.Anonymous() {
+				b.WriteString(f.Name())
+				b.WriteByte(' ')
+			}
+			w.writeType(b, f.Type())
+			if tag := t.Tag(i); tag != "" {
+				fmt.Fprintf(b, " %q", tag)
+			}
+		}
+		b.WriteByte('}')
+
+	case *types.Signature:
+		b.WriteString("func")
+		w.writeSignature(b, t)
+
+	case *types.Interface:
+		b.WriteString("interface{")
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			m := t.ExplicitMethod(i)
+			b.WriteString(m.Name())
+			w.writeSignature(b, m.Type().(*types.Signature))
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if i > 0 || t.NumExplicitMethods() > 0 {
+				b.WriteString("; ")
+			}
+			w.writeType(b, t.Embedded(i))
+		}
+		b.WriteByte('}')
+
+	case *types.Tuple:
+		w.writeTuple(b, t, false)
+
+	case *opaqueType:
+		b.WriteString(t.name)
+
+	default:
+		panic(fmt.Sprintf("unexpected type: %T", t))
+	}
+}
+
+func (w *textWriter) writeTuple(b *bytes.Buffer, t *types.Tuple, variadic bool) {
+	b.WriteByte('(')
+	for i := 0; i < t.Len(); i++ {
+		v := t.At(i)
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if v.Name() != "" {
+			b.WriteString(v.Name())
+			b.WriteByte(' ')
+		}
+		if variadic && i == t.Len()-1 {
+			b.WriteString("...")
+			w.writeType(b, v.Type().(*types.Slice).Elem())
+		} else {
+			w.writeType(b, v.Type())
+		}
+	}
+	b.WriteByte(')')
+}
+
+func (w *textWriter) writeSignature(b *bytes.Buffer, sig *types.Signature) {
+	w.writeTuple(b, sig.Params(), sig.Variadic())
+	res := sig.Results()
+	switch {
+	case res.Len() == 0:
+		// no result
+	case res.Len() == 1 && res.At(0).Name() == "":
+		b.WriteByte(' ')
+		w.writeType(b, res.At(0).Type())
+	default:
+		b.WriteByte(' ')
+		w.writeTuple(b, res, false)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,256 @@
+// antha-tools/antha/ssa/text_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa_test
+
+import (
+	"bytes"
+	"github.com/antha-lang/antha/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+const textTest = `
+package main
+
+type T struct {
+	a int
+	b []string
+	*T
+}
+
+func (t *T) inc(n int) int { t.a += n; return t.a }
+
+func (t T) get() int { return t.a }
+
+type I interface {
+	get() int
+}
+
+var global = 1
+
+func closures(x int) func() int {
+	f := func() int {
+		x++
+		return func() int { return x }()
+	}
+	g := (*T).inc
+	h := new(T).inc
+	k := I.get
+	_, _, _ = g, h, k
+	global = 2
+	return f
+}
+
+func maps(m map[string]int, k string) (v int, ok bool) {
+	m[k] = len(k)
+	for k, v := range m {
+		println(k, v)
+	}
+	v, ok = m[k]
+	return
+}
+
+func chans(c chan int, d chan<- string, e <-chan chan int) int {
+	select {
+	case x := <-c:
+		return x
+	case d <- "hi":
+	case <-e:
+	default:
+	}
+	go func() { c <- 1 }()
+	defer close(c)
+	v, ok := <-c
+	if !ok {
+		return -v
+	}
+	return v ^ 1
+}
+
+func misc(i I, s []int, arr [3]int, str string, p *T, u interface{}) (r float64) {
+	defer func() {
+		recover()
+	}()
+	if t, ok := i.(*T); ok {
+		p = t
+	}
+	p.b = append(p.b, "x", "y")
+	s = append(s, 1, 2)
+	s = s[1:2:3]
+	str = str[1:]
+	for i, r := range str {
+		println(i, r)
+	}
+	_ = arr[1] + arr[len(s)]
+	x := float64(len(s)) / 3
+	c := complex(x, 1.5)
+	_ = c
+	var j interface{} = i
+	u = j
+	_ = u.(T)
+	s[0] = int(p.get())
+	if len(s) > 3 {
+		panic(str)
+	}
+	return 1.0 / 3
+}
+
+func main() {}
+`
+
+// functions returns the non-synthetic functions and methods of pkg,
+// and its initializer, in order of name.
+func functions(pkg *ssa.Package) []*ssa.Function {
+	var fns []*ssa.Function
+	for _, mem := range pkg.Members {
+		switch mem := mem.(type) {
+		case *ssa.Function:
+			fns = append(fns, mem)
+		case *ssa.Type:
+			mset := pkg.Prog.MethodSets.MethodSet(types.NewPointer(mem.Type()))
+			for i := 0; i < mset.Len(); i++ {
+				if fn := pkg.Prog.Method(mset.At(i)); fn.Synthetic == "" {
+					fns = append(fns, fn)
+				}
+			}
+		}
+	}
+	sort.Sort(byName(fns))
+	return fns
+}
+
+type byName []*ssa.Function
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].String() < s[j].String() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// TestTextRoundTrip checks that the textual form of functions built
+// from source, read back into a package with no syntax, yields the
+// same textual form.
+func TestTextRoundTrip(t *testing.T) {
+	var conf loader.Config
+	f, err := conf.ParseFile("<input>", textTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := ssa.Create(iprog, ssa.SanityCheckFunctions)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	var want bytes.Buffer
+	if err := ssa.WriteText(&want, functions(mainPkg)...); err != nil {
+		t.Fatal(err)
+	}
+
+	prog2 := ssa.NewProgram(iprog.Fset, ssa.SanityCheckFunctions)
+	pkg2 := prog2.CreatePackage(&loader.PackageInfo{Pkg: mainPkg.Object})
+	fns, err := ssa.ParseText(pkg2, "main.ssa", want.Bytes())
+	if err != nil {
+		t.Fatalf("%s\n%s", err, &want)
+	}
+	var outer []*ssa.Function
+	for _, fn := range fns {
+		if fn.Enclosing == nil {
+			outer = append(outer, fn)
+		}
+	}
+	var got bytes.Buffer
+	if err := ssa.WriteText(&got, outer...); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("round trip changed the text:\n--- got:\n%s\n--- want:\n%s", &got, &want)
+	}
+}
+
+// TestParseText checks a hand-written function against a package
+// created from scratch.
+func TestParseText(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/loop.ssa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := ssa.NewProgram(token.NewFileSet(), ssa.SanityCheckFunctions)
+	pkg := prog.CreatePackage(&loader.PackageInfo{Pkg: types.NewPackage("loop", "loop")})
+	fns, err := ssa.ParseText(pkg, "testdata/loop.ssa", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := pkg.Func("sum")
+	if sum == nil || len(fns) != 2 || fns[0] != sum {
+		t.Fatalf("ParseText returned %s, want sum first", fns)
+	}
+	if got := len(sum.Blocks); got != 4 {
+		t.Errorf("sum has %d blocks, want 4", got)
+	}
+	phi := sum.Blocks[1].Instrs[0].(*ssa.Phi)
+	if got := len(*phi.Referrers()); got != 3 {
+		t.Errorf("%s has %d referrers, want 3", phi.Name(), got)
+	}
+	if idom := sum.Blocks[3].Idom(); idom != sum.Blocks[1] {
+		t.Errorf("idom of %s is %s, want %s", sum.Blocks[3], idom, sum.Blocks[1])
+	}
+
+	// The fixture is in the canonical form.
+	var buf bytes.Buffer
+	if err := ssa.WriteText(&buf, fns...); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(src) {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, src)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"package main\n", "package main is not package loop"},
+		{"package loop\nfunc f()\n0:\n\tjump 1\n", "no block 1"},
+		{"package loop\nfunc f()\n0:\n\tt0 int = g()\n\treturn\n", "undefined: g"},
+		{"package loop\nfunc f() int\n0:\n\treturn 1:undefined\n", "undefined type: undefined"},
+		{"package loop\nfunc f()\n0:\n1: <- 0\n\treturn\n", "predecessors [0], but the terminators give []"},
+	} {
+		prog := ssa.NewProgram(token.NewFileSet(), 0)
+		pkg := prog.CreatePackage(&loader.PackageInfo{Pkg: types.NewPackage("loop", "loop")})
+		_, err := ssa.ParseText(pkg, "x.ssa", []byte(test.src))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseText(%q) = %v, want error containing %q", test.src, err, test.err)
+		}
+	}
+}
\ No newline at end of file
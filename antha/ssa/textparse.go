@@ -0,0 +1,1522 @@
+// antha-tools/antha/ssa/textparse.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package ssa
+
+// This file defines ParseText, which reads the textual form of SSA
+// functions described in text.go.
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/antha-lang/antha/scanner"
+	"github.com/antha-lang/antha/token"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// ParseText reads the textual form of SSA functions from src, as
+// written by WriteText, and returns the functions it defines, in
+// order.  filename is used in positions and error messages.
+//
+// The functions belong to package pkg.  Each is either a function or
+// method declared by pkg.Object that has no body yet, or a new
+// package-level or anonymous function, which is added to pkg.
+// Functions of other packages may be referred to, but not defined.
+//
+// ParseText marks pkg as built, since building it would attempt to
+// create the functions again from syntax, so it is typically used
+// with a Package created from a types.Package alone, by
+// Program.CreatePackage with a loader.PackageInfo having no Files.
+//
+func ParseText(pkg *Package, filename string, src []byte) (fns []*Function, err error) {
+	p := &textParser{
+		prog:    pkg.Prog,
+		pkg:     pkg,
+		imports: make(map[string]*types.Package),
+		funcs:   make(map[string]*Function),
+	}
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case textError:
+			fns, err = nil, e
+		default:
+			panic(e)
+		}
+	}()
+	p.scan(filename, src)
+	if atomic.CompareAndSwapInt32(&pkg.started, 0, 1) {
+		pkg.info = nil // the package has no syntax to build
+	}
+
+	p.header()
+	var sections [][][]textToken
+	for _, line := range p.lines {
+		if line[0].tok == token.FUNC {
+			sections = append(sections, nil)
+		} else if sections == nil {
+			p.errorf(line[0].pos, "expected function, found %s", describe(line[0]))
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+
+	// Create all the functions before parsing any body, so that
+	// references may precede definitions.
+	bodies := make([][][]textToken, len(sections))
+	for i, section := range sections {
+		fn, body := p.function(section)
+		fns = append(fns, fn)
+		bodies[i] = body
+	}
+	for i, fn := range fns {
+		p.body(fn, bodies[i])
+	}
+
+	for _, fn := range fns {
+		buildReferrers(fn)
+		buildDomTree(fn)
+	}
+	var buf bytes.Buffer
+	for _, fn := range fns {
+		if !sanityCheck(fn, &buf) {
+			return nil, fmt.Errorf("%s: ill-formed function %s:\n%s", filename, fn, &buf)
+		}
+	}
+	return fns, nil
+}
+
+// A textError is an error found by the parser.  The parser panics
+// with it, and ParseText recovers it.
+type textError struct {
+	pos token.Position
+	msg string
+}
+
+func (e textError) Error() string { return fmt.Sprintf("%s: %s", e.pos, e.msg) }
+
+// A textToken is a token of the textual form.
+type textToken struct {
+	pos, end token.Pos
+	tok      token.Token
+	lit      string
+}
+
+// describe returns a description of t for use in error messages.
+func describe(t textToken) string {
+	if t.tok == token.SEMICOLON && t.lit == "\n" {
+		return "end of line"
+	}
+	if t.lit != "" {
+		return fmt.Sprintf("%q", t.lit)
+	}
+	return fmt.Sprintf("%q", t.tok)
+}
+
+type textParser struct {
+	prog    *Program
+	pkg     *Package
+	fset    *token.FileSet
+	lines   [][]textToken             // the tokens of each non-empty line
+	imports map[string]*types.Package // imported packages, by name
+	funcs   map[string]*Function      // anonymous functions, by name
+
+	toks []textToken // the rest of the current line
+	eol  textToken   // the end of the current line
+
+	// Information about the function being parsed:
+	fn       *Function
+	locals   map[string]Value      // parameters, free variables and registers
+	regTypes map[string]types.Type // types of all registers
+	succs    map[*BasicBlock][]int // successor indices, from the terminators
+	phis     map[*Phi]phiEdges     // predecessor indices of φ-node edges
+}
+
+func (p *textParser) errorf(pos token.Pos, format string, args ...interface{}) {
+	panic(textError{p.fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// scan splits src into lines of tokens, combining each '$' and the
+// identifiers and numbers on either side into a single identifier.
+func (p *textParser) scan(filename string, src []byte) {
+	p.fset = p.prog.Fset
+	file := p.fset.AddFile(filename, -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, msg string) {
+		if src[pos.Offset] != '$' {
+			panic(textError{pos, msg})
+		}
+	}, 0)
+
+	lastLine := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // automatic
+		}
+		end := pos + token.Pos(len(lit))
+		if lit == "" {
+			end = pos + token.Pos(len(tok.String()))
+		}
+		t := textToken{pos, end, tok, lit}
+
+		if line := file.Line(pos); line != lastLine {
+			lastLine = line
+			p.lines = append(p.lines, nil)
+		}
+		line := p.lines[len(p.lines)-1]
+		if n := len(line); n > 0 && line[n-1].tok == token.IDENT && line[n-1].end == pos {
+			prev := &line[n-1]
+			if tok == token.ILLEGAL && lit == "$" ||
+				(tok == token.IDENT || tok == token.INT) && strings.HasSuffix(prev.lit, "$") {
+				prev.lit += lit
+				prev.end = end
+				continue
+			}
+		}
+		if tok == token.ILLEGAL {
+			p.errorf(pos, "illegal character %q", lit)
+		}
+		p.lines[len(p.lines)-1] = append(line, t)
+	}
+	if len(p.lines) == 0 {
+		p.errorf(file.Pos(0), "expected package clause")
+	}
+}
+
+// startLine makes line the current line.
+func (p *textParser) startLine(line []textToken) {
+	p.toks = line
+	last := line[len(line)-1]
+	p.eol = textToken{pos: last.end, end: last.end, tok: token.SEMICOLON, lit: "\n"}
+}
+
+// peek returns the next token of the current line without
+// consuming it; peek(1) returns the one after.
+func (p *textParser) peek(i ...int) textToken {
+	n := 0
+	if len(i) > 0 {
+		n = i[0]
+	}
+	if n < len(p.toks) {
+		return p.toks[n]
+	}
+	return p.eol
+}
+
+func (p *textParser) next() textToken {
+	t := p.peek()
+	if len(p.toks) > 0 {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+// got consumes the next token if it is tok, and reports whether it
+// did.
+func (p *textParser) got(tok token.Token) bool {
+	if p.peek().tok == tok && len(p.toks) > 0 {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// gotIdent consumes the next token if it is the identifier name,
+// and reports whether it did.
+func (p *textParser) gotIdent(name string) bool {
+	if t := p.peek(); t.tok == token.IDENT && t.lit == name {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *textParser) expect(tok token.Token) textToken {
+	t := p.next()
+	if t.tok != tok || len(p.toks) == 0 && t == p.eol {
+		p.errorf(t.pos, "expected %q, found %s", tok, describe(t))
+	}
+	return t
+}
+
+func (p *textParser) expectIdent(name string) textToken {
+	t := p.expect(token.IDENT)
+	if name != "" && t.lit != name {
+		p.errorf(t.pos, "expected %q, found %s", name, describe(t))
+	}
+	return t
+}
+
+func (p *textParser) expectEOL() {
+	if t := p.next(); t != p.eol {
+		p.errorf(t.pos, "expected end of line, found %s", describe(t))
+	}
+}
+
+func (p *textParser) expectInt() (int, token.Pos) {
+	t := p.expect(token.INT)
+	i, err := strconv.Atoi(t.lit)
+	if err != nil {
+		p.errorf(t.pos, "bad integer %s", t.lit)
+	}
+	return i, t.pos
+}
+
+func (p *textParser) expectString() string {
+	t := p.expect(token.STRING)
+	s, err := strconv.Unquote(t.lit)
+	if err != nil {
+		p.errorf(t.pos, "bad string %s", t.lit)
+	}
+	return s
+}
+
+// header parses the package clause and imports, and removes them
+// from p.lines.
+func (p *textParser) header() {
+	p.startLine(p.lines[0])
+	p.expect(token.PACKAGE)
+	if t := p.expect(token.IDENT); t.lit != p.pkg.Object.Name() {
+		p.errorf(t.pos, "package %s is not package %s", t.lit, p.pkg.Object.Name())
+	}
+	p.expectEOL()
+	p.lines = p.lines[1:]
+
+	for len(p.lines) > 0 && p.lines[0][0].tok == token.IMPORT {
+		p.startLine(p.lines[0])
+		p.next()
+		var name string
+		if t := p.peek(); t.tok == token.IDENT {
+			name = p.next().lit
+		}
+		pos := p.peek().pos
+		path := p.expectString()
+		p.expectEOL()
+		if name == "" {
+			name = defaultImportName(path)
+		}
+		imp := p.lookupPackage(path)
+		if imp == nil {
+			p.errorf(pos, "cannot find package %q", path)
+		}
+		p.imports[name] = imp
+		p.lines = p.lines[1:]
+	}
+}
+
+// lookupPackage returns the package with the specified path among
+// those of the program and those imported, directly or indirectly,
+// by p.pkg, or nil if there is none.
+func (p *textParser) lookupPackage(path string) *types.Package {
+	if path == "unsafe" {
+		return types.Unsafe
+	}
+	for obj := range p.prog.packages {
+		if obj.Path() == path {
+			return obj
+		}
+	}
+	seen := make(map[*types.Package]bool)
+	var find func(pkgs []*types.Package) *types.Package
+	find = func(pkgs []*types.Package) *types.Package {
+		for _, pkg := range pkgs {
+			if seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			if pkg.Path() == path {
+				return pkg
+			}
+			if found := find(pkg.Imports()); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(p.pkg.Object.Imports())
+}
+
+// function parses the signature and attributes of the function
+// defined by section, creates it or finds its declaration, and
+// returns it and the lines of its blocks.
+func (p *textParser) function(section [][]textToken) (*Function, [][]textToken) {
+	p.startLine(section[0])
+	start := p.expect(token.FUNC).pos
+	var recv *types.Var
+	if p.got(token.LPAREN) {
+		t := p.expect(token.IDENT)
+		recv = types.NewParam(t.pos, p.pkg.Object, t.lit, p.typ())
+		p.expect(token.RPAREN)
+	}
+	id := p.expect(token.IDENT)
+	params, variadic := p.tuple()
+	sig := types.NewSignature(nil, recv, params, p.results(), variadic)
+	p.expectEOL()
+
+	// Attributes.
+	var enclosing *Function
+	var freeVars []*Capture
+	recover := -1
+	synthetic := ""
+	lines := section[1:]
+	for len(lines) > 0 && lines[0][0].tok == token.IDENT {
+		p.startLine(lines[0])
+		t := p.next()
+		switch t.lit {
+		case "enclosing":
+			encl := p.expect(token.IDENT)
+			if enclosing = p.funcs[encl.lit]; enclosing == nil {
+				enclosing = p.pkg.Func(encl.lit)
+			}
+			if enclosing == nil {
+				p.errorf(encl.pos, "undefined: %s", encl.lit)
+			}
+		case "free":
+			name := p.expect(token.IDENT)
+			freeVars = append(freeVars, &Capture{name: name.lit, typ: p.typ(), pos: name.pos})
+		case "recover":
+			recover, _ = p.expectInt()
+		case "synthetic":
+			synthetic = p.expectString()
+		default:
+			p.errorf(t.pos, "unknown attribute %s", t.lit)
+		}
+		p.expectEOL()
+		lines = lines[1:]
+	}
+
+	fn := p.declare(id, sig, enclosing)
+	if len(freeVars) > 0 && enclosing == nil {
+		p.errorf(id.pos, "free variables of non-anonymous function %s", id.lit)
+	}
+	fn.FreeVars = freeVars
+	for _, fv := range freeVars {
+		fv.parent = fn
+	}
+
+	// Parameters take their objects from the signature of the
+	// declaration, if any, and their names from the text.
+	fn.Params = nil
+	var vars []*types.Var
+	if recv := fn.Signature.Recv(); recv != nil {
+		vars = append(vars, recv)
+	}
+	for i := 0; i < fn.Signature.Params().Len(); i++ {
+		vars = append(vars, fn.Signature.Params().At(i))
+	}
+	var names []*types.Var
+	if recv != nil {
+		names = append(names, recv)
+	}
+	for i := 0; i < params.Len(); i++ {
+		names = append(names, params.At(i))
+	}
+	for i, v := range vars {
+		fn.Params = append(fn.Params, &Parameter{
+			name:   names[i].Name(),
+			object: v,
+			typ:    v.Type(),
+			pos:    names[i].Pos(),
+			parent: fn,
+		})
+	}
+
+	fn.Synthetic = synthetic
+	fn.syntax = nil
+	if synthetic == "" {
+		last := section[len(section)-1]
+		fn.syntax = extentNode{start, last[len(last)-1].end}
+	}
+
+	fn.Recover = nil
+	if recover >= 0 {
+		fn.Recover = &BasicBlock{Index: recover} // resolved by body
+	}
+	return fn, lines
+}
+
+// declare returns the function named by id, with signature sig: the
+// declared function or method of that name, or a new function.
+func (p *textParser) declare(id textToken, sig *types.Signature, enclosing *Function) *Function {
+	var fn *Function
+	if recv := sig.Recv(); recv != nil {
+		named, _ := deref(recv.Type()).(*types.Named)
+		if named != nil && named.Obj().Pkg() == p.pkg.Object {
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Name() == id.lit {
+					fn, _ = p.pkg.values[m].(*Function)
+				}
+			}
+		}
+		if fn == nil {
+			p.errorf(id.pos, "no method %s declared for receiver type %s", id.lit, recv.Type())
+		}
+	} else if enclosing == nil {
+		fn = p.pkg.Func(id.lit)
+	}
+
+	if fn == nil {
+		// A new function.
+		if p.pkg.Members[id.lit] != nil || p.funcs[id.lit] != nil {
+			p.errorf(id.pos, "%s redeclared", id.lit)
+		}
+		fn = &Function{
+			name:      id.lit,
+			Signature: sig,
+			pos:       id.pos,
+			Enclosing: enclosing,
+			Pkg:       p.pkg,
+			Prog:      p.prog,
+		}
+		if enclosing != nil {
+			enclosing.AnonFuncs = append(enclosing.AnonFuncs, fn)
+			p.funcs[fn.name] = fn
+		} else {
+			p.pkg.Members[fn.name] = fn
+		}
+		return fn
+	}
+
+	if fn.Blocks != nil {
+		p.errorf(id.pos, "%s already has a body", fn)
+	}
+	if !types.Identical(sig, fn.Signature) {
+		p.errorf(id.pos, "signature of %s does not match its declaration %s", fn, fn.Signature)
+	}
+	return fn
+}
+
+// body parses the blocks of function fn.
+func (p *textParser) body(fn *Function, lines [][]textToken) {
+	p.fn = fn
+	p.locals = make(map[string]Value)
+	p.regTypes = make(map[string]types.Type)
+	p.succs = make(map[*BasicBlock][]int)
+	p.phis = make(map[*Phi]phiEdges)
+	for _, param := range fn.Params {
+		p.locals[param.name] = param
+	}
+	for _, fv := range fn.FreeVars {
+		p.locals[fv.name] = fv
+	}
+	if len(lines) == 0 {
+		if fn.Recover != nil {
+			p.errorf(fn.pos, "recover block of external function %s", fn)
+		}
+		return // external function
+	}
+
+	// Create the blocks and find the types of the registers.
+	var preds [][]int
+	for _, line := range lines {
+		p.startLine(line)
+		if t := p.peek(); t.tok == token.INT && p.peek(1).tok == token.COLON {
+			i, pos := p.expectInt()
+			if i != len(fn.Blocks) {
+				p.errorf(pos, "block %d out of order", i)
+			}
+			p.next()
+			comment := ""
+			if p.peek().tok == token.STRING {
+				comment = p.expectString()
+			}
+			var ps []int
+			if p.got(token.ARROW) {
+				for {
+					pred, _ := p.expectInt()
+					ps = append(ps, pred)
+					if !p.got(token.COMMA) {
+						break
+					}
+				}
+			}
+			p.expectEOL()
+			fn.newBasicBlock(comment)
+			preds = append(preds, ps)
+		} else if fn.Blocks == nil {
+			p.errorf(t.pos, "expected block, found %s", describe(t))
+		} else if name, ok := p.register(); ok {
+			if p.regTypes[name.lit] != nil {
+				p.errorf(name.pos, "%s redefined", name.lit)
+			}
+			if _, ok := p.locals[name.lit]; ok {
+				p.errorf(name.pos, "register %s has the name of a parameter", name.lit)
+			}
+			p.regTypes[name.lit] = p.typ()
+		}
+	}
+
+	if fn.Recover != nil {
+		if fn.Recover.Index >= len(fn.Blocks) {
+			p.errorf(fn.pos, "recover block %d does not exist", fn.Recover.Index)
+		}
+		fn.Recover = fn.Blocks[fn.Recover.Index]
+	}
+
+	var b *BasicBlock
+	for _, line := range lines {
+		p.startLine(line)
+		if p.peek().tok == token.INT && p.peek(1).tok == token.COLON {
+			b = fn.Blocks[len(p.succs)]
+			p.succs[b] = nil
+			continue
+		}
+		instr := p.instruction(b)
+		instr.(interface {
+			setBlock(*BasicBlock)
+		}).setBlock(b)
+		b.Instrs = append(b.Instrs, instr)
+	}
+
+	// Replace forward references to registers.
+	var rands []*Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			rands = instr.Operands(rands[:0])
+			for _, rand := range rands {
+				if ref, ok := (*rand).(*forwardRef); ok {
+					*rand = p.locals[ref.name]
+				}
+			}
+			if next, ok := instr.(*Next); ok {
+				if rng, ok := next.Iter.(*Range); ok {
+					next.IsString = isBasic(rng.X.Type().Underlying())
+				}
+			}
+		}
+	}
+
+	// Connect the blocks.  The order of each block's
+	// predecessors is that of the text, which must list the
+	// same edges as the terminators.
+	edges := make([][]*BasicBlock, len(fn.Blocks))
+	for _, b := range fn.Blocks {
+		for _, i := range p.succs[b] {
+			if i < 0 || i >= len(fn.Blocks) {
+				p.errorf(fn.pos, "%s: no block %d", b, i)
+			}
+			succ := fn.Blocks[i]
+			b.Succs = append(b.Succs, succ)
+			edges[i] = append(edges[i], b)
+		}
+	}
+	for i, b := range fn.Blocks {
+		for _, pred := range preds[i] {
+			if pred < 0 || pred >= len(fn.Blocks) {
+				p.errorf(fn.pos, "%s: no block %d", b, pred)
+			}
+			b.Preds = append(b.Preds, fn.Blocks[pred])
+		}
+		if !sameBlocks(b.Preds, edges[i]) {
+			p.errorf(fn.pos, "%s: predecessors %s, but the terminators give %s", b, b.Preds, edges[i])
+		}
+	}
+
+	// Order the edges of each φ-node by predecessor.
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if phi, ok := instr.(*Phi); ok {
+				p.orderEdges(phi)
+			}
+		}
+	}
+
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if alloc, ok := instr.(*Alloc); ok && !alloc.Heap {
+				fn.Locals = append(fn.Locals, alloc)
+			}
+		}
+	}
+}
+
+// register reports whether the current line defines a register, and
+// if so, consumes its name.
+func (p *textParser) register() (textToken, bool) {
+	t := p.peek()
+	if t.tok != token.IDENT || !isRegisterName(t.lit) {
+		return t, false
+	}
+	if u := p.peek(1); u.tok == token.LBRACK && u.pos == t.end {
+		return t, false // map update
+	}
+	return p.next(), true
+}
+
+// isRegisterName reports whether name has the form t<n>.
+func isRegisterName(name string) bool {
+	if len(name) < 2 || name[0] != 't' {
+		return false
+	}
+	_, err := strconv.Atoi(name[1:])
+	return err == nil && name[1] != '+' && name[1] != '-'
+}
+
+// isBasic reports whether t is a basic type.
+func isBasic(t types.Type) bool {
+	_, ok := t.(*types.Basic)
+	return ok
+}
+
+// sameBlocks reports whether x and y hold the same blocks, in any
+// order.
+func sameBlocks(x, y []*BasicBlock) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	count := make(map[*BasicBlock]int)
+	for _, b := range x {
+		count[b]++
+	}
+	for _, b := range y {
+		count[b]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// A phiEdges records, while the predecessors of its block are not
+// yet known, the predecessor index of each edge of a φ-node.
+type phiEdges struct {
+	preds []int
+	pos   token.Pos
+}
+
+// orderEdges arranges the edges of phi in the order of the
+// predecessors of its block.
+func (p *textParser) orderEdges(phi *Phi) {
+	info := p.phis[phi]
+	edges := make([]Value, len(phi.block.Preds))
+	for i, pred := range info.preds {
+		j := -1
+		for k, b := range phi.block.Preds {
+			if b.Index == pred {
+				j = k
+			}
+		}
+		if j < 0 {
+			p.errorf(info.pos, "%s is not a predecessor of %s", p.fn.Blocks[pred], phi.block)
+		}
+		edges[j] = phi.Edges[i]
+	}
+	for i, e := range edges {
+		if e == nil {
+			p.errorf(info.pos, "phi has no edge for predecessor %s", phi.block.Preds[i])
+		}
+	}
+	phi.Edges = edges
+}
+
+// instruction parses the current line, an instruction of block b.
+func (p *textParser) instruction(b *BasicBlock) Instruction {
+	start := p.peek().pos
+	if name, ok := p.register(); ok {
+		typ := p.typ()
+		p.expect(token.ASSIGN)
+		v := p.valueInstr(b, typ)
+		p.expectEOL()
+		num, _ := strconv.Atoi(name.lit[1:])
+		r := v.(interface {
+			setNum(int)
+			setType(types.Type)
+		})
+		r.setNum(num)
+		r.setType(typ)
+		if ps, ok := v.(interface {
+			setPos(token.Pos)
+		}); ok && v.Pos() == token.NoPos {
+			ps.setPos(start)
+		}
+		p.locals[name.lit] = v
+		return v.(Instruction)
+	}
+
+	var instr Instruction
+	t := p.next()
+	switch {
+	case t.tok == token.IDENT && t.lit == "jump":
+		target, _ := p.expectInt()
+		p.succs[b] = []int{target}
+		instr = new(Jump)
+
+	case t.tok == token.IF:
+		cond := p.value()
+		p.expect(token.GOTO)
+		then, _ := p.expectInt()
+		p.expect(token.ELSE)
+		els, _ := p.expectInt()
+		p.succs[b] = []int{then, els}
+		instr = &If{Cond: cond}
+
+	case t.tok == token.RETURN:
+		ret := &Return{pos: t.pos}
+		if p.peek() != p.eol {
+			ret.Results = p.values()
+		}
+		instr = ret
+
+	case t.tok == token.IDENT && t.lit == "rundefers":
+		instr = new(RunDefers)
+
+	case t.tok == token.IDENT && t.lit == "panic":
+		instr = &Panic{X: p.value(), pos: t.pos}
+
+	case t.tok == token.GO:
+		g := &Go{pos: t.pos}
+		p.call(&g.Call, types.NewTuple())
+		instr = g
+
+	case t.tok == token.DEFER:
+		d := &Defer{pos: t.pos}
+		p.call(&d.Call, types.NewTuple())
+		instr = d
+
+	case t.tok == token.IDENT && t.lit == "send":
+		ch := p.value()
+		pos := p.expect(token.ARROW).pos
+		instr = &Send{Chan: ch, X: p.value(), pos: pos}
+
+	case t.tok == token.MUL:
+		addr := p.value()
+		pos := p.expect(token.ASSIGN).pos
+		instr = &Store{Addr: addr, Val: p.value(), pos: pos}
+
+	default:
+		p.toks = append([]textToken{t}, p.toks...)
+		m := p.value()
+		p.expect(token.LBRACK)
+		k := p.value()
+		p.expect(token.RBRACK)
+		pos := p.expect(token.ASSIGN).pos
+		instr = &MapUpdate{Map: m, Key: k, Value: p.value(), pos: pos}
+	}
+	p.expectEOL()
+	return instr
+}
+
+// valueInstr parses the right-hand side of the definition of a
+// register of type typ in block b.
+func (p *textParser) valueInstr(b *BasicBlock, typ types.Type) Value {
+	t := p.peek()
+	switch t.tok {
+	case token.IDENT:
+		switch t.lit {
+		case "local", "new":
+			p.next()
+			return &Alloc{Comment: p.expectString(), Heap: t.lit == "new"}
+
+		case "phi":
+			p.next()
+			phi := new(Phi)
+			info := phiEdges{pos: t.pos}
+			p.expect(token.LBRACK)
+			for !p.got(token.RBRACK) {
+				if len(phi.Edges) > 0 {
+					p.expect(token.COMMA)
+				}
+				pred, _ := p.expectInt()
+				p.expect(token.COLON)
+				info.preds = append(info.preds, pred)
+				phi.Edges = append(phi.Edges, p.value())
+			}
+			if p.peek().tok == token.STRING {
+				phi.Comment = p.expectString()
+			}
+			p.phis[phi] = info
+			return phi
+
+		case "invoke":
+			call := new(Call)
+			p.call(&call.Call, typ)
+			return call
+
+		case "changetype":
+			p.next()
+			return &ChangeType{X: p.value()}
+
+		case "convert":
+			p.next()
+			return &Convert{X: p.value()}
+
+		case "change":
+			p.next()
+			p.expect(token.INTERFACE)
+			return &ChangeInterface{X: p.value()}
+
+		case "make":
+			p.next()
+			return p.makeInstr()
+
+		case "slice":
+			p.next()
+			s := &Slice{X: p.value()}
+			p.expect(token.LBRACK)
+			if p.peek().tok != token.COLON {
+				s.Low = p.value()
+			}
+			p.expect(token.COLON)
+			if tok := p.peek().tok; tok != token.COLON && tok != token.RBRACK {
+				s.High = p.value()
+			}
+			if p.got(token.COLON) {
+				s.Max = p.value()
+			}
+			p.expect(token.RBRACK)
+			return s
+
+		case "next":
+			p.next()
+			return &Next{Iter: p.value()}
+
+		case "typeassert":
+			p.next()
+			ta := &TypeAssert{CommaOk: p.commaOk()}
+			ta.X = p.value()
+			p.expect(token.PERIOD)
+			p.expect(token.LPAREN)
+			ta.AssertedType = p.typ()
+			p.expect(token.RPAREN)
+			return ta
+
+		case "extract":
+			p.next()
+			e := &Extract{Tuple: p.value()}
+			e.Index, _ = p.expectInt()
+			return e
+		}
+
+	case token.RANGE:
+		p.next()
+		return &Range{X: p.value()}
+
+	case token.SELECT:
+		p.next()
+		sel := new(Select)
+		switch mode := p.expect(token.IDENT); mode.lit {
+		case "blocking":
+			sel.Blocking = true
+		case "nonblocking":
+		default:
+			p.errorf(mode.pos, "expected blocking or nonblocking, found %s", describe(mode))
+		}
+		p.expect(token.LBRACK)
+		for !p.got(token.RBRACK) {
+			if len(sel.States) > 0 {
+				p.expect(token.COMMA)
+			}
+			if arrow := p.peek(); p.got(token.ARROW) {
+				sel.States = append(sel.States, &SelectState{Dir: types.RecvOnly, Chan: p.value(), Pos: arrow.pos})
+			} else {
+				ch := p.value()
+				pos := p.expect(token.ARROW).pos
+				sel.States = append(sel.States, &SelectState{Dir: types.SendOnly, Chan: ch, Send: p.value(), Pos: pos})
+			}
+		}
+		return sel
+
+	case token.AND:
+		p.next()
+		x := p.value()
+		if p.got(token.PERIOD) {
+			name := p.expect(token.IDENT)
+			st, ok := deref(x.Type()).Underlying().(*types.Struct)
+			if !ok || !isPointer(x.Type()) {
+				p.errorf(name.pos, "%s is not a pointer to a struct", x.Name())
+			}
+			return &FieldAddr{X: x, Field: p.field(st, name)}
+		}
+		p.expect(token.LBRACK)
+		ia := &IndexAddr{X: x, Index: p.value()}
+		p.expect(token.RBRACK)
+		return ia
+
+	case token.MUL, token.SUB, token.NOT, token.XOR, token.ARROW:
+		p.next()
+		u := &UnOp{Op: t.tok, X: p.value()}
+		if u.Op == token.ARROW {
+			u.CommaOk = p.commaOk()
+		}
+		return u
+	}
+
+	// The remaining forms begin with an operand.
+	x := p.operand(true)
+	switch op := p.peek(); {
+	case op.tok == token.LPAREN:
+		call := &Call{Call: CallCommon{Value: x}}
+		p.call(&call.Call, typ)
+		return call
+
+	case op.tok == token.PERIOD:
+		p.next()
+		name := p.expect(token.IDENT)
+		st, ok := x.Type().Underlying().(*types.Struct)
+		if !ok {
+			p.errorf(name.pos, "%s is not a struct", x.Name())
+		}
+		return &Field{X: x, Field: p.field(st, name)}
+
+	case op.tok == token.LBRACK:
+		p.next()
+		index := p.value()
+		p.expect(token.RBRACK)
+		if _, ok := x.Type().Underlying().(*types.Array); ok {
+			return &Index{X: x, Index: index}
+		}
+		return &Lookup{X: x, Index: index, CommaOk: p.commaOk()}
+
+	case op.tok.Precedence() > token.LowestPrec:
+		p.next()
+		return &BinOp{Op: op.tok, X: x, Y: p.value()}
+	}
+	t = p.peek()
+	p.errorf(t.pos, "unexpected %s", describe(t))
+	return nil
+}
+
+// makeInstr parses the rest of a "make" instruction.
+func (p *textParser) makeInstr() Value {
+	t := p.next()
+	switch {
+	case t.tok == token.INTERFACE:
+		return &MakeInterface{X: p.value()}
+
+	case t.tok == token.IDENT && t.lit == "closure":
+		mc := &MakeClosure{Fn: p.value()}
+		if _, ok := mc.Fn.(*Function); !ok {
+			p.errorf(t.pos, "closure of %s, which is not a function", mc.Fn.Name())
+		}
+		p.expect(token.LBRACK)
+		for !p.got(token.RBRACK) {
+			if len(mc.Bindings) > 0 {
+				p.expect(token.COMMA)
+			}
+			mc.Bindings = append(mc.Bindings, p.value())
+		}
+		return mc
+
+	case t.tok == token.MAP:
+		mm := new(MakeMap)
+		if p.peek() != p.eol {
+			mm.Reserve = p.value()
+		}
+		return mm
+
+	case t.tok == token.CHAN:
+		return &MakeChan{Size: p.value()}
+
+	case t.tok == token.IDENT && t.lit == "slice":
+		ms := &MakeSlice{Len: p.value()}
+		ms.Cap = p.value()
+		return ms
+	}
+	p.errorf(t.pos, "unknown make instruction: make %s", describe(t))
+	return nil
+}
+
+// field returns the index of the field named by t in struct st.
+func (p *textParser) field(st *types.Struct, t textToken) int {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Name() == t.lit {
+			return i
+		}
+	}
+	p.errorf(t.pos, "no field %s in %s", t.lit, st)
+	return -1
+}
+
+// commaOk consumes ",ok" if present, and reports whether it was.
+func (p *textParser) commaOk() bool {
+	if p.peek().tok == token.COMMA && p.peek(1).tok == token.IDENT && p.peek(1).lit == "ok" {
+		p.next()
+		p.next()
+		return true
+	}
+	return false
+}
+
+// call parses a function call or method invocation into c.  results
+// is the type of the results, which determines the signature of a
+// call to a built-in function.
+func (p *textParser) call(c *CallCommon, results types.Type) {
+	if p.gotIdent("invoke") {
+		c.Value = p.value()
+		p.expect(token.PERIOD)
+		name := p.expect(token.IDENT)
+		iface, ok := c.Value.Type().Underlying().(*types.Interface)
+		if !ok {
+			p.errorf(name.pos, "invoke of %s, which is not an interface", c.Value.Name())
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			if m := iface.Method(i); m.Name() == name.lit {
+				c.Method = m
+			}
+		}
+		if c.Method == nil {
+			p.errorf(name.pos, "no method %s in %s", name.lit, c.Value.Type())
+		}
+	} else if c.Value == nil {
+		c.Value = p.operand(true)
+	}
+
+	c.pos = p.expect(token.LPAREN).pos
+	variadic := false
+	for !p.got(token.RPAREN) {
+		if len(c.Args) > 0 {
+			p.expect(token.COMMA)
+		}
+		c.Args = append(c.Args, p.value())
+		if p.got(token.ELLIPSIS) {
+			variadic = true
+			p.expect(token.RPAREN)
+			break
+		}
+	}
+
+	if b, ok := c.Value.(*Builtin); ok {
+		var params []*types.Var
+		for _, arg := range c.Args {
+			params = append(params, newVar("", arg.Type()))
+		}
+		res, ok := results.(*types.Tuple)
+		if !ok {
+			res = types.NewTuple(newVar("", results))
+		}
+		b.sig = types.NewSignature(nil, nil, types.NewTuple(params...), res, variadic)
+	}
+}
+
+// values parses a comma-separated list of operands.
+func (p *textParser) values() []Value {
+	vs := []Value{p.value()}
+	for p.got(token.COMMA) {
+		vs = append(vs, p.value())
+	}
+	return vs
+}
+
+// value parses an operand other than a built-in function.
+func (p *textParser) value() Value {
+	return p.operand(false)
+}
+
+// operand parses a reference to a value: a constant, parameter, free
+// variable, register, function or global, or, if builtins is set, a
+// built-in function.
+func (p *textParser) operand(builtins bool) Value {
+	t := p.peek()
+	switch t.tok {
+	case token.INT, token.FLOAT, token.STRING, token.SUB:
+		return p.constant()
+
+	case token.LPAREN:
+		// A method, (T).f.
+		p.next()
+		recv := p.typ()
+		p.expect(token.RPAREN)
+		p.expect(token.PERIOD)
+		return p.prog.Method(p.method(recv, p.expect(token.IDENT)))
+
+	case token.IDENT:
+		switch t.lit {
+		case "nil", "true", "false":
+			if p.peek(1).tok == token.COLON {
+				return p.constant()
+			}
+		case "complex":
+			if p.isComplexConst() {
+				return p.constant()
+			}
+		case "bound$":
+			p.next()
+			p.expect(token.LPAREN)
+			recv := p.typ()
+			p.expect(token.RPAREN)
+			p.expect(token.PERIOD)
+			sel := p.method(recv, p.expect(token.IDENT))
+			return boundMethodWrapper(p.prog, sel.Obj().(*types.Func))
+		}
+		p.next()
+		if v, ok := p.locals[t.lit]; ok {
+			return v
+		}
+		if typ := p.regTypes[t.lit]; typ != nil {
+			return &forwardRef{name: t.lit, typ: typ, pos: t.pos}
+		}
+		if fn := p.funcs[t.lit]; fn != nil {
+			return fn
+		}
+		if imp := p.imports[t.lit]; imp != nil && p.peek().tok == token.PERIOD {
+			p.next()
+			return p.member(p.prog.packages[imp], imp, p.expect(token.IDENT))
+		}
+		if _, ok := p.pkg.Members[t.lit]; ok {
+			return p.member(p.pkg, p.pkg.Object, t)
+		}
+		if obj, ok := types.Universe.Lookup(t.lit).(*types.Builtin); ok && builtins {
+			return &Builtin{object: obj}
+		}
+		p.errorf(t.pos, "undefined: %s", t.lit)
+	}
+	p.errorf(t.pos, "expected operand, found %s", describe(t))
+	return nil
+}
+
+// isComplexConst reports whether the next tokens are a complex
+// constant, complex(re, im):T, not a call of the built-in function.
+func (p *textParser) isComplexConst() bool {
+	if p.peek(1).tok != token.LPAREN {
+		return false
+	}
+	depth := 0
+	for i := 1; i < len(p.toks); i++ {
+		switch p.toks[i].tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth--; depth == 0 {
+				return p.peek(i+1).tok == token.COLON
+			}
+		}
+	}
+	return false
+}
+
+// member returns the function or global named by t in package pkg,
+// whose object is obj; pkg is nil if obj has no SSA package.
+func (p *textParser) member(pkg *Package, obj *types.Package, t textToken) Value {
+	if pkg == nil {
+		p.errorf(t.pos, "package %s has no SSA form", obj.Path())
+	}
+	switch mem := pkg.Members[t.lit].(type) {
+	case *Function:
+		return mem
+	case *Global:
+		return mem
+	}
+	p.errorf(t.pos, "%s.%s is not a function or variable", obj.Name(), t.lit)
+	return nil
+}
+
+// method returns the selection of the method of recv named by t.
+func (p *textParser) method(recv types.Type, t textToken) *types.Selection {
+	mset := p.prog.MethodSets.MethodSet(recv)
+	for i := 0; i < mset.Len(); i++ {
+		if sel := mset.At(i); sel.Obj().Name() == t.lit {
+			return sel
+		}
+	}
+	p.errorf(t.pos, "no method %s in method set of %s", t.lit, recv)
+	return nil
+}
+
+// constant parses a constant, value:type.
+func (p *textParser) constant() *Const {
+	var val exact.Value
+	t := p.peek()
+	switch {
+	case t.tok == token.IDENT && t.lit == "nil":
+		p.next()
+	case t.tok == token.IDENT && (t.lit == "true" || t.lit == "false"):
+		p.next()
+		val = exact.MakeBool(t.lit == "true")
+	case t.tok == token.IDENT && t.lit == "complex":
+		p.next()
+		p.expect(token.LPAREN)
+		re := p.real()
+		p.expect(token.COMMA)
+		im := p.real()
+		p.expect(token.RPAREN)
+		val = exact.BinaryOp(re, token.ADD, exact.MakeImag(im))
+	case t.tok == token.STRING:
+		p.next()
+		val = exact.MakeFromLiteral(t.lit, t.tok)
+		if val == nil {
+			p.errorf(t.pos, "bad string %s", t.lit)
+		}
+	default:
+		val = p.real()
+	}
+	p.expect(token.COLON)
+	return NewConst(val, p.typ())
+}
+
+// real parses an integer or floating-point constant, which may be
+// negative or a fraction.
+func (p *textParser) real() exact.Value {
+	neg := p.got(token.SUB)
+	x := p.number()
+	if p.got(token.QUO) {
+		y := p.number()
+		if exact.Sign(y) == 0 {
+			p.errorf(p.peek().pos, "division by zero")
+		}
+		x = exact.BinaryOp(x, token.QUO, y)
+	}
+	if neg {
+		x = exact.UnaryOp(token.SUB, x, 0)
+	}
+	return x
+}
+
+func (p *textParser) number() exact.Value {
+	t := p.next()
+	if t.tok != token.INT && t.tok != token.FLOAT {
+		p.errorf(t.pos, "expected number, found %s", describe(t))
+	}
+	x := exact.MakeFromLiteral(t.lit, t.tok)
+	if x == nil {
+		p.errorf(t.pos, "bad number %s", t.lit)
+	}
+	return x
+}
+
+// A forwardRef stands for a register used before its definition,
+// until the function has been parsed.
+type forwardRef struct {
+	name string
+	typ  types.Type
+	pos  token.Pos
+}
+
+func (r *forwardRef) Name() string              { return r.name }
+func (r *forwardRef) String() string            { return r.name }
+func (r *forwardRef) Type() types.Type          { return r.typ }
+func (r *forwardRef) Referrers() *[]Instruction { return nil }
+func (r *forwardRef) Pos() token.Pos            { return r.pos }
+
+// -- types ------------------------------------------------------------
+
+// typ parses a type.
+func (p *textParser) typ() types.Type {
+	t := p.next()
+	switch t.tok {
+	case token.IDENT:
+		return p.typeName(t)
+
+	case token.MUL:
+		return types.NewPointer(p.typ())
+
+	case token.LBRACK:
+		if p.got(token.RBRACK) {
+			return types.NewSlice(p.typ())
+		}
+		n, _ := p.expectInt()
+		p.expect(token.RBRACK)
+		return types.NewArray(p.typ(), int64(n))
+
+	case token.MAP:
+		p.expect(token.LBRACK)
+		key := p.typ()
+		p.expect(token.RBRACK)
+		return types.NewMap(key, p.typ())
+
+	case token.CHAN:
+		dir := types.SendRecv
+		if p.got(token.ARROW) {
+			dir = types.SendOnly
+		}
+		if p.got(token.LPAREN) {
+			elem := p.typ()
+			p.expect(token.RPAREN)
+			return types.NewChan(dir, elem)
+		}
+		return types.NewChan(dir, p.typ())
+
+	case token.ARROW:
+		p.expect(token.CHAN)
+		return types.NewChan(types.RecvOnly, p.typ())
+
+	case token.FUNC:
+		params, variadic := p.tuple()
+		return types.NewSignature(nil, nil, params, p.results(), variadic)
+
+	case token.STRUCT:
+		return p.structType()
+
+	case token.INTERFACE:
+		return p.interfaceType()
+
+	case token.LPAREN:
+		p.toks = append([]textToken{t}, p.toks...)
+		tuple, _ := p.tuple()
+		return tuple
+	}
+	p.errorf(t.pos, "expected type, found %s", describe(t))
+	return nil
+}
+
+// typeName returns the type named by t, which may be qualified.
+func (p *textParser) typeName(t textToken) types.Type {
+	scope := p.pkg.Object.Scope()
+	name := t.lit
+	if imp := p.imports[name]; imp != nil && p.peek().tok == token.PERIOD {
+		p.next()
+		t = p.expect(token.IDENT)
+		scope, name = imp.Scope(), t.lit
+	} else {
+		switch name {
+		case "invalid":
+			return tInvalid
+		case "iter":
+			return tRangeIter
+		}
+		if tn, ok := types.Universe.Lookup(name).(*types.TypeName); ok && scope.Lookup(name) == nil {
+			return tn.Type()
+		}
+	}
+	tn, ok := scope.Lookup(name).(*types.TypeName)
+	if !ok {
+		p.errorf(t.pos, "undefined type: %s", name)
+	}
+	return tn.Type()
+}
+
+// startsType reports whether tok may begin a type.
+func startsType(tok token.Token) bool {
+	switch tok {
+	case token.IDENT, token.MUL, token.LBRACK, token.MAP, token.CHAN,
+		token.ARROW, token.FUNC, token.STRUCT, token.INTERFACE, token.LPAREN:
+		return true
+	}
+	return false
+}
+
+// tuple parses a parenthesized list of types, each optionally
+// preceded by a name, as in a parameter list, and reports whether
+// the last is variadic.
+func (p *textParser) tuple() (*types.Tuple, bool) {
+	p.expect(token.LPAREN)
+	var vars []*types.Var
+	variadic := false
+	for !p.got(token.RPAREN) {
+		if len(vars) > 0 {
+			p.expect(token.COMMA)
+		}
+		if variadic {
+			p.errorf(p.peek().pos, "variadic parameter must be last")
+		}
+		t := p.peek()
+		name := ""
+		if next := p.peek(1).tok; t.tok == token.IDENT &&
+			next != token.COMMA && next != token.RPAREN && next != token.PERIOD {
+			name = p.next().lit
+		}
+		var typ types.Type
+		if p.got(token.ELLIPSIS) {
+			variadic = true
+			typ = types.NewSlice(p.typ())
+		} else {
+			typ = p.typ()
+		}
+		vars = append(vars, types.NewParam(t.pos, p.pkg.Object, name, typ))
+	}
+	return types.NewTuple(vars...), variadic
+}
+
+// results parses the optional results of a signature.
+func (p *textParser) results() *types.Tuple {
+	t := p.peek()
+	if t == p.eol || !startsType(t.tok) {
+		return types.NewTuple()
+	}
+	if t.tok == token.LPAREN {
+		res, _ := p.tuple()
+		return res
+	}
+	return types.NewTuple(types.NewParam(t.pos, p.pkg.Object, "", p.typ()))
+}
+
+func (p *textParser) structType() types.Type {
+	p.expect(token.LBRACE)
+	var fields []*types.Var
+	var tags []string
+	for !p.got(token.RBRACE) {
+		if len(fields) > 0 {
+			p.expect(token.SEMICOLON)
+		}
+		t := p.peek()
+		var f *types.Var
+		switch next := p.peek(1).tok; {
+		case t.tok == token.MUL || t.tok == token.IDENT &&
+			(next == token.SEMICOLON || next == token.RBRACE || next == token.STRING || next == token.PERIOD):
+			// embedded field
+			typ := p.typ()
+			named, ok := deref(typ).(*types.Named)
+			if !ok {
+				p.errorf(t.pos, "embedded field %s is not a named type", typ)
+			}
+			f = types.NewField(t.pos, p.pkg.Object, named.Obj().Name(), typ, true)
+		default:
+			name := p.expect(token.IDENT)
+			f = types.NewField(name.pos, p.pkg.Object, name.lit, p.typ(), false)
+		}
+		tag := ""
+		if p.peek().tok == token.STRING {
+			tag = p.expectString()
+		}
+		fields = append(fields, f)
+		tags = append(tags, tag)
+	}
+	return types.NewStruct(fields, tags)
+}
+
+func (p *textParser) interfaceType() types.Type {
+	p.expect(token.LBRACE)
+	var methods []*types.Func
+	var embeddeds []*types.Named
+	for n := 0; !p.got(token.RBRACE); n++ {
+		if n > 0 {
+			p.expect(token.SEMICOLON)
+		}
+		t := p.peek()
+		if t.tok == token.IDENT && p.peek(1).tok == token.LPAREN {
+			p.next()
+			params, variadic := p.tuple()
+			sig := types.NewSignature(nil, nil, params, p.results(), variadic)
+			methods = append(methods, types.NewFunc(t.pos, p.pkg.Object, t.lit, sig))
+			continue
+		}
+		named, ok := p.typ().(*types.Named)
+		if !ok {
+			p.errorf(t.pos, "embedded type is not a named interface")
+		}
+		if _, ok := named.Underlying().(*types.Interface); !ok {
+			p.errorf(t.pos, "embedded type %s is not an interface", named)
+		}
+		embeddeds = append(embeddeds, named)
+	}
+	return types.NewInterface(methods, embeddeds)
+}
\ No newline at end of file
@@ -0,0 +1,747 @@
+// antha-tools/antha/taint/taint.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// Package taint reports the paths along which untrusted data may
+// flow from a source, such as a command-line argument, an element
+// input or a network read, to a sink, such as a call that executes a
+// program or controls a device.  It is intended for reviewing code,
+// such as third-party element contributions, whose inputs should
+// never reach certain operations unchecked.
+//
+// The analysis is whole-program: it follows data through calls using
+// the call graph computed by the pointer analysis, and through memory
+// using its points-to sets.  Memory is modelled at the granularity of
+// objects: storing untrusted data into any part of an object taints
+// the whole of it, and so does an untrusted pointer to it.  Data that
+// merely influences control flow is not tracked.
+//
+// Sources and sinks are specified by patterns, which are matched
+// using path.Match against the names of functions, global variables
+// and struct fields, as printed by the ssa package:
+//
+//	os.Getenv                 a function
+//	(*os.File).Read           a method
+//	os/exec.*                 all functions of a package
+//	os.Args                   a global variable
+//	(main.Inputs).Volume      a field of a named struct type
+//	(main.Inputs).*           all fields of a named struct type
+//
+// A function pattern may be followed by an argument index in
+// brackets, such as "(*os.File).Read[1]"; the receiver of a method is
+// argument 0.  As a source, a function pattern without an index
+// denotes the results of calls to the function; with an index, it
+// denotes the memory to which that argument points, such as the
+// buffer filled by Read.  As a sink, a function pattern denotes all
+// the arguments of calls to the function, or just the one given by
+// the index.  Data is not followed into the sinks themselves.
+// Global and field patterns may only be used as sources; they denote
+// the values loaded from the variable or field.
+//
+package taint
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/callgraph"
+	"github.com/antha-lang/antha-tools/antha/pointer"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// A Config specifies the sources and sinks of a taint analysis.
+type Config struct {
+	Sources []string // patterns denoting untrusted data
+	Sinks   []string // patterns denoting the arguments it must not reach
+}
+
+// DefaultConfig returns a new Config whose sources are the
+// command line, the environment, and reads from files and network
+// connections, and whose sinks are the functions that execute
+// programs or make system calls.
+//
+func DefaultConfig() *Config {
+	return &Config{
+		Sources: []string{
+			"os.Args",
+			"syscall.Getenv",
+			"syscall.Environ",
+			"(*os.File).Read[1]",
+			"(*os.File).ReadAt[1]",
+			"(*net.conn).Read[1]",
+			"(*net.UDPConn).ReadFrom*[1]",
+		},
+		Sinks: []string{
+			"os/exec.Command",
+			"os.StartProcess",
+			"syscall.Exec",
+			"syscall.ForkExec",
+			"syscall.StartProcess",
+			"syscall.Syscall*",
+			"syscall.RawSyscall*",
+		},
+	}
+}
+
+// A Flow is a path along which untrusted data flows from a source to
+// a sink.
+type Flow struct {
+	Source string    // name of the source
+	Sink   string    // name of the sink function
+	Pos    token.Pos // position of the call to the sink
+	Path   []Step    // the steps from source to sink
+}
+
+// A Step is one step of a Flow.  Steps of no known position are
+// omitted, except for the first.
+type Step struct {
+	Pos         token.Pos
+	Description string
+}
+
+// A pattern is a parsed source or sink pattern.
+type pattern struct {
+	glob string // for path.Match
+	arg  int    // argument index, or -1
+}
+
+func parsePatterns(specs []string) ([]pattern, error) {
+	var patterns []pattern
+	for _, spec := range specs {
+		p := pattern{glob: spec, arg: -1}
+		if strings.HasSuffix(spec, "]") {
+			i := strings.LastIndex(spec, "[")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid pattern %q", spec)
+			}
+			arg, err := strconv.Atoi(spec[i+1 : len(spec)-1])
+			if err != nil || arg < 0 {
+				return nil, fmt.Errorf("invalid argument index in pattern %q", spec)
+			}
+			p.glob, p.arg = spec[:i], arg
+		}
+		if _, err := path.Match(p.glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", spec, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func (p pattern) match(name string) bool {
+	ok, _ := path.Match(p.glob, name)
+	return ok
+}
+
+// An object identifies the memory object of a pointer.Label: its
+// allocation site, if any, and the name of the label without its
+// subelement path.
+type object struct {
+	site ssa.Value
+	name string
+}
+
+func (obj object) String() string { return obj.name }
+
+// A node is a tainted entity: an ssa.Value or an object.
+type node interface{}
+
+// A reason records why a node is tainted.
+type reason struct {
+	from   node   // the node from which the taint came; nil for a source
+	step   Step   // the step by which it came
+	source string // name of the original source
+}
+
+type analysis struct {
+	sources, sinks []pattern
+	fns            map[*ssa.Function]bool
+	ptr            *pointer.Result
+	callers        map[*ssa.Function][]ssa.CallInstruction
+	callees        map[ssa.CallInstruction][]*ssa.Function
+	readers        map[object][]ssa.Instruction // instructions that read each object
+	sinkObjects    map[object][]sinkArg         // sink arguments that point to each object
+	reached        map[node]*reason
+	queue          []node
+	reported       map[sinkArg]bool
+	flows          []*Flow
+}
+
+// A sinkArg identifies an argument of a call to a sink.
+type sinkArg struct {
+	call ssa.CallInstruction
+	sink *ssa.Function
+	arg  int
+}
+
+// Analyze runs the pointer analysis described by ptaConfig, whose
+// Mains must be built, and returns the flows from the sources to the
+// sinks of config, ordered by the position of the sink.
+//
+// Analyze adds the queries it needs to ptaConfig and requests a call
+// graph.
+//
+func Analyze(config *Config, ptaConfig *pointer.Config) ([]*Flow, error) {
+	a := &analysis{
+		callers:     make(map[*ssa.Function][]ssa.CallInstruction),
+		callees:     make(map[ssa.CallInstruction][]*ssa.Function),
+		readers:     make(map[object][]ssa.Instruction),
+		sinkObjects: make(map[object][]sinkArg),
+		reached:     make(map[node]*reason),
+		reported:    make(map[sinkArg]bool),
+	}
+	var err error
+	if a.sources, err = parsePatterns(config.Sources); err != nil {
+		return nil, err
+	}
+	if a.sinks, err = parsePatterns(config.Sinks); err != nil {
+		return nil, err
+	}
+	if len(ptaConfig.Mains) == 0 {
+		return nil, fmt.Errorf("no main packages")
+	}
+	prog := ptaConfig.Mains[0].Prog
+
+	// Query every pointer-like value, since any of them may
+	// become tainted, and every global.
+	a.fns = ssautil.AllFunctions(prog)
+	for fn := range a.fns {
+		for _, p := range fn.Params {
+			addQuery(ptaConfig, p)
+		}
+		for _, fv := range fn.FreeVars {
+			addQuery(ptaConfig, fv)
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if v, ok := instr.(ssa.Value); ok {
+					addQuery(ptaConfig, v)
+				}
+				var rands []*ssa.Value
+				for _, rand := range instr.Operands(rands) {
+					if g, ok := (*rand).(*ssa.Global); ok {
+						addQuery(ptaConfig, g)
+					}
+				}
+			}
+		}
+	}
+	for _, pkg := range prog.AllPackages() {
+		for _, mem := range pkg.Members {
+			if g, ok := mem.(*ssa.Global); ok {
+				addQuery(ptaConfig, g)
+			}
+		}
+	}
+
+	ptaConfig.BuildCallGraph = true
+	a.ptr, err = pointer.Analyze(ptaConfig)
+	if err != nil {
+		return nil, err
+	}
+	a.indexCalls(a.ptr.CallGraph)
+	a.indexReaders()
+	a.indexSinks()
+	a.seed(prog)
+
+	for len(a.queue) > 0 {
+		n := a.queue[0]
+		a.queue = a.queue[1:]
+		switch n := n.(type) {
+		case ssa.Value:
+			a.visitValue(n)
+		case object:
+			a.visitObject(n)
+		}
+	}
+
+	sort.Sort(byPos(a.flows))
+	return a.flows, nil
+}
+
+func addQuery(ptaConfig *pointer.Config, v ssa.Value) {
+	if pointer.CanPoint(v.Type()) {
+		ptaConfig.AddQuery(v)
+	}
+}
+
+// indexCalls records the callers and callees of each function and
+// call site in the call graph cg.
+func (a *analysis) indexCalls(cg *callgraph.Graph) {
+	for _, cgn := range cg.Nodes {
+		for _, e := range cgn.Out {
+			if e.Site != nil {
+				a.callees[e.Site] = append(a.callees[e.Site], e.Callee.Func)
+				a.callers[e.Callee.Func] = append(a.callers[e.Callee.Func], e.Site)
+			}
+		}
+	}
+}
+
+// indexReaders records the instructions that read each object.
+func (a *analysis) indexReaders() {
+	for fn := range a.fns {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				var from ssa.Value
+				switch instr := instr.(type) {
+				case *ssa.UnOp:
+					if instr.Op == token.MUL || instr.Op == token.ARROW {
+						from = instr.X
+					}
+				case *ssa.Lookup:
+					if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+						from = instr.X
+					}
+				case *ssa.Next:
+					if !instr.IsString {
+						from = instr.Iter.(*ssa.Range).X
+					}
+				case *ssa.Call:
+					if b, ok := instr.Call.Value.(*ssa.Builtin); ok && b.Name() == "copy" {
+						from = instr.Call.Args[1]
+					}
+				case *ssa.Select:
+					for _, st := range instr.States {
+						if st.Dir == types.RecvOnly {
+							for _, obj := range a.objects(st.Chan) {
+								a.readers[obj] = append(a.readers[obj], instr)
+							}
+						}
+					}
+				}
+				if from != nil {
+					for _, obj := range a.objects(from) {
+						a.readers[obj] = append(a.readers[obj], instr)
+					}
+				}
+			}
+		}
+	}
+}
+
+// indexSinks records the arguments of calls to sinks that point to
+// each object, since untrusted data may reach a sink through memory
+// as well as directly.
+func (a *analysis) indexSinks() {
+	for fn := range a.fns {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				for _, callee := range a.calleesOf(call) {
+					for i, arg := range actuals(call) {
+						if !a.isSink(callee, i) {
+							continue
+						}
+						for _, obj := range a.objects(arg) {
+							a.sinkObjects[obj] = append(a.sinkObjects[obj], sinkArg{call, callee, i})
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// calleesOf returns the possible callees of call.
+func (a *analysis) calleesOf(call ssa.CallInstruction) []*ssa.Function {
+	if callees := a.callees[call]; callees != nil {
+		return callees
+	}
+	if fn := call.Common().StaticCallee(); fn != nil {
+		return []*ssa.Function{fn}
+	}
+	return nil
+}
+
+// objects returns the objects to which v may point.
+func (a *analysis) objects(v ssa.Value) []object {
+	ptr, ok := a.ptr.Queries[v]
+	if !ok {
+		return nil
+	}
+	var objs []object
+	seen := make(map[object]bool)
+	for _, l := range ptr.PointsTo().Labels() {
+		obj := object{l.Value(), strings.TrimSuffix(l.String(), l.Path())}
+		if !seen[obj] {
+			seen[obj] = true
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// seed taints the sources.
+func (a *analysis) seed(prog *ssa.Program) {
+	for fn := range a.fns {
+		for _, p := range a.sources {
+			if !p.match(fn.String()) {
+				continue
+			}
+			for _, site := range a.callers[fn] {
+				r := &reason{source: fn.String()}
+				if p.arg < 0 {
+					if v := site.Value(); v != nil {
+						r.step = Step{site.Pos(), fmt.Sprintf("call to source %s", fn)}
+						a.taint(v, r)
+					}
+				} else if args := actuals(site); p.arg < len(args) {
+					r.step = Step{site.Pos(), fmt.Sprintf("call to source %s writes untrusted data to argument %d", fn, p.arg)}
+					for _, obj := range a.objects(args[p.arg]) {
+						a.taint(obj, r)
+					}
+				}
+			}
+		}
+
+		// Field sources.
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				var X ssa.Value
+				var field int
+				switch instr := instr.(type) {
+				case *ssa.Field:
+					X, field = instr.X, instr.Field
+				case *ssa.FieldAddr:
+					X, field = instr.X, instr.Field
+				default:
+					continue
+				}
+				T := X.Type()
+				if ptr, ok := T.Underlying().(*types.Pointer); ok {
+					T = ptr.Elem()
+				}
+				named, ok := T.(*types.Named)
+				if !ok {
+					continue
+				}
+				name := fmt.Sprintf("(%s).%s", named, named.Underlying().(*types.Struct).Field(field).Name())
+				for _, p := range a.sources {
+					if p.arg >= 0 || !p.match(name) {
+						continue
+					}
+					if v, ok := instr.(*ssa.Field); ok {
+						a.taint(v, &reason{
+							source: name,
+							step:   Step{v.Pos(), fmt.Sprintf("read of source %s", name)},
+						})
+						break
+					}
+					for _, ref := range *instr.(*ssa.FieldAddr).Referrers() {
+						if load, ok := ref.(*ssa.UnOp); ok && load.Op == token.MUL {
+							a.taint(load, &reason{
+								source: name,
+								step:   Step{load.Pos(), fmt.Sprintf("read of source %s", name)},
+							})
+						}
+					}
+					break
+				}
+			}
+		}
+	}
+
+	// Global sources.
+	for _, pkg := range prog.AllPackages() {
+		for _, mem := range pkg.Members {
+			g, ok := mem.(*ssa.Global)
+			if !ok {
+				continue
+			}
+			for _, p := range a.sources {
+				if p.arg < 0 && p.match(g.String()) {
+					r := &reason{source: g.String(), step: Step{g.Pos(), fmt.Sprintf("source %s", g)}}
+					for _, obj := range a.objects(g) {
+						a.taint(obj, r)
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// taint marks n as tainted for reason r, if it is not already.
+func (a *analysis) taint(n node, r *reason) {
+	if _, ok := a.reached[n]; ok {
+		return
+	}
+	if r.from != nil {
+		r.source = a.reached[r.from].source
+	}
+	a.reached[n] = r
+	a.queue = append(a.queue, n)
+}
+
+// flow marks n as tainted by its predecessor from, by a step at pos.
+func (a *analysis) flow(n, from node, pos token.Pos, format string, args ...interface{}) {
+	a.taint(n, &reason{from: from, step: Step{pos, fmt.Sprintf(format, args...)}})
+}
+
+// taintObjects marks the objects to which ptr points as tainted by v.
+func (a *analysis) taintObjects(ptr, v ssa.Value, pos token.Pos, verb string) {
+	for _, obj := range a.objects(ptr) {
+		a.flow(obj, v, pos, "%s %s", verb, obj)
+	}
+}
+
+func (a *analysis) visitObject(obj object) {
+	for _, arg := range a.sinkObjects[obj] {
+		a.report(obj, arg)
+	}
+	for _, instr := range a.readers[obj] {
+		switch instr := instr.(type) {
+		case *ssa.Call: // copy
+			a.taintObjects(instr.Call.Args[0], instr.Call.Args[1], instr.Pos(), "copied into")
+		default:
+			a.flow(instr, obj, instrPos(instr), "loaded from %s", obj)
+		}
+	}
+}
+
+func (a *analysis) visitValue(v ssa.Value) {
+	// An untrusted pointer taints the objects to which it points.
+	for _, obj := range a.objects(v) {
+		a.flow(obj, v, token.NoPos, "refers to %s", obj)
+	}
+
+	refs := v.Referrers()
+	if refs == nil {
+		return
+	}
+	for _, instr := range *refs {
+		switch instr := instr.(type) {
+		case *ssa.Store:
+			if instr.Val == v {
+				a.taintObjects(instr.Addr, v, instrPos(instr), "stored into")
+			}
+
+		case *ssa.MapUpdate:
+			if instr.Key == v || instr.Value == v {
+				a.taintObjects(instr.Map, v, instrPos(instr), "stored into")
+			}
+
+		case *ssa.Send:
+			if instr.X == v {
+				a.taintObjects(instr.Chan, v, instrPos(instr), "sent on")
+			}
+
+		case *ssa.Select:
+			for _, st := range instr.States {
+				if st.Dir == types.SendOnly && st.Send == v {
+					a.taintObjects(st.Chan, v, st.Pos, "sent on")
+				}
+			}
+
+		case *ssa.Return:
+			fn := instr.Parent()
+			for _, site := range a.callers[fn] {
+				if res := site.Value(); res != nil {
+					a.flow(res, v, site.Pos(), "returned by %s", fn)
+				}
+			}
+
+		case *ssa.MakeClosure:
+			fn := instr.Fn.(*ssa.Function)
+			for i, b := range instr.Bindings {
+				if b == v {
+					a.flow(fn.FreeVars[i], v, instrPos(instr), "captured by %s", fn)
+				}
+			}
+
+		case ssa.CallInstruction:
+			a.visitCall(v, instr)
+
+		case *ssa.UnOp:
+			// Loads depend on the contents of memory, not the address.
+			if instr.Op != token.MUL && instr.Op != token.ARROW {
+				a.flow(instr, v, instrPos(instr), "%s = %s", instr.Name(), instr)
+			}
+
+		case *ssa.Lookup:
+			// Map lookups depend on the contents of the map.
+			if instr.X == v && !isMap(v.Type()) {
+				a.flow(instr, v, instrPos(instr), "%s = %s", instr.Name(), instr)
+			}
+
+		case *ssa.Next:
+			if instr.IsString {
+				a.flow(instr, v, instrPos(instr), "%s = %s", instr.Name(), instr)
+			}
+
+		case *ssa.FieldAddr, *ssa.IndexAddr, *ssa.Alloc, *ssa.MakeSlice, *ssa.MakeMap, *ssa.MakeChan:
+			// Addresses and sizes of objects are not data.
+
+		case ssa.Value:
+			a.flow(instr, v, instrPos(instr.(ssa.Instruction)), "%s = %s", instr.Name(), instr)
+		}
+	}
+}
+
+// visitCall propagates the tainted value v through call, of which it
+// is an operand.
+func (a *analysis) visitCall(v ssa.Value, call ssa.CallInstruction) {
+	common := call.Common()
+	if b, ok := common.Value.(*ssa.Builtin); ok {
+		switch b.Name() {
+		case "copy":
+			if common.Args[1] == v {
+				a.taintObjects(common.Args[0], v, call.Pos(), "copied into")
+			}
+		default:
+			if res := call.Value(); res != nil {
+				a.flow(res, v, call.Pos(), "result of %s", b.Name())
+			}
+		}
+		return
+	}
+
+	callees := a.calleesOf(call)
+	for i, arg := range actuals(call) {
+		if arg != v {
+			continue
+		}
+		for _, callee := range callees {
+			if a.isSink(callee, i) {
+				a.report(v, sinkArg{call, callee, i})
+				continue
+			}
+			if callee.Blocks == nil {
+				// No code: assume that the result depends
+				// on the arguments.
+				if res := call.Value(); res != nil {
+					a.flow(res, v, call.Pos(), "result of %s", callee)
+				}
+				continue
+			}
+			if i < len(callee.Params) {
+				p := callee.Params[i]
+				a.flow(p, v, call.Pos(), "passed to parameter %s of %s", p.Name(), callee)
+			}
+		}
+	}
+	if len(callees) == 0 {
+		if res := call.Value(); res != nil {
+			a.flow(res, v, call.Pos(), "result of unknown function")
+		}
+	}
+}
+
+// actuals returns the arguments of call, including any receiver.
+func actuals(call ssa.CallInstruction) []ssa.Value {
+	common := call.Common()
+	if common.IsInvoke() {
+		return append([]ssa.Value{common.Value}, common.Args...)
+	}
+	return common.Args
+}
+
+func (a *analysis) isSink(fn *ssa.Function, arg int) bool {
+	for _, p := range a.sinks {
+		if (p.arg < 0 || p.arg == arg) && p.match(fn.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// report records the flow of the tainted node n to the sink argument
+// arg, which is n itself or points to it.
+func (a *analysis) report(n node, arg sinkArg) {
+	if a.reported[arg] {
+		return
+	}
+	a.reported[arg] = true
+
+	var path []Step
+	var r *reason
+	for ; n != nil; n = r.from {
+		r = a.reached[n]
+		if r.step.Pos.IsValid() || r.from == nil {
+			path = append(path, r.step)
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	pos := arg.call.Pos()
+	path = append(path, Step{pos, fmt.Sprintf("argument %d of call to sink %s", arg.arg, arg.sink)})
+
+	a.flows = append(a.flows, &Flow{
+		Source: r.source,
+		Sink:   arg.sink.String(),
+		Pos:    pos,
+		Path:   path,
+	})
+}
+
+// instrPos returns the source position of instr.  Many instructions
+// (e.g. loads and stores) have no position of their own; they are
+// reported at the position of the expression recorded by a DebugRef
+// for the value they define or store, if the function was built
+// with debug information.
+func instrPos(instr ssa.Instruction) token.Pos {
+	if pos := instr.Pos(); pos.IsValid() {
+		return pos
+	}
+	var v ssa.Value
+	switch instr := instr.(type) {
+	case *ssa.Store:
+		v = instr.Val
+	case ssa.Value:
+		v = instr
+	default:
+		return token.NoPos
+	}
+	for _, other := range instr.Block().Instrs {
+		if ref, ok := other.(*ssa.DebugRef); ok && ref.X == v {
+			return ref.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+func isMap(T types.Type) bool {
+	_, ok := T.Underlying().(*types.Map)
+	return ok
+}
+
+type byPos []*Flow
+
+func (p byPos) Len() int { return len(p) }
+func (p byPos) Less(i, j int) bool {
+	if p[i].Pos != p[j].Pos {
+		return p[i].Pos < p[j].Pos
+	}
+	return len(p[i].Path) < len(p[j].Path)
+}
+func (p byPos) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
\ No newline at end of file
@@ -0,0 +1,107 @@
+// antha-tools/antha/taint/taint_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package taint_test
+
+import (
+	"github.com/antha-lang/antha/parser"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/pointer"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/taint"
+)
+
+func TestAnalyze(t *testing.T) {
+	conf := loader.Config{ParserMode: parser.ParseComments}
+	f, err := conf.ParseFile("testdata/taint.go", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.CreateFromFiles("main", f)
+	iprog, err := conf.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	prog := ssa.Create(iprog, 0)
+	mainPkg := prog.Package(iprog.Created[0].Pkg)
+	mainPkg.Build()
+
+	var want []int
+	for _, c := range f.Comments {
+		if strings.TrimSpace(c.Text()) == "flow" {
+			want = append(want, iprog.Fset.Position(c.Pos()).Line)
+		}
+	}
+
+	config := &taint.Config{
+		Sources: []string{"main.readInput", "(main.Inputs).*"},
+		Sinks:   []string{"main.run", "main.move[0]"},
+	}
+	flows, err := taint.Analyze(config, &pointer.Config{Mains: []*ssa.Package{mainPkg}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var got []int
+	for _, flow := range flows {
+		got = append(got, prog.Fset.Position(flow.Pos).Line)
+		if first := flow.Path[0].Description; !strings.Contains(first, "source") {
+			t.Errorf("flow to line %d does not begin at a source: %q", got[len(got)-1], first)
+		}
+	}
+	sort.Ints(got)
+	if strings.Join(strs(got), " ") != strings.Join(strs(want), " ") {
+		t.Errorf("flows reach lines %v, want %v", got, want)
+		for _, flow := range flows {
+			t.Logf("flow from %s to %s:", flow.Source, flow.Sink)
+			for _, step := range flow.Path {
+				t.Logf("\t%s: %s", prog.Fset.Position(step.Pos), step.Description)
+			}
+		}
+	}
+}
+
+func TestBadPattern(t *testing.T) {
+	config := &taint.Config{Sinks: []string{"os.Exec[x]"}}
+	if _, err := taint.Analyze(config, &pointer.Config{}); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+}
+
+func strs(ints []int) []string {
+	var s []string
+	for _, i := range ints {
+		s = append(s, strconv.Itoa(i))
+	}
+	return s
+}
\ No newline at end of file
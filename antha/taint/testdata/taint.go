@@ -0,0 +1,77 @@
+// antha-tools/antha/taint/testdata/taint.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+// +build ignore
+
+package main
+
+// This file is the input to TestAnalyze in taint_test.go.  Its
+// source is readInput and the Inputs fields; its sinks are run and
+// the first argument of move.  Each call to a sink that untrusted
+// data reaches is commented "flow".
+
+type Inputs struct {
+	Name   string
+	Volume float64
+}
+
+var config struct {
+	cmd string
+}
+
+func readInput() string { return "" }
+
+func run(cmd string, args ...string) {}
+
+func move(steps int, why string) {}
+
+func identity(x string) string { return x }
+
+func main() {
+	s := readInput()
+	run("echo " + s) // flow
+	run("ls", "-l")
+	run("ls", s) // flow
+
+	var in Inputs
+	move(int(in.Volume)*2, "") // flow
+	move(0, in.Name)
+
+	c := make(chan string, 1)
+	c <- s
+	run(<-c) // flow
+
+	m := make(map[string]string)
+	m["k"] = s
+	run(m["k"]) // flow
+
+	p := &config
+	p.cmd = s
+	run(config.cmd) // flow
+
+	f := func() {
+		run(s) // flow
+	}
+	f()
+
+	run(identity(s)) // flow
+}
\ No newline at end of file
@@ -54,6 +54,14 @@ Write out an HTML file instead of launching a web browser:
 Display coverage percentages to stdout for each function:
 	go tool cover -func=c.out
 
+Show only the coverage of the tests whose names match a regular
+expression, in a profile that records it by test:
+	go tool cover -html=c.out -test=TestMix
+
+Merge the profiles of several runs, recording the coverage of each
+under the name given before its file:
+	go tool cover -merge -o c.out TestMix=mix.out TestDilute=dilute.out
+
 Finally, to generate modified source code with coverage annotations
 (what antha test -cover does):
 	go tool cover -mode=set -var=CoverageVariableName program.go
@@ -63,7 +71,7 @@ func usage() {
 	fmt.Fprintln(os.Stderr, usageMessage)
 	fmt.Fprintln(os.Stderr, "Flags:")
 	flag.PrintDefaults()
-	fmt.Fprintln(os.Stderr, "\n  Only one of -html, -func, or -mode may be set.")
+	fmt.Fprintln(os.Stderr, "\n  Only one of -html, -func, -merge or -mode may be set.")
 	os.Exit(2)
 }
 
@@ -73,6 +81,8 @@ var (
 	output  = flag.String("o", "", "file for output; default: stdout")
 	htmlOut = flag.String("html", "", "generate HTML representation of coverage profile")
 	funcOut = flag.String("func", "", "output coverage profile information for each function")
+	merge   = flag.Bool("merge", false, "merge the coverage profiles named by the arguments, each optionally prefixed by test=")
+	testRun = flag.String("test", "", "with -html or -func, show only the coverage of tests matching this regular expression")
 )
 
 var profile string // The profile to read; the value of -html or -func
@@ -106,8 +116,10 @@ func main() {
 		return
 	}
 
-	// Output HTML or function coverage information.
-	if *htmlOut != "" {
+	// Output HTML or function coverage information, or a merged profile.
+	if *merge {
+		err = mergeOutput(flag.Args(), *output)
+	} else if *htmlOut != "" {
 		err = htmlOutput(profile, *output)
 	} else {
 		err = funcOutput(profile, *output)
@@ -129,10 +141,23 @@ func parseFlags() error {
 		profile = *funcOut
 	}
 
+	if *merge {
+		if profile != "" || *mode != "" {
+			return fmt.Errorf("too many options")
+		}
+		if flag.NArg() == 0 {
+			return fmt.Errorf("missing profile")
+		}
+		return nil
+	}
+
 	// Must either display a profile or rewrite Go source.
 	if (profile == "") == (*mode == "") {
 		return fmt.Errorf("too many options")
 	}
+	if *testRun != "" && profile == "" {
+		return fmt.Errorf("-test requires -html or -func")
+	}
 
 	if *mode != "" {
 		switch *mode {
@@ -52,7 +52,7 @@ import (
 //	total:		(statements)		91.4%
 
 func funcOutput(profile, outputFile string) error {
-	profiles, err := cover.ParseProfiles(profile)
+	profiles, err := readProfiles(profile)
 	if err != nil {
 		return err
 	}
@@ -43,7 +43,7 @@ import (
 // coverage report, writing it to outfile. If outfile is empty,
 // it writes the report to a temporary file and opens it in a web browser.
 func htmlOutput(profile, outfile string) error {
-	profiles, err := cover.ParseProfiles(profile)
+	profiles, err := readProfiles(profile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,96 @@
+// antha-tools/cmd/cover/merge.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/cover"
+)
+
+// readProfiles reads the named profile, limited with -test to the
+// coverage of the matching tests.  A file that no test recorded, such
+// as one merged from an unnamed run, is shown as not run.
+func readProfiles(profile string) ([]*cover.Profile, error) {
+	profiles, err := cover.ParseProfiles(profile)
+	if err != nil || *testRun == "" {
+		return profiles, err
+	}
+	re, err := regexp.Compile(*testRun)
+	if err != nil {
+		return nil, fmt.Errorf("bad -test: %v", err)
+	}
+	for i, p := range profiles {
+		if profiles[i], err = p.ForTests(re.MatchString); err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+// mergeOutput merges the profiles named by args, each of which may be
+// given as test=profile to record its coverage as that of the test, and
+// writes the result to outputFile ("" means to write to standard
+// output).
+func mergeOutput(args []string, outputFile string) error {
+	var runs [][]*cover.Profile
+	for _, arg := range args {
+		test := ""
+		if eq := strings.Index(arg, "="); eq >= 0 {
+			test, arg = arg[:eq], arg[eq+1:]
+		}
+		profiles, err := cover.ParseProfiles(arg)
+		if err != nil {
+			return err
+		}
+		if test != "" {
+			cover.SetTest(profiles, test)
+		}
+		runs = append(runs, profiles)
+	}
+	profiles, err := cover.Merge(runs...)
+	if err != nil {
+		return err
+	}
+
+	var out *bufio.Writer
+	if outputFile == "" {
+		out = bufio.NewWriter(os.Stdout)
+	} else {
+		fd, err := os.Create(outputFile)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		out = bufio.NewWriter(fd)
+	}
+	if err := cover.WriteProfiles(out, profiles); err != nil {
+		return err
+	}
+	return out.Flush()
+}
\ No newline at end of file
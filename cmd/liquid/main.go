@@ -0,0 +1,162 @@
+// antha-tools/cmd/liquid/main.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// liquid: a tool for lowering Antha elements into a device-independent
+// list of liquid-handling instructions.
+//
+// Run with -help flag for usage information.
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/build"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/liquid"
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+)
+
+var funcFlag = flag.String("func", "Steps", "Name of the function to lower.")
+
+var inputsFlag = flag.String("inputs", "", "JSON file of an object giving the values of inputs by name.")
+
+var maxStepsFlag = flag.Int("maxsteps", 0, "Maximum number of SSA instructions to evaluate; 0 means the default.")
+
+// inputFlags holds the -input flags, in order.
+type inputFlags []string
+
+func (f *inputFlags) String() string     { return strings.Join(*f, ",") }
+func (f *inputFlags) Set(s string) error { *f = append(*f, s); return nil }
+
+var inputFlag inputFlags
+
+func init() {
+	flag.Var(&inputFlag, "input", "An input `name=value`; the value is JSON, or else a string.  May be repeated.")
+}
+
+const usage = `Lower an Antha element to liquid-handling instructions.
+Usage: liquid [<flag> ...] <args> ...
+Use -help flag to display options.
+
+liquid evaluates the Steps function of the element in the single
+package given, and prints the liquid-handling instructions it
+performs as a JSON array.  Inputs not given are unknown; it is an
+error if a volume, location or branch depends on one.  See the
+documentation of package antha/liquid for details.
+
+Examples:
+% liquid -input Wells=8 -input Row=B element.go
+% liquid -inputs=dilution.json example.com/elements/dilution
+` + loader.FromArgsUsage
+
+func main() {
+	if err := doMain(); err != nil {
+		fmt.Fprintf(os.Stderr, "liquid: %s.\n", err)
+		os.Exit(2)
+	}
+}
+
+func doMain() error {
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	config := liquid.DefaultConfig()
+	config.MaxSteps = *maxStepsFlag
+	if *inputsFlag != "" {
+		data, err := ioutil.ReadFile(*inputsFlag)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &config.Inputs); err != nil {
+			return fmt.Errorf("%s: %s", *inputsFlag, err)
+		}
+	}
+	for _, input := range inputFlag {
+		eq := strings.Index(input, "=")
+		if eq < 0 {
+			return fmt.Errorf("-input %q is not of the form name=value", input)
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(input[eq+1:]), &v); err != nil {
+			v = input[eq+1:]
+		}
+		config.Inputs[input[:eq]] = v
+	}
+
+	conf := loader.Config{
+		Build:         &build.Default,
+		SourceImports: true,
+	}
+	args, err := conf.FromArgs(args, false)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("surplus arguments: %q", args)
+	}
+
+	// Load, parse and type-check the whole program.
+	iprog, err := conf.Load()
+	if err != nil {
+		return err
+	}
+	initial := iprog.InitialPackages()
+	if len(initial) != 1 {
+		return fmt.Errorf("%d packages given, want exactly one", len(initial))
+	}
+
+	// Create and build SSA-form program representation.
+	prog := ssa.Create(iprog, 0)
+	prog.BuildAll()
+
+	pkg := prog.Package(initial[0].Pkg)
+	fn := pkg.Func(*funcFlag)
+	if fn == nil {
+		return fmt.Errorf("package %s has no function %s", pkg.Object.Path(), *funcFlag)
+	}
+	instrs, err := liquid.Lower(config, fn)
+	if err != nil {
+		return err
+	}
+	if instrs == nil {
+		instrs = []*liquid.Instruction{} // print [], not null
+	}
+
+	b, err := json.MarshalIndent(instrs, "", "\t")
+	if err != nil {
+		return fmt.Errorf("JSON error: %s", err)
+	}
+	os.Stdout.Write(b)
+	fmt.Println()
+	return nil
+}
\ No newline at end of file
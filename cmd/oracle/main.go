@@ -41,8 +41,10 @@ import (
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 
 	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/taint"
 	"github.com/antha-lang/antha-tools/oracle"
 )
 
@@ -58,6 +60,14 @@ var formatFlag = flag.String("format", "plain", "Output format.  One of {plain,j
 // TODO(adonovan): flip this flag after PTA presolver is implemented.
 var reflectFlag = flag.Bool("reflect", false, "Analyze reflection soundly (slow).")
 
+var sourcesFlag = flag.String("sources", "",
+	"Comma-separated patterns denoting the sources of untrusted data for 'taint' queries, "+
+		"e.g. os.Args,(*os.File).Read[1], or empty for the defaults.")
+
+var sinksFlag = flag.String("sinks", "",
+	"Comma-separated patterns denoting the arguments that untrusted data must not reach in 'taint' queries, "+
+		"e.g. os/exec.Command, or empty for the defaults.")
+
 const useHelp = "Run 'oracle -help' for more information.\n"
 
 const helpMessage = `Go source code oracle.
@@ -69,7 +79,10 @@ The -format flag controls the output format:
 	json	structured data in JSON syntax.
 	xml	structured data in XML syntax.
 
-The -pos flag is required in all modes except 'callgraph'.
+The -pos flag is required in all modes except 'callgraph' and 'taint'.
+
+The -sources and -sinks flags replace the default sources and sinks of
+'taint' queries.
 
 The mode argument determines the query to perform:
 
@@ -82,6 +95,8 @@ The mode argument determines the query to perform:
 	implements	show 'implements' relation for selected package
 	peers     	show send/receive corresponding to selected channel op
 	referrers 	show all refs to entity denoted by selected identifier
+	slice     	show statements that may affect the value of selected expression
+	taint     	show paths along which untrusted data reaches exec or device calls
 
 The user manual is available here:  http://golang.org/s/oracle-user-manual
 
@@ -180,8 +195,17 @@ func main() {
 		os.Exit(2)
 	}
 
+	// -sources and -sinks flags
+	taintConfig := taint.DefaultConfig()
+	if *sourcesFlag != "" {
+		taintConfig.Sources = strings.Split(*sourcesFlag, ",")
+	}
+	if *sinksFlag != "" {
+		taintConfig.Sinks = strings.Split(*sinksFlag, ",")
+	}
+
 	// Ask the oracle.
-	res, err := oracle.Query(args, mode, *posFlag, ptalog, &build.Default, *reflectFlag)
+	res, err := oracle.Query(args, mode, *posFlag, ptalog, &build.Default, *reflectFlag, taintConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s.\n", err)
 		os.Exit(1)
@@ -49,6 +49,8 @@
     (define-key m (kbd "C-c C-o d") #'go-oracle-definition)
     (define-key m (kbd "C-c C-o p") #'go-oracle-pointsto)
     (define-key m (kbd "C-c C-o s") #'go-oracle-callstack)
+    (define-key m (kbd "C-c C-o l") #'go-oracle-slice)
+    (define-key m (kbd "C-c C-o u") #'go-oracle-taint) ; u for untrusted
     (define-key m (kbd "C-c C-o <") #'go-oracle-callers)
     (define-key m (kbd "C-c C-o >") #'go-oracle-callees)
     m))
@@ -207,6 +209,18 @@ this channel receive/send operation."
   (interactive)
   (go-oracle--run "peers"))
 
+(defun go-oracle-slice ()
+  "Enumerate the statements that may affect the value of the
+selected expression."
+  (interactive)
+  (go-oracle--run "slice"))
+
+(defun go-oracle-taint ()
+  "Enumerate the paths along which untrusted data reaches exec or
+device-control calls in the package containing the current point."
+  (interactive)
+  (go-oracle--run "taint"))
+
 (defun go-oracle-referrers ()
   "Enumerate all references to the object denoted by the selected
 identifier."
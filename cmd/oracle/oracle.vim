@@ -105,3 +105,13 @@ command! -range=% GoOracleImplements
 " this channel receive/send operation.
 command! -range=% GoOracleChannelPeers
   \ call s:RunOracle('peers', <count>)
+
+" Show the statements that may affect the value of the selected
+" expression.
+command! -range=% GoOracleSlice
+  \ call s:RunOracle('slice', <count>)
+
+" Show the paths along which untrusted data reaches exec or
+" device-control calls in the package containing the current point.
+command! -range=% GoOracleTaint
+  \ call s:RunOracle('taint', <count>)
@@ -25,16 +25,20 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"github.com/antha-lang/antha/build"
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"sort"
 
 	"github.com/antha-lang/antha-tools/antha/loader"
 	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/escape"
 	"github.com/antha-lang/antha-tools/antha/ssa/interp"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
@@ -48,6 +52,7 @@ S	log [S]ource locations as SSA builder progresses.
 G	use binary object files from gc to provide imports (no code).
 L	build distinct packages seria[L]ly instead of in parallel.
 N	build [N]aive SSA form: don't replace local loads/stores with registers.
+O	[O]ptimize function bodies: propagate constants and copies, eliminate dead code.
 `)
 
 var testFlag = flag.Bool("test", false, "Loads test code (*_test.go) for imported packages.")
@@ -60,6 +65,12 @@ R	disable [R]ecover() from panic; show interpreter crash instead.
 T	[T]race execution of the program.  Best for single-threaded programs!
 `)
 
+var escapeFlag = flag.Bool("escape", false, "Print the SSA code of the initial packages, annotated with the results of escape analysis.")
+
+var recordFlag = flag.String("record", "", "Record the interpreted program's nondeterministic events to the named trace file.")
+
+var replayFlag = flag.String("replay", "", "Replay the execution recorded in the named trace file.")
+
 const usage = `SSA builder and interpreter.
 Usage: ssadump [<flag> ...] <args> ...
 Use -help flag to display options.
@@ -67,7 +78,10 @@ Use -help flag to display options.
 Examples:
 % ssadump -build=FPG hello.go            # quickly dump SSA form of a single package
 % ssadump -run -interp=T hello.go        # interpret a program, with tracing
+% ssadump -escape hello.go               # show which allocations escape, and why
 % ssadump -run -test unicode -- -test.v  # interpret the unicode package's tests, verbosely
+% ssadump -run -record=run.trace prog.go # interpret a program, recording a trace
+% ssadump -run -replay=run.trace prog.go # reproduce the recorded execution
 ` + loader.FromArgsUsage +
 	`
 When -run is specified, ssadump will run the program.
@@ -132,6 +146,8 @@ func doMain() error {
 			mode |= ssa.SanityCheckFunctions
 		case 'N':
 			mode |= ssa.NaiveForm
+		case 'O':
+			mode |= ssa.OptimizeFunctions
 		case 'G':
 			conf.SourceImports = false
 		case 'L':
@@ -190,6 +206,12 @@ func doMain() error {
 	prog := ssa.Create(iprog, mode)
 	prog.BuildAll()
 
+	if *escapeFlag {
+		for _, info := range iprog.InitialPackages() {
+			printEscapes(prog.Package(info.Pkg))
+		}
+	}
+
 	// Run the interpreter.
 	if *runFlag {
 		var main *ssa.Package
@@ -223,7 +245,65 @@ func doMain() error {
 				build.Default.GOARCH, runtime.GOARCH)
 		}
 
-		interp.Interpret(main, interpMode, conf.TypeChecker.Sizes, main.Object.Path(), args)
+		sizes := conf.TypeChecker.Sizes
+		switch {
+		case *recordFlag != "" && *replayFlag != "":
+			return fmt.Errorf("cannot both -record and -replay")
+
+		case *recordFlag != "":
+			f, err := os.Create(*recordFlag)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			interp.Record(f, main, interpMode, sizes, main.Object.Path(), args)
+
+		case *replayFlag != "":
+			f, err := os.Open(*replayFlag)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			interp.Replay(f, main, interpMode, sizes, main.Object.Path(), args)
+
+		default:
+			interp.Interpret(main, interpMode, sizes, main.Object.Path(), args)
+		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// printEscapes prints the SSA code of the functions of pkg, in order
+// of position, annotating each Alloc with whether it escapes.
+func printEscapes(pkg *ssa.Package) {
+	result := escape.AnalyzePackage(pkg)
+	note := func(instr ssa.Instruction) string {
+		alloc, ok := instr.(*ssa.Alloc)
+		if !ok {
+			return ""
+		}
+		if e := result.Escape(alloc); e != nil {
+			return "escapes: " + e.Description(alloc.Parent())
+		}
+		return "does not escape"
+	}
+
+	var fns []*ssa.Function
+	for fn := range ssautil.AllFunctions(pkg.Prog) {
+		if fn.Pkg == pkg && fn.Blocks != nil && fn.Synthetic == "" {
+			fns = append(fns, fn)
+		}
+	}
+	sort.Sort(byPos(fns))
+	var buf bytes.Buffer
+	for _, fn := range fns {
+		ssa.WriteFunctionNotes(&buf, fn, note)
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+type byPos []*ssa.Function
+
+func (p byPos) Len() int           { return len(p) }
+func (p byPos) Less(i, j int) bool { return p[i].Pos() < p[j].Pos() }
+func (p byPos) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
\ No newline at end of file
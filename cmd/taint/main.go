@@ -0,0 +1,224 @@
+// antha-tools/cmd/taint/main.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// taint: a tool for finding the paths along which untrusted data
+// reaches exec or device-control calls in Go programs.
+//
+// Run with -help flag for usage information.
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/build"
+	"github.com/antha-lang/antha/token"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/pointer"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/taint"
+	"github.com/antha-lang/antha-tools/oracle/serial"
+)
+
+var sourceFlag = flag.String("source", "",
+	"Comma-separated list of additional source patterns, e.g. '(main.Inputs).*'.")
+
+var sinkFlag = flag.String("sink", "",
+	"Comma-separated list of additional sink patterns, e.g. 'example.com/driver.Move[1]'.")
+
+var nodefaultsFlag = flag.Bool("nodefaults", false,
+	"Don't use the default sources (command line, environment, file and network reads) and sinks (exec and system calls).")
+
+var formatFlag = flag.String("format", "plain", "Output format.  One of {plain,json}.")
+
+var testFlag = flag.Bool("test", false, "Loads test code (*_test.go) for imported packages.")
+
+var reflectFlag = flag.Bool("reflect", false, "Analyze reflection soundly (slow).")
+
+const usage = `Taint analysis for Go programs.
+Usage: taint [<flag> ...] <args> ...
+Use -help flag to display options.
+
+taint reports each path along which data from a source may reach a
+sink.  Patterns are matched against the names of functions, global
+variables and struct fields, and may be followed by an argument
+index; see the documentation of package antha/taint for details.
+The exit status is 1 if any path is found.
+
+Examples:
+% taint prog.go                                    # default sources and sinks
+% taint -source='(main.Inputs).*' -sink='example.com/driver.*' prog.go
+% taint -format=json -test example.com/element    # analyse an element's tests
+` + loader.FromArgsUsage
+
+func init() {
+	// If $GOMAXPROCS isn't set, use the full capacity of the machine.
+	// For small machines, use at least 4 threads.
+	if os.Getenv("GOMAXPROCS") == "" {
+		n := runtime.NumCPU()
+		if n < 4 {
+			n = 4
+		}
+		runtime.GOMAXPROCS(n)
+	}
+}
+
+func main() {
+	flows, err := doMain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "taint: %s.\n", err)
+		os.Exit(2)
+	}
+	if len(flows) > 0 {
+		os.Exit(1)
+	}
+}
+
+func doMain() ([]*taint.Flow, error) {
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch *formatFlag {
+	case "json", "plain":
+		// ok
+	default:
+		return nil, fmt.Errorf("illegal -format value: %q", *formatFlag)
+	}
+
+	config := new(taint.Config)
+	if !*nodefaultsFlag {
+		config = taint.DefaultConfig()
+	}
+	config.Sources = append(config.Sources, splitPatterns(*sourceFlag)...)
+	config.Sinks = append(config.Sinks, splitPatterns(*sinkFlag)...)
+
+	conf := loader.Config{
+		Build:         &build.Default,
+		SourceImports: true,
+	}
+	args, err := conf.FromArgs(args, *testFlag)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("surplus arguments: %q", args)
+	}
+
+	// Load, parse and type-check the whole program.
+	iprog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and build SSA-form program representation.
+	prog := ssa.Create(iprog, ssa.GlobalDebug)
+	prog.BuildAll()
+
+	// The analysis starts from the main packages, or the tests.
+	ptaConfig := &pointer.Config{Reflection: *reflectFlag}
+	for _, info := range iprog.InitialPackages() {
+		pkg := prog.Package(info.Pkg)
+		if *testFlag {
+			if main := prog.CreateTestMainPackage(pkg); main != nil {
+				ptaConfig.Mains = append(ptaConfig.Mains, main)
+			}
+		} else if pkg.Func("main") != nil {
+			ptaConfig.Mains = append(ptaConfig.Mains, pkg)
+		}
+	}
+	if len(ptaConfig.Mains) == 0 {
+		return nil, fmt.Errorf("no main packages")
+	}
+
+	flows, err := taint.Analyze(config, ptaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Print the flows.
+	switch *formatFlag {
+	case "json":
+		var res []serial.TaintFlow
+		for _, flow := range flows {
+			var steps []serial.TaintStep
+			for _, step := range flow.Path {
+				steps = append(steps, serial.TaintStep{
+					Pos:  prog.Fset.Position(step.Pos).String(),
+					Desc: step.Description,
+				})
+			}
+			res = append(res, serial.TaintFlow{
+				Source: flow.Source,
+				Sink:   flow.Sink,
+				Pos:    prog.Fset.Position(flow.Pos).String(),
+				Steps:  steps,
+			})
+		}
+		b, err := json.MarshalIndent(res, "", "\t")
+		if err != nil {
+			return nil, fmt.Errorf("JSON error: %s", err)
+		}
+		os.Stdout.Write(b)
+
+	case "plain":
+		for _, flow := range flows {
+			printf(prog, flow.Pos, "%s reaches %s", flow.Source, flow.Sink)
+			for _, step := range flow.Path {
+				printf(prog, step.Pos, "\t%s", step.Description)
+			}
+		}
+	}
+	return flows, nil
+}
+
+// printf prints a line in the "pos: text" format of the oracle, where
+// pos is "-" if unknown.
+func printf(prog *ssa.Program, pos token.Pos, format string, args ...interface{}) {
+	if pos.IsValid() {
+		fmt.Printf("%s: ", prog.Fset.Position(pos))
+	} else {
+		fmt.Print("-: ")
+	}
+	fmt.Printf(format, args...)
+	fmt.Println()
+}
+
+// splitPatterns splits a comma-separated list of patterns.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
\ No newline at end of file
@@ -28,7 +28,7 @@ calls whose arguments do not align with the format string. Vet uses heuristics
 that do not guarantee all reports are genuine problems, but it can find errors
 not caught by the compilers.
 
-It can be invoked three ways:
+It can be invoked four ways:
 
 By package, from the antha tool:
 	go vet package/path/name
@@ -40,8 +40,23 @@ vets the files named, all of which must be in the same package.
 
 By directory:
 	go tool vet source/directory
-recursively descends the directory, vetting each file in isolation.
-Package-level type-checking is disabled, so the vetting is weaker.
+recursively descends the directory, vetting each package found, but
+skipping directories named testdata or beginning with "." or "_".
+
+By import path:
+	go tool vet package/path/name
+	go tool vet package/path/...
+vets the package whose import path is provided or, given the /...
+suffix, the packages in and below its directory.
+
+All the packages named are type-checked together, so that each of
+their dependencies is loaded only once.  By default the dependencies
+are loaded from the export data of compiled packages, which must be
+up to date; with -source they are loaded from source instead, so that
+checks that need type information, such as printf, copylocks and
+unsafeptr, work in a fresh checkout without a prior build.  With
+-p=n, n packages are checked at a time, and the problems of different
+packages may be reported in any order.
 
 Vet's exit code is 2 for erroneous invocation of the tool, 1 if a
 problem was reported, and 0 otherwise. Note that the tool does not
@@ -128,29 +143,108 @@ Flag: -copylocks
 
 Locks that are erroneously passed by value.
 
+WaitGroup counting
+
+Flag: -waitgroup
+
+Calls of the Add method of a sync.WaitGroup inside the function
+literal started by a go statement.  The goroutine may not run before
+Wait is called, so Add should be called before the go statement.
+
+Guarded fields
+
+Flag: -guardedby
+
+Accesses to a struct field whose comment says it is guarded by a mutex
+field of the same struct, as in
+
+	items []int // guarded by mu
+
+made without holding the mutex.  Within a function the mutex is held
+from a call of Lock or RLock on it to the next call of Unlock or
+RUnlock that is not deferred; an unlock in a block that ends by
+returning or branching lasts only to the end of the block.  A function
+literal called where it is written holds the locks held at the call,
+and a deferred one those held at the end of the function.  Functions
+whose names end in Locked are not checked, nor are accesses through
+variables declared in the function.  A function whose comment says
+
+	// mu must be held.
+
+may access the fields guarded by mu; the mutex may also be named in
+full, as in "q.mu must be held".
+
+Lost cancel functions
+
+Flag: -lostcancel
+
+Cancel functions returned by context.WithCancel, WithDeadline or
+WithTimeout that are discarded by assigning them to the blank
+identifier, so that the context is not released until its parent is
+canceled.
+
 Nil function comparison
 
 Flag: -nilfunc
 
 Comparisons between functions and nil.
 
+Nil dereferences
+
+Flag: -nilness
+
+Dereferences, map writes, and calls of function and interface values
+that panic because the value is nil.  The check follows comparisons
+with nil along the package's SSA form: an operation on a value known
+to be nil is reported, as is one on a value that is nil on some paths,
+that is compared with nil elsewhere in the function, or that is a
+pointer field of an element's Inputs struct that is never checked.
+
 Range loop variables
 
 Flag: -rangeloops
 
 Incorrect uses of range loop variables in closures.
 
+Loop variables in goroutines
+
+Flag: -loopclosure
+
+Loop variables captured by the function literal of a go statement that
+ends an iteration, in the cases that -rangeloops does not cover: the
+variables of three-clause for loops, and go statements at the end of
+an if, switch or select statement that ends the loop body.
+
 Unreachable code
 
 Flag: -unreachable
 
 Unreachable code.
 
+Unchecked errors
+
+Flag: -errcheck
+
+Calls whose results include an error that is discarded, because the
+call is a statement of its own or the error is assigned to the blank
+identifier.  Deferred calls and go statements are not checked.  Errors
+from fmt.Print, Printf and Println, from methods called on os.Stdout or
+os.Stderr, from the Fprint functions when they print to os.Stdout,
+os.Stderr, a bytes.Buffer, a strings.Builder or a tabwriter.Writer, and
+from the Write methods of bytes.Buffer may be discarded, as may those
+of the functions listed by -errfuncs.  A deliberately discarded error
+may be marked with a //vet:ignore errcheck comment.
+
 Shadowed variables
 
-Flag: -shadow=false (experimental; must be set explicitly)
+Flag: -shadow
 
-Variables that may have been unintentionally shadowed.
+Variables that may have been unintentionally shadowed: a variable
+declared in an inner scope, such as the init statement of an if or
+for, with the same name and type as a variable of the same function
+that is mentioned after the inner declaration, including by a naked
+return or on the next iteration of an enclosing loop.  With
+-shadowstrict the outer variable need not be mentioned again.
 
 Misuse of unsafe Pointers
 
@@ -162,6 +256,89 @@ there is a uintptr-typed word in memory that holds a pointer value,
 because that word will be invisible to stack copying and to the garbage
 collector.
 
+Pipette volumes
+
+Flag: -volumes
+
+Arguments of numeric parameters whose names end in "volume" or
+"concentration", disregarding case, that may be negative, or that may
+exceed the constant MaxVolume or MaxConcentration declared by the
+callee's package.  The range of each argument is computed by interval
+analysis of the package's SSA form; arguments whose range is unknown
+are not reported.  Quantities are recognized by name alone, so the
+check is a heuristic with known false negatives: a volume passed to a
+parameter named otherwise, such as amount, is not checked, nor is a
+limit declared under another name.
+
+Element blocks
+
+Flag: -elements
+
+Mistakes in the interface of an element, whose package declares the
+struct types Parameters, Inputs, Outputs and Data and the phases Setup,
+Steps, Analysis and Validation: a field of Parameters or Inputs that
+no phase reads, a field of Outputs that no phase sets, and a field of
+Data that is set but unexported, and so hidden from the element's
+consumers.  Functions of the package called by a phase count as part
+of it; a block passed elsewhere is assumed to use all of its fields.
+
+Custom checks
+
+Checks are registered with the library package antha-tools/vet,
+which implements this command.  A lab may build its own vet binary,
+with additional checks, from a main package that imports the
+packages registering them and calls vet.Main; see that package for
+details.  Each additional check is enabled by a flag of its name.
+
+Suppressing problems
+
+A problem that is known and accepted may be suppressed by a comment
+of the form
+
+	//vet:ignore check[,check...] reason
+
+on the line before a statement, declaration, specification or struct
+field, or at the end of its first line.  It suppresses the problems
+that the named checks report on the lines spanned by that statement
+or declaration.
+
+Alternatively, the problems of existing code may be recorded in a
+baseline file by -writebaseline=file, and suppressed by
+-baseline=file, so that only new problems are reported and set the
+exit code.  Each line of the file holds the name of the source file,
+the name of the check and the message, separated by tabs; line
+numbers are omitted so that entries survive unrelated edits.  An
+entry suppresses one problem, so a new instance of a recorded problem
+in the same file is still reported.
+
+Machine-readable output
+
+By default vet reports each problem on standard error as
+file:line: message.  With -json it instead writes to standard output
+a JSON array of the problems found, each an object with the name of
+the check, its severity ("error", which sets the exit code, or
+"warning"), its start and, where known, end position, its message,
+and an optional suggested fix: a list of edits, each replacing the
+text between two positions.  With -sarif it writes the same problems
+as a SARIF 2.1.0 log, whose rules are the checks that were run.  The
+exit code is unaffected.
+
+Fixing problems
+
+Some checks suggest a fix for the problems they find: composites adds
+the field keys of the struct type, structtags rewrites a tag in
+canonical form where its meaning is clear, rangeloops copies the range
+variables at the start of the loop body, assign removes the
+self-assignment and unreachable deletes the unreachable statements.
+
+With -fix vet applies these fixes to the files in place, reformatting
+the declarations it changes with antha/printer, and reports only the
+problems it could not fix.  With -diff it instead reports every
+problem and writes the fixes to standard output as unified diffs,
+leaving the files unchanged.  A fix may leave code that no longer
+compiles, such as a variable whose only use was unreachable, so the
+result should be reviewed.
+
 Other flags
 
 These flags configure the behavior of vet:
@@ -170,6 +347,23 @@ These flags configure the behavior of vet:
 		Check everything; disabled if any explicit check is requested.
 	-v
 		Verbose mode
+	-source
+		Load imported packages from source rather than export data.
+	-p
+		The number of packages to check in parallel; default 1.
+	-json
+		Write the problems found to standard output as JSON.
+	-sarif
+		Write the problems found to standard output as SARIF.
+	-fix
+		Apply the suggested fixes to the files.
+	-diff
+		Display the suggested fixes as diffs instead of applying them.
+	-baseline
+		Suppress the problems recorded in the named baseline file.
+	-writebaseline
+		Record the problems found in the named baseline file
+		instead of reporting them.
 	-printfuncs
 		A comma-separated list of print-like functions to supplement
 		the standard list.  Each entry is in the form Name:N where N
@@ -179,6 +373,12 @@ These flags configure the behavior of vet:
 		if you have Warn and Warnf functions that take an
 		io.Writer as their first argument, like Fprintf,
 			-printfuncs=Warn:1,Warnf:1
+	-errfuncs
+		A comma-separated list of functions whose errors may be
+		discarded, in addition to the standard list.  Each entry is a
+		name, matching any function or method of that name; a
+		package-qualified name such as io.Copy; or a method such as
+		(*os.File).Close.
 	-shadowstrict
 		Whether to be strict about shadowing; can be noisy.
 	-test
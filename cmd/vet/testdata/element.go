@@ -0,0 +1,92 @@
+// antha-tools/cmd/vet/testdata/element.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the element checker.  It shares the
+// Inputs block with nilness.go.
+
+package testdata
+
+type Parameters struct {
+	Volume  float64
+	Wells   []string
+	Factor  int
+	Spare   int // ERROR "Parameters.Spare is never used"
+	Options *Options
+}
+
+type Options struct {
+	Mix bool
+}
+
+type Outputs struct {
+	Mixed   Component
+	Wells   []string
+	Count   int
+	Waste   *Component // ERROR "Outputs.Waste is never set"
+	Reports []string
+}
+
+type Data struct {
+	Yield   float64
+	Ratio   float64
+	scratch float64 // ERROR "Data.scratch is set but not exported"
+	unset   float64
+}
+
+type Element struct {
+	Name string
+}
+
+func (e *Element) Setup(p *Parameters) {
+	e.Name = "mix"
+}
+
+func (e *Element) Steps(p *Parameters, in *Inputs, out *Outputs) {
+	out.Mixed = in.Sample
+	out.Mixed.Volume = p.Volume * in.Volumes[in.Sample.Name]
+	if in.Buffer != nil {
+		out.Mixed.Volume += in.Buffer.Volume
+	}
+	for i, w := range p.Wells {
+		out.Wells[i] = w
+	}
+	out.Count += factor(p)
+	report(out)
+}
+
+func (e *Element) Analysis(p *Parameters, out *Outputs, d *Data) {
+	d.scratch = out.Mixed.Volume
+	*d = Data{Yield: d.scratch, Ratio: 1}
+}
+
+func (e *Element) Validation(p *Parameters, d *Data) bool {
+	return p.Options.Mix && d.Yield > 0
+}
+
+func factor(p *Parameters) int {
+	return p.Factor
+}
+
+func report(out *Outputs) {
+	out.Reports = append(out.Reports, "done")
+}
\ No newline at end of file
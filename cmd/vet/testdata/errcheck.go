@@ -0,0 +1,58 @@
+// antha-tools/cmd/vet/testdata/errcheck.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the errcheck checker.
+
+package testdata
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+type Head struct{}
+
+func (p *Head) Aspirate(volume float64) error            { return nil }
+func (p *Head) Dispense(volume float64) (float64, error) { return volume, nil }
+
+func ErrCheckTests(p *Head, f *os.File) error {
+	p.Aspirate(10)             // ERROR "error returned by p.Aspirate is not checked"
+	_ = p.Aspirate(10)         // ERROR "error returned by p.Aspirate is not checked"
+	v, _ := p.Dispense(10)     // ERROR "error returned by p.Dispense is not checked"
+	v, _ = p.Dispense(v), 1    // OK: not an error
+	f.Close()                  // ERROR "error returned by f.Close is not checked"
+	fmt.Fprintln(f, v)         // ERROR "error returned by fmt.Fprintln is not checked"
+	fmt.Fprintln(os.Stderr, v) // OK: standard error
+	fmt.Println(v)             // OK: allowed
+	var b bytes.Buffer
+	b.WriteString("ok")        // OK: allowed
+	fmt.Fprintf(&b, "%g", v)   // OK: a buffer
+	os.Stdout.Write(b.Bytes()) // OK: standard output
+	f.Write(b.Bytes())         // ERROR "error returned by f.Write is not checked"
+	if err := p.Aspirate(v); err != nil {
+		return err
+	}
+	_, err := p.Dispense(v)
+	return err
+}
\ No newline at end of file
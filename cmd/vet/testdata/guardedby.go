@@ -0,0 +1,122 @@
+// antha-tools/cmd/vet/testdata/guardedby.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the guardedby checker.
+
+package testdata
+
+import "sync"
+
+type Queue struct {
+	mu    sync.Mutex
+	items []int // guarded by mu
+	// count is guarded by mu.
+	count int
+	name  string
+}
+
+func NewQueue(name string) *Queue {
+	q := &Queue{name: name}
+	q.items = make([]int, 0, 8) // OK: not yet shared
+	return q
+}
+
+func (q *Queue) Push(x int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, x) // OK
+	q.count++                    // OK
+}
+
+func (q *Queue) Pop() (int, bool) {
+	q.mu.Lock()
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return 0, false
+	}
+	x := q.items[0] // OK: the unlock above returns
+	q.items = q.items[1:]
+	q.mu.Unlock()
+	q.count-- // ERROR "q.count is guarded by q.mu but accessed without holding it"
+	return x, true
+}
+
+func (q *Queue) Len() int {
+	return len(q.items) // ERROR "q.items is guarded by q.mu but accessed without holding it"
+}
+
+func (q *Queue) Name() string {
+	return q.name // OK: not guarded
+}
+
+func (q *Queue) lenLocked() int {
+	return len(q.items) // OK: called with q.mu held
+}
+
+func (q *Queue) Drain() {
+	q.mu.Lock()
+	go func() {
+		q.items = nil // ERROR "q.items is guarded by q.mu but accessed without holding it"
+	}()
+	q.mu.Unlock()
+}
+
+func (q *Queue) Reset() {
+	q.mu.Lock()
+	defer func() {
+		q.count = 0 // OK: runs before the unlock
+		q.mu.Unlock()
+	}()
+	func() {
+		q.items = nil // OK: called with q.mu held
+	}()
+}
+
+func (q *Queue) Clear() {
+	q.mu.Lock()
+	n := len(q.items)
+	q.mu.Unlock()
+	func() {
+		q.count -= n // ERROR "q.count is guarded by q.mu but accessed without holding it"
+	}()
+}
+
+// size returns the number of items.  q.mu must be held.
+func (q *Queue) size() int {
+	return len(q.items) // OK: annotated
+}
+
+// first returns the first item.  mu must be held.
+func (q *Queue) first() int {
+	return q.items[0] // OK: annotated by field name
+}
+
+// total returns the count, which is held in a field.
+func (q *Queue) total() int {
+	return q.count // ERROR "q.count is guarded by q.mu but accessed without holding it"
+}
+
+// peek returns the first item.  other.mu must be held.
+func (q *Queue) peek() int {
+	return q.items[0] // ERROR "q.items is guarded by q.mu but accessed without holding it"
+}
\ No newline at end of file
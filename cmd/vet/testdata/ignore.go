@@ -0,0 +1,48 @@
+// antha-tools/cmd/vet/testdata/ignore.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for vet:ignore comments.
+
+package testdata
+
+func IgnoreStatements(s *ST, x int) {
+	//vet:ignore assign kept to exercise the suppression of a statement
+	x = x
+	s.x = s.x //vet:ignore rangeloops,assign a trailing comment
+	x = x     // ERROR "self-assignment of x to x"
+	//vet:ignore printf names another check
+	x = x // ERROR "self-assignment of x to x"
+
+	// A comment group may hold the directive
+	// on any line.
+	//vet:ignore assign the whole statement is covered
+	if x > 0 {
+		x = x
+	}
+	x = x // ERROR "self-assignment of x to x"
+}
+
+//vet:ignore assign a declaration covers its body
+func IgnoreDeclaration(x int) {
+	x = x
+}
\ No newline at end of file
@@ -0,0 +1,66 @@
+// antha-tools/cmd/vet/testdata/loopclosure.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the loopclosure checker.
+
+package testdata
+
+func LoopClosureTests(s []int, ready []bool) {
+	for i := 0; i < len(s); i++ {
+		go func() {
+			println(i) // ERROR "loop variable i captured by func literal in go statement"
+		}()
+	}
+	for i, j := 0, len(s); i < j; i++ {
+		if ready[i] {
+			go func() {
+				println(s[i], j) // ERROR "loop variable i captured by func literal in go statement" "loop variable j captured by func literal in go statement"
+			}()
+		} else {
+			go func(i int) {
+				println(i) // OK: a parameter
+			}(i)
+		}
+	}
+	for i, v := range s {
+		switch {
+		case ready[i]:
+			go func() {
+				println(v) // ERROR "loop variable v captured by func literal in go statement"
+			}()
+		}
+	}
+	for i, v := range s {
+		go func() {
+			println(i, v) // ERROR "range variable i enclosed by function" "range variable v enclosed by function"
+		}()
+	}
+	for i := 0; i < len(s); i++ {
+		go func() {
+			println(i) // OK: waited for
+		}()
+		wait()
+	}
+}
+
+func wait() {}
\ No newline at end of file
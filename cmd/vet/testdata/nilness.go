@@ -0,0 +1,59 @@
+// antha-tools/cmd/vet/testdata/nilness.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the nilness checker.
+
+package testdata
+
+type Component struct {
+	Name   string
+	Volume float64
+}
+
+type Inputs struct {
+	Sample  Component
+	Buffer  *Component // optional
+	Volumes map[string]float64
+}
+
+func Nilness(in *Inputs, c *Component) float64 {
+	v := in.Sample.Volume
+	if in.Buffer != nil {
+		v += in.Buffer.Volume
+	}
+	v += in.Buffer.Volume // ERROR "possible nil dereference in field selection: field Buffer is compared with nil elsewhere"
+
+	if c == nil {
+		return c.Volume // ERROR "nil dereference in field selection"
+	}
+
+	var m map[string]float64
+	if in.Volumes == nil {
+		m["sample"] = v // ERROR "nil map write"
+	}
+	return v
+}
+
+func OptionalInput(in *Inputs) float64 {
+	return in.Buffer.Volume // ERROR "possible nil dereference in field selection: optional input Inputs.Buffer is not checked"
+}
\ No newline at end of file
@@ -36,7 +36,7 @@ func ShadowRead(f *os.File, buf []byte) (err error) {
 		_ = err
 	}
 	if f != nil {
-		_, err := f.Read(buf) // ERROR "declaration of err shadows declaration at testdata/shadow.go:13"
+		_, err := f.Read(buf) // ERROR "declaration of err shadows declaration at testdata/shadow.go:32"
 		if err != nil {
 			return err
 		}
@@ -44,7 +44,7 @@ func ShadowRead(f *os.File, buf []byte) (err error) {
 		_ = i
 	}
 	if f != nil {
-		var _, err = f.Read(buf) // ERROR "declaration of err shadows declaration at testdata/shadow.go:13"
+		var _, err = f.Read(buf) // ERROR "declaration of err shadows declaration at testdata/shadow.go:32"
 		if err != nil {
 			return err
 		}
@@ -64,10 +64,50 @@ func ShadowRead(f *os.File, buf []byte) (err error) {
 	if shadowTemp := shadowTemp; true { // OK: obviously intentional idiomatic redeclaration
 		var f *os.File // OK because f is not mentioned later in the function.
 		// The declaration of x is a shadow because x is mentioned below.
-		var x int // ERROR "declaration of x shadows declaration at testdata/shadow.go:14"
+		var x int // ERROR "declaration of x shadows declaration at testdata/shadow.go:33"
 		_, _, _ = x, f, shadowTemp
 	}
 	// Use a couple of variables to trigger shadowing errors.
 	_, _ = err, x
 	return
+}
+
+func ShadowInit(f *os.File, buf []byte) error {
+	var err error
+	if _, err := f.Read(buf); err != nil { // ERROR "declaration of err shadows declaration at testdata/shadow.go:76"
+		return err
+	}
+	for err := f.Close(); err != nil; err = nil { // ERROR "declaration of err shadows declaration at testdata/shadow.go:76"
+		println(err)
+	}
+	return err
+}
+
+func ShadowLoop(s []int) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if n > s[i] {
+			break
+		}
+		// The next iteration mentions n above.
+		n := s[i] // ERROR "declaration of n shadows declaration at testdata/shadow.go:87"
+		_ = n
+	}
+}
+
+func ShadowNakedReturn(f *os.File, buf []byte) (n int, err error) {
+	if f != nil {
+		_, err := f.Read(buf) // ERROR "declaration of err shadows declaration at testdata/shadow.go:98"
+		println(err)
+	}
+	return
+}
+
+func ShadowFuncLit(f *os.File) error {
+	err := f.Close()
+	go func() {
+		err := f.Close() // OK: a function literal has its own variables.
+		_ = err
+	}()
+	return err
 }
\ No newline at end of file
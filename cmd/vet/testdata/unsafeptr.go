@@ -46,9 +46,9 @@ func f() {
 	var v reflect.Value
 	x = unsafe.Pointer(v.Pointer())
 	x = unsafe.Pointer(v.UnsafeAddr())
-	var s1 *reflect.StringHeader
+	s1 := new(reflect.StringHeader)
 	x = unsafe.Pointer(s1.Data)
-	var s2 *reflect.SliceHeader
+	s2 := new(reflect.SliceHeader)
 	x = unsafe.Pointer(s2.Data)
 	var s3 reflect.StringHeader
 	x = unsafe.Pointer(s3.Data) // ERROR "possible misuse of unsafe.Pointer"
@@ -59,9 +59,9 @@ func f() {
 	var vv V
 	x = unsafe.Pointer(vv.Pointer())    // ERROR "possible misuse of unsafe.Pointer"
 	x = unsafe.Pointer(vv.UnsafeAddr()) // ERROR "possible misuse of unsafe.Pointer"
-	var ss1 *StringHeader
+	ss1 := new(StringHeader)
 	x = unsafe.Pointer(ss1.Data) // ERROR "possible misuse of unsafe.Pointer"
-	var ss2 *SliceHeader
+	ss2 := new(SliceHeader)
 	x = unsafe.Pointer(ss2.Data) // ERROR "possible misuse of unsafe.Pointer"
 
 }
@@ -0,0 +1,63 @@
+// antha-tools/cmd/vet/testdata/volumes.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the volumes checker.
+
+package testdata
+
+// MaxVolume is the capacity of the pipette, in microlitres.
+const MaxVolume = 200
+
+type Pipette struct{}
+
+func (p *Pipette) Transfer(from, to string, volume float64) {}
+
+func Dilute(stock string, concentration float64) {}
+
+func transferAll(p *Pipette, total float64, n int) {
+	if n <= 0 {
+		return
+	}
+	p.Transfer("a", "b", total/float64(n)) // ERROR "volume argument of Transfer may exceed MaxVolume \(200\): range is \[25, 500\]"
+}
+
+func Volumes(p *Pipette, v float64) {
+	p.Transfer("a", "b", 50)
+	p.Transfer("a", "b", 250)   // ERROR "volume argument of Transfer may exceed MaxVolume \(200\): range is \[250, 250\]"
+	p.Transfer("a", "b", 50-60) // ERROR "volume argument of Transfer may be negative: range is \[-10, -10\]"
+	p.Transfer("a", "b", v)     // unknown: no report
+
+	if v > 0 && v < 100 {
+		p.Transfer("a", "b", v)
+		p.Transfer("a", "b", v*3)  // ERROR "volume argument of Transfer may exceed MaxVolume \(200\): range is \[0, 300\]"
+		p.Transfer("a", "b", v-10) // ERROR "may be negative: range is \[-10, 90\]"
+	}
+
+	for i := 0; i < 8; i++ {
+		p.Transfer("a", "b", float64(i)*25)
+		Dilute("x", float64(i)-1) // ERROR "concentration argument of Dilute may be negative: range is \[-1, 6\]"
+	}
+
+	transferAll(p, 100, 4)
+	transferAll(p, 1000, 2) // the report is within transferAll
+}
\ No newline at end of file
@@ -0,0 +1,59 @@
+// antha-tools/cmd/vet/testdata/waitgroup.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains tests for the waitgroup checker.
+
+package testdata
+
+import "sync"
+
+func WaitGroupTests(jobs []func()) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		go func(job func()) {
+			wg.Add(1) // ERROR "sync.WaitGroup.Add called inside the goroutine it waits for"
+			defer wg.Done()
+			job()
+		}(job)
+	}
+	wg.Wait()
+
+	p := &wg
+	for _, job := range jobs {
+		p.Add(1) // OK
+		go func(job func()) {
+			defer p.Done()
+			job()
+		}(job)
+	}
+	p.Wait()
+
+	go func() {
+		var inner sync.WaitGroup
+		inner.Add(1) // ERROR "sync.WaitGroup.Add called inside the goroutine it waits for"
+		go func() {
+			defer inner.Done()
+		}()
+		inner.Wait()
+	}()
+}
\ No newline at end of file
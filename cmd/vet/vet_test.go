@@ -70,7 +70,6 @@ func TestVet(t *testing.T) {
 	flags := []string{
 		"./" + binary,
 		"-printfuncs=Warn:1,Warnf:1",
-		"-test", // TODO: Delete once -shadow is part of -all.
 	}
 	cmd = exec.Command(errchk, append(flags, files...)...)
 	if !run(cmd, t) {
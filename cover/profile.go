@@ -22,12 +22,19 @@
 
 
 // Package cover provides support for parsing coverage profiles
-// generated by "go test -coverprofile=cover.out".
+// generated by "go test -coverprofile=cover.out", and for merging and
+// writing them.
+//
+// A profile may also record the blocks of each test that contributed
+// to it.  These follow the blocks of the profile in sections headed
+//	# test TestName
+// which the go tool does not understand.
 package cover
 
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"regexp"
@@ -41,6 +48,11 @@ type Profile struct {
 	FileName string
 	Mode     string
 	Blocks   []ProfileBlock
+
+	// Tests, if not nil, holds the blocks of the profile for each test
+	// that contributed to it, by test name.  A test with no blocks, or
+	// a nil Tests, counts as covering nothing.
+	Tests map[string][]ProfileBlock
 }
 
 // ProfileBlock represents a single block of profiling data.
@@ -64,15 +76,23 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 		return nil, err
 	}
 	defer pf.Close()
+	return parseProfiles(pf)
+}
 
+// testHeader introduces the blocks of a test.
+const testHeader = "# test "
+
+func parseProfiles(r io.Reader) ([]*Profile, error) {
 	files := make(map[string]*Profile)
-	buf := bufio.NewReader(pf)
+	buf := bufio.NewReader(r)
 	// First line is "mode: foo", where foo is "set", "count", or "atomic".
 	// Rest of file is in the format
 	//	encoding/base64/base64.go:34.44,37.40 3 1
 	// where the fields are: name.go:line.column,line.column numberOfStatements count
+	// and may end with the blocks of each test, after a testHeader line.
 	s := bufio.NewScanner(buf)
 	mode := ""
+	test := ""
 	for s.Scan() {
 		line := s.Text()
 		if mode == "" {
@@ -83,6 +103,10 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 			mode = line[len(p):]
 			continue
 		}
+		if strings.HasPrefix(line, testHeader) {
+			test = line[len(testHeader):]
+			continue
+		}
 		m := lineRe.FindStringSubmatch(line)
 		if m == nil {
 			return nil, fmt.Errorf("line %q doesn't match expected format: %v", m, lineRe)
@@ -96,20 +120,31 @@ func ParseProfiles(fileName string) ([]*Profile, error) {
 			}
 			files[fn] = p
 		}
-		p.Blocks = append(p.Blocks, ProfileBlock{
+		b := ProfileBlock{
 			StartLine: toInt(m[2]),
 			StartCol:  toInt(m[3]),
 			EndLine:   toInt(m[4]),
 			EndCol:    toInt(m[5]),
 			NumStmt:   toInt(m[6]),
 			Count:     toInt(m[7]),
-		})
+		}
+		if test == "" {
+			p.Blocks = append(p.Blocks, b)
+			continue
+		}
+		if p.Tests == nil {
+			p.Tests = make(map[string][]ProfileBlock)
+		}
+		p.Tests[test] = append(p.Tests[test], b)
 	}
 	if err := s.Err(); err != nil {
 		return nil, err
 	}
 	for _, p := range files {
 		sort.Sort(blocksByStart(p.Blocks))
+		for _, blocks := range p.Tests {
+			sort.Sort(blocksByStart(blocks))
+		}
 	}
 	// Generate a sorted slice.
 	profiles := make([]*Profile, 0, len(files))
@@ -129,6 +164,162 @@ func (b blocksByStart) Less(i, j int) bool {
 	return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
 }
 
+// WriteProfiles writes profiles to w in the format read by ParseProfiles,
+// followed by the blocks of each test, if any.  The profiles must have
+// the same mode.
+func WriteProfiles(w io.Writer, profiles []*Profile) error {
+	mode := "set"
+	if len(profiles) > 0 {
+		mode = profiles[0].Mode
+	}
+	tests := make(map[string]bool)
+	for _, p := range profiles {
+		if p.Mode != mode {
+			return fmt.Errorf("%s: mode %s does not match %s", p.FileName, p.Mode, mode)
+		}
+		for name := range p.Tests {
+			tests[name] = true
+		}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, p := range profiles {
+		writeBlocks(bw, p.FileName, p.Blocks)
+	}
+	var names []string
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(bw, "%s%s\n", testHeader, name)
+		for _, p := range profiles {
+			writeBlocks(bw, p.FileName, p.Tests[name])
+		}
+	}
+	return bw.Flush()
+}
+
+func writeBlocks(w io.Writer, fileName string, blocks []ProfileBlock) {
+	for _, b := range blocks {
+		fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n", fileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+	}
+}
+
+// SetTest records the blocks of each of profiles as those of the named
+// test, in place of any it had, so that the tests of separate runs may
+// be told apart once the runs are merged.
+func SetTest(profiles []*Profile, name string) {
+	for _, p := range profiles {
+		p.Tests = map[string][]ProfileBlock{
+			name: append([]ProfileBlock(nil), p.Blocks...),
+		}
+	}
+}
+
+// Merge combines the profiles of several runs, such as the separate
+// runs of the tests of each element, into one profile for each source
+// file.  The counts of a block in count and atomic mode are summed; in
+// set mode, a block is set if it is set in any run.  The blocks of each
+// test are merged likewise; a run with no tests, such as one not given
+// to SetTest, adds to the blocks of the profile alone.  The runs must have the same mode, and the
+// blocks of a file must match, as they do unless the file changed
+// between runs.
+func Merge(runs ...[]*Profile) ([]*Profile, error) {
+	files := make(map[string]*Profile)
+	mode := ""
+	for _, run := range runs {
+		for _, p := range run {
+			if mode == "" {
+				mode = p.Mode
+			} else if p.Mode != mode {
+				return nil, fmt.Errorf("%s: mode %s does not match %s", p.FileName, p.Mode, mode)
+			}
+			q := files[p.FileName]
+			if q == nil {
+				q = &Profile{FileName: p.FileName, Mode: p.Mode}
+				files[p.FileName] = q
+			}
+			var err error
+			if q.Blocks, err = mergeBlocks(q.Blocks, p.Blocks, mode); err != nil {
+				return nil, fmt.Errorf("%s: %v", p.FileName, err)
+			}
+			for name, blocks := range p.Tests {
+				if q.Tests == nil {
+					q.Tests = make(map[string][]ProfileBlock)
+				}
+				if q.Tests[name], err = mergeBlocks(q.Tests[name], blocks, mode); err != nil {
+					return nil, fmt.Errorf("%s: test %s: %v", p.FileName, name, err)
+				}
+			}
+		}
+	}
+	profiles := make([]*Profile, 0, len(files))
+	for _, profile := range files {
+		profiles = append(profiles, profile)
+	}
+	sort.Sort(byFileName(profiles))
+	return profiles, nil
+}
+
+// mergeBlocks returns the union of two sorted lists of blocks, with
+// the counts of identical blocks combined according to mode.
+func mergeBlocks(a, b []ProfileBlock, mode string) ([]ProfileBlock, error) {
+	all := make([]ProfileBlock, 0, len(a)+len(b))
+	all = append(all, a...)
+	all = append(all, b...)
+	sort.Stable(blocksByStart(all))
+	var merged []ProfileBlock
+	for _, b := range all {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if b.StartLine == last.StartLine && b.StartCol == last.StartCol && b.EndLine == last.EndLine && b.EndCol == last.EndCol {
+				if b.NumStmt != last.NumStmt {
+					return nil, fmt.Errorf("block at %d.%d has %d statements and %d", b.StartLine, b.StartCol, last.NumStmt, b.NumStmt)
+				}
+				if mode == "set" {
+					if b.Count > 0 {
+						last.Count = 1
+					}
+				} else {
+					last.Count += b.Count
+				}
+				continue
+			}
+			if b.StartLine < last.EndLine || b.StartLine == last.EndLine && b.StartCol < last.EndCol {
+				return nil, fmt.Errorf("block at %d.%d overlaps block at %d.%d", b.StartLine, b.StartCol, last.StartLine, last.StartCol)
+			}
+		}
+		merged = append(merged, b)
+	}
+	return merged, nil
+}
+
+// ForTests returns the profile of the tests whose names satisfy match:
+// its blocks are those of p, with the counts of those tests alone.
+// Blocks that no such test recorded have a count of zero.
+func (p *Profile) ForTests(match func(name string) bool) (*Profile, error) {
+	q := &Profile{FileName: p.FileName, Mode: p.Mode}
+	for _, b := range p.Blocks {
+		b.Count = 0
+		q.Blocks = append(q.Blocks, b)
+	}
+	var names []string
+	for name := range p.Tests {
+		if match(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var err error
+		if q.Blocks, err = mergeBlocks(q.Blocks, p.Tests[name], p.Mode); err != nil {
+			return nil, fmt.Errorf("%s: test %s: %v", p.FileName, name, err)
+		}
+	}
+	return q, nil
+}
+
 var lineRe = regexp.MustCompile(`^(.+):([0-9]+).([0-9]+),([0-9]+).([0-9]+) ([0-9]+) ([0-9]+)$`)
 
 func toInt(s string) int {
@@ -0,0 +1,136 @@
+// antha-tools/cover/profile_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package cover
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	mixProfile = `mode: count
+a.go:1.10,3.2 2 1
+a.go:4.10,6.2 1 0
+`
+	diluteProfile = `mode: count
+a.go:1.10,3.2 2 3
+a.go:4.10,6.2 1 2
+b.go:1.10,2.2 1 1
+`
+)
+
+func parse(t *testing.T, s string) []*Profile {
+	profiles, err := parseProfiles(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profiles
+}
+
+// TestMerge checks that merged profiles sum the counts of each run and
+// keep those of each test, through a write and a parse.
+func TestMerge(t *testing.T) {
+	mix, dilute := parse(t, mixProfile), parse(t, diluteProfile)
+	SetTest(mix, "TestMix")
+	SetTest(dilute, "TestDilute")
+	merged, err := Merge(mix, dilute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteProfiles(&buf, merged); err != nil {
+		t.Fatal(err)
+	}
+	want := `mode: count
+a.go:1.10,3.2 2 4
+a.go:4.10,6.2 1 2
+b.go:1.10,2.2 1 1
+# test TestDilute
+a.go:1.10,3.2 2 3
+a.go:4.10,6.2 1 2
+b.go:1.10,2.2 1 1
+# test TestMix
+a.go:1.10,3.2 2 1
+a.go:4.10,6.2 1 0
+`
+	if buf.String() != want {
+		t.Fatalf("got profile\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	profiles := parse(t, buf.String())
+	if !reflect.DeepEqual(profiles, merged) {
+		t.Errorf("parsed %v, want %v", profiles, merged)
+	}
+	p, err := profiles[1].ForTests(func(name string) bool { return name == "TestMix" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Blocks) != 1 || p.Blocks[0].Count != 0 {
+		t.Errorf("got b.go blocks %v for TestMix, want one block not run", p.Blocks)
+	}
+}
+
+// TestMergeMismatch checks that runs that disagree are not merged.
+func TestMergeMismatch(t *testing.T) {
+	mix := parse(t, mixProfile)
+	if _, err := Merge(mix, parse(t, strings.Replace(mixProfile, "count", "set", 1))); err == nil {
+		t.Error("merged profiles of different modes")
+	}
+	if _, err := Merge(mix, parse(t, strings.Replace(mixProfile, "3.2 2", "5.2 2", 1))); err == nil {
+		t.Error("merged overlapping blocks")
+	}
+}
+
+// TestMergeUnnamed checks that a run with no test name merges with a
+// named one, and that the tests did not cover the files of the former.
+func TestMergeUnnamed(t *testing.T) {
+	mix := parse(t, mixProfile)
+	SetTest(mix, "TestMix")
+	merged, err := Merge(mix, parse(t, diluteProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 2 || merged[1].Tests != nil {
+		t.Fatalf("got %v, want a.go and b.go with no tests", merged)
+	}
+	for _, p := range merged {
+		q, err := p.ForTests(func(name string) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts []int
+		for _, b := range q.Blocks {
+			counts = append(counts, b.Count)
+		}
+		want := []int{0}
+		if p.FileName == "a.go" {
+			want = []int{1, 0}
+		}
+		if !reflect.DeepEqual(counts, want) {
+			t.Errorf("%s: got counts %v for all tests, want %v", p.FileName, counts, want)
+		}
+	}
+}
\ No newline at end of file
@@ -79,16 +79,18 @@ import (
 	"github.com/antha-lang/antha-tools/antha/loader"
 	"github.com/antha-lang/antha-tools/antha/pointer"
 	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/taint"
 	"github.com/antha-lang/antha-tools/antha/types"
 	"github.com/antha-lang/antha-tools/oracle/serial"
 )
 
 // An Oracle holds the program state required for one or more queries.
 type Oracle struct {
-	fset      *token.FileSet                         // file set [all queries]
-	prog      *ssa.Program                           // the SSA program [needSSA]
-	ptaConfig pointer.Config                         // pointer analysis configuration [needPTA]
-	typeInfo  map[*types.Package]*loader.PackageInfo // type info for all ASTs in the program [needRetainTypeInfo]
+	fset        *token.FileSet                         // file set [all queries]
+	prog        *ssa.Program                           // the SSA program [needSSA]
+	ptaConfig   pointer.Config                         // pointer analysis configuration [needPTA]
+	taintConfig *taint.Config                          // sources and sinks of 'taint' queries [needPTA]
+	typeInfo    map[*types.Package]*loader.PackageInfo // type info for all ASTs in the program [needRetainTypeInfo]
 }
 
 // A set of bits indicating the analytical requirements of each mode.
@@ -120,6 +122,8 @@ var modes = []*modeInfo{
 	{"callstack", needPTA | needPos, callstack},
 	{"peers", needPTA | needSSADebug | needPos, peers},
 	{"pointsto", needPTA | needSSADebug | needExactPos, pointsto},
+	{"slice", needPTA | needSSADebug | needExactPos, slice},
+	{"taint", needPTA | needSSADebug, doTaint},
 
 	// Type-based, modular analyses:
 	{"definition", needPos, definition},
@@ -207,6 +211,8 @@ func (res *Result) Serial() *serial.Result {
 // ptalog is the (optional) pointer-analysis log file.
 // buildContext is the antha/build configuration for locating packages.
 // reflection determines whether to model reflection soundly (currently slow).
+// taintConfig specifies the sources and sinks of 'taint' queries;
+// if nil, those of taint.DefaultConfig are used.
 //
 // Clients that intend to perform multiple queries against the same
 // analysis scope should use this pattern instead:
@@ -215,7 +221,7 @@ func (res *Result) Serial() *serial.Result {
 //	... populate config, e.g. conf.FromArgs(args) ...
 //	iprog, err := conf.Load()
 //	if err != nil { ... }
-// 	o, err := oracle.New(iprog, nil, false)
+// 	o, err := oracle.New(iprog, nil, false, nil)
 //	if err != nil { ... }
 //	for ... {
 //		qpos, err := oracle.ParseQueryPos(imp, pos, needExact)
@@ -230,7 +236,7 @@ func (res *Result) Serial() *serial.Result {
 // TODO(adonovan): the ideal 'needsExact' parameter for ParseQueryPos
 // depends on the query mode; how should we expose this?
 //
-func Query(args []string, mode, pos string, ptalog io.Writer, buildContext *build.Context, reflection bool) (*Result, error) {
+func Query(args []string, mode, pos string, ptalog io.Writer, buildContext *build.Context, reflection bool, taintConfig *taint.Config) (*Result, error) {
 	if mode == "what" {
 		// Bypass package loading, type checking, SSA construction.
 		return what(pos, buildContext)
@@ -271,7 +277,7 @@ func Query(args []string, mode, pos string, ptalog io.Writer, buildContext *buil
 		return nil, err
 	}
 
-	o, err := newOracle(iprog, ptalog, minfo.needs, reflection)
+	o, err := newOracle(iprog, ptalog, minfo.needs, reflection, taintConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -359,12 +365,14 @@ func pkgContainsFile(bp *build.Package, filename string) bool {
 // iprog specifies the program to analyze.
 // ptalog is the (optional) pointer-analysis log file.
 // reflection determines whether to model reflection soundly (currently slow).
+// taintConfig specifies the sources and sinks of 'taint' queries;
+// if nil, those of taint.DefaultConfig are used.
 //
-func New(iprog *loader.Program, ptalog io.Writer, reflection bool) (*Oracle, error) {
-	return newOracle(iprog, ptalog, needAll, reflection)
+func New(iprog *loader.Program, ptalog io.Writer, reflection bool, taintConfig *taint.Config) (*Oracle, error) {
+	return newOracle(iprog, ptalog, needAll, reflection, taintConfig)
 }
 
-func newOracle(iprog *loader.Program, ptalog io.Writer, needs int, reflection bool) (*Oracle, error) {
+func newOracle(iprog *loader.Program, ptalog io.Writer, needs int, reflection bool, taintConfig *taint.Config) (*Oracle, error) {
 	o := &Oracle{fset: iprog.Fset}
 
 	// Retain type info for all ASTs in the program.
@@ -406,6 +414,11 @@ func newOracle(iprog *loader.Program, ptalog io.Writer, needs int, reflection bo
 		o.ptaConfig.Reflection = reflection
 		o.ptaConfig.Mains = mains
 
+		if taintConfig == nil {
+			taintConfig = taint.DefaultConfig()
+		}
+		o.taintConfig = taintConfig
+
 		o.prog = prog
 	}
 
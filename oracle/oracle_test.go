@@ -64,6 +64,7 @@ import (
 	"testing"
 
 	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/taint"
 	"github.com/antha-lang/antha-tools/oracle"
 )
 
@@ -182,9 +183,9 @@ func WriteResult(w io.Writer, res *oracle.Result) {
 	}
 }
 
-// doQuery poses query q to the oracle and writes its response and
-// error (if any) to out.
-func doQuery(out io.Writer, q *query, useJson bool) {
+// doQuery poses query q to the oracle, whose 'taint' queries use
+// taintConfig, and writes its response and error (if any) to out.
+func doQuery(out io.Writer, q *query, useJson bool, taintConfig *taint.Config) {
 	fmt.Fprintf(out, "-------- @%s %s --------\n", q.verb, q.id)
 
 	var buildContext = build.Default
@@ -194,7 +195,8 @@ func doQuery(out io.Writer, q *query, useJson bool) {
 		q.queryPos,
 		nil, // ptalog,
 		&buildContext,
-		true) // reflection
+		true, // reflection
+		taintConfig)
 	if err != nil {
 		fmt.Fprintf(out, "\nError: %s\n", err)
 		return
@@ -220,6 +222,13 @@ func TestOracle(t *testing.T) {
 		t.Skipf("skipping test on %q (no /usr/bin/diff)", runtime.GOOS)
 	}
 
+	// The standard library is not analysed by these tests, so
+	// 'taint' queries use sources and sinks of their own.
+	taintConfig := &taint.Config{
+		Sources: []string{"taint.readInput"},
+		Sinks:   []string{"taint.run[0]"},
+	}
+
 	for _, filename := range []string{
 		"testdata/src/main/calls.go",
 		"testdata/src/main/callgraph.go",
@@ -231,6 +240,8 @@ func TestOracle(t *testing.T) {
 		"testdata/src/main/peers.go",
 		"testdata/src/main/pointsto.go",
 		"testdata/src/main/reflection.go",
+		"testdata/src/main/slice.go",
+		"testdata/src/main/taint.go",
 		"testdata/src/main/what.go",
 		// JSON:
 		// TODO(adonovan): most of these are very similar; combine them.
@@ -258,7 +269,7 @@ func TestOracle(t *testing.T) {
 		// Run the oracle on each query, redirecting its output
 		// and error (if any) to the foo.got file.
 		for _, q := range queries {
-			doQuery(gotfh, q, useJson)
+			doQuery(gotfh, q, useJson, taintConfig)
 		}
 
 		// Compare foo.got with foo.golden.
@@ -299,7 +310,7 @@ func TestMultipleQueries(t *testing.T) {
 	}
 
 	// Oracle
-	o, err := oracle.New(iprog, nil, true)
+	o, err := oracle.New(iprog, nil, true, nil)
 	if err != nil {
 		t.Fatalf("oracle.New failed: %s", err)
 	}
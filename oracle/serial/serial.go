@@ -51,6 +51,33 @@ type Referrers struct {
 	Refs   []string `json:"refs,omitempty"`   // locations of all references
 }
 
+// A SliceStmt is one element of the Stmts slice of a Slice.
+type SliceStmt struct {
+	Pos  string `json:"pos"`  // location of the statement
+	Desc string `json:"desc"` // description of the statement
+}
+
+// A Slice is the result of a 'slice' query.
+type Slice struct {
+	Pos   string      `json:"pos"`             // location of the selected expression
+	Stmts []SliceStmt `json:"stmts,omitempty"` // statements that may affect its value
+}
+
+// A TaintStep is one step of a TaintFlow.
+type TaintStep struct {
+	Pos  string `json:"pos"`  // location of the step, if known
+	Desc string `json:"desc"` // description of the step
+}
+
+// A TaintFlow is one element of the result of a 'taint' query: a path
+// along which untrusted data flows from a source to a sink.
+type TaintFlow struct {
+	Source string      `json:"source"` // name of the source
+	Sink   string      `json:"sink"`   // name of the sink function
+	Pos    string      `json:"pos"`    // location of the call to the sink
+	Steps  []TaintStep `json:"steps"`  // the steps from source to sink
+}
+
 // A Definition is the result of a 'definition' query.
 type Definition struct {
 	ObjPos string `json:"objpos,omitempty"` // location of the definition
@@ -268,6 +295,8 @@ type Result struct {
 	Peers      *Peers      `json:"peers,omitempty"`
 	PointsTo   []PointsTo  `json:"pointsto,omitempty"`
 	Referrers  *Referrers  `json:"referrers,omitempty"`
+	Slice      *Slice      `json:"slice,omitempty"`
+	Taint      []TaintFlow `json:"taint,omitempty"`
 	What       *What       `json:"what,omitempty"`
 
 	Warnings []PTAWarning `json:"warnings,omitempty"` // warnings from pointer analysis
@@ -0,0 +1,422 @@
+// antha-tools/oracle/slice.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package oracle
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+	"sort"
+
+	"github.com/antha-lang/antha-tools/astutil"
+	"github.com/antha-lang/antha-tools/antha/pointer"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/ssautil"
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha-tools/oracle/serial"
+)
+
+// slice reports the backward program slice of the selected
+// expression: the statements that may affect its value through data
+// and control dependences, following calls through the call graph
+// and memory through the pointer analysis.  Writes and reads are
+// matched using the points-to sets of their addresses, maps and
+// channels, not merely their types, so the slice is only as precise
+// as the pointer analysis; values for which it has no points-to set
+// are conservatively assumed to alias everything.
+//
+func slice(o *Oracle, qpos *QueryPos) (queryResult, error) {
+	path, action := findInterestingNode(qpos.info, qpos.path)
+	if action != actionExpr {
+		return nil, fmt.Errorf("slicing wants an expression; got %s",
+			astutil.NodeDescription(qpos.path[0]))
+	}
+
+	var expr ast.Expr
+	var obj types.Object
+	switch n := path[0].(type) {
+	case *ast.ValueSpec:
+		// ambiguous ValueSpec containing multiple names
+		return nil, fmt.Errorf("multiple value specification")
+	case *ast.Ident:
+		obj = qpos.info.ObjectOf(n)
+		expr = n
+	case ast.Expr:
+		expr = n
+	default:
+		return nil, fmt.Errorf("unexpected AST for expr: %T", n)
+	}
+	if qpos.info.Types[expr].Value != nil {
+		return nil, fmt.Errorf("slicing wants a variable expression; got a constant")
+	}
+
+	// Determine the ssa.Value for the expression.
+	var value ssa.Value
+	var isAddr bool
+	var err error
+	if obj != nil {
+		value, isAddr, err = ssaValueForIdent(o.prog, qpos.info, obj, path)
+	} else {
+		value, isAddr, err = ssaValueForExpr(o.prog, qpos.info, path)
+	}
+	if err != nil {
+		return nil, err // e.g. trivially dead code
+	}
+
+	buildSSA(o)
+
+	// Query the pointer analysis for every address, map and
+	// channel that is read or written, to determine which
+	// writes may affect which reads.
+	for fn := range ssautil.AllFunctions(o.prog) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, v := range accessedObjects(instr) {
+					if pointer.CanPoint(v.Type()) {
+						o.ptaConfig.AddQuery(v)
+					}
+				}
+			}
+		}
+	}
+	if isAddr {
+		o.ptaConfig.AddQuery(value)
+	}
+	o.ptaConfig.BuildCallGraph = true
+	ptares := ptrAnalysis(o)
+	mayAlias := func(x, y ssa.Value) bool {
+		px, ok := ptares.Queries[x]
+		py, ok2 := ptares.Queries[y]
+		return !ok || !ok2 || px.MayAlias(py)
+	}
+
+	var stmts []sliceStmt
+	seen := make(map[sliceStmt]bool)
+	for instr := range ssautil.BackwardSlice(ptares.CallGraph, mayAlias, value, isAddr) {
+		stmt := sliceStmt{instrPos(instr), describeSliceInstr(instr)}
+		if stmt.pos.IsValid() && !seen[stmt] {
+			seen[stmt] = true
+			stmts = append(stmts, stmt)
+		}
+	}
+	sort.Sort(byStmtPos(stmts))
+
+	return &sliceResult{
+		qpos:  qpos,
+		expr:  expr,
+		stmts: stmts,
+	}, nil
+}
+
+// accessedObjects returns the addresses, maps and channels read or
+// written by instr.
+func accessedObjects(instr ssa.Instruction) []ssa.Value {
+	switch instr := instr.(type) {
+	case *ssa.UnOp:
+		if instr.Op == token.MUL || instr.Op == token.ARROW {
+			return []ssa.Value{instr.X}
+		}
+	case *ssa.Store:
+		return []ssa.Value{instr.Addr}
+	case *ssa.MapUpdate:
+		return []ssa.Value{instr.Map}
+	case *ssa.Send:
+		return []ssa.Value{instr.Chan}
+	case *ssa.Lookup:
+		return []ssa.Value{instr.X}
+	case *ssa.Range:
+		return []ssa.Value{instr.X}
+	case *ssa.Select:
+		var chans []ssa.Value
+		for _, st := range instr.States {
+			chans = append(chans, st.Chan)
+		}
+		return chans
+	}
+	return nil
+}
+
+// instrPos returns the source position of instr.  Many instructions
+// (e.g. loads, stores and branches) have no position of their own;
+// they are reported at the position of the expression recorded by a
+// DebugRef for the value they define, store, or branch on, if any.
+//
+func instrPos(instr ssa.Instruction) token.Pos {
+	if pos := instr.Pos(); pos.IsValid() {
+		return pos
+	}
+	var v ssa.Value
+	switch instr := instr.(type) {
+	case *ssa.If:
+		v = instr.Cond
+	case *ssa.Store:
+		v = instr.Val
+	case ssa.Value:
+		v = instr
+	}
+	if v != nil {
+		for _, other := range instr.Block().Instrs {
+			if ref, ok := other.(*ssa.DebugRef); ok && ref.X == v {
+				return ref.Pos()
+			}
+		}
+	}
+	if store, ok := instr.(*ssa.Store); ok {
+		// e.g. a package-level variable's initializer
+		return store.Addr.Pos()
+	}
+	return token.NoPos
+}
+
+// describeSliceInstr returns a brief description of the statement
+// or expression denoted by instr, naming the kind of operation and
+// its principal operand, e.g. "store to s.Valid" or "call to measure".
+func describeSliceInstr(instr ssa.Instruction) string {
+	switch instr := instr.(type) {
+	case *ssa.If:
+		return "branch on " + operand(instr.Cond)
+	case *ssa.Go:
+		return "go " + describeCall(instr.Common())
+	case *ssa.Defer:
+		return "deferred " + describeCall(instr.Common())
+	case ssa.CallInstruction:
+		return describeCall(instr.Common())
+	case *ssa.Store:
+		return "store to " + addrString(instr.Addr)
+	case *ssa.MapUpdate:
+		return "update of map " + operand(instr.Map)
+	case *ssa.Send:
+		return "send on " + operand(instr.Chan)
+	case *ssa.Select:
+		return "select"
+	case *ssa.Return:
+		return "return from " + instr.Parent().Name()
+	case *ssa.Panic:
+		return "panic"
+	case *ssa.Phi:
+		return "definition of " + instr.Comment
+	case *ssa.UnOp:
+		switch instr.Op {
+		case token.MUL:
+			return "load of " + addrString(instr.X)
+		case token.ARROW:
+			return "receive from " + operand(instr.X)
+		}
+	case *ssa.Lookup:
+		return "lookup in " + operand(instr.X)
+	case *ssa.Range:
+		return "range over " + operand(instr.X)
+	case *ssa.Alloc:
+		if ref := addrRef(instr); ref != nil {
+			if lit, ok := ref.Expr.(*ast.CompositeLit); ok {
+				return "allocation of " + types.ExprString(lit.Type) + " literal"
+			}
+		}
+		return "allocation of " + instr.Comment
+	case *ssa.MakeClosure:
+		return "closure " + instr.Fn.Name()
+	case *ssa.TypeAssert:
+		return "type assertion on " + operand(instr.X)
+	case *ssa.FieldAddr, *ssa.IndexAddr:
+		return "address of " + addrString(instr.(ssa.Value))
+	}
+	if v, ok := instr.(ssa.Value); ok {
+		return "computation of " + expr(v)
+	}
+	return "computation"
+}
+
+// describeCall returns a description of the call c.
+func describeCall(c *ssa.CallCommon) string {
+	if c.IsInvoke() {
+		return "call to method " + c.Method.Name()
+	}
+	if callee := c.StaticCallee(); callee != nil {
+		return "call to " + callee.Name()
+	}
+	return "dynamic call to " + operand(c.Value)
+}
+
+// operand returns a source expression for v: the variable it was
+// assigned to, if a DebugRef records one, or else expr(v).
+func operand(v ssa.Value) string {
+	if id := debugIdent(v); id != nil {
+		return id.Name
+	}
+	return expr(v)
+}
+
+// expr returns an approximation of the source expression that
+// computes v, built from its definition.
+func expr(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.Value == nil {
+			return "nil"
+		}
+		return v.Value.String()
+	case *ssa.Field:
+		return operand(v.X) + "." + fieldName(v.X.Type(), v.Field)
+	case *ssa.Index:
+		return operand(v.X) + "[" + operand(v.Index) + "]"
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			return addrString(v.X)
+		}
+		return v.Op.String() + operand(v.X)
+	case *ssa.BinOp:
+		return operand(v.X) + " " + v.Op.String() + " " + operand(v.Y)
+	case *ssa.Call:
+		if v.Call.IsInvoke() {
+			return operand(v.Call.Value) + "." + v.Call.Method.Name() + "(...)"
+		}
+		return operand(v.Call.Value) + "(...)"
+	case *ssa.FieldAddr, *ssa.IndexAddr:
+		return "&" + addrString(v)
+	case *ssa.Global, *ssa.Function, *ssa.Parameter, *ssa.Capture:
+		return v.Name()
+	}
+	if ref := debugRef(v); ref != nil {
+		return types.ExprString(ref.Expr)
+	}
+	return "value"
+}
+
+// addrString returns a source expression for the variable whose
+// address is addr.
+func addrString(addr ssa.Value) string {
+	switch addr := addr.(type) {
+	case *ssa.Global:
+		return addr.Name()
+	case *ssa.FieldAddr:
+		return operand(addr.X) + "." + fieldName(addr.X.Type(), addr.Field)
+	case *ssa.IndexAddr:
+		return operand(addr.X) + "[" + operand(addr.Index) + "]"
+	case *ssa.Alloc:
+		if ref := addrRef(addr); ref != nil {
+			return types.ExprString(ref.Expr)
+		}
+		return addr.Comment
+	}
+	return "*" + operand(addr)
+}
+
+// debugRef returns a DebugRef for v, preferring one that denotes a
+// variable, or nil if there is none.
+func debugRef(v ssa.Value) *ssa.DebugRef {
+	var found *ssa.DebugRef
+	if refs := v.Referrers(); refs != nil {
+		for _, instr := range *refs {
+			if ref, ok := instr.(*ssa.DebugRef); ok {
+				if isVariable(ref.Object()) {
+					return ref
+				}
+				if found == nil {
+					found = ref
+				}
+			}
+		}
+	}
+	return found
+}
+
+// addrRef returns a DebugRef for the expression whose address is v,
+// or nil if there is none.
+func addrRef(v ssa.Value) *ssa.DebugRef {
+	for _, instr := range *v.Referrers() {
+		if ref, ok := instr.(*ssa.DebugRef); ok && ref.IsAddr {
+			return ref
+		}
+	}
+	return nil
+}
+
+// debugIdent returns the identifier of the variable whose value is v,
+// if a DebugRef records it.
+func debugIdent(v ssa.Value) *ast.Ident {
+	if ref := debugRef(v); ref != nil && !ref.IsAddr && isVariable(ref.Object()) {
+		return ref.Expr.(*ast.Ident)
+	}
+	return nil
+}
+
+// isVariable reports whether obj is a variable other than a field.
+func isVariable(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && !v.IsField()
+}
+
+// fieldName returns the name of the ith field of the struct type t,
+// or of the struct type to which t points.
+func fieldName(t types.Type, i int) string {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return t.Underlying().(*types.Struct).Field(i).Name()
+}
+
+// A sliceStmt is a source statement or expression in the slice.
+type sliceStmt struct {
+	pos  token.Pos
+	desc string
+}
+
+type byStmtPos []sliceStmt
+
+func (a byStmtPos) Len() int { return len(a) }
+func (a byStmtPos) Less(i, j int) bool {
+	cmp := a[i].pos - a[j].pos
+	return cmp < 0 || (cmp == 0 && a[i].desc < a[j].desc)
+}
+func (a byStmtPos) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+type sliceResult struct {
+	qpos  *QueryPos
+	expr  ast.Expr    // the selected expression
+	stmts []sliceStmt // statements in the slice, in order
+}
+
+func (r *sliceResult) display(printf printfFunc) {
+	if len(r.stmts) == 0 {
+		printf(r.expr, "The value of this expression depends on no statements.")
+		return
+	}
+	printf(r.expr, "The value of this expression may depend on these %d statements:", len(r.stmts))
+	for _, stmt := range r.stmts {
+		printf(stmt.pos, "\t%s", stmt.desc)
+	}
+}
+
+func (r *sliceResult) toSerial(res *serial.Result, fset *token.FileSet) {
+	slice := &serial.Slice{
+		Pos: fset.Position(r.expr.Pos()).String(),
+	}
+	for _, stmt := range r.stmts {
+		slice.Stmts = append(slice.Stmts, serial.SliceStmt{
+			Pos:  fset.Position(stmt.pos).String(),
+			Desc: stmt.desc,
+		})
+	}
+	res.Slice = slice
+}
\ No newline at end of file
@@ -0,0 +1,112 @@
+// antha-tools/oracle/taint.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package oracle
+
+import (
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/taint"
+	"github.com/antha-lang/antha-tools/antha/types"
+	"github.com/antha-lang/antha-tools/oracle/serial"
+)
+
+// doTaint reports the paths along which untrusted data may flow from
+// the sources to the sinks of the oracle's taint configuration, or if a query -pos was
+// provided, only those paths that pass through the query package.
+//
+func doTaint(o *Oracle, qpos *QueryPos) (queryResult, error) {
+	buildSSA(o)
+
+	flows, err := taint.Analyze(o.taintConfig, &o.ptaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var qpkg *types.Package
+	if qpos != nil {
+		qpkg = qpos.info.Pkg
+		files := make(map[*token.File]bool)
+		for _, f := range qpos.info.Files {
+			files[o.fset.File(f.Pos())] = true
+		}
+		var inPkg []*taint.Flow
+		for _, flow := range flows {
+			for _, step := range flow.Path {
+				if step.Pos.IsValid() && files[o.fset.File(step.Pos)] {
+					inPkg = append(inPkg, flow)
+					break
+				}
+			}
+		}
+		flows = inPkg
+	}
+
+	return &taintResult{
+		qpkg:  qpkg,
+		flows: flows,
+	}, nil
+}
+
+type taintResult struct {
+	qpkg  *types.Package // the query package, or nil
+	flows []*taint.Flow
+}
+
+func (r *taintResult) display(printf printfFunc) {
+	descr := "the entire program"
+	if r.qpkg != nil {
+		descr = "package " + r.qpkg.Path()
+	}
+	if len(r.flows) == 0 {
+		printf(nil, "No untrusted data reaches a sink in %s.", descr)
+		return
+	}
+	printf(nil, "Untrusted data reaches a sink in %s along these %d paths:", descr, len(r.flows))
+	for _, flow := range r.flows {
+		printf(flow.Pos, "%s reaches %s", flow.Source, flow.Sink)
+		for _, step := range flow.Path {
+			printf(step.Pos, "\t%s", step.Description)
+		}
+	}
+}
+
+func (r *taintResult) toSerial(res *serial.Result, fset *token.FileSet) {
+	var flows []serial.TaintFlow
+	for _, flow := range r.flows {
+		var steps []serial.TaintStep
+		for _, step := range flow.Path {
+			steps = append(steps, serial.TaintStep{
+				Pos:  fset.Position(step.Pos).String(),
+				Desc: step.Description,
+			})
+		}
+		flows = append(flows, serial.TaintFlow{
+			Source: flow.Source,
+			Sink:   flow.Sink,
+			Pos:    fset.Position(flow.Pos).String(),
+			Steps:  steps,
+		})
+	}
+	res.Taint = flows
+}
\ No newline at end of file
@@ -0,0 +1,61 @@
+// antha-tools/oracle/testdata/src/main/slice.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+package slice
+
+// Tests of 'slice' queries.
+// See go.tools/oracle/oracle_test.go for explanation.
+// See slice.golden for expected query results.
+
+type Sample struct {
+	Volume float64
+	Valid  bool
+}
+
+var calibration = 1.5
+
+func measure(s *Sample) float64 {
+	v := s.Volume * calibration
+	if !s.Valid {
+		v = 0
+	}
+	return v
+}
+
+func label(s *Sample) string {
+	if s.Valid {
+		return "ok"
+	}
+	return "invalid"
+}
+
+func main() {
+	s := &Sample{Volume: 10}
+	s.Valid = s.Volume > 0
+	other := &Sample{Volume: 20}
+	other.Valid = true
+	calibration = 2
+	result := measure(s)
+	_ = label(other)
+	_ = result // @slice slice-result "result"
+	_ = label(s) // @slice slice-label "label.s."
+}
\ No newline at end of file
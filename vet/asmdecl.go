@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/asmdecl.go: Part of the Antha language
+// antha-tools/vet/asmdecl.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // Identify mismatches between assembly files and Go func declarations.
 
-package main
+package vet
 
 import (
 	"bytes"
@@ -98,11 +98,15 @@ var (
 	asmOpcode    = re(`^\s*(?:[A-Z0-9a-z_]+:)?\s*([A-Z]+)\s*([^,]*)(?:,\s*(.*))?`)
 )
 
-func asmCheck(pkg *Package) {
-	if !vet("asmdecl") {
-		return
-	}
+func init() {
+	Register(&Checker{
+		Name:       "asmdecl",
+		Doc:        "check assembly against Go declarations",
+		RunPackage: asmCheck,
+	})
+}
 
+func asmCheck(pkg *Package) {
 	// No work if no assembly files.
 	if !pkg.hasFileWithSuffix(".s") {
 		return
@@ -142,7 +146,7 @@ func asmCheck(pkg *Package) {
 			lineno++
 
 			badf := func(format string, args ...interface{}) {
-				f.Badf(token.NoPos, "%s:%d: [%s] %s", f.name, lineno, arch, fmt.Sprintf(format, args...))
+				f.reportLinef(Error, lineno, "[%s] %s", arch, fmt.Sprintf(format, args...))
 			}
 
 			if arch == "" {
@@ -162,12 +166,16 @@ func asmCheck(pkg *Package) {
 
 			if m := asmTEXT.FindStringSubmatch(line); m != nil {
 				if arch == "" {
-					f.Warnf(token.NoPos, "%s: cannot determine architecture for assembly file", f.name)
+					f.Report(Diagnostic{
+						Severity: Warning,
+						Pos:      token.Position{Filename: f.name},
+						Message:  "cannot determine architecture for assembly file",
+					})
 					return
 				}
 				fn = knownFunc[m[1]][arch]
 				if fn != nil {
-					size, _ := strconv.Atoi(m[4])
+					size, _ := strconv.Atoi(m[4]) //vet:ignore errcheck an absent size is 0
 					if size != fn.size && (m[2] != "7" && !strings.Contains(m[2], "NOSPLIT") || size != 0) {
 						badf("wrong argument size %d; expected $...-%d", size, fn.size)
 					}
@@ -194,7 +202,7 @@ func asmCheck(pkg *Package) {
 				name := m[1]
 				off := 0
 				if m[2] != "" {
-					off, _ = strconv.Atoi(m[2])
+					off, _ = strconv.Atoi(m[2]) //vet:ignore errcheck m[2] is all digits
 				}
 				v := fn.vars[name]
 				if v == nil {
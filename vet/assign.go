@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/assign.go: Part of the Antha language
+// antha-tools/vet/assign.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -25,9 +25,10 @@
 This file contains the code to check for useless assignments.
 */
 
-package main
+package vet
 
 import (
+	"fmt"
 	"github.com/antha-lang/antha/ast"
 	"github.com/antha-lang/antha/token"
 	"reflect"
@@ -36,12 +37,19 @@ import (
 // TODO: should also check for assignments to struct fields inside methods
 // that are on T instead of *T.
 
+func init() {
+	Register(&Checker{
+		Name:  "assign",
+		Doc:   "check for useless assignments",
+		Nodes: []ast.Node{assignStmt},
+		Run:   checkAssignStmt,
+	})
+}
+
 // checkAssignStmt checks for assignments of the form "<expr> = <expr>".
 // These are almost always useless, and even when they aren't they are usually a mistake.
-func (f *File) checkAssignStmt(stmt *ast.AssignStmt) {
-	if !vet("assign") {
-		return
-	}
+func checkAssignStmt(f *File, node ast.Node) {
+	stmt := node.(*ast.AssignStmt)
 	if stmt.Tok != token.ASSIGN {
 		return // ignore :=
 	}
@@ -56,8 +64,20 @@ func (f *File) checkAssignStmt(stmt *ast.AssignStmt) {
 		}
 		le := f.gofmt(lhs)
 		re := f.gofmt(rhs)
-		if le == re {
-			f.Badf(stmt.Pos(), "self-assignment of %s to %s", re, le)
+		if le != re {
+			continue
+		}
+		d := Diagnostic{
+			Pos:     f.fset.Position(stmt.Pos()),
+			End:     f.fset.Position(stmt.End()),
+			Message: fmt.Sprintf("self-assignment of %s to %s", re, le),
+		}
+		if len(stmt.Lhs) == 1 {
+			d.Fix = &SuggestedFix{
+				Message: "remove self-assignment",
+				Edits:   []TextEdit{f.deleteEdit(stmt.Pos(), stmt.End())},
+			}
 		}
+		f.Report(d)
 	}
 }
\ No newline at end of file
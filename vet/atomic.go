@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/atomic.go: Part of the Antha language
+// antha-tools/vet/atomic.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -21,20 +21,26 @@
 // 1 Royal College St, London NW1 0NH UK
 
 
-package main
+package vet
 
 import (
 	"github.com/antha-lang/antha/ast"
 	"github.com/antha-lang/antha/token"
 )
 
+func init() {
+	Register(&Checker{
+		Name:  "atomic",
+		Doc:   "check for common mistaken usages of the sync/atomic package",
+		Nodes: []ast.Node{assignStmt},
+		Run:   checkAtomicAssignment,
+	})
+}
+
 // checkAtomicAssignment walks the assignment statement checking for common
 // mistaken usage of atomic package, such as: x = atomic.AddUint64(&x, 1)
-func (f *File) checkAtomicAssignment(n *ast.AssignStmt) {
-	if !vet("atomic") {
-		return
-	}
-
+func checkAtomicAssignment(f *File, node ast.Node) {
+	n := node.(*ast.AssignStmt)
 	if len(n.Lhs) != len(n.Rhs) {
 		return
 	}
@@ -76,6 +82,6 @@ func (f *File) checkAtomicAddAssignment(left ast.Expr, call *ast.CallExpr) {
 	}
 
 	if broken {
-		f.Bad(left.Pos(), "direct assignment to atomic value")
+		f.BadRangef(left, "direct assignment to atomic value")
 	}
 }
\ No newline at end of file
@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/buildtag.go: Part of the Antha language
+// antha-tools/vet/buildtag.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -21,12 +21,10 @@
 // 1 Royal College St, London NW1 0NH UK
 
 
-package main
+package vet
 
 import (
 	"bytes"
-	"fmt"
-	"os"
 	"strings"
 	"unicode"
 )
@@ -37,12 +35,17 @@ var (
 	plusBuild  = []byte("+build")
 )
 
+func init() {
+	Register(&Checker{
+		Name:    "buildtags",
+		Doc:     "check that +build tags are valid",
+		RunFile: checkBuildTag,
+	})
+}
+
 // checkBuildTag checks that build tags are in the correct location and well-formed.
-func checkBuildTag(name string, data []byte) {
-	if !vet("buildtags") {
-		return
-	}
-	lines := bytes.SplitAfter(data, nl)
+func checkBuildTag(f *File) {
+	lines := bytes.SplitAfter(f.content, nl)
 
 	// Determine cutpoint where +build comments are no longer valid.
 	// They are valid in leading // comments in the file followed by
@@ -70,12 +73,11 @@ func checkBuildTag(name string, data []byte) {
 			fields := bytes.Fields(text)
 			if !bytes.Equal(fields[0], plusBuild) {
 				// Comment is something like +buildasdf not +build.
-				fmt.Fprintf(os.Stderr, "%s:%d: possible malformed +build comment\n", name, i+1)
+				f.reportLinef(Warning, i+1, "possible malformed +build comment")
 				continue
 			}
 			if i >= cutoff {
-				fmt.Fprintf(os.Stderr, "%s:%d: +build comment must appear before package clause and be followed by a blank line\n", name, i+1)
-				setExit(1)
+				f.reportLinef(Error, i+1, "+build comment must appear before package clause and be followed by a blank line")
 				continue
 			}
 			// Check arguments.
@@ -83,8 +85,7 @@ func checkBuildTag(name string, data []byte) {
 			for _, arg := range fields[1:] {
 				for _, elem := range strings.Split(string(arg), ",") {
 					if strings.HasPrefix(elem, "!!") {
-						fmt.Fprintf(os.Stderr, "%s:%d: invalid double negative in build constraint: %s\n", name, i+1, arg)
-						setExit(1)
+						f.reportLinef(Error, i+1, "invalid double negative in build constraint: %s", arg)
 						break Args
 					}
 					if strings.HasPrefix(elem, "!") {
@@ -92,8 +93,7 @@ func checkBuildTag(name string, data []byte) {
 					}
 					for _, c := range elem {
 						if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '.' {
-							fmt.Fprintf(os.Stderr, "%s:%d: invalid non-alphanumeric build constraint: %s\n", name, i+1, arg)
-							setExit(1)
+							f.reportLinef(Error, i+1, "invalid non-alphanumeric build constraint: %s", arg)
 							break Args
 						}
 					}
@@ -103,7 +103,7 @@ func checkBuildTag(name string, data []byte) {
 		}
 		// Comment with +build but not at beginning.
 		if bytes.Contains(line, plusBuild) && i < cutoff {
-			fmt.Fprintf(os.Stderr, "%s:%d: possible malformed +build comment\n", name, i+1)
+			f.reportLinef(Warning, i+1, "possible malformed +build comment")
 			continue
 		}
 	}
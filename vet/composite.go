@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/composite.go: Part of the Antha language
+// antha-tools/vet/composite.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,25 +23,33 @@
 
 // This file contains the test for unkeyed struct literals.
 
-package main
+package vet
 
 import (
 	"flag"
+	"fmt"
 	"github.com/antha-lang/antha/ast"
 	"strings"
 
+	"github.com/antha-lang/antha-tools/antha/types"
 	"github.com/antha-lang/antha-tools/cmd/vet/whitelist"
 )
 
 var compositeWhiteList = flag.Bool("compositewhitelist", true, "use composite white list; for testing only")
 
+func init() {
+	Register(&Checker{
+		Name:  "composites",
+		Doc:   "check that composite literals used field-keyed elements",
+		Nodes: []ast.Node{compositeLit},
+		Run:   checkUnkeyedLiteral,
+	})
+}
+
 // checkUnkeyedLiteral checks if a composite literal is a struct literal with
 // unkeyed fields.
-func (f *File) checkUnkeyedLiteral(c *ast.CompositeLit) {
-	if !vet("composites") {
-		return
-	}
-
+func checkUnkeyedLiteral(f *File, node ast.Node) {
+	c := node.(*ast.CompositeLit)
 	typ := c.Type
 	for {
 		if typ1, ok := c.Type.(*ast.ParenExpr); ok {
@@ -105,7 +113,7 @@ func (f *File) checkUnkeyedLiteral(c *ast.CompositeLit) {
 	// Convert the package name to an import path, and compare to a whitelist.
 	path := pkgPath(f, pkg.Name)
 	if path == "" {
-		f.Badf(c.Pos(), "unresolvable package for %s.%s literal", pkg.Name, s.Sel.Name)
+		f.BadRangef(c, "unresolvable package for %s.%s literal", pkg.Name, s.Sel.Name)
 		return
 	}
 	typeName := path + "." + s.Sel.Name
@@ -113,7 +121,33 @@ func (f *File) checkUnkeyedLiteral(c *ast.CompositeLit) {
 		return
 	}
 
-	f.Bad(c.Pos(), typeString+" composite literal uses unkeyed fields")
+	f.Report(Diagnostic{
+		Pos:     f.fset.Position(c.Pos()),
+		End:     f.fset.Position(c.End()),
+		Message: fmt.Sprintf("%s composite literal uses unkeyed fields", typeString),
+		Fix:     addKeysFix(f, c),
+	})
+}
+
+// addKeysFix returns the fix that adds field keys to the elements of c,
+// or nil if the fields of its struct type are unknown.
+func addKeysFix(f *File, c *ast.CompositeLit) *SuggestedFix {
+	typ := f.pkg.types[c].Type
+	if typ == nil {
+		return nil
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok || st.NumFields() != len(c.Elts) {
+		return nil
+	}
+	var edits []TextEdit
+	for i, e := range c.Elts {
+		if _, ok := e.(*ast.KeyValueExpr); ok {
+			return nil
+		}
+		edits = append(edits, f.Edit(e.Pos(), e.Pos(), st.Field(i).Name()+": "))
+	}
+	return &SuggestedFix{Message: "add field keys", Edits: edits}
 }
 
 // pkgPath returns the import path "image/png" for the package name "png".
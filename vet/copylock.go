@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/copylock.go: Part of the Antha language
+// antha-tools/vet/copylock.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // This file contains the code to check that locks are not passed by value.
 
-package main
+package vet
 
 import (
 	"bytes"
@@ -33,19 +33,25 @@ import (
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
+func init() {
+	Register(&Checker{
+		Name:  "copylocks",
+		Doc:   "check that locks are not passed by value",
+		Nodes: []ast.Node{funcDecl},
+		Run:   checkCopyLocks,
+	})
+}
+
 // checkCopyLocks checks whether a function might
 // inadvertently copy a lock, by checking whether
 // its receiver, parameters, or return values
 // are locks.
-func (f *File) checkCopyLocks(d *ast.FuncDecl) {
-	if !vet("copylocks") {
-		return
-	}
-
+func checkCopyLocks(f *File, node ast.Node) {
+	d := node.(*ast.FuncDecl)
 	if d.Recv != nil && len(d.Recv.List) > 0 {
 		expr := d.Recv.List[0].Type
 		if path := lockPath(f.pkg.typesPkg, f.pkg.types[expr].Type); path != nil {
-			f.Badf(expr.Pos(), "%s passes Lock by value: %v", d.Name.Name, path)
+			f.BadRangef(expr, "%s passes Lock by value: %v", d.Name.Name, path)
 		}
 	}
 
@@ -53,7 +59,7 @@ func (f *File) checkCopyLocks(d *ast.FuncDecl) {
 		for _, field := range d.Type.Params.List {
 			expr := field.Type
 			if path := lockPath(f.pkg.typesPkg, f.pkg.types[expr].Type); path != nil {
-				f.Badf(expr.Pos(), "%s passes Lock by value: %v", d.Name.Name, path)
+				f.BadRangef(expr, "%s passes Lock by value: %v", d.Name.Name, path)
 			}
 		}
 	}
@@ -62,7 +68,7 @@ func (f *File) checkCopyLocks(d *ast.FuncDecl) {
 		for _, field := range d.Type.Results.List {
 			expr := field.Type
 			if path := lockPath(f.pkg.typesPkg, f.pkg.types[expr].Type); path != nil {
-				f.Badf(expr.Pos(), "%s returns Lock by value: %v", d.Name.Name, path)
+				f.BadRangef(expr, "%s returns Lock by value: %v", d.Name.Name, path)
 			}
 		}
 	}
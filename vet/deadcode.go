@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/deadcode.go: Part of the Antha language
+// antha-tools/vet/deadcode.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // Check for syntactically unreachable code.
 
-package main
+package vet
 
 import (
 	"github.com/antha-lang/antha/ast"
@@ -36,13 +36,30 @@ type deadState struct {
 	hasGoto     map[string]bool
 	labels      map[string]ast.Stmt
 	breakTarget ast.Stmt
+	following   []ast.Stmt // statements after the one being walked
 
 	reachable bool
 }
 
+func init() {
+	Register(&Checker{
+		Name:  "unreachable",
+		Doc:   "check for unreachable code",
+		Nodes: []ast.Node{funcDecl, funcLit},
+		Run:   checkUnreachable,
+	})
+}
+
 // checkUnreachable checks a function body for dead code.
-func (f *File) checkUnreachable(body *ast.BlockStmt) {
-	if !vet("unreachable") || body == nil {
+func checkUnreachable(f *File, node ast.Node) {
+	var body *ast.BlockStmt
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		body = n.Body
+	case *ast.FuncLit:
+		body = n.Body
+	}
+	if body == nil {
 		return
 	}
 
@@ -158,6 +175,9 @@ func (d *deadState) findLabels(stmt ast.Stmt) {
 // When findDead returns, d.reachable tells whether the
 // statement following stmt is reachable.
 func (d *deadState) findDead(stmt ast.Stmt) {
+	following := d.following
+	d.following = nil
+
 	// Is this a labeled goto target?
 	// If so, assume it is reachable due to the goto.
 	// This is slightly conservative, in that we don't
@@ -174,7 +194,12 @@ func (d *deadState) findDead(stmt ast.Stmt) {
 		case *ast.EmptyStmt:
 			// do not warn about unreachable empty statements
 		default:
-			d.f.Bad(stmt.Pos(), "unreachable code")
+			d.f.Report(Diagnostic{
+				Pos:     d.f.fset.Position(stmt.Pos()),
+				End:     d.f.fset.Position(stmt.End()),
+				Message: "unreachable code",
+				Fix:     d.deleteFix(stmt, following),
+			})
 			d.reachable = true // silence error about next statement
 		}
 	}
@@ -194,9 +219,7 @@ func (d *deadState) findDead(stmt ast.Stmt) {
 		// no control flow
 
 	case *ast.BlockStmt:
-		for _, stmt := range x.List {
-			d.findDead(stmt)
-		}
+		d.findDeadList(x.List)
 
 	case *ast.BranchStmt:
 		switch x.Tok {
@@ -257,9 +280,7 @@ func (d *deadState) findDead(stmt ast.Stmt) {
 		anyReachable := false
 		for _, comm := range x.Body.List {
 			d.reachable = true
-			for _, stmt := range comm.(*ast.CommClause).Body {
-				d.findDead(stmt)
-			}
+			d.findDeadList(comm.(*ast.CommClause).Body)
 			anyReachable = anyReachable || d.reachable
 		}
 		d.reachable = anyReachable || d.hasBreak[x]
@@ -273,9 +294,7 @@ func (d *deadState) findDead(stmt ast.Stmt) {
 				hasDefault = true
 			}
 			d.reachable = true
-			for _, stmt := range cc.Body {
-				d.findDead(stmt)
-			}
+			d.findDeadList(cc.Body)
 			anyReachable = anyReachable || d.reachable
 		}
 		d.reachable = anyReachable || d.hasBreak[x] || !hasDefault
@@ -289,11 +308,33 @@ func (d *deadState) findDead(stmt ast.Stmt) {
 				hasDefault = true
 			}
 			d.reachable = true
-			for _, stmt := range cc.Body {
-				d.findDead(stmt)
-			}
+			d.findDeadList(cc.Body)
 			anyReachable = anyReachable || d.reachable
 		}
 		d.reachable = anyReachable || d.hasBreak[x] || !hasDefault
 	}
+}
+
+// findDeadList walks the statements of list looking for dead code.
+func (d *deadState) findDeadList(list []ast.Stmt) {
+	for i, stmt := range list {
+		d.following = list[i+1:]
+		d.findDead(stmt)
+	}
+}
+
+// deleteFix returns the fix that deletes the unreachable statement stmt
+// and the statements following it, up to the next goto target.
+func (d *deadState) deleteFix(stmt ast.Stmt, following []ast.Stmt) *SuggestedFix {
+	last := stmt
+	for _, s := range following {
+		if x, isLabel := s.(*ast.LabeledStmt); isLabel && d.hasGoto[x.Label.Name] {
+			break
+		}
+		last = s
+	}
+	return &SuggestedFix{
+		Message: "remove unreachable code",
+		Edits:   []TextEdit{d.f.deleteEdit(stmt.Pos(), last.End())},
+	}
 }
\ No newline at end of file
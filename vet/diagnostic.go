@@ -0,0 +1,377 @@
+// antha-tools/vet/diagnostic.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the representation of the problems reported by
+// the checks, and their output as text, JSON or SARIF.
+
+package vet
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var jsonFlag = flag.Bool("json", false, "write diagnostics to standard output as JSON")
+var sarifFlag = flag.Bool("sarif", false, "write diagnostics to standard output as SARIF 2.1.0")
+
+// Severity is the severity of a diagnostic.
+type Severity string
+
+const (
+	// An Error sets the exit code of vet.
+	Error Severity = "error"
+	// A Warning does not.
+	Warning Severity = "warning"
+)
+
+// A Diagnostic is a problem reported by a check.
+type Diagnostic struct {
+	Check    string         // name of the check; set by File.Report if empty
+	Severity Severity       // Error if empty
+	Pos      token.Position // start of the problem; Line is 0 if unknown
+	End      token.Position // end of the problem, or invalid if unknown
+	Message  string
+	Fix      *SuggestedFix // optional
+}
+
+// A SuggestedFix is a change to the source that would correct the
+// problem reported by a diagnostic.
+type SuggestedFix struct {
+	Message string
+	Edits   []TextEdit
+}
+
+// A TextEdit replaces the text between Pos and End, which are in the
+// same file, with NewText.
+type TextEdit struct {
+	Pos, End token.Position
+	NewText  string
+}
+
+// A Range is the extent of the source of a problem, such as an ast.Node.
+type Range interface {
+	Pos() token.Pos
+	End() token.Pos
+}
+
+// diagnostics holds the diagnostics reported so far, if they are to
+// be written on completion as JSON or SARIF.
+var diagnostics []*Diagnostic
+
+// reportMu serializes the reporting of diagnostics by packages
+// checked in parallel, and guards diagnostics and the baseline.
+var reportMu sync.Mutex
+
+// Report reports the diagnostic d, and sets the exit code if it is an
+// Error, unless d is suppressed by a vet:ignore comment or the baseline,
+// or fixed by -fix.
+func (f *File) Report(d Diagnostic) {
+	if d.Check == "" {
+		d.Check = f.check
+	}
+	if d.Severity == "" {
+		d.Severity = Error
+	}
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if f.suppressed(&d) || addFix(&d) {
+		return
+	}
+	if d.Severity == Error {
+		setExit(1)
+	}
+	if *jsonFlag || *sarifFlag {
+		diagnostics = append(diagnostics, &d)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s%s\n", posString(d.Pos), d.Message)
+}
+
+// BadRangef reports a formatted error spanning r and sets the exit code.
+func (f *File) BadRangef(r Range, format string, args ...interface{}) {
+	f.Report(Diagnostic{
+		Pos:     f.fset.Position(r.Pos()),
+		End:     f.position(r.End()),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Edit returns the TextEdit that replaces the text between pos and
+// end with newText, for use in a SuggestedFix.
+func (f *File) Edit(pos, end token.Pos, newText string) TextEdit {
+	return TextEdit{f.fset.Position(pos), f.fset.Position(end), newText}
+}
+
+// reportLinef reports a formatted diagnostic at line of the file, for
+// checks of its raw content.
+func (f *File) reportLinef(severity Severity, line int, format string, args ...interface{}) {
+	f.Report(Diagnostic{
+		Severity: severity,
+		Pos:      token.Position{Filename: f.name, Line: line},
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// position returns the position of pos, or an invalid position if pos
+// is token.NoPos.
+func (f *File) position(pos token.Pos) token.Position {
+	if pos == token.NoPos {
+		return token.Position{}
+	}
+	return f.fset.Position(pos)
+}
+
+// posString returns the prefix of a diagnostic at posn in text output.
+// Columns are not printed: because the position often points to the
+// start of an expression instead of the inner part with the actual
+// error, the precision can mislead.
+func posString(posn token.Position) string {
+	switch {
+	case posn.IsValid():
+		return fmt.Sprintf("%s:%d: ", posn.Filename, posn.Line)
+	case posn.Filename != "":
+		return posn.Filename + ": "
+	}
+	return ""
+}
+
+// writeDiagnostics writes the diagnostics to standard output in the
+// format requested by the flags, if any.
+func writeDiagnostics() {
+	var v interface{}
+	switch {
+	case *sarifFlag:
+		v = sarifLog()
+	case *jsonFlag:
+		ds := make([]jsonDiagnostic, len(diagnostics))
+		for i, d := range diagnostics {
+			ds[i] = jsonDiagnostic{
+				Check:    d.Check,
+				Severity: d.Severity,
+				Pos:      jsonPosition(d.Pos),
+				End:      jsonPosition(d.End),
+				Message:  d.Message,
+			}
+			if d.Fix != nil {
+				fix := &jsonFix{Message: d.Fix.Message}
+				for _, e := range d.Fix.Edits {
+					fix.Edits = append(fix.Edits, jsonEdit{jsonPosition(e.Pos), jsonPosition(e.End), e.NewText})
+				}
+				ds[i].Fix = fix
+			}
+		}
+		v = ds
+	default:
+		return
+	}
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		errorf("%s", err)
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// JSON output.
+
+type jsonDiagnostic struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Pos      *jsonPos `json:"pos,omitempty"`
+	End      *jsonPos `json:"end,omitempty"`
+	Message  string   `json:"message"`
+	Fix      *jsonFix `json:"fix,omitempty"`
+}
+
+type jsonPos struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
+type jsonFix struct {
+	Message string     `json:"message"`
+	Edits   []jsonEdit `json:"edits"`
+}
+
+type jsonEdit struct {
+	Pos     *jsonPos `json:"pos"`
+	End     *jsonPos `json:"end"`
+	NewText string   `json:"new"`
+}
+
+func jsonPosition(posn token.Position) *jsonPos {
+	if !posn.IsValid() {
+		return nil
+	}
+	return &jsonPos{posn.Filename, posn.Line, posn.Column, posn.Offset}
+}
+
+// SARIF output, following the Static Analysis Results Interchange
+// Format version 2.1.0.  Only the properties vet can fill are present.
+
+type sarifLogFile struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     Severity        `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// sarifLog returns the diagnostics as a SARIF log of a single run,
+// whose rules are the enabled checks.
+func sarifLog() *sarifLogFile {
+	run := sarifRun{
+		Tool:    sarifTool{sarifDriver{Name: "vet"}},
+		Results: []sarifResult{},
+	}
+	for _, c := range enabled {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{c.Name, sarifMessage{c.Doc}})
+	}
+	for _, d := range diagnostics {
+		r := sarifResult{
+			RuleID:  d.Check,
+			Level:   d.Severity,
+			Message: sarifMessage{d.Message},
+		}
+		if d.Pos.Filename != "" {
+			r.Locations = []sarifLocation{{sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{filepath.ToSlash(d.Pos.Filename)},
+				Region:           sarifRegionOf(d.Pos, d.End),
+			}}}
+		}
+		if d.Fix != nil && len(d.Fix.Edits) > 0 {
+			// Group the edits by file, in order of first appearance.
+			var changes []sarifArtifactChange
+			index := make(map[string]int)
+			for _, e := range d.Fix.Edits {
+				if !e.Pos.IsValid() {
+					continue // a replacement needs a region
+				}
+				i, ok := index[e.Pos.Filename]
+				if !ok {
+					i = len(changes)
+					index[e.Pos.Filename] = i
+					changes = append(changes, sarifArtifactChange{
+						ArtifactLocation: sarifArtifactLocation{filepath.ToSlash(e.Pos.Filename)},
+					})
+				}
+				changes[i].Replacements = append(changes[i].Replacements, sarifReplacement{
+					DeletedRegion:   *sarifRegionOf(e.Pos, e.End),
+					InsertedContent: sarifMessage{e.NewText},
+				})
+			}
+			if len(changes) > 0 {
+				r.Fixes = []sarifFix{{sarifMessage{d.Fix.Message}, changes}}
+			}
+		}
+		run.Results = append(run.Results, r)
+	}
+	return &sarifLogFile{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// sarifRegionOf returns the region between pos and end, or nil if
+// pos is unknown.
+func sarifRegionOf(pos, end token.Position) *sarifRegion {
+	if !pos.IsValid() {
+		return nil
+	}
+	r := &sarifRegion{StartLine: pos.Line, StartColumn: pos.Column}
+	if end.IsValid() {
+		r.EndLine, r.EndColumn = end.Line, end.Column
+	}
+	return r
+}
\ No newline at end of file
@@ -0,0 +1,344 @@
+// antha-tools/vet/element.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the code to check the blocks of an Antha element.
+An element compiles to a package that declares the struct types
+Parameters, Inputs, Outputs and Data, and the phase functions Setup,
+Steps, Analysis and Validation that take them.  A parameter or input
+that no phase reads is a mistake in the element's interface, as is an
+output that no phase sets, or a data field that is set but whose
+unexported name hides it from the element's consumers.
+*/
+
+package vet
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+func init() {
+	Register(&Checker{
+		Name:       "elements",
+		Doc:        "check for unused Parameters and Inputs and unset Outputs and Data of elements",
+		RunPackage: checkElement,
+	})
+}
+
+// elementBlocks lists the names of the block types of an element.
+var elementBlocks = []string{"Parameters", "Inputs", "Outputs", "Data"}
+
+// elementPhases lists the names of the phase functions of an element.
+var elementPhases = map[string]bool{
+	"Setup":      true,
+	"Steps":      true,
+	"Analysis":   true,
+	"Validation": true,
+}
+
+// An elementUse records how the phases of an element use the fields
+// of its blocks.  A selector that could not be resolved, because of a
+// type error, is recorded by field name.
+type elementUse struct {
+	pkg         *Package
+	blocks      map[*types.Named]string // block type -> block name
+	read        map[*types.Var]bool
+	written     map[*types.Var]bool
+	escaped     map[*types.Var]bool // field of a block whose value escapes
+	readName    map[string]bool
+	writtenName map[string]bool
+	callees     map[*types.Func]bool // functions whose arguments do not escape
+}
+
+func checkElement(pkg *Package) {
+	if pkg.typesPkg == nil {
+		return
+	}
+	u := &elementUse{
+		pkg:         pkg,
+		blocks:      make(map[*types.Named]string),
+		read:        make(map[*types.Var]bool),
+		written:     make(map[*types.Var]bool),
+		readName:    make(map[string]bool),
+		escaped:     make(map[*types.Var]bool),
+		writtenName: make(map[string]bool),
+		callees:     make(map[*types.Func]bool),
+	}
+	scope := pkg.typesPkg.Scope()
+	for _, name := range elementBlocks {
+		if tn, ok := scope.Lookup(name).(*types.TypeName); ok {
+			if named, ok := tn.Type().(*types.Named); ok {
+				if _, ok := named.Underlying().(*types.Struct); ok {
+					u.blocks[named] = name
+				}
+			}
+		}
+	}
+	if len(u.blocks) == 0 {
+		return
+	}
+
+	// Find the phases and the functions of the package that they call.
+	decls := make(map[*types.Func]*ast.FuncDecl)
+	var queue []*ast.FuncDecl
+	for _, f := range pkg.files {
+		if f.file == nil {
+			continue // assembly
+		}
+		for _, decl := range f.file.Decls {
+			d, ok := decl.(*ast.FuncDecl)
+			if !ok || d.Body == nil {
+				continue
+			}
+			if fn, ok := pkg.defs[d.Name].(*types.Func); ok {
+				decls[fn] = d
+			}
+			if elementPhases[d.Name.Name] {
+				queue = append(queue, d)
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return
+	}
+	seen := make(map[*ast.FuncDecl]bool)
+	for _, d := range queue {
+		seen[d] = true
+	}
+	for i := 0; i < len(queue); i++ {
+		ast.Inspect(queue[i].Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if fn, ok := pkg.uses[id].(*types.Func); ok {
+					if d := decls[fn]; d != nil {
+						u.callees[fn] = true
+						if !seen[d] {
+							seen[d] = true
+							queue = append(queue, d)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	for _, d := range queue {
+		u.walk(d.Body, nil)
+	}
+
+	var reports []ssaReport
+	for named, name := range u.blocks {
+		st := named.Underlying().(*types.Struct)
+		for i := 0; i < st.NumFields(); i++ {
+			v := st.Field(i)
+			read := u.read[v] || u.readName[v.Name()]
+			written := u.written[v] || u.writtenName[v.Name()]
+			var msg string
+			switch {
+			case (name == "Parameters" || name == "Inputs") && !read && !u.escaped[v]:
+				msg = "is never used"
+			case name == "Outputs" && !written && !u.escaped[v]:
+				msg = "is never set"
+			case name == "Data" && written && !v.Exported():
+				msg = "is set but not exported"
+			default:
+				continue
+			}
+			reports = append(reports, ssaReport{v.Pos(), v.Pos() + token.Pos(len(v.Name())), fmt.Sprintf("%s.%s %s", name, v.Name(), msg)})
+		}
+	}
+	pkg.reportAll(reports)
+}
+
+// walk records the uses of block fields in the subtree rooted at n,
+// whose ancestors are stack, innermost last.
+func (u *elementUse) walk(n ast.Node, stack []ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			u.selector(n, stack)
+		case *ast.CompositeLit:
+			u.compositeLit(n)
+		case *ast.Ident:
+			u.ident(n, stack)
+		}
+		stack = append(stack, n)
+		return true
+	})
+}
+
+// selector records the use of the field selected by sel, if any.
+func (u *elementUse) selector(sel *ast.SelectorExpr, stack []ast.Node) {
+	read, written := access(sel, stack)
+	s, ok := u.pkg.info.Selections[sel]
+	if !ok {
+		if _, ok := u.pkg.uses[sel.Sel]; !ok {
+			// Unresolved: assume the worst of any field of that name.
+			u.readName[sel.Sel.Name] = u.readName[sel.Sel.Name] || read
+			u.writtenName[sel.Sel.Name] = u.writtenName[sel.Sel.Name] || written
+		}
+		return
+	}
+	if s.Kind() != types.FieldVal {
+		return
+	}
+	if v, ok := s.Obj().(*types.Var); ok {
+		u.read[v] = u.read[v] || read
+		u.written[v] = u.written[v] || written
+		u.escape(sel, v.Type(), stack)
+	}
+}
+
+// compositeLit records the fields set by a literal of a block type.
+func (u *elementUse) compositeLit(lit *ast.CompositeLit) {
+	named, st := u.block(u.pkg.types[lit].Type)
+	if named == nil {
+		return
+	}
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				if v, ok := u.pkg.uses[key].(*types.Var); ok {
+					u.written[v] = true
+				} else {
+					u.writtenName[key.Name] = true
+				}
+			}
+		} else if i < st.NumFields() {
+			u.written[st.Field(i)] = true
+		}
+	}
+}
+
+// ident records the use of the variable id, if it holds a block.
+func (u *elementUse) ident(id *ast.Ident, stack []ast.Node) {
+	if v, ok := u.pkg.uses[id].(*types.Var); ok && !v.IsField() {
+		u.escape(id, v.Type(), stack)
+	}
+}
+
+// escape records that all the fields of a block may be used if x, of
+// type t, is a block, or a pointer to one, whose value escapes the
+// analysis: that is, if x is used other than to select a field or as
+// the argument of a function of the package.
+func (u *elementUse) escape(x ast.Expr, t types.Type, stack []ast.Node) {
+	_, st := u.block(t)
+	if st == nil {
+		return
+	}
+	switch parent := stack[len(stack)-1].(type) {
+	case *ast.SelectorExpr:
+		if s, ok := u.pkg.info.Selections[parent]; parent.X == x && ok && s.Kind() == types.FieldVal {
+			return
+		}
+	case *ast.CallExpr:
+		if fn, ok := u.pkg.uses[calleeIdent(parent.Fun)].(*types.Func); ok && u.callees[fn] {
+			return
+		}
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		u.escaped[st.Field(i)] = true
+	}
+}
+
+// block returns the block type that is t, or that t points to, and
+// its underlying struct.
+func (u *elementUse) block(t types.Type) (*types.Named, *types.Struct) {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || u.blocks[named] == "" {
+		return nil, nil
+	}
+	return named, named.Underlying().(*types.Struct)
+}
+
+// calleeIdent returns the identifier naming the function called by
+// fun, or nil.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	case *ast.ParenExpr:
+		return calleeIdent(fun.X)
+	}
+	return nil
+}
+
+// access reports whether the field selected by sel, whose ancestors
+// are stack, is read or written.  Setting part of a field, such as an
+// element of a slice it holds, sets the field; taking its address
+// both reads and sets it.
+func access(sel *ast.SelectorExpr, stack []ast.Node) (read, written bool) {
+	var x ast.Expr = sel
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch p := stack[i].(type) {
+		case *ast.SelectorExpr:
+			if p.X != x {
+				return true, false
+			}
+			x = p
+			continue
+		case *ast.IndexExpr:
+			if p.X != x {
+				return true, false
+			}
+			x = p
+			continue
+		case *ast.StarExpr:
+			x = p
+			continue
+		case *ast.ParenExpr:
+			x = p
+			continue
+		case *ast.AssignStmt:
+			for _, lhs := range p.Lhs {
+				if lhs == x {
+					return p.Tok != token.ASSIGN && p.Tok != token.DEFINE, true
+				}
+			}
+		case *ast.IncDecStmt:
+			return true, true
+		case *ast.RangeStmt:
+			if p.Key == x || p.Value == x {
+				return false, true
+			}
+		case *ast.UnaryExpr:
+			if p.Op == token.AND {
+				return true, true
+			}
+		}
+		return true, false
+	}
+	return true, false
+}
\ No newline at end of file
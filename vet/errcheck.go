@@ -0,0 +1,193 @@
+// antha-tools/vet/errcheck.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the check for errors returned by calls and then
+// discarded.
+
+package vet
+
+import (
+	"flag"
+	"github.com/antha-lang/antha/ast"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+var errfuncs = flag.String("errfuncs", "", "comma-separated list of functions whose errors may be discarded")
+
+// errAllowed records the functions whose errors may be discarded,
+// by name, by package-qualified name such as fmt.Println, or by method
+// such as (*bytes.Buffer).Write.
+var errAllowed = map[string]bool{
+	"fmt.Print":                   true,
+	"fmt.Printf":                  true,
+	"fmt.Println":                 true,
+	"(*bytes.Buffer).Write":       true,
+	"(*bytes.Buffer).WriteByte":   true,
+	"(*bytes.Buffer).WriteRune":   true,
+	"(*bytes.Buffer).WriteString": true,
+}
+
+func init() {
+	Register(&Checker{
+		Name:  "errcheck",
+		Doc:   "check for errors returned by calls that are discarded",
+		Nodes: []ast.Node{exprStmt, assignStmt},
+		Run:   checkUncheckedError,
+	})
+}
+
+// checkUncheckedError checks for calls whose error result is discarded,
+// by calling them as a statement or by assigning the error to the blank
+// identifier.
+func checkUncheckedError(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.ExprStmt:
+		if call, ok := n.X.(*ast.CallExpr); ok && len(f.errorResults(call)) > 0 {
+			f.reportUncheckedError(call)
+		}
+	case *ast.AssignStmt:
+		if len(n.Rhs) == 1 {
+			// x, _ := f()
+			call, ok := n.Rhs[0].(*ast.CallExpr)
+			if !ok {
+				return
+			}
+			for _, i := range f.errorResults(call) {
+				if i < len(n.Lhs) && isBlank(n.Lhs[i]) {
+					f.reportUncheckedError(call)
+					return
+				}
+			}
+			return
+		}
+		// x, _ := f(), g()
+		for i, rhs := range n.Rhs {
+			call, ok := rhs.(*ast.CallExpr)
+			if ok && i < len(n.Lhs) && isBlank(n.Lhs[i]) && len(f.errorResults(call)) > 0 {
+				f.reportUncheckedError(call)
+			}
+		}
+	}
+}
+
+func (f *File) reportUncheckedError(call *ast.CallExpr) {
+	f.BadRangef(call, "error returned by %s is not checked", f.gofmt(call.Fun))
+}
+
+// isBlank reports whether x is the blank identifier.
+func isBlank(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// errorResults returns the indexes of the results of call that are of
+// type error, unless its function is allowed to discard them.
+func (f *File) errorResults(call *ast.CallExpr) []int {
+	typ := f.pkg.types[call].Type
+	if typ == nil {
+		return nil
+	}
+	var results []int
+	if tuple, ok := typ.(*types.Tuple); ok {
+		for i := 0; i < tuple.Len(); i++ {
+			if isError(tuple.At(i).Type()) {
+				results = append(results, i)
+			}
+		}
+	} else if isError(typ) {
+		results = append(results, 0)
+	}
+	if len(results) == 0 || f.errAllowed(call) {
+		return nil
+	}
+	return results
+}
+
+// isError reports whether typ is the predeclared type error.
+func isError(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+// errAllowed reports whether the function called by call is listed in
+// errAllowed.
+func (f *File) errAllowed(call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+	if errAllowed[id.Name] {
+		return true
+	}
+	fn, ok := f.pkg.uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	if recv := sig.Recv(); recv != nil {
+		// Writing to standard output or error is allowed.
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && isStdStream(f.gofmt(sel.X)) {
+			return true
+		}
+		return errAllowed["("+recv.Type().String()+")."+fn.Name()]
+	}
+	name := fn.Pkg().Path() + "." + fn.Name()
+	if fprintToStd[name] && len(call.Args) > 0 {
+		// So is printing to them, or to a buffer.
+		if isStdStream(f.gofmt(call.Args[0])) {
+			return true
+		}
+		if typ := f.pkg.types[call.Args[0]].Type; typ != nil && bufferTypes[typ.String()] {
+			return true
+		}
+	}
+	return errAllowed[name]
+}
+
+// isStdStream reports whether the expression x, formatted, denotes
+// standard output or error.
+func isStdStream(x string) bool {
+	return x == "os.Stdout" || x == "os.Stderr"
+}
+
+// fprintToStd records the functions whose errors may be discarded when
+// they print to standard output or error, or to one of bufferTypes.
+var fprintToStd = map[string]bool{
+	"fmt.Fprint":   true,
+	"fmt.Fprintf":  true,
+	"fmt.Fprintln": true,
+}
+
+// bufferTypes records the writers that only buffer what is printed to
+// them, so that printing to them cannot fail.  A tabwriter.Writer
+// reports the errors of its destination when it is flushed.
+var bufferTypes = map[string]bool{
+	"*bytes.Buffer":          true,
+	"*strings.Builder":       true,
+	"*text/tabwriter.Writer": true,
+}
\ No newline at end of file
@@ -0,0 +1,256 @@
+// antha-tools/vet/fix.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the code to apply the fixes suggested by the
+// checks, for -fix and -diff.
+
+package vet
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/parser"
+	"github.com/antha-lang/antha/printer"
+	"github.com/antha-lang/antha/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+)
+
+var fixFlag = flag.Bool("fix", false, "apply the suggested fixes to the files")
+var diffFlag = flag.Bool("diff", false, "display the suggested fixes as diffs instead of applying them")
+
+// fixes maps the name of each file to the edits suggested for it.
+// It is guarded by reportMu.
+var fixes = make(map[string][]TextEdit)
+
+// fixing reports whether suggested fixes are to be collected.
+func fixing() bool {
+	return *fixFlag || *diffFlag
+}
+
+// addFix records the edits of d's suggested fix, if any, and reports
+// whether d is thereby fixed, so that it need not be reported.  With
+// -diff nothing is fixed.
+func addFix(d *Diagnostic) bool {
+	if !fixing() || d.Fix == nil || len(d.Fix.Edits) == 0 {
+		return false
+	}
+	for _, e := range d.Fix.Edits {
+		fixes[e.Pos.Filename] = append(fixes[e.Pos.Filename], e)
+	}
+	return !*diffFlag
+}
+
+// applyFixes applies the suggested fixes to each file, or with -diff
+// prints the differences they would make.
+func applyFixes() {
+	var names []string
+	for name := range fixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			warnf("%s", err)
+			continue
+		}
+		res, err := applyEdits(name, src, fixes[name])
+		if err != nil {
+			warnf("%s: cannot apply fixes: %s", name, err)
+			continue
+		}
+		if bytes.Equal(src, res) {
+			continue
+		}
+		if *diffFlag {
+			data, err := diff(src, res)
+			if err != nil {
+				warnf("computing diff: %s", err)
+				continue
+			}
+			fmt.Printf("diff %s fixed/%s\n", name, name)
+			os.Stdout.Write(data)
+			continue
+		}
+		if err := ioutil.WriteFile(name, res, 0); err != nil {
+			warnf("%s", err)
+		}
+	}
+}
+
+// applyEdits returns src, the content of the named file, with edits
+// applied and the declarations they touch reformatted by antha/printer.
+// Duplicate edits, which arise when a problem is reported at several
+// places, are applied once; an edit that overlaps an earlier one is
+// dropped, so that its problem remains to be fixed by a later run.
+func applyEdits(name string, src []byte, edits []TextEdit) ([]byte, error) {
+	sort.Stable(byOffset(edits))
+	var b bytes.Buffer
+	var edited []int // offsets of the edits in the result
+	offset := 0
+	seen := make(map[TextEdit]bool)
+	for _, e := range edits {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		start, end := e.Pos.Offset, e.End.Offset
+		if start < offset || end < start || end > len(src) {
+			continue
+		}
+		b.Write(src[offset:start])
+		edited = append(edited, b.Len())
+		b.WriteString(e.NewText)
+		offset = end
+	}
+	b.Write(src[offset:])
+	return formatDecls(name, b.Bytes(), edited)
+}
+
+// formatDecls returns src with each top-level declaration that
+// contains one of the edited offsets reformatted.  The rest of the file
+// is left as it was.
+func formatDecls(name string, src []byte, edited []int) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	var b bytes.Buffer
+	offset := 0
+	for _, decl := range file.Decls {
+		start, end := fset.Position(decl.Pos()).Offset, fset.Position(decl.End()).Offset
+		touched := false
+		for _, off := range edited {
+			if start <= off && off <= end {
+				touched = true
+				break
+			}
+		}
+		if !touched {
+			continue
+		}
+		var comments []*ast.CommentGroup
+		for _, c := range file.Comments {
+			if c.Pos() >= decl.Pos() && c.End() <= decl.End() {
+				comments = append(comments, c)
+			}
+		}
+		// The doc comment precedes the declaration and is kept as it is.
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			d.Doc = nil
+		case *ast.GenDecl:
+			d.Doc = nil
+		}
+		b.Write(src[offset:start])
+		if err := config.Fprint(&b, fset, &printer.CommentedNode{Node: decl, Comments: comments}); err != nil {
+			return nil, err
+		}
+		offset = end
+	}
+	b.Write(src[offset:])
+	return b.Bytes(), nil
+}
+
+type byOffset []TextEdit
+
+func (p byOffset) Len() int      { return len(p) }
+func (p byOffset) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byOffset) Less(i, j int) bool {
+	if p[i].Pos.Offset != p[j].Pos.Offset {
+		return p[i].Pos.Offset < p[j].Pos.Offset
+	}
+	return p[i].End.Offset < p[j].End.Offset
+}
+
+// deleteEdit returns the TextEdit that deletes the text between pos
+// and end or, if that text is alone on its lines but for a trailing
+// comment, the lines too.
+func (f *File) deleteEdit(pos, end token.Pos) TextEdit {
+	e := f.Edit(pos, end, "")
+	start, stop := e.Pos.Offset, e.End.Offset
+	for start > 0 && (f.content[start-1] == ' ' || f.content[start-1] == '\t') {
+		start--
+	}
+	for stop < len(f.content) && (f.content[stop] == ' ' || f.content[stop] == '\t') {
+		stop++
+	}
+	if bytes.HasPrefix(f.content[stop:], []byte("//")) {
+		for stop < len(f.content) && f.content[stop] != '\n' {
+			stop++
+		}
+	}
+	if (start == 0 || f.content[start-1] == '\n') && (stop == len(f.content) || f.content[stop] == '\n') {
+		if stop < len(f.content) {
+			stop++
+		}
+		e.Pos = f.offsetPosition(start)
+		e.End = f.offsetPosition(stop)
+	}
+	return e
+}
+
+// offsetPosition returns the position at offset in the file.
+func (f *File) offsetPosition(offset int) token.Position {
+	tf := f.fset.File(f.file.Pos())
+	return f.fset.Position(tf.Pos(offset))
+}
+
+// diff returns the unified diff of b1 and b2, computed by diff(1).
+func diff(b1, b2 []byte) (data []byte, err error) {
+	f1, err := ioutil.TempFile("", "vet")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f1.Name())
+	defer f1.Close()
+
+	f2, err := ioutil.TempFile("", "vet")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f2.Name())
+	defer f2.Close()
+
+	if _, err = f1.Write(b1); err != nil {
+		return
+	}
+	if _, err = f2.Write(b2); err != nil {
+		return
+	}
+
+	data, err = exec.Command("diff", "-u", f1.Name(), f2.Name()).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		err = nil
+	}
+	return
+}
\ No newline at end of file
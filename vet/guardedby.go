@@ -0,0 +1,366 @@
+// antha-tools/vet/guardedby.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the check for struct fields guarded by a mutex that
+are accessed without holding it.  A field is guarded by a mutex field
+of the same struct if its comment says so:
+
+	type queue struct {
+		mu    sync.Mutex
+		items []int // guarded by mu
+	}
+
+Within each function, the mutex is taken to be held from a call of its
+Lock or RLock method to the next call of Unlock or RUnlock, other than
+a deferred one.  An unlock in a block that ends by returning or
+branching lasts only to the end of that block.  A function literal
+that is called where it is written runs with the locks held at the
+call, and a deferred one with those held at the end of the function;
+any other function literal may run at any time, and holds no lock.
+Functions whose names end in Locked are expected to be called with
+all their locks held, and functions whose comments say
+
+	// mu must be held.
+
+with the mutex mu held, and variables declared in the function, such
+as a value under construction, are not yet shared.
+*/
+
+package vet
+
+import (
+	"bytes"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+	"regexp"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+func init() {
+	Register(&Checker{
+		Name:       "guardedby",
+		Doc:        "check for fields guarded by a mutex that are accessed without holding it",
+		RunPackage: checkGuardedBy,
+	})
+}
+
+var (
+	guardedByRE  = regexp.MustCompile(`guarded by (\w+)`)
+	mustBeHeldRE = regexp.MustCompile(`([\w.]+) must be held`)
+)
+
+// hasGuards reports whether src may contain guarded by comments,
+// which must then be parsed.
+func hasGuards(src []byte) bool {
+	return bytes.Contains(src, []byte("guarded by"))
+}
+
+func checkGuardedBy(pkg *Package) {
+	if pkg.typesPkg == nil {
+		return
+	}
+	guards := make(map[*types.Var]string) // field -> name of its mutex
+	for _, f := range pkg.files {
+		if f.file == nil {
+			continue // assembly
+		}
+		ast.Inspect(f.file, func(n ast.Node) bool {
+			if st, ok := n.(*ast.StructType); ok {
+				pkg.findGuards(st, guards)
+			}
+			return true
+		})
+	}
+	if len(guards) == 0 {
+		return
+	}
+	for _, f := range pkg.files {
+		if f.file == nil {
+			continue
+		}
+		ast.Inspect(f.file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Body != nil {
+					f.checkGuardedAccesses(n.Body, guards, heldByCaller(n))
+				}
+				return false
+			case *ast.FuncLit:
+				f.checkGuardedAccesses(n.Body, guards, nothingHeld)
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// findGuards records the fields of st whose comments name the mutex
+// field of st that guards them.
+func (pkg *Package) findGuards(st *ast.StructType, guards map[*types.Var]string) {
+	mutexes := make(map[string]bool)
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if v, ok := pkg.defs[name].(*types.Var); ok && isMutex(v.Type()) {
+				mutexes[name.Name] = true
+			}
+		}
+	}
+	for _, field := range st.Fields.List {
+		mu := guardName(field.Doc)
+		if mu == "" {
+			mu = guardName(field.Comment)
+		}
+		if !mutexes[mu] {
+			continue
+		}
+		for _, name := range field.Names {
+			if v, ok := pkg.defs[name].(*types.Var); ok {
+				guards[v] = mu
+			}
+		}
+	}
+}
+
+// guardName returns the name of the mutex named by a guarded by
+// comment, or "".
+func guardName(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	if m := guardedByRE.FindStringSubmatch(cg.Text()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// isMutex reports whether a pointer to a value of type typ has a Lock
+// method.
+func isMutex(typ types.Type) bool {
+	return types.NewMethodSet(types.NewPointer(typ)).Lookup(nil, "Lock") != nil
+}
+
+// heldByCaller returns a function that reports whether fn is expected
+// to be called with a mutex held: any mutex if the name of fn ends in
+// Locked, and otherwise those that its comment says must be held.  A
+// mutex may be named in full, as in "q.mu must be held", or by the
+// name of its field alone.
+func heldByCaller(fn *ast.FuncDecl) func(mutex string) bool {
+	if strings.HasSuffix(fn.Name.Name, "Locked") {
+		return func(string) bool { return true }
+	}
+	var names []string
+	if fn.Doc != nil {
+		for _, m := range mustBeHeldRE.FindAllStringSubmatch(fn.Doc.Text(), -1) {
+			names = append(names, m[1])
+		}
+	}
+	if names == nil {
+		return nothingHeld
+	}
+	return func(mutex string) bool {
+		for _, name := range names {
+			if mutex == name || strings.HasSuffix(mutex, "."+name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func nothingHeld(mutex string) bool { return false }
+
+// A lockEvent is a call that locks or unlocks a mutex, whose effect
+// lasts from pos to end.
+type lockEvent struct {
+	mutex    string // the mutex, as written
+	pos, end token.Pos
+	locked   bool
+}
+
+// A guardedAccess is the selection of a guarded field.
+type guardedAccess struct {
+	sel   *ast.SelectorExpr
+	mutex string // the mutex that guards it, as it would be written
+}
+
+// A nestedFunc is a function literal within the body of a function.
+type nestedFunc struct {
+	lit *ast.FuncLit
+	at  token.Pos // where it runs within the body, if known
+}
+
+// checkGuardedAccesses checks the accesses to guarded fields in the
+// body of a function, on entry to which held reports the mutexes
+// held, and then those in its function literals.
+func (f *File) checkGuardedAccesses(body *ast.BlockStmt, guards map[*types.Var]string, held func(mutex string) bool) {
+	var events []lockEvent
+	var accesses []guardedAccess
+	var nested []nestedFunc
+	var stack []ast.Node
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case nil:
+			stack = stack[:len(stack)-1]
+			return true
+		case *ast.FuncLit:
+			// A literal that is called at once runs there, and a
+			// deferred one at the end of the body.
+			nf := nestedFunc{lit: x}
+			if call, ok := stack[len(stack)-1].(*ast.CallExpr); ok && call.Fun == x {
+				switch stack[len(stack)-2].(type) {
+				case *ast.GoStmt:
+				case *ast.DeferStmt:
+					nf.at = body.Rbrace
+				default:
+					nf.at = call.Pos()
+				}
+			}
+			nested = append(nested, nf)
+			return false // checked below
+		case *ast.CallExpr:
+			if mutex, locked, ok := f.lockCall(x); ok {
+				if d, ok := stack[len(stack)-1].(*ast.DeferStmt); ok && d.Call == x {
+					break
+				}
+				end := body.End()
+				if !locked {
+					end = unlockEnd(stack, end)
+				}
+				events = append(events, lockEvent{mutex, x.Pos(), end, locked})
+			}
+		case *ast.SelectorExpr:
+			if v, ok := f.pkg.uses[x.Sel].(*types.Var); ok {
+				if mu, ok := guards[v]; ok && !f.declaredIn(x.X, body) {
+					accesses = append(accesses, guardedAccess{x, f.gofmt(x.X) + "." + mu})
+				}
+			}
+		}
+		stack = append(stack, n)
+		return true
+	})
+	for _, a := range accesses {
+		if !lockHeld(events, held, a.mutex, a.sel.Pos()) {
+			f.BadRangef(a.sel, "%s is guarded by %s but accessed without holding it", f.gofmt(a.sel), a.mutex)
+		}
+	}
+	for _, nf := range nested {
+		inner := nothingHeld
+		if at := nf.at; at.IsValid() {
+			inner = func(mutex string) bool { return lockHeld(events, held, mutex, at) }
+		}
+		f.checkGuardedAccesses(nf.lit.Body, guards, inner)
+	}
+}
+
+// lockCall reports whether call locks or unlocks a mutex, and which.
+func (f *File) lockCall(call *ast.CallExpr) (mutex string, locked, ok bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) != 0 {
+		return "", false, false
+	}
+	switch sel.Sel.Name {
+	case "Lock", "RLock":
+		locked = true
+	case "Unlock", "RUnlock":
+	default:
+		return "", false, false
+	}
+	return f.gofmt(sel.X), locked, true
+}
+
+// unlockEnd returns the end of the effect of an unlock within the nodes
+// of stack: the end of its block if that block ends by returning or
+// branching, and otherwise end, the end of the function.
+func unlockEnd(stack []ast.Node, end token.Pos) token.Pos {
+	for i := len(stack) - 1; i >= 0; i-- {
+		var list []ast.Stmt
+		switch b := stack[i].(type) {
+		case *ast.BlockStmt:
+			list = b.List
+		case *ast.CaseClause:
+			list = b.Body
+		case *ast.CommClause:
+			list = b.Body
+		default:
+			continue
+		}
+		if len(list) > 0 && isTerminating(list[len(list)-1]) {
+			return stack[i].End()
+		}
+		return end
+	}
+	return end
+}
+
+// isTerminating reports whether stmt returns, branches or panics.
+func isTerminating(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt:
+		return true
+	case *ast.ExprStmt:
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" && id.Obj == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declaredIn reports whether the variable at the root of x is declared
+// within body.
+func (f *File) declaredIn(x ast.Expr, body *ast.BlockStmt) bool {
+	for {
+		switch e := x.(type) {
+		case *ast.SelectorExpr:
+			x = e.X
+		case *ast.StarExpr:
+			x = e.X
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.IndexExpr:
+			x = e.X
+		case *ast.Ident:
+			v, ok := f.pkg.uses[e].(*types.Var)
+			return ok && body.Pos() <= v.Pos() && v.Pos() < body.End()
+		default:
+			return false
+		}
+	}
+}
+
+// lockHeld reports whether the last of events in effect at pos that
+// concerns mutex locks it, or if there is none, whether mutex is held
+// on entry to the function.
+func lockHeld(events []lockEvent, held func(mutex string) bool, mutex string, pos token.Pos) bool {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.mutex == mutex && e.pos < pos && pos < e.end {
+			return e.locked
+		}
+	}
+	return held(mutex)
+}
\ No newline at end of file
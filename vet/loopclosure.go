@@ -0,0 +1,149 @@
+// antha-tools/vet/loopclosure.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the check for loop variables captured by the
+function literals of go statements, in the cases that rangeloops does
+not cover: the variables of three-clause for loops, and go statements
+that are last in a block that is itself last in the loop body.
+
+For example:
+
+	for i := 0; i < n; i++ {
+		if ready[i] {
+			go func() {
+				run(i) // i has moved on by the time this runs
+			}()
+		}
+	}
+
+As in rangeloops, only go statements that end an iteration are
+examined; one that is followed by other statements may be waited for.
+*/
+
+package vet
+
+import (
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "loopclosure",
+		Doc:   "check for loop variables captured by goroutines",
+		Nodes: []ast.Node{forStmt, rangeStmt},
+		Run:   checkLoopClosure,
+	})
+}
+
+// checkLoopClosure checks the go statements that end an iteration of a
+// loop for function literals that capture its variables.
+func checkLoopClosure(f *File, node ast.Node) {
+	var vars []*ast.Ident
+	var body *ast.BlockStmt
+	switch n := node.(type) {
+	case *ast.ForStmt:
+		if init, ok := n.Init.(*ast.AssignStmt); ok && init.Tok == token.DEFINE {
+			for _, lhs := range init.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					vars = append(vars, id)
+				}
+			}
+		}
+		body = n.Body
+	case *ast.RangeStmt:
+		for _, x := range []ast.Expr{n.Key, n.Value} {
+			if id, ok := x.(*ast.Ident); ok {
+				vars = append(vars, id)
+			}
+		}
+		body = n.Body
+	}
+	if len(vars) == 0 || len(body.List) == 0 {
+		return
+	}
+	for _, g := range lastGoStmts(nil, body.List) {
+		if _, isRange := node.(*ast.RangeStmt); isRange && g == body.List[len(body.List)-1] {
+			continue // reported by rangeloops
+		}
+		lit, ok := g.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			continue
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Obj == nil {
+				return true
+			}
+			for _, v := range vars {
+				if id.Obj == v.Obj {
+					f.BadRangef(id, "loop variable %s captured by func literal in go statement", id.Name)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// lastGoStmts appends to gos the go statements that are the last to be
+// executed in list, directly or as the last statement of a nested block.
+func lastGoStmts(gos []*ast.GoStmt, list []ast.Stmt) []*ast.GoStmt {
+	if len(list) == 0 {
+		return gos
+	}
+	switch s := list[len(list)-1].(type) {
+	case *ast.GoStmt:
+		gos = append(gos, s)
+	case *ast.BlockStmt:
+		gos = lastGoStmts(gos, s.List)
+	case *ast.LabeledStmt:
+		gos = lastGoStmts(gos, []ast.Stmt{s.Stmt})
+	case *ast.IfStmt:
+		gos = lastGoStmts(gos, s.Body.List)
+		if s.Else != nil {
+			gos = lastGoStmts(gos, []ast.Stmt{s.Else})
+		}
+	case *ast.SwitchStmt:
+		gos = lastClauseGoStmts(gos, s.Body)
+	case *ast.TypeSwitchStmt:
+		gos = lastClauseGoStmts(gos, s.Body)
+	case *ast.SelectStmt:
+		gos = lastClauseGoStmts(gos, s.Body)
+	}
+	return gos
+}
+
+// lastClauseGoStmts appends to gos the last go statements of each
+// clause of a switch or select statement.
+func lastClauseGoStmts(gos []*ast.GoStmt, body *ast.BlockStmt) []*ast.GoStmt {
+	for _, clause := range body.List {
+		switch c := clause.(type) {
+		case *ast.CaseClause:
+			gos = lastGoStmts(gos, c.Body)
+		case *ast.CommClause:
+			gos = lastGoStmts(gos, c.Body)
+		}
+	}
+	return gos
+}
\ No newline at end of file
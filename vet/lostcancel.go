@@ -0,0 +1,75 @@
+// antha-tools/vet/lostcancel.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the check for discarded context cancel functions.
+
+package vet
+
+import (
+	"github.com/antha-lang/antha/ast"
+	"strings"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "lostcancel",
+		Doc:   "check for discarded cancel functions of contexts",
+		Nodes: []ast.Node{assignStmt},
+		Run:   checkLostCancel,
+	})
+}
+
+// checkLostCancel checks for assignments of the cancel function returned
+// by context.WithCancel, WithDeadline or WithTimeout to the blank
+// identifier.  The resources of such a context are not released until
+// its parent is canceled.
+func checkLostCancel(f *File, node ast.Node) {
+	a := node.(*ast.AssignStmt)
+	if len(a.Lhs) != 2 || len(a.Rhs) != 1 {
+		return
+	}
+	if id, ok := a.Lhs[1].(*ast.Ident); !ok || id.Name != "_" {
+		return
+	}
+	call, ok := a.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+	switch sel.Sel.Name {
+	case "WithCancel", "WithDeadline", "WithTimeout":
+	default:
+		return
+	}
+	if path := pkgPath(f, pkg.Name); path != "context" && !strings.HasSuffix(path, "/context") {
+		return
+	}
+	f.BadRangef(a.Lhs[1], "the cancel function returned by %s.%s should be called, not discarded, to avoid a context leak", pkg.Name, sel.Sel.Name)
+}
\ No newline at end of file
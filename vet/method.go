@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/method.go: Part of the Antha language
+// antha-tools/vet/method.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // This file contains the code to check canonical methods.
 
-package main
+package vet
 
 import (
 	"fmt"
@@ -74,10 +74,33 @@ var canonicalMethods = map[string]MethodSig{
 	"WriteTo":       {[]string{"=io.Writer"}, []string{"int64", "error"}}, // io.WriterTo
 }
 
-func (f *File) checkCanonicalMethod(id *ast.Ident, t *ast.FuncType) {
-	if !vet("methods") {
-		return
+func init() {
+	Register(&Checker{
+		Name:  "methods",
+		Doc:   "check that canonically named methods are canonically defined",
+		Nodes: []ast.Node{funcDecl, interfaceType},
+		Run:   checkCanonicalMethods,
+	})
+}
+
+// checkCanonicalMethods checks the signatures of a method
+// declaration, or of the methods of an interface.
+func checkCanonicalMethods(f *File, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if n.Recv != nil {
+			f.checkCanonicalMethod(n.Name, n.Type)
+		}
+	case *ast.InterfaceType:
+		for _, field := range n.Methods.List {
+			for _, id := range field.Names {
+				f.checkCanonicalMethod(id, field.Type.(*ast.FuncType))
+			}
+		}
 	}
+}
+
+func (f *File) checkCanonicalMethod(id *ast.Ident, t *ast.FuncType) {
 	// Expected input/output.
 	expect, ok := canonicalMethods[id.Name]
 	if !ok {
@@ -113,7 +136,7 @@ func (f *File) checkCanonicalMethod(id *ast.Ident, t *ast.FuncType) {
 		actual = strings.TrimPrefix(actual, "func")
 		actual = id.Name + actual
 
-		f.Badf(id.Pos(), "method %s should have signature %s", actual, expectFmt)
+		f.BadRangef(id, "method %s should have signature %s", actual, expectFmt)
 	}
 }
 
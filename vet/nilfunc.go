@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/nilfunc.go: Part of the Antha language
+// antha-tools/vet/nilfunc.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -26,7 +26,7 @@ This file contains the code to check for useless function comparisons.
 A useless comparison is one like f == nil as opposed to f() == nil.
 */
 
-package main
+package vet
 
 import (
 	"github.com/antha-lang/antha/ast"
@@ -35,11 +35,17 @@ import (
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
-func (f *File) checkNilFuncComparison(e *ast.BinaryExpr) {
-	if !vet("nilfunc") {
-		return
-	}
+func init() {
+	Register(&Checker{
+		Name:  "nilfunc",
+		Doc:   "check for comparisons between functions and nil",
+		Nodes: []ast.Node{binaryExpr},
+		Run:   checkNilFuncComparison,
+	})
+}
 
+func checkNilFuncComparison(f *File, node ast.Node) {
+	e := node.(*ast.BinaryExpr)
 	// Only want == or != comparisons.
 	if e.Op != token.EQL && e.Op != token.NEQ {
 		return
@@ -72,7 +78,7 @@ func (f *File) checkNilFuncComparison(e *ast.BinaryExpr) {
 		return
 	}
 
-	f.Badf(e.Pos(), "comparison of function %v %v nil is always %v", obj.Name(), e.Op, e.Op == token.NEQ)
+	f.BadRangef(e, "comparison of function %v %v nil is always %v", obj.Name(), e.Op, e.Op == token.NEQ)
 }
 
 // isNil reports whether the provided expression is the built-in nil
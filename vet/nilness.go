@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/structtag.go: Part of the Antha language
+// antha-tools/vet/nilness.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -21,36 +21,35 @@
 // 1 Royal College St, London NW1 0NH UK
 
 
-// This file contains the test for canonical struct tags.
+/*
+This file contains the code to check for operations that panic
+because a value is nil.  It runs the nilness analysis over the SSA
+form of the package.
+*/
 
-package main
+package vet
 
 import (
-	"github.com/antha-lang/antha/ast"
-	"reflect"
-	"strconv"
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/ssa/nilness"
 )
 
-// checkField checks a struct field tag.
-func (f *File) checkCanonicalFieldTag(field *ast.Field) {
-	if !vet("structtags") {
-		return
-	}
-	if field.Tag == nil {
-		return
-	}
+func init() {
+	Register(&Checker{
+		Name:       "nilness",
+		Doc:        "check for nil dereferences, nil map writes and nil function calls",
+		RunPackage: checkNilness,
+	})
+}
 
-	tag, err := strconv.Unquote(field.Tag.Value)
-	if err != nil {
-		f.Badf(field.Pos(), "unable to read struct tag %s", field.Tag.Value)
+func checkNilness(pkg *Package) {
+	if pkg.typeErr != nil {
 		return
 	}
-
-	// Check tag for validity by appending
-	// new key:value to end and checking that
-	// the tag parsing code can find it.
-	if reflect.StructTag(tag+` _gofix:"_magic"`).Get("_gofix") != "_magic" {
-		f.Badf(field.Pos(), "struct field tag %s not compatible with reflect.StructTag.Get", field.Tag.Value)
-		return
+	var reports []ssaReport
+	for _, r := range nilness.AnalyzePackage(pkg.ssaPackage()) {
+		reports = append(reports, ssaReport{r.Pos, token.NoPos, r.Msg})
 	}
+	pkg.reportAll(reports)
 }
\ No newline at end of file
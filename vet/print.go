@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/print.go: Part of the Antha language
+// antha-tools/vet/print.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // This file contains the printf-checker.
 
-package main
+package vet
 
 import (
 	"bytes"
@@ -35,6 +35,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/types"
 )
 
 var printfuncs = flag.String("printfuncs", "", "comma-separated list of print function names to check")
@@ -63,11 +64,34 @@ var printList = map[string]int{
 	"sprint": 0, "sprintln": 0,
 }
 
-// checkCall triggers the print-specific checks if the call invokes a print function.
-func (f *File) checkFmtPrintfCall(call *ast.CallExpr, Name string) {
-	if !vet("printf") {
+func init() {
+	Register(&Checker{
+		Name:  "printf",
+		Doc:   "check printf-like invocations",
+		Nodes: []ast.Node{funcDecl, callExpr},
+		Run:   checkFmtPrintfCall,
+	})
+}
+
+// checkFmtPrintfCall triggers the print-specific checks if the call
+// invokes a print function.  Function declarations are visited to
+// note String methods.
+func checkFmtPrintfCall(f *File, node ast.Node) {
+	if d, ok := node.(*ast.FuncDecl); ok {
+		f.prepStringerReceiver(d)
 		return
 	}
+	call := node.(*ast.CallExpr)
+	var Name string
+	switch x := call.Fun.(type) {
+	case *ast.Ident:
+		Name = x.Name
+	case *ast.SelectorExpr:
+		Name = x.Sel.Name
+	default:
+		return
+	}
+
 	name := strings.ToLower(Name)
 	if skip, ok := printfList[name]; ok {
 		f.checkPrintf(call, Name, skip)
@@ -79,6 +103,28 @@ func (f *File) checkFmtPrintfCall(call *ast.CallExpr, Name string) {
 	}
 }
 
+// prepStringerReceiver checks whether the given declaration is a fmt.Stringer
+// implementation, and if so sets the File's lastStringerReceiver field to the
+// declaration's receiver object.
+func (f *File) prepStringerReceiver(d *ast.FuncDecl) {
+	if !f.isStringer(d) {
+		return
+	}
+	if l := d.Recv.List; len(l) == 1 {
+		if n := l[0].Names; len(n) == 1 {
+			f.lastStringerReceiver = n[0].Obj
+		}
+	}
+}
+
+// isStringer returns true if the provided declaration is a "String() string"
+// method; an implementation of fmt.Stringer.
+func (f *File) isStringer(d *ast.FuncDecl) bool {
+	return d.Recv != nil && d.Name.Name == "String" && d.Type.Results != nil &&
+		len(d.Type.Params.List) == 0 && len(d.Type.Results.List) == 1 &&
+		f.pkg.types[d.Type.Results.List[0].Type].Type == types.Typ[types.String]
+}
+
 // formatState holds the parsed representation of a printf directive such as "%3.*[4]d".
 // It is constructed by parsePrintfVerb.
 type formatState struct {
@@ -101,7 +147,7 @@ type formatState struct {
 // call.Args[formatIndex] is (well, should be) the format argument.
 func (f *File) checkPrintf(call *ast.CallExpr, name string, formatIndex int) {
 	if formatIndex >= len(call.Args) {
-		f.Bad(call.Pos(), "too few arguments in call to", name)
+		f.BadRangef(call, "too few arguments in call to %s", name)
 		return
 	}
 	lit := f.pkg.types[call.Args[formatIndex]].Value
@@ -112,14 +158,14 @@ func (f *File) checkPrintf(call *ast.CallExpr, name string, formatIndex int) {
 		return
 	}
 	if lit.Kind() != exact.String {
-		f.Badf(call.Pos(), "constant %v not a string in call to %s", lit, name)
+		f.BadRangef(call, "constant %v not a string in call to %s", lit, name)
 		return
 	}
 	format := exact.StringVal(lit)
 	firstArg := formatIndex + 1 // Arguments are immediately after format string.
 	if !strings.Contains(format, "%") {
 		if len(call.Args) > firstArg {
-			f.Badf(call.Pos(), "no formatting directive in %s call", name)
+			f.BadRangef(call, "no formatting directive in %s call", name)
 		}
 		return
 	}
@@ -155,7 +201,7 @@ func (f *File) checkPrintf(call *ast.CallExpr, name string, formatIndex int) {
 	if !indexed && argNum != len(call.Args) {
 		expect := argNum - firstArg
 		numArgs := len(call.Args) - firstArg
-		f.Badf(call.Pos(), "wrong number of args for format in %s call: %d needed but %d args", name, expect, numArgs)
+		f.BadRangef(call, "wrong number of args for format in %s call: %d needed but %d args", name, expect, numArgs)
 	}
 }
 
@@ -193,12 +239,12 @@ func (s *formatState) parseIndex() bool {
 	start := s.nbytes
 	s.scanNum()
 	if s.nbytes == len(s.format) || s.nbytes == start || s.format[s.nbytes] != ']' {
-		s.file.Badf(s.call.Pos(), "illegal syntax for printf argument index")
+		s.file.BadRangef(s.call, "illegal syntax for printf argument index")
 		return false
 	}
 	arg32, err := strconv.ParseInt(s.format[start:s.nbytes], 10, 32)
 	if err != nil {
-		s.file.Badf(s.call.Pos(), "illegal syntax for printf argument index: %s", err)
+		s.file.BadRangef(s.call, "illegal syntax for printf argument index: %s", err)
 		return false
 	}
 	s.nbytes++ // skip ']'
@@ -276,7 +322,7 @@ func (f *File) parsePrintfVerb(call *ast.CallExpr, name, format string, firstArg
 		return nil
 	}
 	if state.nbytes == len(state.format) {
-		f.Badf(call.Pos(), "missing verb at end of format string in %s call", name)
+		f.BadRangef(call, "missing verb at end of format string in %s call", name)
 		return nil
 	}
 	verb, w := utf8.DecodeRuneInString(state.format[state.nbytes:])
@@ -362,12 +408,12 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 		}
 	}
 	if !found {
-		f.Badf(call.Pos(), "unrecognized printf verb %q", state.verb)
+		f.BadRangef(call, "unrecognized printf verb %q", state.verb)
 		return false
 	}
 	for _, flag := range state.flags {
 		if !strings.ContainsRune(v.flags, rune(flag)) {
-			f.Badf(call.Pos(), "unrecognized printf flag for verb %q: %q", state.verb, flag)
+			f.BadRangef(call, "unrecognized printf flag for verb %q: %q", state.verb, flag)
 			return false
 		}
 	}
@@ -385,7 +431,7 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 		}
 		arg := call.Args[argNum]
 		if !f.matchArgType(argInt, nil, arg) {
-			f.Badf(call.Pos(), "arg %s for * in printf format not of type int", f.gofmt(arg))
+			f.BadRangef(call, "arg %s for * in printf format not of type int", f.gofmt(arg))
 			return false
 		}
 	}
@@ -402,11 +448,11 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 		if typ := f.pkg.types[arg].Type; typ != nil {
 			typeString = typ.String()
 		}
-		f.Badf(call.Pos(), "arg %s for printf verb %%%c of wrong type: %s", f.gofmt(arg), state.verb, typeString)
+		f.BadRangef(call, "arg %s for printf verb %%%c of wrong type: %s", f.gofmt(arg), state.verb, typeString)
 		return false
 	}
 	if v.typ&argString != 0 && v.verb != 'T' && !bytes.Contains(state.flags, []byte{'#'}) && f.recursiveStringer(arg) {
-		f.Badf(call.Pos(), "arg %s for printf causes recursive call to String method", f.gofmt(arg))
+		f.BadRangef(call, "arg %s for printf causes recursive call to String method", f.gofmt(arg))
 		return false
 	}
 	return true
@@ -463,7 +509,7 @@ func (f *File) argCanBeChecked(call *ast.CallExpr, formatArg int, isStar bool, s
 	// There are bad indexes in the format or there are fewer arguments than the format needs.
 	// This is the argument number relative to the format: Printf("%s", "hi") will give 1 for the "hi".
 	arg := argNum - state.firstArg + 1 // People think of arguments as 1-indexed.
-	f.Badf(call.Pos(), `missing argument for %s("%s"): format reads arg %d, have only %d args`, state.name, state.format, arg, len(call.Args)-state.firstArg)
+	f.BadRangef(call, `missing argument for %s("%s"): format reads arg %d, have only %d args`, state.name, state.format, arg, len(call.Args)-state.firstArg)
 	return false
 }
 
@@ -478,7 +524,7 @@ func (f *File) checkPrint(call *ast.CallExpr, name string, firstArg int) {
 		if sel, ok := args[0].(*ast.SelectorExpr); ok {
 			if x, ok := sel.X.(*ast.Ident); ok {
 				if x.Name == "os" && strings.HasPrefix(sel.Sel.Name, "Std") {
-					f.Badf(call.Pos(), "first argument to %s is %s.%s", name, x.Name, sel.Sel.Name)
+					f.BadRangef(call, "first argument to %s is %s.%s", name, x.Name, sel.Sel.Name)
 				}
 			}
 		}
@@ -494,7 +540,7 @@ func (f *File) checkPrint(call *ast.CallExpr, name string, firstArg int) {
 		if !isLn {
 			// Check the signature to be sure: there are niladic functions called "error".
 			if firstArg != 0 || f.numArgsInSignature(call) != firstArg {
-				f.Badf(call.Pos(), "no args in %s call", name)
+				f.BadRangef(call, "no args in %s call", name)
 			}
 		}
 		return
@@ -502,7 +548,7 @@ func (f *File) checkPrint(call *ast.CallExpr, name string, firstArg int) {
 	arg := args[firstArg]
 	if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
 		if strings.Contains(lit.Value, "%") {
-			f.Badf(call.Pos(), "possible formatting directive in %s call", name)
+			f.BadRangef(call, "possible formatting directive in %s call", name)
 		}
 	}
 	if isLn {
@@ -510,13 +556,13 @@ func (f *File) checkPrint(call *ast.CallExpr, name string, firstArg int) {
 		arg = args[len(call.Args)-1]
 		if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
 			if strings.HasSuffix(lit.Value, `\n"`) {
-				f.Badf(call.Pos(), "%s call ends with newline", name)
+				f.BadRangef(call, "%s call ends with newline", name)
 			}
 		}
 	}
 	for _, arg := range args {
 		if f.recursiveStringer(arg) {
-			f.Badf(call.Pos(), "arg %s for print causes recursive call to String method", f.gofmt(arg))
+			f.BadRangef(call, "arg %s for print causes recursive call to String method", f.gofmt(arg))
 		}
 	}
 }
\ No newline at end of file
@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/rangeloop.go: Part of the Antha language
+// antha-tools/vet/rangeloop.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -38,17 +38,27 @@ For example:
 See: http://golang.org/doc/go_faq.html#closures_and_goroutines
 */
 
-package main
+package vet
 
-import "github.com/antha-lang/antha/ast"
+import (
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "rangeloops",
+		Doc:   "check that range loop variables are used correctly",
+		Nodes: []ast.Node{rangeStmt},
+		Run:   checkRangeLoop,
+	})
+}
 
 // checkRangeLoop walks the body of the provided range statement, checking if
 // its index or value variables are used unsafely inside goroutines or deferred
 // function literals.
-func checkRangeLoop(f *File, n *ast.RangeStmt) {
-	if !vet("rangeloops") {
-		return
-	}
+func checkRangeLoop(f *File, node ast.Node) {
+	n := node.(*ast.RangeStmt)
 	key, _ := n.Key.(*ast.Ident)
 	val, _ := n.Value.(*ast.Ident)
 	if key == nil && val == nil {
@@ -71,13 +81,22 @@ func checkRangeLoop(f *File, n *ast.RangeStmt) {
 	if !ok {
 		return
 	}
+	body := n.Body
 	ast.Inspect(lit.Body, func(n ast.Node) bool {
 		id, ok := n.(*ast.Ident)
 		if !ok || id.Obj == nil {
 			return true
 		}
 		if key != nil && id.Obj == key.Obj || val != nil && id.Obj == val.Obj {
-			f.Bad(id.Pos(), "range variable", id.Name, "enclosed by function")
+			f.Report(Diagnostic{
+				Pos:     f.fset.Position(id.Pos()),
+				End:     f.fset.Position(id.End()),
+				Message: fmt.Sprintf("range variable %s enclosed by function", id.Name),
+				Fix: &SuggestedFix{
+					Message: "copy " + id.Name + " in the loop body",
+					Edits:   []TextEdit{f.Edit(body.Lbrace+1, body.Lbrace+1, "\n"+id.Name+" := "+id.Name)},
+				},
+			})
 		}
 		return true
 	})
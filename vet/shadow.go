@@ -0,0 +1,281 @@
+// antha-tools/vet/shadow.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the code to check for shadowed variables.
+A shadowed variable is a variable declared in an inner scope
+with the same name and type as a variable in an outer scope
+of the same function, and where the outer variable is mentioned
+after the inner one is declared: later in the function, by a
+naked return if it is a named result, or anywhere in a loop
+that encloses the inner declaration but not the outer one.
+
+For example:
+
+	func BadRead(f *os.File, buf []byte) error {
+		var err error
+		for {
+			n, err := f.Read(buf) // shadows the function variable 'err'
+			if err != nil {
+				break // causes return of wrong value
+			}
+			foo(buf)
+		}
+		return err
+	}
+
+*/
+
+package vet
+
+import (
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "shadow",
+		Doc:   "check for shadowed variables",
+		Nodes: []ast.Node{assignStmt, genDecl},
+		Run:   checkShadow,
+	})
+}
+
+// findMentions records the positions at which each object of the
+// package is used, and the node of each scope.  A naked return counts
+// as a use of each named result of its function.
+func (pkg *Package) findMentions() {
+	pkg.mentions = make(map[types.Object][]token.Pos)
+	for id, obj := range pkg.uses {
+		pkg.mentions[obj] = append(pkg.mentions[obj], id.Pos())
+	}
+	pkg.scopeNodes = make(map[*types.Scope]ast.Node)
+	for node, scope := range pkg.info.Scopes {
+		pkg.scopeNodes[scope] = node
+	}
+	for _, file := range pkg.astFiles {
+		ast.Walk(returnVisitor{pkg: pkg}, file)
+	}
+}
+
+// returnVisitor records the naked returns of a function with named
+// results as mentions of the results.
+type returnVisitor struct {
+	pkg     *Package
+	results *ast.FieldList // of the enclosing function
+}
+
+func (v returnVisitor) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		return returnVisitor{v.pkg, n.Type.Results}
+	case *ast.FuncLit:
+		return returnVisitor{v.pkg, n.Type.Results}
+	case *ast.ReturnStmt:
+		if len(n.Results) > 0 || v.results == nil {
+			break
+		}
+		for _, field := range v.results.List {
+			for _, name := range field.Names {
+				if obj := v.pkg.defs[name]; obj != nil {
+					v.pkg.mentions[obj] = append(v.pkg.mentions[obj], n.Pos())
+				}
+			}
+		}
+	}
+	return v
+}
+
+// checkShadow checks for shadowing in a declaration.
+func checkShadow(f *File, node ast.Node) {
+	if f.pkg.mentions == nil {
+		return // not type-checked
+	}
+	switch n := node.(type) {
+	case *ast.AssignStmt:
+		f.checkShadowAssignment(n)
+	case *ast.GenDecl:
+		f.checkShadowDecl(n)
+	}
+}
+
+// checkShadowAssignment checks for shadowing in a short variable declaration.
+func (f *File) checkShadowAssignment(a *ast.AssignStmt) {
+	if a.Tok != token.DEFINE {
+		return
+	}
+	if f.idiomaticShortRedecl(a) {
+		return
+	}
+	for _, expr := range a.Lhs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok {
+			f.BadRangef(expr, "invalid AST: short variable declaration of non-identifier")
+			return
+		}
+		f.checkShadowing(ident)
+	}
+}
+
+// idiomaticShortRedecl reports whether this short declaration can be ignored for
+// the purposes of shadowing, that is, that any redeclarations it contains are deliberate.
+func (f *File) idiomaticShortRedecl(a *ast.AssignStmt) bool {
+	// Don't complain about deliberate redeclarations of the form
+	//	i := i
+	// Such constructs are idiomatic in range loops to create a new variable
+	// for each iteration. Another example is
+	//	switch n := n.(type)
+	if len(a.Rhs) != len(a.Lhs) {
+		return false
+	}
+	// We know it's an assignment, so the LHS must be all identifiers. (We check anyway.)
+	for i, expr := range a.Lhs {
+		lhs, ok := expr.(*ast.Ident)
+		if !ok {
+			f.BadRangef(expr, "invalid AST: short variable declaration of non-identifier")
+			return true // Don't do any more processing.
+		}
+		switch rhs := a.Rhs[i].(type) {
+		case *ast.Ident:
+			if lhs.Name != rhs.Name {
+				return false
+			}
+		case *ast.TypeAssertExpr:
+			if id, ok := rhs.X.(*ast.Ident); !ok || lhs.Name != id.Name {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// idiomaticRedecl reports whether this declaration spec can be ignored for
+// the purposes of shadowing, that is, that any redeclarations it contains are deliberate.
+func (f *File) idiomaticRedecl(d *ast.ValueSpec) bool {
+	// Don't complain about deliberate redeclarations of the form
+	//	var i, j = i, j
+	if len(d.Names) != len(d.Values) {
+		return false
+	}
+	for i, lhs := range d.Names {
+		if rhs, ok := d.Values[i].(*ast.Ident); !ok || lhs.Name != rhs.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// checkShadowDecl checks for shadowing in a general variable declaration.
+func (f *File) checkShadowDecl(d *ast.GenDecl) {
+	if d.Tok != token.VAR {
+		return
+	}
+	for _, spec := range d.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			f.BadRangef(spec, "invalid AST: var GenDecl not ValueSpec")
+			return
+		}
+		// Don't complain about deliberate redeclarations of the form
+		//	var i = i
+		if f.idiomaticRedecl(valueSpec) {
+			continue
+		}
+		for _, ident := range valueSpec.Names {
+			f.checkShadowing(ident)
+		}
+	}
+}
+
+// checkShadowing checks whether the identifier shadows a variable in an outer
+// scope of the same function that is mentioned after it is declared.
+func (f *File) checkShadowing(ident *ast.Ident) {
+	if ident.Name == "_" {
+		// Can't shadow the blank identifier.
+		return
+	}
+	obj := f.pkg.defs[ident]
+	if obj == nil {
+		return
+	}
+	shadowed, loops := f.pkg.shadowed(obj)
+	if shadowed == nil {
+		return
+	}
+	if !*strictShadowing && !f.pkg.mentionedAfter(shadowed, ident, loops) {
+		return
+	}
+	// Don't complain if the types differ: that implies the programmer really wants two variables.
+	if types.Identical(obj.Type(), shadowed.Type()) {
+		f.BadRangef(ident, "declaration of %s shadows declaration at %s", obj.Name(), f.loc(shadowed.Pos()))
+	}
+}
+
+// shadowed returns the variable that obj shadows, declared before it in
+// an outer scope of the same function, and the loops that enclose the
+// declaration of obj but not that of the variable.  Variables outside
+// the function, including those of a function enclosing a function
+// literal, are not considered.
+func (pkg *Package) shadowed(obj types.Object) (types.Object, []ast.Node) {
+	var loops []ast.Node
+	for s := obj.Parent(); s != nil; s = s.Parent() {
+		if s != obj.Parent() {
+			if outer := s.Lookup(obj.Name()); outer != nil && outer.Pos() < obj.Pos() {
+				if _, ok := outer.(*types.Var); !ok {
+					return nil, nil
+				}
+				return outer, loops
+			}
+		}
+		switch node := pkg.scopeNodes[s].(type) {
+		case nil, *ast.FuncType:
+			// The package scope, or the function's.
+			return nil, nil
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops = append(loops, node)
+		}
+	}
+	return nil, nil
+}
+
+// mentionedAfter reports whether the shadowed variable is mentioned after
+// the declaration of ident, or anywhere in one of the loops enclosing
+// that declaration, whose next iteration follows it.
+func (pkg *Package) mentionedAfter(shadowed types.Object, ident *ast.Ident, loops []ast.Node) bool {
+	for _, pos := range pkg.mentions[shadowed] {
+		if pos > ident.Pos() {
+			return true
+		}
+		for _, loop := range loops {
+			if loop.Pos() <= pos && pos < loop.End() {
+				return true
+			}
+		}
+	}
+	return false
+}
\ No newline at end of file
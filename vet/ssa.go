@@ -0,0 +1,119 @@
+// antha-tools/vet/ssa.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the pieces of the tool that use SSA code from the antha/ssa package.
+
+package vet
+
+import (
+	"github.com/antha-lang/antha/token"
+	"sort"
+
+	"github.com/antha-lang/antha-tools/antha/loader"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// An ssaReport is a problem found by a check of SSA code.
+type ssaReport struct {
+	pos, end token.Pos // end is token.NoPos if unknown
+	msg      string
+}
+
+// createSSA creates, in a single SSA program, the SSA packages of
+// those of pkgs that type-checked without error, and of all the
+// packages they import, which have no code.  The program is shared by
+// the packages, so it must be created before they are checked in
+// parallel; the code of each package is built later, by ssaPackage,
+// and the SSA builder allows the packages of a program to be built
+// concurrently.
+func createSSA(fs *token.FileSet, pkgs []*Package) {
+	prog := ssa.NewProgram(fs, 0)
+	var create func(p *types.Package)
+	create = func(p *types.Package) {
+		if prog.Package(p) != nil {
+			return
+		}
+		prog.CreatePackage(&loader.PackageInfo{Pkg: p, Importable: true})
+		for _, imp := range p.Imports() {
+			create(imp)
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg.typeErr != nil {
+			continue
+		}
+		for _, imp := range pkg.imports {
+			create(imp)
+		}
+		for _, imp := range pkg.typesPkg.Imports() {
+			create(imp)
+		}
+		pkg.ssaPkg = prog.CreatePackage(&loader.PackageInfo{
+			Pkg:   pkg.typesPkg,
+			Files: pkg.astFiles,
+			Info:  *pkg.info,
+		})
+	}
+}
+
+// ssaPackage returns the SSA code for pkg, building it on first use.
+// The imported packages, whose types were loaded from export data,
+// have no code.
+//
+// Precondition: pkg type-checked without error.
+func (pkg *Package) ssaPackage() *ssa.Package {
+	pkg.ssaPkg.Build()
+	return pkg.ssaPkg
+}
+
+// reportAll reports each of reports, in order of position.
+func (pkg *Package) reportAll(reports []ssaReport) {
+	sort.Sort(byPos(reports))
+	for _, report := range reports {
+		if f := pkg.fileFor(report.pos); f != nil {
+			f.Report(Diagnostic{
+				Pos:     f.position(report.pos),
+				End:     f.position(report.end),
+				Message: report.msg,
+			})
+		}
+	}
+}
+
+// fileFor returns the file of pkg containing pos, or nil.
+func (pkg *Package) fileFor(pos token.Pos) *File {
+	name := pkg.fset.Position(pos).Filename
+	for _, f := range pkg.files {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type byPos []ssaReport
+
+func (p byPos) Len() int           { return len(p) }
+func (p byPos) Less(i, j int) bool { return p[i].pos < p[j].pos }
+func (p byPos) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
\ No newline at end of file
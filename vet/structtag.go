@@ -0,0 +1,166 @@
+// antha-tools/vet/structtag.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the test for canonical struct tags.
+
+package vet
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "structtags",
+		Doc:   "check that struct field tags have canonical format",
+		Nodes: []ast.Node{field},
+		Run:   checkCanonicalFieldTag,
+	})
+}
+
+// checkCanonicalFieldTag checks a struct field tag.
+func checkCanonicalFieldTag(f *File, node ast.Node) {
+	field := node.(*ast.Field)
+	if field.Tag == nil {
+		return
+	}
+
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		f.BadRangef(field, "unable to read struct tag %s", field.Tag.Value)
+		return
+	}
+
+	// Check tag for validity by appending
+	// new key:value to end and checking that
+	// the tag parsing code can find it.
+	if reflect.StructTag(tag+` _gofix:"_magic"`).Get("_gofix") != "_magic" {
+		d := Diagnostic{
+			Pos:     f.fset.Position(field.Pos()),
+			End:     f.fset.Position(field.End()),
+			Message: fmt.Sprintf("struct field tag %s not compatible with reflect.StructTag.Get", field.Tag.Value),
+		}
+		if canon, ok := canonicalTag(tag); ok {
+			d.Fix = &SuggestedFix{
+				Message: "canonicalise struct tag",
+				Edits:   []TextEdit{f.Edit(field.Tag.Pos(), field.Tag.End(), quoteTag(canon))},
+			}
+		}
+		f.Report(d)
+		return
+	}
+}
+
+// canonicalTag rewrites a loosely written tag, such as
+//	json: "name", xml:name
+// in the canonical form
+//	json:"name" xml:"name"
+// It reports false if it cannot tell what the tag means.
+func canonicalTag(tag string) (string, bool) {
+	type pair struct{ key, value string }
+	var pairs []pair
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' }
+	i := 0
+	skip := func(sep bool) {
+		for i < len(tag) && (isSpace(tag[i]) || sep && tag[i] == ',') {
+			i++
+		}
+	}
+	for skip(true); i < len(tag); skip(true) {
+		j := i
+		for i < len(tag) && !isSpace(tag[i]) && tag[i] != ':' && tag[i] != '"' && tag[i] != '\'' {
+			i++
+		}
+		key := tag[j:i]
+		skip(false)
+		if key == "" || i == len(tag) || tag[i] != ':' {
+			return "", false
+		}
+		i++
+		skip(false)
+		var value string
+		switch {
+		case i == len(tag):
+			return "", false
+		case tag[i] == '"':
+			j = i
+			for i++; i < len(tag) && tag[i] != '"'; i++ {
+				if tag[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(tag) {
+				return "", false
+			}
+			i++
+			v, err := strconv.Unquote(tag[j:i])
+			if err != nil {
+				return "", false
+			}
+			value = v
+		case tag[i] == '\'':
+			j = i + 1
+			for i++; i < len(tag) && tag[i] != '\''; i++ {
+			}
+			if i == len(tag) {
+				return "", false
+			}
+			value = tag[j:i]
+			i++
+		default:
+			j = i
+			for i < len(tag) && !isSpace(tag[i]) {
+				i++
+			}
+			value = tag[j:i]
+		}
+		pairs = append(pairs, pair{key, value})
+	}
+	if len(pairs) == 0 {
+		return "", false
+	}
+	var canon []string
+	for _, p := range pairs {
+		canon = append(canon, p.key+":"+strconv.Quote(p.value))
+	}
+	res := strings.Join(canon, " ")
+	st := reflect.StructTag(res)
+	for _, p := range pairs {
+		if st.Get(p.key) != p.value {
+			return "", false
+		}
+	}
+	return res, true
+}
+
+// quoteTag returns tag as a string literal, raw if possible.
+func quoteTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
\ No newline at end of file
@@ -0,0 +1,218 @@
+// antha-tools/vet/suppress.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the code to suppress problems that are known and
+accepted: those marked by a vet:ignore comment, and those recorded in
+a baseline file.
+
+A comment of the form
+
+	//vet:ignore check[,check...] reason
+
+on the line before a statement, declaration, specification or struct
+field, or at the end of its first line, suppresses the problems that
+the named checks report on the lines it spans.
+
+A baseline file lists problems, one per line, as the name of the file,
+the name of the check and the message, separated by tabs.  Line
+numbers are omitted so that the baseline survives unrelated edits; a
+problem is suppressed if it matches an entry not already used by
+another problem.
+*/
+
+package vet
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+var baselineFlag = flag.String("baseline", "", "suppress the problems recorded in the named baseline file")
+var writeBaselineFlag = flag.String("writebaseline", "", "record the problems found in the named baseline file instead of reporting them")
+
+const ignoreDirective = "//vet:ignore "
+
+// An ignore records that the problems reported by the named checks on
+// lines first through last of a file are to be suppressed.
+type ignore struct {
+	checks      []string
+	first, last int
+}
+
+// hasIgnores reports whether src may contain vet:ignore comments,
+// which must then be parsed.
+func hasIgnores(src []byte) bool {
+	return bytes.Contains(src, []byte(ignoreDirective))
+}
+
+// findIgnores returns the ignores of file, whose content is src.
+func findIgnores(fset *token.FileSet, file *ast.File, src []byte) []ignore {
+	if !hasIgnores(src) {
+		return nil
+	}
+	// Map each line of a directive to its checks.  The directives of
+	// a comment group that stand alone on their lines also apply to
+	// the line that follows the group; a trailing directive applies
+	// only to its own line.
+	directives := make(map[int][]string)
+	for _, group := range file.Comments {
+		var checks []string
+		for _, c := range group.List {
+			if !strings.HasPrefix(c.Text, ignoreDirective) {
+				continue
+			}
+			fields := strings.Fields(c.Text[len(ignoreDirective):])
+			if len(fields) == 0 {
+				continue
+			}
+			names := strings.Split(fields[0], ",")
+			pos := fset.Position(c.Pos())
+			directives[pos.Line] = append(directives[pos.Line], names...)
+			if alone(src, pos) {
+				checks = append(checks, names...)
+			}
+		}
+		if checks != nil {
+			next := fset.Position(group.End()).Line + 1
+			directives[next] = append(directives[next], checks...)
+		}
+	}
+
+	var ignores []ignore
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n.(type) {
+		case ast.Stmt, ast.Decl, ast.Spec, *ast.Field:
+			first := fset.Position(n.Pos()).Line
+			if checks := directives[first]; checks != nil {
+				ignores = append(ignores, ignore{checks, first, fset.Position(n.End()).Line})
+			}
+		}
+		return true
+	})
+	return ignores
+}
+
+// alone reports whether only white space precedes pos on its line.
+func alone(src []byte, pos token.Position) bool {
+	start := pos.Offset - (pos.Column - 1)
+	return start >= 0 && len(bytes.TrimSpace(src[start:pos.Offset])) == 0
+}
+
+// ignored reports whether d is suppressed by a vet:ignore comment in f.
+func (f *File) ignored(d *Diagnostic) bool {
+	for _, ig := range f.ignores {
+		if d.Pos.Line < ig.first || d.Pos.Line > ig.last {
+			continue
+		}
+		for _, check := range ig.checks {
+			if check == d.Check {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// baseline maps each entry of the baseline file to the number of
+// problems it may yet suppress.
+var baseline map[string]int
+
+// newBaseline holds the entries to be written by -writebaseline.
+var newBaseline []string
+
+// baselineEntry returns the baseline entry that matches d.
+func baselineEntry(d *Diagnostic) string {
+	return d.Pos.Filename + "\t" + d.Check + "\t" + d.Message
+}
+
+// readBaseline reads the baseline file named by -baseline, if any.
+// Blank lines and lines starting with '#' are ignored.
+func readBaseline() {
+	if *baselineFlag == "" {
+		return
+	}
+	f, err := os.Open(*baselineFlag)
+	if err != nil {
+		errorf("%s", err)
+	}
+	defer f.Close()
+	baseline = make(map[string]int)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.Count(line, "\t") < 2 {
+			errorf("%s: malformed baseline entry %q", *baselineFlag, line)
+		}
+		baseline[line]++
+	}
+	if err := s.Err(); err != nil {
+		errorf("%s: %s", *baselineFlag, err)
+	}
+}
+
+// suppressed reports whether d, reported in f, is to be suppressed by
+// a vet:ignore comment or the baseline.  With -writebaseline, every
+// problem not ignored is recorded, and suppressed.
+func (f *File) suppressed(d *Diagnostic) bool {
+	if f.ignored(d) {
+		return true
+	}
+	entry := baselineEntry(d)
+	if *writeBaselineFlag != "" {
+		newBaseline = append(newBaseline, entry)
+		return true
+	}
+	if baseline[entry] > 0 {
+		baseline[entry]--
+		return true
+	}
+	return false
+}
+
+// writeBaseline writes the file named by -writebaseline, if any.
+func writeBaseline() {
+	if *writeBaselineFlag == "" {
+		return
+	}
+	sort.Strings(newBaseline)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# vet baseline: file, check and message of each accepted problem\n")
+	for _, entry := range newBaseline {
+		fmt.Fprintf(&b, "%s\n", entry)
+	}
+	if err := ioutil.WriteFile(*writeBaselineFlag, b.Bytes(), 0666); err != nil {
+		errorf("%s", err)
+	}
+}
\ No newline at end of file
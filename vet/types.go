@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/types.go: Part of the Antha language
+// antha-tools/vet/types.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,40 +23,57 @@
 
 // This file contains the pieces of the tool that use typechecking from the antha/types package.
 
-package main
+package vet
 
 import (
+	"flag"
 	"github.com/antha-lang/antha/ast"
 	"github.com/antha-lang/antha/token"
 
+	"github.com/antha-lang/antha-tools/antha/loader"
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
-func (pkg *Package) check(fs *token.FileSet, astFiles []*ast.File) error {
-	pkg.defs = make(map[*ast.Ident]types.Object)
-	pkg.uses = make(map[*ast.Ident]types.Object)
-	pkg.spans = make(map[types.Object]Span)
-	pkg.types = make(map[ast.Expr]types.TypeAndValue)
-	// By providing a Config with our own error function, it will continue
-	// past the first error. There is no need for that function to do anything.
-	config := types.Config{
-		Error: func(error) {},
+var source = flag.Bool("source", false, "load imported packages from source rather than from compiled export data")
+
+// checkTypes type-checks pkgs, whose files were parsed using fs,
+// together: their imports are loaded only once, from source if
+// -source is set, and otherwise from export data.
+func checkTypes(fs *token.FileSet, pkgs []*Package) {
+	conf := loader.Config{
+		Fset:            fs,
+		SourceImports:   *source,
+		AllowTypeErrors: true,
+		// By providing a Config with our own error function, it will continue
+		// past the first error. There is no need for that function to do anything.
+		TypeChecker: types.Config{Error: func(error) {}},
 	}
-	info := &types.Info{
-		Types: pkg.types,
-		Defs:  pkg.defs,
-		Uses:  pkg.uses,
+	initial := make(map[string]bool)
+	for _, pkg := range pkgs {
+		conf.CreateFromFiles(pkg.path, pkg.astFiles...)
+		initial[pkg.path] = true
 	}
-	typesPkg, err := config.Check(pkg.path, fs, astFiles, info)
-	pkg.typesPkg = typesPkg
-	// update spans
-	for id, obj := range pkg.defs {
-		pkg.growSpan(id, obj)
+	if *source {
+		// Imported packages need only their declarations.
+		conf.TypeCheckFuncBodies = func(path string) bool { return initial[path] }
 	}
-	for id, obj := range pkg.uses {
-		pkg.growSpan(id, obj)
+	prog, err := conf.Load()
+	if err != nil {
+		// Not reached: Load fails only for want of packages or
+		// for type errors, which are allowed.
+		errorf("%s", err)
+	}
+	for i, pkg := range pkgs {
+		info := prog.Created[i]
+		pkg.info = &info.Info
+		pkg.defs = info.Defs
+		pkg.uses = info.Uses
+		pkg.types = info.Types
+		pkg.typesPkg = info.Pkg
+		pkg.imports = prog.ImportMap
+		pkg.typeErr = info.TypeError
+		pkg.findMentions()
 	}
-	return err
 }
 
 // isStruct reports whether the composite literal c is a struct.
@@ -1,4 +1,4 @@
-// antha-tools/cmd/vet/unsafeptr.go: Part of the Antha language
+// antha-tools/vet/unsafeptr.go: Part of the Antha language
 // Copyright (C) 2014 The Antha authors. All rights reserved.
 // 
 // This program is free software; you can redistribute it and/or
@@ -23,7 +23,7 @@
 
 // Check for invalid uintptr -> unsafe.Pointer conversions.
 
-package main
+package vet
 
 import (
 	"github.com/antha-lang/antha/ast"
@@ -32,15 +32,22 @@ import (
 	"github.com/antha-lang/antha-tools/antha/types"
 )
 
-func (f *File) checkUnsafePointer(x *ast.CallExpr) {
-	if !vet("unsafeptr") {
-		return
-	}
+func init() {
+	Register(&Checker{
+		Name:  "unsafeptr",
+		Doc:   "check for misuse of unsafe.Pointer",
+		Nodes: []ast.Node{callExpr},
+		Run:   checkUnsafePointer,
+	})
+}
+
+func checkUnsafePointer(f *File, node ast.Node) {
+	x := node.(*ast.CallExpr)
 	if len(x.Args) != 1 {
 		return
 	}
 	if f.hasBasicType(x.Fun, types.UnsafePointer) && f.hasBasicType(x.Args[0], types.Uintptr) && !f.isSafeUintptr(x.Args[0]) {
-		f.Badf(x.Pos(), "possible misuse of unsafe.Pointer")
+		f.BadRangef(x, "possible misuse of unsafe.Pointer")
 	}
 }
 
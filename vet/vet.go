@@ -0,0 +1,730 @@
+// antha-tools/vet/vet.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// Package vet implements the vet command, a simple checker for static
+// errors in Go source code; see antha-tools/cmd/vet for its usage.
+//
+// Each check is described by a Checker, and registers itself by
+// calling Register from an init function.  A custom vet binary may
+// add checks of its own, for example those of a particular lab, by
+// importing the packages that register them:
+//
+//	package main
+//
+//	import (
+//		"github.com/antha-lang/antha-tools/vet"
+//		_ "example.org/lab/vetchecks"
+//	)
+//
+//	func main() { vet.Main() }
+//
+package vet
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"github.com/antha-lang/antha/build"
+	"github.com/antha-lang/antha/parser"
+	"github.com/antha-lang/antha/printer"
+	"github.com/antha-lang/antha/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// TODO: Need a flag to set build tags when parsing the package.
+
+var verbose = flag.Bool("v", false, "verbose")
+var strictShadowing = flag.Bool("shadowstrict", false, "whether to be strict about shadowing; can be noisy")
+var testFlag = flag.Bool("test", false, "for testing only: sets -all and -shadow")
+var parallel = flag.Int("p", 1, "number of packages to check in parallel")
+var exitCode = 0
+var exitMu sync.Mutex // guards exitCode
+
+// "all" is here only for the appearance of backwards compatibility.
+// It has no effect; the triState flags do the work.
+var all = flag.Bool("all", true, "check everything; disabled if any explicit check is requested")
+
+// A Checker describes a check performed by vet.
+//
+// A check may examine syntax nodes of particular types, using Nodes
+// and Run; the raw content of each file, using RunFile; or the whole
+// package, using RunPackage, which has access to its type information
+// and SSA form.  Problems are reported using File.Report, or its
+// shorthands such as File.Badf.
+//
+type Checker struct {
+	Name string // name of the check and its flag, e.g. "printf"
+	Doc  string // one-line description, the usage of its flag
+
+	// Experimental checks must be requested explicitly;
+	// they are not enabled by -all.
+	Experimental bool
+
+	// Nodes lists the types of syntax node of interest to Run,
+	// each represented by a nil pointer of that type, for
+	// example (*ast.CallExpr)(nil).
+	Nodes []ast.Node
+
+	// Run, if non-nil, is called for each node of a type listed
+	// in Nodes, during a depth-first walk of each Go file.
+	Run func(f *File, node ast.Node)
+
+	// RunFile, if non-nil, is called for each file of the
+	// package, including assembly files, before it is parsed.
+	RunFile func(f *File)
+
+	// RunPackage, if non-nil, is called for each package after
+	// its files have been walked.
+	RunPackage func(pkg *Package)
+}
+
+// Nil pointers of the types of syntax node examined by the checks,
+// for use in Checker.Nodes.
+var (
+	assignStmt    *ast.AssignStmt
+	binaryExpr    *ast.BinaryExpr
+	callExpr      *ast.CallExpr
+	compositeLit  *ast.CompositeLit
+	exprStmt      *ast.ExprStmt
+	field         *ast.Field
+	forStmt       *ast.ForStmt
+	funcDecl      *ast.FuncDecl
+	funcLit       *ast.FuncLit
+	genDecl       *ast.GenDecl
+	goStmt        *ast.GoStmt
+	interfaceType *ast.InterfaceType
+	rangeStmt     *ast.RangeStmt
+)
+
+// checkers holds the registered checks, in order of registration.
+var checkers []*Checker
+
+// Flags to control which individual checks to perform.
+var report = make(map[string]*triState)
+
+// Register adds c to the checks performed by vet, and defines the
+// flag that enables it.  It must be called before Main, typically
+// from an init function, and panics if a check of the same name is
+// already registered.
+func Register(c *Checker) {
+	if c.Name == "" {
+		panic("vet.Register: check has no name")
+	}
+	if report[c.Name] != nil {
+		panic("vet.Register: duplicate check " + c.Name)
+	}
+	report[c.Name] = triStateFlag(c.Name, unset, c.Doc)
+	checkers = append(checkers, c)
+}
+
+// setTrueCount record how many flags are explicitly set to true.
+var setTrueCount int
+
+// A triState is a boolean that knows whether it has been set to either true or false.
+// It is used to identify if a flag appears; the standard boolean flag cannot
+// distinguish missing from unset. It also satisfies flag.Value.
+type triState int
+
+const (
+	unset triState = iota
+	setTrue
+	setFalse
+)
+
+func triStateFlag(name string, value triState, usage string) *triState {
+	flag.Var(&value, name, usage)
+	return &value
+}
+
+// triState implements flag.Value, flag.Getter, and flag.boolFlag.
+// They work like boolean flags: we can say vet -printf as well as vet -printf=true
+func (ts *triState) Get() interface{} {
+	return *ts == setTrue
+}
+
+func (ts triState) isTrue() bool {
+	return ts == setTrue
+}
+
+func (ts *triState) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if b {
+		*ts = setTrue
+		setTrueCount++
+	} else {
+		*ts = setFalse
+	}
+	return nil
+}
+
+func (ts *triState) String() string {
+	switch *ts {
+	case unset:
+		return "unset"
+	case setTrue:
+		return "true"
+	case setFalse:
+		return "false"
+	}
+	panic("not reached")
+}
+
+func (ts triState) IsBoolFlag() bool {
+	return true
+}
+
+// vet tells whether to report errors for the named check, a flag name.
+func vet(name string) bool {
+	if *testFlag {
+		return true
+	}
+	return report[name].isTrue()
+}
+
+// enabled holds the checks to run, in order of registration, and
+// nodeCheckers those of them that examine each type of syntax node.
+var (
+	enabled      []*Checker
+	nodeCheckers = make(map[reflect.Type][]*Checker)
+)
+
+// selectCheckers populates enabled and nodeCheckers according to the flags.
+func selectCheckers() {
+	for _, c := range checkers {
+		if !vet(c.Name) {
+			continue
+		}
+		enabled = append(enabled, c)
+		if c.Run != nil {
+			for _, n := range c.Nodes {
+				t := reflect.TypeOf(n)
+				nodeCheckers[t] = append(nodeCheckers[t], c)
+			}
+		}
+	}
+}
+
+// setExit sets the value for os.Exit when it is called, later.  It
+// remembers the highest value.
+func setExit(err int) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	if err > exitCode {
+		exitCode = err
+	}
+}
+
+// Usage is a replacement usage function for the flags package.
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\tvet [flags] directory...\n")
+	fmt.Fprintf(os.Stderr, "\tvet [flags] files... # Must be a single package\n")
+	fmt.Fprintf(os.Stderr, "For more information run\n")
+	fmt.Fprintf(os.Stderr, "\tgodoc antha-tools/cmd/vet\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// File is a wrapper for the state of a file used in the parser.
+// The parse tree walkers are all methods of this type.
+type File struct {
+	pkg     *Package
+	fset    *token.FileSet
+	name    string
+	content []byte
+	file    *ast.File
+	ignores []ignore
+	check   string       // name of the running check
+	b       bytes.Buffer // for use by methods
+
+	// The last "String() string" method receiver we saw while walking.
+	// This is used by the recursiveStringer method in print.go.
+	lastStringerReceiver *ast.Object
+}
+
+// Name returns the name of the file.
+func (f *File) Name() string { return f.name }
+
+// Content returns the contents of the file.
+func (f *File) Content() []byte { return f.content }
+
+// AST returns the syntax tree of the file, or nil if it is not a Go
+// file or has not yet been parsed.
+func (f *File) AST() *ast.File { return f.file }
+
+// Fset returns the file set of the file's package.
+func (f *File) Fset() *token.FileSet { return f.fset }
+
+// Package returns the package of the file.
+func (f *File) Package() *Package { return f.pkg }
+
+// Main runs the vet command, with the registered checks, on the
+// files or directories named by the command-line arguments.
+func Main() {
+	flag.Usage = Usage
+	flag.Parse()
+
+	// If any flag is set, we run only those checks requested.
+	// If no flags are set true, set all the non-experimental ones not explicitly set (in effect, set the "-all" flag).
+	if setTrueCount == 0 {
+		for _, c := range checkers {
+			if setting := report[c.Name]; *setting == unset && !c.Experimental {
+				*setting = setTrue
+			}
+		}
+	}
+
+	if *printfuncs != "" {
+		for _, name := range strings.Split(*printfuncs, ",") {
+			if len(name) == 0 {
+				flag.Usage()
+			}
+			skip := 0
+			if colon := strings.LastIndex(name, ":"); colon > 0 {
+				var err error
+				skip, err = strconv.Atoi(name[colon+1:])
+				if err != nil {
+					errorf(`illegal format for "Func:N" argument %q; %s`, name, err)
+				}
+				name = name[:colon]
+			}
+			name = strings.ToLower(name)
+			if name[len(name)-1] == 'f' {
+				printfList[name] = skip
+			} else {
+				printList[name] = skip
+			}
+		}
+	}
+
+	if *errfuncs != "" {
+		for _, name := range strings.Split(*errfuncs, ",") {
+			if len(name) == 0 {
+				flag.Usage()
+			}
+			errAllowed[name] = true
+		}
+	}
+
+	selectCheckers()
+	readBaseline()
+
+	if flag.NArg() == 0 {
+		Usage()
+	}
+	var srcs []pkgFiles
+	dirs := false
+	files := false
+	for _, name := range flag.Args() {
+		// Is it a directory, or a pattern or import path denoting one?
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			files = true
+			continue
+		}
+		dirs = true
+		dir, recursive, err := findDir(name)
+		if err != nil {
+			warnf("error walking tree: %s", err)
+			continue
+		}
+		srcs = append(srcs, packagesInTree(dir, recursive)...)
+	}
+	if dirs && files {
+		Usage()
+	}
+	if files {
+		srcs = []pkgFiles{{".", flag.Args()}}
+	}
+	if doPackages(srcs) == 0 && files {
+		warnf("no files checked")
+	}
+	applyFixes()
+	writeDiagnostics()
+	writeBaseline()
+	os.Exit(exitCode)
+}
+
+// A pkgFiles lists the files of a package to check.
+type pkgFiles struct {
+	dir   string
+	names []string
+}
+
+// findDir returns the directory denoted by name, a directory or an
+// import path, and whether its subdirectories are to be checked too.
+// They are if name is a directory, or ends in "/...".
+func findDir(name string) (dir string, recursive bool, err error) {
+	if strings.HasSuffix(name, "/...") {
+		name = strings.TrimSuffix(name, "/...")
+		recursive = true
+	}
+	fi, err := os.Stat(name)
+	if err == nil && fi.IsDir() {
+		return name, true, nil
+	}
+	bp, err := build.Import(name, ".", build.FindOnly)
+	if err != nil {
+		return "", false, err
+	}
+	return bp.Dir, recursive, nil
+}
+
+// packagesInTree returns the packages in the directory root and, if
+// recursive, in its subdirectories, except those named testdata or
+// starting with "." or "_", which the antha tool also ignores.
+func packagesInTree(root string, recursive bool) []pkgFiles {
+	if !recursive {
+		return packagesInDir(root)
+	}
+	var srcs []pkgFiles
+	walk := func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// One package per directory. Ignore the files themselves.
+		if !f.IsDir() {
+			return nil
+		}
+		if name := f.Name(); path != root && (name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		srcs = append(srcs, packagesInDir(path)...)
+		return nil
+	}
+	if err := filepath.Walk(root, walk); err != nil {
+		warnf("walk error: %s", err)
+	}
+	return srcs
+}
+
+// prefixDirectory places the directory name on the beginning of each name in the list.
+func prefixDirectory(directory string, names []string) {
+	if directory != "." {
+		for i, name := range names {
+			names[i] = filepath.Join(directory, name)
+		}
+	}
+}
+
+// packagesInDir returns the single package found in the directory, if there is one,
+// plus a test package, if there is one.
+func packagesInDir(directory string) []pkgFiles {
+	pkg, err := build.Default.ImportDir(directory, 0)
+	if err != nil {
+		// If it's just that there are no antha source files, that's fine.
+		if _, nogo := err.(*build.NoGoError); nogo {
+			return nil
+		}
+		// Non-fatal: we are doing a recursive walk and there may be other directories.
+		warnf("cannot process directory %s: %s", directory, err)
+		return nil
+	}
+	var names []string
+	names = append(names, pkg.GoFiles...)
+	names = append(names, pkg.CgoFiles...)
+	names = append(names, pkg.TestGoFiles...) // These are also in the "foo" package.
+	names = append(names, pkg.SFiles...)
+	prefixDirectory(directory, names)
+	srcs := []pkgFiles{{directory, names}}
+	// Is there also a "foo_test" package? If so, do that one as well.
+	if len(pkg.XTestGoFiles) > 0 {
+		names = pkg.XTestGoFiles
+		prefixDirectory(directory, names)
+		srcs = append(srcs, pkgFiles{directory, names})
+	}
+	return srcs
+}
+
+// A Package holds the files of a package being checked and the
+// results of type-checking them.
+type Package struct {
+	path     string
+	defs     map[*ast.Ident]types.Object
+	uses     map[*ast.Ident]types.Object
+	types    map[ast.Expr]types.TypeAndValue
+	files    []*File
+	typesPkg *types.Package
+
+	// Complete type information, for building SSA code.
+	fset     *token.FileSet
+	astFiles []*ast.File
+	info     *types.Info
+	imports  map[string]*types.Package // all packages loaded by the type checker
+	typeErr  error
+	ssaPkg   *ssa.Package // created by createSSA, built on demand by ssaPackage
+
+	// For the shadow check.
+	mentions   map[types.Object][]token.Pos
+	scopeNodes map[*types.Scope]ast.Node
+}
+
+// Path returns the name of the package.
+func (pkg *Package) Path() string { return pkg.path }
+
+// Files returns the files of the package, including assembly files.
+func (pkg *Package) Files() []*File { return pkg.files }
+
+// Types returns the type-checked package.
+func (pkg *Package) Types() *types.Package { return pkg.typesPkg }
+
+// Info returns the type information for the package's syntax trees.
+// It is incomplete if type checking failed.
+func (pkg *Package) Info() *types.Info { return pkg.info }
+
+// TypeErr returns the first error, if any, from type checking.
+func (pkg *Package) TypeErr() error { return pkg.typeErr }
+
+// SSA returns the SSA form of the package, building it on first use.
+// The imported packages have no code.
+//
+// Precondition: TypeErr() == nil.
+func (pkg *Package) SSA() *ssa.Package { return pkg.ssaPackage() }
+
+// doPackage analyzes the single package constructed from the named files.
+// It returns whether any files were checked.
+func doPackage(directory string, names []string) bool {
+	return doPackages([]pkgFiles{{directory, names}}) > 0
+}
+
+// doPackages analyzes the packages constructed from the named files,
+// type-checking them together so that their dependencies are loaded
+// only once, and then checking -p of them at a time.  It returns the
+// number of packages checked.
+//
+// The packages share their type information and SSA program, which are
+// created before the checks start; each goroutine then builds the SSA
+// code of only the package it checks, and reports its diagnostics
+// under reportMu.
+func doPackages(srcs []pkgFiles) int {
+	fs := token.NewFileSet()
+	var pkgs []*Package
+	for _, src := range srcs {
+		if pkg := parsePackage(fs, src.names); pkg != nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	checkTypes(fs, pkgs)
+	createSSA(fs, pkgs)
+
+	if *parallel <= 1 {
+		for _, pkg := range pkgs {
+			pkg.run()
+		}
+		return len(pkgs)
+	}
+	work := make(chan *Package)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range work {
+				pkg.run()
+			}
+		}()
+	}
+	for _, pkg := range pkgs {
+		work <- pkg
+	}
+	close(work)
+	wg.Wait()
+	return len(pkgs)
+}
+
+// parsePackage reads and parses the named files of a package, running
+// the checks of their content.  It returns nil if a file cannot be
+// read or parsed, or there are no Go files.
+func parsePackage(fs *token.FileSet, names []string) *Package {
+	var files []*File
+	var astFiles []*ast.File
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			// Warn but continue to next package.
+			warnf("%s", err)
+			return nil
+		}
+		file := &File{fset: fs, content: data, name: name}
+		for _, c := range enabled {
+			if c.RunFile != nil {
+				file.check = c.Name
+				c.RunFile(file)
+			}
+		}
+		if strings.HasSuffix(name, ".go") {
+			var mode parser.Mode
+			if hasIgnores(data) || hasGuards(data) {
+				mode = parser.ParseComments
+			}
+			file.file, err = parser.ParseFile(fs, name, bytes.NewReader(data), mode)
+			if err != nil {
+				warnf("%s: %s", name, err)
+				return nil
+			}
+			file.ignores = findIgnores(fs, file.file, data)
+			astFiles = append(astFiles, file.file)
+		}
+		files = append(files, file)
+	}
+	if len(astFiles) == 0 {
+		return nil
+	}
+	pkg := &Package{
+		path:     astFiles[0].Name.Name,
+		files:    files,
+		fset:     fs,
+		astFiles: astFiles,
+	}
+	for _, file := range files {
+		file.pkg = pkg
+	}
+	return pkg
+}
+
+// run runs the enabled checks on the type-checked package.
+func (pkg *Package) run() {
+	if pkg.typeErr != nil && *verbose {
+		warnf("%s", pkg.typeErr)
+	}
+	for _, file := range pkg.files {
+		if file.file != nil {
+			file.walkFile(file.name, file.file)
+		}
+	}
+	for _, c := range enabled {
+		if c.RunPackage != nil {
+			for _, file := range pkg.files {
+				file.check = c.Name
+			}
+			c.RunPackage(pkg)
+		}
+	}
+}
+
+func (pkg *Package) hasFileWithSuffix(suffix string) bool {
+	for _, f := range pkg.files {
+		if strings.HasSuffix(f.name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorf formats the error to standard error, adding program
+// identification and a newline, and exits.
+func errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vet: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+// warnf formats the error to standard error, adding program
+// identification and a newline, but does not exit.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vet: "+format+"\n", args...)
+	setExit(1)
+}
+
+// Println is fmt.Println guarded by -v.
+func Println(args ...interface{}) {
+	if !*verbose {
+		return
+	}
+	fmt.Println(args...)
+}
+
+// Printf is fmt.Printf guarded by -v.
+func Printf(format string, args ...interface{}) {
+	if !*verbose {
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
+
+// Bad reports an error and sets the exit code..
+func (f *File) Bad(pos token.Pos, args ...interface{}) {
+	f.report(Error, pos, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Badf reports a formatted error and sets the exit code.
+func (f *File) Badf(pos token.Pos, format string, args ...interface{}) {
+	f.report(Error, pos, fmt.Sprintf(format, args...))
+}
+
+// loc returns a formatted representation of the position.
+func (f *File) loc(pos token.Pos) string {
+	return posString(f.position(pos))
+}
+
+// Warn reports an error but does not set the exit code.
+func (f *File) Warn(pos token.Pos, args ...interface{}) {
+	f.report(Warning, pos, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Warnf reports a formatted error but does not set the exit code.
+func (f *File) Warnf(pos token.Pos, format string, args ...interface{}) {
+	f.report(Warning, pos, fmt.Sprintf(format, args...))
+}
+
+// report reports msg at pos with the given severity.
+func (f *File) report(severity Severity, pos token.Pos, msg string) {
+	f.Report(Diagnostic{Severity: severity, Pos: f.position(pos), Message: msg})
+}
+
+// walkFile walks the file's tree.
+func (f *File) walkFile(name string, file *ast.File) {
+	Println("Checking file", name)
+	ast.Walk(f, file)
+}
+
+// Visit implements the ast.Visitor interface.
+func (f *File) Visit(node ast.Node) ast.Visitor {
+	for _, c := range nodeCheckers[reflect.TypeOf(node)] {
+		f.check = c.Name
+		c.Run(f, node)
+	}
+	return f
+}
+
+// gofmt returns a string representation of the expression.
+func (f *File) gofmt(x ast.Expr) string {
+	f.b.Reset()
+	printer.Fprint(&f.b, f.fset, x)
+	return f.b.String()
+}
\ No newline at end of file
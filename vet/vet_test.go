@@ -0,0 +1,405 @@
+// antha-tools/vet/vet_test.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+package vet
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antha-lang/antha/ast"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePackage writes src as the file p.go of a new temporary
+// directory, which the caller must remove, and returns their names.
+func writePackage(t *testing.T, src string) (dir, name string) {
+	dir, err := ioutil.TempDir("", "vet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name = filepath.Join(dir, "p.go")
+	writeFile(t, name, src)
+	return dir, name
+}
+
+// writeFile writes src to the named file, creating its directory.
+func writeFile(t *testing.T, name, src string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const registerInput = `package p
+
+func f() {
+	g()
+	func() { h() }()
+}
+
+func g() {}
+
+func h() {}
+`
+
+// TestRegister checks that a registered check is run on the nodes,
+// files and package it requests.
+func TestRegister(t *testing.T) {
+	dir, name := writePackage(t, registerInput)
+	defer os.RemoveAll(dir)
+
+	var calls, files []string
+	var pkgs []*Package
+	Register(&Checker{
+		Name:  "testregister",
+		Doc:   "for testing only",
+		Nodes: []ast.Node{callExpr},
+		Run: func(f *File, node ast.Node) {
+			calls = append(calls, f.gofmt(node.(*ast.CallExpr).Fun))
+		},
+		RunFile: func(f *File) {
+			if f.AST() != nil {
+				t.Errorf("RunFile: %s already parsed", f.Name())
+			}
+			files = append(files, f.Name())
+		},
+		RunPackage: func(pkg *Package) {
+			if pkg.TypeErr() != nil {
+				t.Errorf("RunPackage: %s", pkg.TypeErr())
+			}
+			pkgs = append(pkgs, pkg)
+		},
+	})
+	*report["testregister"] = setTrue
+	selectCheckers()
+	defer func() {
+		enabled = nil
+		nodeCheckers = make(map[reflect.Type][]*Checker)
+	}()
+
+	if !doPackage(dir, []string{name}) {
+		t.Fatal("no files checked")
+	}
+	if want := []string{"g", "func() { h() }", "h"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Run: got calls %q, want %q", calls, want)
+	}
+	if want := []string{name}; !reflect.DeepEqual(files, want) {
+		t.Errorf("RunFile: got %q, want %q", files, want)
+	}
+	if len(pkgs) != 1 || pkgs[0].Path() != "p" || len(pkgs[0].Files()) != 1 {
+		t.Errorf("RunPackage: got %v, want package p", pkgs)
+	}
+
+	// A second registration of the same name is an error.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("duplicate Register did not panic")
+		}
+	}()
+	Register(&Checker{Name: "testregister"})
+}
+
+// enableOnly enables the named checks alone, and the collection of
+// diagnostics, until the returned function is called.
+func enableOnly(checks ...string) func() {
+	saved := make(map[string]triState)
+	for name, setting := range report {
+		saved[name] = *setting
+		*setting = unset
+	}
+	for _, check := range checks {
+		*report[check] = setTrue
+	}
+	*jsonFlag = true
+	selectCheckers()
+	return func() {
+		for name, setting := range saved {
+			*report[name] = setting
+		}
+		*jsonFlag = false
+		enabled = nil
+		nodeCheckers = make(map[reflect.Type][]*Checker)
+		diagnostics = nil
+		fixes = make(map[string][]TextEdit)
+		baseline = nil
+		exitCode = 0
+	}
+}
+
+const diagnosticInput = `package p
+
+func f(x, y int) {
+	x = x
+	x, y = x, y
+}
+`
+
+// TestDiagnostics checks the diagnostics collected for JSON and SARIF
+// output.
+func TestDiagnostics(t *testing.T) {
+	dir, name := writePackage(t, diagnosticInput)
+	defer os.RemoveAll(dir)
+
+	defer enableOnly("assign")()
+
+	if !doPackage(dir, []string{name}) {
+		t.Fatal("no files checked")
+	}
+	if len(diagnostics) != 3 {
+		t.Fatalf("got %d diagnostics, want 3", len(diagnostics))
+	}
+	d := diagnostics[0]
+	if d.Check != "assign" || d.Severity != Error || d.Message != "self-assignment of x to x" {
+		t.Errorf("got %+v, want self-assignment error from assign", d)
+	}
+	if d.Pos.Line != 4 || d.Pos.Column != 2 || d.End.Line != 4 || d.End.Column != 7 {
+		t.Errorf("got range %d:%d-%d:%d, want 4:2-4:7", d.Pos.Line, d.Pos.Column, d.End.Line, d.End.Column)
+	}
+	if d.Fix == nil || len(d.Fix.Edits) != 1 {
+		t.Fatalf("got fix %+v, want deletion of the statement", d.Fix)
+	}
+	if e := d.Fix.Edits[0]; e.Pos.Line != 4 || e.Pos.Column != 1 || e.End.Line != 5 || e.End.Column != 1 || e.NewText != "" {
+		t.Errorf("got edit %+v, want deletion of line 4", e)
+	}
+	for _, d := range diagnostics[1:] {
+		if d.Fix != nil {
+			t.Errorf("got fix %+v for parallel assignment, want none", d.Fix)
+		}
+	}
+	if exitCode != 1 {
+		t.Errorf("got exit code %d, want 1", exitCode)
+	}
+
+	// The SARIF log names the rule and region of each result.
+	data, err := json.Marshal(sarifLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string
+				Locations []struct {
+					PhysicalLocation struct {
+						Region struct{ StartLine, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 3 {
+		t.Fatalf("got SARIF %s, want one run of three results", data)
+	}
+	r := log.Runs[0].Results[0]
+	if r.RuleID != "assign" || len(r.Locations) != 1 || r.Locations[0].PhysicalLocation.Region.StartLine != 4 || r.Locations[0].PhysicalLocation.Region.EndColumn != 7 {
+		t.Errorf("got SARIF result %+v, want assign at 4:2-4:7", r)
+	}
+
+	// An edit without a position is left out of the SARIF log.
+	diagnostics = []*Diagnostic{{
+		Check:   "assign",
+		Message: "no position",
+		Fix:     &SuggestedFix{"delete", []TextEdit{{NewText: ""}}},
+	}}
+	if fixes := sarifLog().Runs[0].Results[0].Fixes; len(fixes) != 0 {
+		t.Errorf("got SARIF fixes %+v for an edit without a position, want none", fixes)
+	}
+}
+
+// TestBaseline checks that each baseline entry suppresses one problem.
+func TestBaseline(t *testing.T) {
+	dir, name := writePackage(t, diagnosticInput)
+	defer os.RemoveAll(dir)
+	bl := filepath.Join(dir, "baseline")
+	entries := "# comment\n\n" + name + "\tassign\tself-assignment of x to x\n"
+	writeFile(t, bl, entries)
+
+	defer enableOnly("assign")()
+	*baselineFlag = bl
+	defer func() { *baselineFlag = "" }()
+	readBaseline()
+
+	if !doPackage(dir, []string{name}) {
+		t.Fatal("no files checked")
+	}
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, fmt.Sprintf("%d: %s", d.Pos.Line, d.Message))
+	}
+	if want := []string{"5: self-assignment of x to x", "5: self-assignment of y to y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+const fixInput = `package p
+
+type T struct {
+	X int ` + "`json: \"x\"`" + `
+}
+
+func f(x int, s []int) int {
+	x = x
+	for i, v := range s {
+		go func() {
+			println(i, v, i)
+		}()
+	}
+	return x
+	println(x)
+	x++
+}
+`
+
+const fixOutput = `package p
+
+type T struct {
+	X int ` + "`json:\"x\"`" + `
+}
+
+func f(x int, s []int) int {
+	for i, v := range s {
+		i := i
+		v := v
+		go func() {
+			println(i, v, i)
+		}()
+	}
+	return x
+}
+`
+
+// TestFix checks that -fix applies the suggested fixes, and does not
+// report the problems it fixes.
+func TestFix(t *testing.T) {
+	dir, name := writePackage(t, fixInput)
+	defer os.RemoveAll(dir)
+
+	defer enableOnly("assign", "rangeloops", "structtags", "unreachable")()
+	*fixFlag = true
+	defer func() { *fixFlag = false }()
+
+	if !doPackage(dir, []string{name}) {
+		t.Fatal("no files checked")
+	}
+	if len(diagnostics) != 0 || exitCode != 0 {
+		t.Errorf("got diagnostics %v and exit code %d, want none", diagnostics, exitCode)
+	}
+	applyFixes()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != fixOutput {
+		t.Errorf("got fixed file\n%s\nwant\n%s", data, fixOutput)
+	}
+}
+
+// TestPackages checks that the packages of a tree, other than those
+// under testdata, are found and checked in parallel.
+func TestPackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a/a.go", "a/b/b.go", "a/testdata/c.go", "d/d.go"} {
+		name = filepath.Join(dir, name)
+		writeFile(t, name, strings.Replace(diagnosticInput, "package p", "package "+filepath.Base(filepath.Dir(name)), 1))
+	}
+
+	defer enableOnly("assign")()
+	*parallel = 2
+	defer func() { *parallel = 1 }()
+
+	srcs := packagesInTree(filepath.Join(dir, "a"), true)
+	if len(srcs) != 2 {
+		t.Fatalf("got packages %v, want a and a/b", srcs)
+	}
+	if n := doPackages(srcs); n != 2 {
+		t.Errorf("checked %d packages, want 2", n)
+	}
+	got := make(map[string]int)
+	for _, d := range diagnostics {
+		rel, err := filepath.Rel(dir, d.Pos.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.ToSlash(rel)]++
+	}
+	if want := map[string]int{"a/a.go": 3, "a/b/b.go": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics per file %v, want %v", got, want)
+	}
+}
+
+const nilnessInput = `package p
+
+type T struct{ f int }
+
+func F(t *T) int {
+	if t == nil {
+		return t.f
+	}
+	return 0
+}
+`
+
+// TestPackagesSSA checks that the SSA code of packages checked in
+// parallel is built correctly; run it with -race.
+func TestPackagesSSA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var srcs []pkgFiles
+	for _, name := range []string{"a", "b", "c", "d"} {
+		file := filepath.Join(dir, name, name+".go")
+		writeFile(t, file, nilnessInput)
+		srcs = append(srcs, pkgFiles{filepath.Join(dir, name), []string{file}})
+	}
+
+	defer enableOnly("nilness")()
+	*parallel = 4
+	defer func() { *parallel = 1 }()
+
+	if n := doPackages(srcs); n != 4 {
+		t.Errorf("checked %d packages, want 4", n)
+	}
+	got := make(map[string]int)
+	for _, d := range diagnostics {
+		got[filepath.Base(d.Pos.Filename)]++
+	}
+	if want := map[string]int{"a.go": 1, "b.go": 1, "c.go": 1, "d.go": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics per file %v, want %v", got, want)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,145 @@
+// antha-tools/vet/volumes.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+/*
+This file contains the code to check that pipette volumes and
+concentrations are within range.  It computes the range of each
+numeric value of the package by interval analysis of its SSA form.
+*/
+
+package vet
+
+import (
+	"fmt"
+	"github.com/antha-lang/antha/token"
+	"math"
+	"strings"
+
+	"github.com/antha-lang/antha-tools/antha/exact"
+	"github.com/antha-lang/antha-tools/antha/ssa"
+	"github.com/antha-lang/antha-tools/antha/ssa/interval"
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+// quantities lists the kinds of quantity whose range is checked.  A
+// numeric parameter whose name ends with suffix, disregarding case,
+// holds such a quantity.  Its arguments must not be negative, nor
+// exceed the constant named max if the callee's package declares one.
+var quantities = []struct {
+	suffix, max string
+}{
+	{"volume", "MaxVolume"},
+	{"concentration", "MaxConcentration"},
+}
+
+func init() {
+	Register(&Checker{
+		Name:       "volumes",
+		Doc:        "check that pipette volumes and concentrations are within range",
+		RunPackage: checkVolumes,
+	})
+}
+
+func checkVolumes(pkg *Package) {
+	if pkg.typeErr != nil {
+		return
+	}
+
+	ssapkg := pkg.ssaPackage()
+	var reports []ssaReport
+	for fn, r := range interval.AnalyzePackage(ssapkg) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(ssa.CallInstruction); ok {
+					reports = append(reports, checkQuantities(call, r)...)
+				}
+			}
+		}
+	}
+
+	pkg.reportAll(reports)
+}
+
+// checkQuantities checks the quantity arguments of call, whose
+// ranges are given by r.
+func checkQuantities(call ssa.CallInstruction, r *interval.Result) []ssaReport {
+	common := call.Common()
+	sig := common.Signature()
+	params := sig.Params()
+	args := common.Args[len(common.Args)-params.Len():] // skip any receiver
+	if sig.Variadic() {
+		args = args[:len(args)-1]
+	}
+
+	var reports []ssaReport
+	for i, arg := range args {
+		param := params.At(i)
+		kind := quantityKind(param)
+		if kind < 0 {
+			continue
+		}
+		x := r.At(arg, call.Block())
+		if x.IsEmpty() {
+			continue // unreachable
+		}
+		// Report only ranges bounded by the analysis; an infinite
+		// bound merely means that nothing is known.
+		what := fmt.Sprintf("%s argument of %s", param.Name(), calleeName(common))
+		if x.Lo < 0 && !math.IsInf(x.Lo, -1) {
+			reports = append(reports, ssaReport{call.Pos(), token.NoPos, fmt.Sprintf("%s may be negative: range is %s", what, x)})
+		}
+		name := quantities[kind].max
+		if max, ok := param.Pkg().Scope().Lookup(name).(*types.Const); ok {
+			if m, _ := exact.Float64Val(max.Val()); x.Hi > m && !math.IsInf(x.Hi, +1) {
+				reports = append(reports, ssaReport{call.Pos(), token.NoPos, fmt.Sprintf("%s may exceed %s (%g): range is %s", what, name, m, x)})
+			}
+		}
+	}
+	return reports
+}
+
+// quantityKind returns the index within quantities of the kind of
+// quantity held by the parameter v, or -1 if it holds none.
+func quantityKind(v *types.Var) int {
+	if b, ok := v.Type().Underlying().(*types.Basic); !ok || b.Info()&(types.IsInteger|types.IsFloat) == 0 || v.Pkg() == nil {
+		return -1
+	}
+	name := strings.ToLower(v.Name())
+	for i, q := range quantities {
+		if strings.HasSuffix(name, q.suffix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// calleeName returns the name of the function or method called by c.
+func calleeName(c *ssa.CallCommon) string {
+	if c.IsInvoke() {
+		return c.Method.Name()
+	}
+	if fn := c.StaticCallee(); fn != nil {
+		return fn.Name()
+	}
+	return c.Value.Name()
+}
\ No newline at end of file
@@ -0,0 +1,80 @@
+// antha-tools/vet/waitgroup.go: Part of the Antha language
+// Copyright (C) 2014 The Antha authors. All rights reserved.
+// 
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+// 
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+// 
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+// 
+// For more information relating to the software or licensing issues please
+// contact license@antha-lang.org or write to the Antha team c/o 
+// Synthace Ltd. The London Bioscience Innovation Centre
+// 1 Royal College St, London NW1 0NH UK
+
+
+// This file contains the check for sync.WaitGroup.Add called by the
+// goroutine it counts.
+
+package vet
+
+import (
+	"github.com/antha-lang/antha/ast"
+
+	"github.com/antha-lang/antha-tools/antha/types"
+)
+
+func init() {
+	Register(&Checker{
+		Name:  "waitgroup",
+		Doc:   "check for sync.WaitGroup.Add called inside the goroutine it waits for",
+		Nodes: []ast.Node{goStmt},
+		Run:   checkWaitGroupAdd,
+	})
+}
+
+// checkWaitGroupAdd checks the function literal started by a go
+// statement for calls to the Add method of a sync.WaitGroup.  Such a
+// call races with the Wait it is meant to delay, which may return
+// before the goroutine has started.
+func checkWaitGroupAdd(f *File, node ast.Node) {
+	g := node.(*ast.GoStmt)
+	lit, ok := g.Call.Fun.(*ast.FuncLit)
+	if !ok {
+		return
+	}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			return false // checked separately
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if ok && sel.Sel.Name == "Add" && isNamedType(f.pkg.types[sel.X].Type, "sync", "WaitGroup") {
+				f.BadRangef(n, "sync.WaitGroup.Add called inside the goroutine it waits for; call it before the go statement")
+			}
+		}
+		return true
+	})
+}
+
+// isNamedType reports whether typ, or the type it points to, is the
+// named type path.name.
+func isNamedType(typ types.Type, path, name string) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == name && obj.Pkg() != nil && obj.Pkg().Path() == path
+}
\ No newline at end of file